@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// readAlertJSON returns the alert document from ALERT_JSON_FILE, or from
+// ALERT_JSON when no file is set, and an empty string when neither is.
+// Grouped payloads can exceed what runners allow in an environment
+// variable, so the file takes precedence. A missing or empty file is an
+// error of its own rather than a JSON parse error.
+func readAlertJSON() (string, error) {
+	path := os.Getenv("ALERT_JSON_FILE")
+	if path == "" {
+		return os.Getenv("ALERT_JSON"), nil
+	}
+
+	if os.Getenv("ALERT_JSON") != "" {
+		log.Printf("Both ALERT_JSON_FILE and ALERT_JSON are set, reading the alert from ALERT_JSON_FILE %s", path)
+	} else {
+		log.Printf("Reading the alert from ALERT_JSON_FILE %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("ALERT_JSON_FILE %s does not exist", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read ALERT_JSON_FILE: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", fmt.Errorf("ALERT_JSON_FILE %s is empty", path)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMissingAlertNameMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{mode: "", want: "derive"},
+		{mode: "derive", want: "derive"},
+		{mode: "skip", want: "skip"},
+		{mode: "fail", want: "fail"},
+		{mode: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMissingAlertNameMode(tt.mode)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMissingAlertNameMode(%q) error = %v, wantErr %t", tt.mode, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseMissingAlertNameMode(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureAlertName(t *testing.T) {
+	// An alert without an alertname label, e.g. from a non-Prometheus source
+	labels := map[string]string{"job": "node-exporter", "instance": "node-1"}
+
+	tests := []struct {
+		name       string
+		mode       string
+		fromLabels []string
+		alertName  string
+		want       string
+		wantSend   bool
+		wantErr    bool
+	}{
+		{name: "named alert is unchanged", mode: "fail", alertName: "DiskFull", want: "DiskFull", wantSend: true},
+		{name: "derive from configured label", mode: "derive", fromLabels: []string{"check", "job"}, want: "node-exporter", wantSend: true},
+		{name: "derive falls back to fingerprint", mode: "derive", fromLabels: []string{"check"}, want: "alert-", wantSend: true},
+		{name: "skip", mode: "skip", wantSend: false},
+		{name: "fail", mode: "fail", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{MissingAlertNameMode: tt.mode, AlertNameLabels: tt.fromLabels}
+
+			got, send, err := ensureAlertName(config, tt.alertName, labels)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureAlertName() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if send != tt.wantSend {
+				t.Errorf("ensureAlertName() send = %t, want %t", send, tt.wantSend)
+			}
+			if !strings.HasPrefix(got, tt.want) || (tt.want == "" && got != "") {
+				t.Errorf("ensureAlertName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeriveAlertNameFingerprintIsStable(t *testing.T) {
+	a := deriveAlertName(map[string]string{"job": "api", "instance": "node-1"}, nil)
+	b := deriveAlertName(map[string]string{"instance": "node-1", "job": "api"}, nil)
+	c := deriveAlertName(map[string]string{"job": "api", "instance": "node-2"}, nil)
+
+	if a != b {
+		t.Errorf("fingerprint name depends on label order: %q != %q", a, b)
+	}
+	if a == c {
+		t.Errorf("different label sets produced the same name %q", a)
+	}
+	if len(a) != len("alert-")+16 {
+		t.Errorf("unexpected fingerprint name format %q", a)
+	}
+}
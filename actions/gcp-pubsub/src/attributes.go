@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Pub/Sub limits on message attributes
+const (
+	maxAttributes          = 100
+	maxAttributeKeyBytes   = 256
+	maxAttributeValueBytes = 1024
+)
+
+// isBuiltinAttribute reports whether name is one of builtinAttributes
+func isBuiltinAttribute(name string) bool {
+	return slices.Contains(builtinAttributes, name)
+}
+
+// parseAttributesMap parses ATTRIBUTES_MAP_JSON, a JSON object of attribute
+// names to the message fields they are copied from, e.g.
+// {"team": "labels.team", "runbook": "annotations.runbook_url"}
+func parseAttributesMap(spec string) (map[string]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(spec), &attributes); err != nil {
+		return nil, fmt.Errorf("invalid ATTRIBUTES_MAP_JSON: %w", err)
+	}
+	for name, field := range attributes {
+		switch {
+		case name == "" || len(name) > maxAttributeKeyBytes:
+			return nil, fmt.Errorf("invalid ATTRIBUTES_MAP_JSON: attribute names must be 1 to %d bytes, got '%s'", maxAttributeKeyBytes, name)
+		case strings.HasPrefix(name, "goog"):
+			return nil, fmt.Errorf("invalid ATTRIBUTES_MAP_JSON: attribute '%s' uses the reserved 'goog' prefix", name)
+		case isBuiltinAttribute(name):
+			return nil, fmt.Errorf("invalid ATTRIBUTES_MAP_JSON: attribute '%s' conflicts with a built-in attribute", name)
+		case !isMessageField(field):
+			return nil, fmt.Errorf("invalid ATTRIBUTES_MAP_JSON: attribute '%s' maps unknown message field '%s'", name, field)
+		}
+	}
+	return attributes, nil
+}
+
+// addLabelAttributes copies every label into the message attributes, named
+// with ATTRIBUTE_PREFIX, for ATTRIBUTES_ALL_LABELS. Labels whose attribute
+// would be a built-in or reserved one are skipped, as are those over the
+// Pub/Sub size limits, and labels beyond the attribute limit.
+func addLabelAttributes(attributes map[string]string, config *Config, labels map[string]string) {
+	addPrefixedAttributes(attributes, config.AttributePrefix, labels, "label")
+}
+
+// addPrefixedAttributes copies every entry of values into the message
+// attributes, in key order, named with the prefix. kind names the entries
+// in warnings.
+func addPrefixedAttributes(attributes map[string]string, prefix string, values map[string]string, kind string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		name, value := prefix+key, values[key]
+		switch {
+		case value == "" || isBuiltinAttribute(name) || strings.HasPrefix(name, "goog"):
+			continue
+		case len(name) > maxAttributeKeyBytes || len(value) > maxAttributeValueBytes:
+			log.Printf("Warning: The %s %s exceeds the Pub/Sub attribute size limits, not copied to the attributes", kind, key)
+			continue
+		case len(attributes) >= maxAttributes:
+			log.Printf("Warning: Message has %d attributes, the Pub/Sub limit; the %s %s and later ones are not copied", maxAttributes, kind, key)
+			return
+		}
+		attributes[name] = value
+	}
+}
+
+// annotationAttributePrefix names the attributes annotations are copied to
+// with FORMAT=attributes
+const annotationAttributePrefix = "annotation_"
+
+// addAlertAttributes carries the alert in the attributes for
+// FORMAT=attributes, which publishes no data: the message fields that are
+// not built-in attributes, and every annotation prefixed with annotation_.
+// The labels follow with ATTRIBUTES_ALL_LABELS, which the format turns on.
+// Fields over the Pub/Sub size limit are truncated and the message marked
+// truncated.
+func addAlertAttributes(attributes map[string]string, message *PubSubMessage) {
+	fields := map[string]string{
+		"instance":    message.Instance,
+		"summary":     message.Summary,
+		"description": message.Description,
+		"startsAt":    message.StartsAt,
+		"endsAt":      message.EndsAt,
+		"fingerprint": message.Fingerprint,
+	}
+	for name, value := range fields {
+		if len(value) > maxAttributeValueBytes {
+			value = trimValue(value, len(value)-maxAttributeValueBytes)
+			attributes["truncated"] = "true"
+		}
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	addPrefixedAttributes(attributes, annotationAttributePrefix, message.Annotations, "annotation")
+}
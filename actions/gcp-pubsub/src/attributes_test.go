@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributesMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    int
+		wantErr string
+	}{
+		{name: "unset", spec: ""},
+		{name: "fields", spec: `{"team": "labels.team", "runbook": "annotations.runbook_url", "host": "instance"}`, want: 3},
+		{name: "not an object", spec: `["labels.team"]`, wantErr: "invalid ATTRIBUTES_MAP_JSON"},
+		{name: "unknown field", spec: `{"team": "team"}`, wantErr: "maps unknown message field 'team'"},
+		{name: "built-in conflict", spec: `{"severity": "labels.priority"}`, wantErr: "conflicts with a built-in attribute"},
+		{name: "reserved goog prefix", spec: `{"googTeam": "labels.team"}`, wantErr: "reserved 'goog' prefix"},
+		{name: "empty name", spec: `{"": "labels.team"}`, wantErr: "attribute names must be 1 to 256 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAttributesMap(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseAttributesMap() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAttributesMap() unexpected error: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("parseAttributesMap() returned %d attributes, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCustomAttributesMapAndAllLabels(t *testing.T) {
+	message := &PubSubMessage{
+		AlertName:   "DiskFull",
+		Instance:    "node-1",
+		Labels:      map[string]string{"alertname": "DiskFull", "severity": "critical", "team": "storage", "cluster": "prod", "empty": ""},
+		Annotations: map[string]string{"runbook_url": "https://runbooks.example.com/disk"},
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    map[string]string
+		wantNot []string
+	}{
+		{
+			name:    "map",
+			config:  Config{AttributesMap: map[string]string{"runbook": "annotations.runbook_url", "host": "instance", "owner": "labels.owner"}},
+			want:    map[string]string{"runbook": "https://runbooks.example.com/disk", "host": "node-1"},
+			wantNot: []string{"owner", "team"},
+		},
+		{
+			name:    "all labels",
+			config:  Config{AllLabelAttributes: true},
+			want:    map[string]string{"alertname": "DiskFull", "team": "storage", "cluster": "prod"},
+			wantNot: []string{"empty"},
+		},
+		{
+			name:   "all labels with prefix",
+			config: Config{AllLabelAttributes: true, AttributePrefix: "label_"},
+			want:   map[string]string{"label_severity": "critical", "label_team": "storage"},
+		},
+		{
+			name:   "map wins over labels",
+			config: Config{AllLabelAttributes: true, AttributesMap: map[string]string{"team": "labels.cluster"}},
+			want:   map[string]string{"team": "prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := map[string]string{"severity": "critical"}
+			addCustomAttributes(attributes, &tt.config, message)
+			assertAttributes(t, attributes, tt.want)
+			for _, name := range tt.wantNot {
+				if _, ok := attributes[name]; ok {
+					t.Errorf("attribute %s is set, want it absent", name)
+				}
+			}
+		})
+	}
+}
+
+func TestAddLabelAttributesLimit(t *testing.T) {
+	labels := map[string]string{}
+	for i := 0; i < maxAttributes+10; i++ {
+		labels[fmt.Sprintf("label%03d", i)] = "value"
+	}
+	labels["a-long"] = strings.Repeat("x", maxAttributeValueBytes+1)
+
+	attributes := map[string]string{}
+	output := captureLog(t, func() { addLabelAttributes(attributes, &Config{}, labels) })
+	if len(attributes) != maxAttributes {
+		t.Errorf("copied %d attributes, want the limit %d", len(attributes), maxAttributes)
+	}
+	if _, ok := attributes["a-long"]; ok {
+		t.Error("label over the value size limit was copied")
+	}
+	if !strings.Contains(output, "exceeds the Pub/Sub attribute size limits") || !strings.Contains(output, "the Pub/Sub limit") {
+		t.Errorf("expected warnings about the limits, got:\n%s", output)
+	}
+}
+
+func TestBuildPubSubMessageAttributesFormat(t *testing.T) {
+	config := &Config{Format: formatAttributes, AllLabelAttributes: true, AttributePrefix: "label_"}
+	message := &PubSubMessage{
+		AlertName:   "DiskFull",
+		Status:      "firing",
+		Severity:    "critical",
+		Instance:    "node-1",
+		Summary:     "Disk is full",
+		Description: strings.Repeat("d", maxAttributeValueBytes+10),
+		StartsAt:    "2025-10-01T12:29:56Z",
+		Fingerprint: "c2a4d9e6b1f07a35",
+		Labels:      map[string]string{"alertname": "DiskFull", "team": "storage"},
+		Annotations: map[string]string{"runbook_url": "https://runbooks.example.com/disk"},
+	}
+
+	pubsubMsg, err := buildPubSubMessage(config, message)
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	if len(pubsubMsg.Data) != 0 {
+		t.Errorf("data = %s, want none", pubsubMsg.Data)
+	}
+	assertAttributes(t, pubsubMsg.Attributes, map[string]string{
+		"alertName":              "DiskFull",
+		"severity":               "critical",
+		"instance":               "node-1",
+		"summary":                "Disk is full",
+		"startsAt":               "2025-10-01T12:29:56Z",
+		"fingerprint":            "c2a4d9e6b1f07a35",
+		"label_team":             "storage",
+		"annotation_runbook_url": "https://runbooks.example.com/disk",
+		"truncated":              "true",
+	})
+	if got := pubsubMsg.Attributes["description"]; len(got) > maxAttributeValueBytes || !strings.HasSuffix(got, truncatedSuffix) {
+		t.Errorf("description attribute is %d bytes, want it truncated to %d", len(got), maxAttributeValueBytes)
+	}
+	if _, ok := pubsubMsg.Attributes["endsAt"]; ok {
+		t.Error("empty endsAt was copied to the attributes")
+	}
+}
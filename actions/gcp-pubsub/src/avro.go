@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hamba/avro/v2"
+)
+
+// parseAvroSchema parses an Avro schema definition, which must be a record.
+// Each schema gets its own cache of named types, so a name declared by one
+// topic's schema can't resolve in another's.
+func parseAvroSchema(definition string) (*avro.RecordSchema, error) {
+	schema, err := avro.ParseWithCache(definition, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	record, ok := schema.(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("invalid Avro schema: top-level type must be a record, got %s", schema.Type())
+	}
+	return record, nil
+}
+
+// avroTypeName is the name a union branch is identified by when encoding,
+// the full name of a named type or the primitive type with its logical type
+func avroTypeName(schema avro.Schema) string {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+	if named, ok := schema.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	if logical, ok := schema.(avro.LogicalTypeSchema); ok && logical.Logical() != nil {
+		return string(schema.Type()) + "." + string(logical.Logical().Type())
+	}
+	return string(schema.Type())
+}
+
+// avroConvert converts an alert value, or a field default as parsed by the
+// schema, to the value the encoder expects: nil, bool, int32, int64,
+// float32, float64, string, []byte, a map or slice of converted values, or a
+// record as a map of field values. Non-null union values are wrapped in a
+// map keyed by their branch's type name.
+func avroConvert(schema avro.Schema, value interface{}) (interface{}, error) {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return avroConvert(s.Schema(), value)
+	case *avro.NullSchema:
+		if value != nil {
+			return nil, fmt.Errorf("expected null, got %v", value)
+		}
+		return nil, nil
+	case *avro.PrimitiveSchema:
+		switch s.Type() {
+		case avro.Boolean:
+			switch v := value.(type) {
+			case bool:
+				return v, nil
+			case string:
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("expected a boolean, got '%s'", v)
+				}
+				return b, nil
+			}
+		case avro.Int, avro.Long:
+			var n int64
+			switch v := value.(type) {
+			case int:
+				n = int64(v)
+			case int64:
+				n = v
+			case float64:
+				if v != math.Trunc(v) {
+					return nil, fmt.Errorf("expected an integer, got %v", v)
+				}
+				n = int64(v)
+			case string:
+				var err error
+				if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+					return nil, fmt.Errorf("expected an integer, got '%s'", v)
+				}
+			default:
+				return nil, avroConvertError(schema, value)
+			}
+			if s.Type() == avro.Long {
+				return n, nil
+			}
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("%d is out of range for int", n)
+			}
+			return int32(n), nil
+		case avro.Float, avro.Double:
+			var f float64
+			switch v := value.(type) {
+			case float32:
+				f = float64(v)
+			case float64:
+				f = v
+			case string:
+				var err error
+				if f, err = strconv.ParseFloat(v, 64); err != nil {
+					return nil, fmt.Errorf("expected a number, got '%s'", v)
+				}
+			default:
+				return nil, avroConvertError(schema, value)
+			}
+			if s.Type() == avro.Float {
+				return float32(f), nil
+			}
+			return f, nil
+		case avro.String, avro.Bytes:
+			var str string
+			switch v := value.(type) {
+			case string:
+				str = v
+			case []byte:
+				str = string(v)
+			case bool, float64:
+				str = fmt.Sprint(v)
+			default:
+				return nil, avroConvertError(schema, value)
+			}
+			if s.Type() == avro.Bytes {
+				return []byte(str), nil
+			}
+			return str, nil
+		}
+	case *avro.EnumSchema:
+		if v, ok := value.(string); ok {
+			for _, symbol := range s.Symbols() {
+				if v == symbol {
+					return v, nil
+				}
+			}
+			return nil, fmt.Errorf("'%s' is not a symbol of enum %s", v, s.FullName())
+		}
+	case *avro.ArraySchema:
+		if items, ok := value.([]interface{}); ok {
+			converted := make([]interface{}, len(items))
+			for i, item := range items {
+				c, err := avroConvert(s.Items(), item)
+				if err != nil {
+					return nil, fmt.Errorf("item %d: %w", i, err)
+				}
+				converted[i] = c
+			}
+			return converted, nil
+		}
+	case *avro.MapSchema:
+		if entries, ok := value.(map[string]interface{}); ok {
+			converted := make(map[string]interface{}, len(entries))
+			for key, entry := range entries {
+				c, err := avroConvert(s.Values(), entry)
+				if err != nil {
+					return nil, fmt.Errorf("key '%s': %w", key, err)
+				}
+				converted[key] = c
+			}
+			return converted, nil
+		}
+	case *avro.RecordSchema:
+		if fields, ok := value.(map[string]interface{}); ok {
+			return avroConvertRecord(s, fields)
+		}
+	case *avro.UnionSchema:
+		for _, branch := range s.Types() {
+			c, err := avroConvert(branch, value)
+			if err != nil {
+				continue
+			}
+			if branch.Type() == avro.Null {
+				return nil, nil
+			}
+			return map[string]interface{}{avroTypeName(branch): c}, nil
+		}
+		return nil, fmt.Errorf("value %v matches no branch of the union", value)
+	}
+	return nil, avroConvertError(schema, value)
+}
+
+// avroConvertError reports a value that can't be converted to the schema
+func avroConvertError(schema avro.Schema, value interface{}) error {
+	if value == nil {
+		return fmt.Errorf("missing value for %s", avroTypeName(schema))
+	}
+	return fmt.Errorf("cannot convert %T to %s", value, avroTypeName(schema))
+}
+
+// avroConvertRecord converts every field of the record from the alert field
+// of the same name. An absent field takes its default, or null when the
+// field allows it; otherwise the alert can't be mapped.
+func avroConvertRecord(schema *avro.RecordSchema, fields map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(schema.Fields()))
+	for _, field := range schema.Fields() {
+		value, ok := fields[field.Name()]
+		if !ok && field.HasDefault() {
+			value = field.Default()
+		}
+		converted, err := avroConvert(field.Type(), value)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", field.Name(), err)
+		}
+		values[field.Name()] = converted
+	}
+	return values, nil
+}
+
+// encodeAvroBinary appends the converted record in the Avro binary encoding
+func encodeAvroBinary(b []byte, schema *avro.RecordSchema, values map[string]interface{}) ([]byte, error) {
+	data, err := avro.Marshal(schema, values)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, data...), nil
+}
+
+// avroJSONValue returns the converted value in the shape of the Avro JSON
+// encoding, which the library doesn't implement. Union values are keyed by
+// their branch's type name without a logical type, and bytes are strings.
+func avroJSONValue(schema avro.Schema, value interface{}) interface{} {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return avroJSONValue(s.Schema(), value)
+	case *avro.PrimitiveSchema:
+		if b, ok := value.([]byte); ok {
+			return string(b)
+		}
+	case *avro.ArraySchema:
+		items := value.([]interface{})
+		encoded := make([]interface{}, len(items))
+		for i, item := range items {
+			encoded[i] = avroJSONValue(s.Items(), item)
+		}
+		return encoded
+	case *avro.MapSchema:
+		entries := value.(map[string]interface{})
+		encoded := make(map[string]interface{}, len(entries))
+		for key, entry := range entries {
+			encoded[key] = avroJSONValue(s.Values(), entry)
+		}
+		return encoded
+	case *avro.RecordSchema:
+		fields := value.(map[string]interface{})
+		encoded := make(map[string]interface{}, len(fields))
+		for _, field := range s.Fields() {
+			encoded[field.Name()] = avroJSONValue(field.Type(), fields[field.Name()])
+		}
+		return encoded
+	case *avro.UnionSchema:
+		// A null union value is nil rather than a map
+		branches, _ := value.(map[string]interface{})
+		for name, branchValue := range branches {
+			branch, _ := s.Types().Get(name)
+			jsonName := avroTypeName(branch)
+			if _, ok := branch.(*avro.PrimitiveSchema); ok {
+				jsonName = string(branch.Type())
+			}
+			return map[string]interface{}{jsonName: avroJSONValue(branch, branchValue)}
+		}
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "Alert",
+	"namespace": "karo",
+	"fields": [
+		{"name": "alertName", "type": "string"},
+		{"name": "severity", "type": {"type": "enum", "name": "Severity", "symbols": ["critical", "warning"]}},
+		{"name": "count", "type": ["null", "long"], "default": null},
+		{"name": "labels", "type": {"type": "map", "values": "string"}}
+	]
+}`
+
+func TestParseAvroSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		wantErr    string
+	}{
+		{name: "record", definition: testAvroSchema},
+		{name: "named reference", definition: `{"type": "record", "name": "Alert", "fields": [
+			{"name": "current", "type": {"type": "enum", "name": "State", "symbols": ["firing", "resolved"]}},
+			{"name": "previous", "type": ["null", "State"]}
+		]}`},
+		{name: "not a record", definition: `"string"`, wantErr: "top-level type must be a record"},
+		{name: "unknown type", definition: `{"type": "record", "name": "Alert", "fields": [{"name": "at", "type": "Timestamp"}]}`, wantErr: "unknown type: Timestamp"},
+		{name: "not JSON", definition: `record Alert {}`, wantErr: "invalid Avro schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAvroSchema(tt.definition)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseAvroSchema() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAvroSchema() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAvroEncoding(t *testing.T) {
+	schema, err := parseAvroSchema(testAvroSchema)
+	if err != nil {
+		t.Fatalf("parseAvroSchema() unexpected error: %v", err)
+	}
+	values, err := avroConvertRecord(schema, map[string]interface{}{
+		"alertName": "DiskFull",
+		"severity":  "critical",
+		"labels":    map[string]interface{}{"team": "db"},
+		"status":    "firing",
+	})
+	if err != nil {
+		t.Fatalf("avroConvertRecord() unexpected error: %v", err)
+	}
+
+	// The labels map is written as a block with a negative count, -1,
+	// followed by the block's size in bytes, 8
+	want := []byte{0x10, 'D', 'i', 's', 'k', 'F', 'u', 'l', 'l', 0x00, 0x00, 0x01, 0x10, 0x08, 't', 'e', 'a', 'm', 0x04, 'd', 'b', 0x00}
+	got, err := encodeAvroBinary(nil, schema, values)
+	if err != nil {
+		t.Fatalf("encodeAvroBinary() unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeAvroBinary() = %x, want %x", got, want)
+	}
+
+	data, err := json.Marshal(avroJSONValue(schema, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"alertName":"DiskFull","count":null,"labels":{"team":"db"},"severity":"critical"}`; string(data) != want {
+		t.Errorf("avroJSONValue() = %s, want %s", data, want)
+	}
+}
+
+func TestAvroEncodingUnionValue(t *testing.T) {
+	schema, err := parseAvroSchema(`{"type": "record", "name": "Alert", "fields": [
+		{"name": "count", "type": ["null", "long"]},
+		{"name": "startsAt", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}], "default": null}
+	]}`)
+	if err != nil {
+		t.Fatalf("parseAvroSchema() unexpected error: %v", err)
+	}
+	values, err := avroConvertRecord(schema, map[string]interface{}{"count": "3", "startsAt": float64(1700000000000)})
+	if err != nil {
+		t.Fatalf("avroConvertRecord() unexpected error: %v", err)
+	}
+
+	got, err := encodeAvroBinary(nil, schema, values)
+	if err != nil {
+		t.Fatalf("encodeAvroBinary() unexpected error: %v", err)
+	}
+	if want := []byte{0x02, 0x06, 0x02, 0x80, 0xa0, 0xab, 0xfe, 0xf9, 0x62}; !bytes.Equal(got, want) {
+		t.Errorf("encodeAvroBinary() = %x, want %x", got, want)
+	}
+
+	data, err := json.Marshal(avroJSONValue(schema, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"count":{"long":3},"startsAt":{"long":1700000000000}}`; string(data) != want {
+		t.Errorf("avroJSONValue() = %s, want %s", data, want)
+	}
+}
+
+func TestAvroConvertErrors(t *testing.T) {
+	schema, err := parseAvroSchema(testAvroSchema)
+	if err != nil {
+		t.Fatalf("parseAvroSchema() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		fields  map[string]interface{}
+		wantErr string
+	}{
+		{name: "missing field", fields: map[string]interface{}{"severity": "critical", "labels": map[string]interface{}{}}, wantErr: "field 'alertName': missing value for string"},
+		{name: "unknown symbol", fields: map[string]interface{}{"alertName": "DiskFull", "severity": "info", "labels": map[string]interface{}{}}, wantErr: "'info' is not a symbol of enum karo.Severity"},
+		{name: "no union branch", fields: map[string]interface{}{"alertName": "DiskFull", "severity": "critical", "count": "many", "labels": map[string]interface{}{}}, wantErr: "field 'count': value many matches no branch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := avroConvertRecord(schema, tt.fields)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("avroConvertRecord() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
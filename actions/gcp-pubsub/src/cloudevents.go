@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// FORMAT values
+const (
+	formatKaro        = "karo"
+	formatCloudEvents = "cloudevents"
+	formatAttributes  = "attributes"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	// cloudEventsDataContentType is the media type of the event data, the
+	// built-in JSON message
+	cloudEventsDataContentType = "application/json"
+	// defaultCloudEventsSource identifies this action when CLOUDEVENTS_SOURCE
+	// is unset
+	defaultCloudEventsSource = "karo/gcp-pubsub"
+	// cloudEventTypePrefix is suffixed with the alert status, e.g.
+	// com.karo.alert.firing
+	cloudEventTypePrefix = "com.karo.alert"
+	// cloudEventAttributePrefix marks the attributes of the Pub/Sub protocol
+	// binding that carry CloudEvents attributes
+	cloudEventAttributePrefix = "ce-"
+)
+
+// parseFormatConfig reads FORMAT and CLOUDEVENTS_SOURCE. The data of a
+// CloudEvent is the built-in JSON message and FORMAT=attributes publishes
+// none, so neither can be combined with a message template or a topic or
+// registry schema.
+func parseFormatConfig(config *Config) error {
+	switch format := os.Getenv("FORMAT"); format {
+	case "", formatKaro:
+		config.Format = formatKaro
+	case formatCloudEvents, formatAttributes:
+		config.Format = format
+	default:
+		return fmt.Errorf("unsupported FORMAT '%s', must be '%s', '%s' or '%s'", format, formatKaro, formatCloudEvents, formatAttributes)
+	}
+	if config.Format != formatKaro && (config.MessageTemplate != nil || config.TopicSchema || config.SchemaRegistryURL != "") {
+		return fmt.Errorf("FORMAT=%s cannot be combined with MESSAGE_TEMPLATE, TOPIC_SCHEMA or SCHEMA_REGISTRY_URL", config.Format)
+	}
+
+	if config.Format == formatAttributes {
+		if config.GroupMode == groupModeDigest {
+			return fmt.Errorf("FORMAT=%s cannot be combined with ALERT_GROUP_MODE=%s, whose alerts only fit in the data", formatAttributes, groupModeDigest)
+		}
+		// The labels are part of the alert the attributes carry
+		config.AllLabelAttributes = true
+	}
+
+	if config.Format != formatCloudEvents {
+		if os.Getenv("CLOUDEVENTS_SOURCE") != "" {
+			log.Printf("Warning: CLOUDEVENTS_SOURCE is ignored unless FORMAT=%s", formatCloudEvents)
+		}
+		return nil
+	}
+	for name := range config.AttributesMap {
+		if strings.HasPrefix(name, cloudEventAttributePrefix) {
+			return fmt.Errorf("invalid ATTRIBUTES_MAP_JSON: attribute '%s' uses the '%s' prefix reserved by FORMAT=%s", name, cloudEventAttributePrefix, formatCloudEvents)
+		}
+	}
+
+	config.CloudEventsSource = os.Getenv("CLOUDEVENTS_SOURCE")
+	if config.CloudEventsSource == "" {
+		config.CloudEventsSource = defaultCloudEventsSource
+	}
+	return nil
+}
+
+// cloudEventType derives the event type from the alert status, e.g.
+// com.karo.alert.firing, falling back to the bare prefix without a status
+func cloudEventType(status string) string {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "" {
+		return cloudEventTypePrefix
+	}
+	return cloudEventTypePrefix + "." + status
+}
+
+// addCloudEventAttributes sets the attributes of a binary-mode CloudEvent
+// with a new ID, as defined by the Pub/Sub protocol binding. The message
+// data is the event data, so consumers such as Eventarc and Knative read
+// the event without parsing it.
+func addCloudEventAttributes(attributes map[string]string, config *Config, message *PubSubMessage) {
+	attributes["ce-specversion"] = cloudEventsSpecVersion
+	attributes["ce-type"] = cloudEventType(message.Status)
+	attributes["ce-source"] = config.CloudEventsSource
+	attributes["ce-id"] = uuid.NewString()
+	if message.Timestamp != "" {
+		attributes["ce-time"] = message.Timestamp
+	}
+	attributes["content-type"] = cloudEventsDataContentType
+}
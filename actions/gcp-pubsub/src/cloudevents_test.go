@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestParseFormatConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		config     Config
+		wantFormat string
+		wantSource string
+		wantErr    bool
+	}{
+		{name: "defaults", env: map[string]string{}, wantFormat: formatKaro},
+		{name: "cloudevents", env: map[string]string{"FORMAT": "cloudevents"}, wantFormat: formatCloudEvents, wantSource: defaultCloudEventsSource},
+		{name: "cloudevents with source", env: map[string]string{"FORMAT": "cloudevents", "CLOUDEVENTS_SOURCE": "/clusters/prod"}, wantFormat: formatCloudEvents, wantSource: "/clusters/prod"},
+		{name: "invalid format", env: map[string]string{"FORMAT": "cloudevent"}, wantErr: true},
+		{name: "message template is rejected", env: map[string]string{"FORMAT": "cloudevents"}, config: Config{MessageTemplate: template.Must(template.New("message").Parse(`{{ .AlertName }}`))}, wantErr: true},
+		{name: "topic schema is rejected", env: map[string]string{"FORMAT": "cloudevents"}, config: Config{TopicSchema: true}, wantErr: true},
+		{name: "reserved attribute is rejected", env: map[string]string{"FORMAT": "cloudevents"}, config: Config{AttributesMap: map[string]string{"ce-subject": "alertName"}}, wantErr: true},
+		{name: "attributes", env: map[string]string{"FORMAT": "attributes"}, wantFormat: formatAttributes},
+		{name: "attributes with digest is rejected", env: map[string]string{"FORMAT": "attributes"}, config: Config{GroupMode: groupModeDigest}, wantErr: true},
+		{name: "attributes with message template is rejected", env: map[string]string{"FORMAT": "attributes"}, config: Config{MessageTemplate: template.Must(template.New("message").Parse(`{{ .AlertName }}`))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"FORMAT", "CLOUDEVENTS_SOURCE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := tt.config
+			err := parseFormatConfig(&config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFormatConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if config.Format != tt.wantFormat || config.CloudEventsSource != tt.wantSource {
+				t.Errorf("format, source = %q, %q, want %q, %q", config.Format, config.CloudEventsSource, tt.wantFormat, tt.wantSource)
+			}
+			if config.AllLabelAttributes != (tt.wantFormat == formatAttributes) {
+				t.Errorf("AllLabelAttributes = %t, want it set only for FORMAT=%s", config.AllLabelAttributes, formatAttributes)
+			}
+		})
+	}
+}
+
+func TestCloudEventType(t *testing.T) {
+	tests := map[string]string{
+		"firing":     "com.karo.alert.firing",
+		" Resolved ": "com.karo.alert.resolved",
+		"":           "com.karo.alert",
+	}
+	for status, want := range tests {
+		if got := cloudEventType(status); got != want {
+			t.Errorf("cloudEventType(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestBuildPubSubMessageCloudEvents(t *testing.T) {
+	config := &Config{Format: formatCloudEvents, CloudEventsSource: "/clusters/prod"}
+	message := &PubSubMessage{AlertName: "DiskFull", Status: "firing", Timestamp: "2025-10-01T12:29:56Z", Source: "karo"}
+
+	first, err := buildPubSubMessage(config, message)
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	assertAttributes(t, first.Attributes, map[string]string{
+		"ce-specversion": "1.0",
+		"ce-type":        "com.karo.alert.firing",
+		"ce-source":      "/clusters/prod",
+		"ce-time":        "2025-10-01T12:29:56Z",
+		"content-type":   "application/json",
+		"alertName":      "DiskFull",
+	})
+
+	var data PubSubMessage
+	if err := json.Unmarshal(first.Data, &data); err != nil || data.AlertName != "DiskFull" {
+		t.Errorf("event data = %s, want the JSON message", first.Data)
+	}
+
+	second, err := buildPubSubMessage(config, message)
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	if first.Attributes["ce-id"] == "" || first.Attributes["ce-id"] == second.Attributes["ce-id"] {
+		t.Errorf("ce-id = %q and %q, want a new ID per event", first.Attributes["ce-id"], second.Attributes["ce-id"])
+	}
+}
+
+func TestBuildPubSubMessageWithoutCloudEvents(t *testing.T) {
+	pubsubMsg, err := buildPubSubMessage(&Config{Format: formatKaro}, &PubSubMessage{AlertName: "DiskFull", Status: "firing"})
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	for name := range pubsubMsg.Attributes {
+		if name == "content-type" || strings.HasPrefix(name, cloudEventAttributePrefix) {
+			t.Errorf("unexpected CloudEvents attribute %s", name)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+)
+
+// COMPRESS values
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+)
+
+// contentEncodingAttribute names the compression of compressed message data
+const contentEncodingAttribute = "content-encoding"
+
+// parseCompressConfig reads COMPRESS. Pub/Sub validates data against a
+// topic schema and registry consumers expect the wire format, which
+// compressed data can't satisfy, so COMPRESS excludes both.
+func parseCompressConfig(config *Config) error {
+	switch compress := os.Getenv("COMPRESS"); compress {
+	case "":
+		config.Compress = compressNone
+	case compressNone, compressGzip:
+		config.Compress = compress
+	default:
+		return fmt.Errorf("unsupported COMPRESS '%s', must be '%s' or '%s'", compress, compressNone, compressGzip)
+	}
+	if config.Compress == compressGzip && (config.TopicSchema || config.SchemaRegistryURL != "") {
+		return fmt.Errorf("COMPRESS=%s cannot be combined with TOPIC_SCHEMA or SCHEMA_REGISTRY_URL", compressGzip)
+	}
+	if config.Compress == compressGzip && config.Format == formatAttributes {
+		return fmt.Errorf("COMPRESS=%s cannot be combined with FORMAT=%s, which publishes no data", compressGzip, formatAttributes)
+	}
+	return nil
+}
+
+// gzipData compresses the message data for COMPRESS=gzip
+func gzipData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress message data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress message data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseCompressConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress string
+		schema   bool
+		format   string
+		want     string
+		wantErr  bool
+	}{
+		{name: "unset", want: compressNone},
+		{name: "none", compress: "none", want: compressNone},
+		{name: "gzip", compress: "gzip", want: compressGzip},
+		{name: "unsupported", compress: "zstd", wantErr: true},
+		{name: "gzip with topic schema", compress: "gzip", schema: true, wantErr: true},
+		{name: "none with topic schema", compress: "none", schema: true, want: compressNone},
+		{name: "gzip with attributes format", compress: "gzip", format: formatAttributes, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COMPRESS", tt.compress)
+
+			config := &Config{TopicSchema: tt.schema, Format: tt.format}
+			err := parseCompressConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCompressConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.Compress != tt.want {
+				t.Errorf("Compress = %q, want %q", config.Compress, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPubSubMessageGzip(t *testing.T) {
+	// A large annotation that only fits MAX_PAYLOAD_BYTES compressed
+	message := &PubSubMessage{
+		AlertName:   "DiskFull",
+		Status:      "firing",
+		Annotations: map[string]string{"details": strings.Repeat("disk /var is full; ", 5000)},
+	}
+	config := &Config{Compress: compressGzip, MaxPayloadBytes: 10000}
+
+	pubsubMsg, err := buildPubSubMessage(config, message)
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	if got := pubsubMsg.Attributes[contentEncodingAttribute]; got != "gzip" {
+		t.Errorf("%s attribute = %q, want gzip", contentEncodingAttribute, got)
+	}
+	if len(pubsubMsg.Data) > config.MaxPayloadBytes {
+		t.Errorf("compressed data is %d bytes, want at most %d", len(pubsubMsg.Data), config.MaxPayloadBytes)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(pubsubMsg.Data))
+	if err != nil {
+		t.Fatalf("data is not gzip: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress data: %v", err)
+	}
+	var decoded PubSubMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decompressed data is not a JSON message: %v", err)
+	}
+	if decoded.AlertName != "DiskFull" || decoded.Annotations["details"] != message.Annotations["details"] || decoded.Truncated {
+		t.Errorf("decompressed message = %s..., want the whole alert", data[:100])
+	}
+}
+
+func TestBuildPubSubMessageUncompressed(t *testing.T) {
+	pubsubMsg, err := buildPubSubMessage(&Config{Compress: compressNone}, &PubSubMessage{AlertName: "DiskFull"})
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	if _, ok := pubsubMsg.Attributes[contentEncodingAttribute]; ok {
+		t.Errorf("unexpected %s attribute", contentEncodingAttribute)
+	}
+	if !json.Valid(pubsubMsg.Data) {
+		t.Errorf("data = %q, want JSON", pubsubMsg.Data)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// logDryRun logs the message that would have been published, in the same
+// shape as a file sink record, without contacting Pub/Sub
+func logDryRun(config *Config, message *PubSubMessage) error {
+	record, err := buildSinkRecord(config, message)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dry-run record: %w", err)
+	}
+	log.Printf("Dry run, would publish: %s", payloadForLog(data, config.RedactFields))
+	return nil
+}
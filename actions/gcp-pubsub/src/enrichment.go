@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultEnrichmentKeyField is the alert field used to look up enrichment
+// entries when ENRICHMENT_KEY_FIELD is not set
+const defaultEnrichmentKeyField = "labels.instance"
+
+// Enrichment holds the labels and annotations merged into an alert whose
+// key matches an entry in the enrichment file
+type Enrichment struct {
+	Labels      map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations" json:"annotations,omitempty"`
+}
+
+// loadEnrichmentFile reads a JSON or YAML file mapping key values to
+// enrichment entries, e.g.
+//
+//	node-1:
+//	  labels:
+//	    team: storage
+func loadEnrichmentFile(path string) (map[string]Enrichment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read enrichment file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both formats
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	table := map[string]Enrichment{}
+	if err := decoder.Decode(&table); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse enrichment file %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// validateEnrichmentKeyField checks that the key field names a label or
+// annotation, the only alert fields enrichment can be keyed by
+func validateEnrichmentKeyField(field string) error {
+	for _, prefix := range []string{"labels.", "annotations."} {
+		if strings.HasPrefix(field, prefix) && len(field) > len(prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid ENRICHMENT_KEY_FIELD '%s': must be labels.<key> or annotations.<key>", field)
+}
+
+// enrichAlert merges the enrichment entry matching the alert's key field into
+// its labels and annotations. Values already present on the alert win. It
+// reports whether an entry matched; alerts without a matching key are left
+// unchanged.
+func enrichAlert(alert *AlertData, keyField string, table map[string]Enrichment) bool {
+	if alert == nil || len(table) == 0 {
+		return false
+	}
+
+	var key string
+	if name, ok := strings.CutPrefix(keyField, "labels."); ok {
+		key = alert.Labels[name]
+	} else if name, ok := strings.CutPrefix(keyField, "annotations."); ok {
+		key = alert.Annotations[name]
+	}
+	if key == "" {
+		return false
+	}
+
+	entry, ok := table[key]
+	if !ok {
+		return false
+	}
+
+	alert.Labels = mergeMissing(alert.Labels, entry.Labels)
+	alert.Annotations = mergeMissing(alert.Annotations, entry.Annotations)
+	return true
+}
+
+// mergeMissing copies keys from src that are missing or empty in dst
+func mergeMissing(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for key, value := range src {
+		if dst[key] == "" {
+			dst[key] = value
+		}
+	}
+	return dst
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseStrictEnv reads STRICT_ENV. The flag itself is always parsed
+// strictly, since a typo in it would silently disable strict parsing.
+func parseStrictEnv() (bool, error) {
+	value := strings.TrimSpace(os.Getenv("STRICT_ENV"))
+	if value == "" {
+		return false, nil
+	}
+	strict, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid STRICT_ENV '%s': must be a boolean", value)
+	}
+	return strict, nil
+}
+
+// envInt sets target from the named environment variable when it is set.
+// A malformed value is a configuration error in strict mode; otherwise it is
+// logged and ignored so the default applies.
+func envInt(strict bool, name string, target *int) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		if strict {
+			return fmt.Errorf("invalid %s '%s': must be an integer", name, value)
+		}
+		log.Printf("Warning: Invalid %s value '%s', using default %d", name, value, *target)
+		return nil
+	}
+	*target = parsed
+	return nil
+}
+
+// envBool sets target from the named environment variable when it is set,
+// accepting the values understood by strconv.ParseBool. Malformed values are
+// handled as in envInt.
+func envBool(strict bool, name string, target *bool) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		if strict {
+			return fmt.Errorf("invalid %s '%s': must be a boolean (true/false)", name, value)
+		}
+		log.Printf("Warning: Invalid %s value '%s', using default %t", name, value, *target)
+		return nil
+	}
+	*target = parsed
+	return nil
+}
+
+// envFloat sets target from the named environment variable when it is set.
+// Malformed values are handled as in envInt.
+func envFloat(strict bool, name string, target *float64) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		if strict {
+			return fmt.Errorf("invalid %s '%s': must be a number", name, value)
+		}
+		log.Printf("Warning: Invalid %s value '%s', using default %g", name, value, *target)
+		return nil
+	}
+	*target = parsed
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+// PUBLISH_LIMIT_EXCEEDED values
+const (
+	limitExceededBlock  = "block"
+	limitExceededError  = "error"
+	limitExceededIgnore = "ignore"
+)
+
+// parseFlowControlConfig reads the client's batching settings,
+// PUBLISH_DELAY_THRESHOLD_MS, PUBLISH_COUNT_THRESHOLD and
+// PUBLISH_BYTE_THRESHOLD, and its flow control settings,
+// PUBLISH_MAX_OUTSTANDING_MESSAGES, PUBLISH_MAX_OUTSTANDING_BYTES and
+// PUBLISH_LIMIT_EXCEEDED. Zero keeps the client default. Flow control only
+// applies once a maximum is set, and then blocks by default; the client
+// holds at most 1000 messages unless PUBLISH_MAX_OUTSTANDING_MESSAGES says
+// otherwise.
+func parseFlowControlConfig(config *Config) error {
+	for _, setting := range []struct {
+		name  string
+		value *int
+		max   int
+	}{
+		{"PUBLISH_DELAY_THRESHOLD_MS", &config.DelayThresholdMs, 0},
+		{"PUBLISH_COUNT_THRESHOLD", &config.CountThreshold, pubsub.MaxPublishRequestCount},
+		{"PUBLISH_BYTE_THRESHOLD", &config.ByteThreshold, maxMessageBytes},
+		{"PUBLISH_MAX_OUTSTANDING_MESSAGES", &config.MaxOutstandingMsgs, 0},
+		{"PUBLISH_MAX_OUTSTANDING_BYTES", &config.MaxOutstandingBytes, 0},
+	} {
+		if err := envInt(config.StrictEnv, setting.name, setting.value); err != nil {
+			return err
+		}
+		if *setting.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", setting.name, *setting.value)
+		}
+		if setting.max > 0 && *setting.value > setting.max {
+			return fmt.Errorf("%s must be at most %d, Pub/Sub's limit for a publish request, got %d", setting.name, setting.max, *setting.value)
+		}
+	}
+
+	switch behavior := os.Getenv("PUBLISH_LIMIT_EXCEEDED"); behavior {
+	case "":
+		config.LimitExceeded = limitExceededBlock
+	case limitExceededBlock, limitExceededError, limitExceededIgnore:
+		config.LimitExceeded = behavior
+	default:
+		return fmt.Errorf("unsupported PUBLISH_LIMIT_EXCEEDED '%s', must be '%s', '%s' or '%s'", behavior, limitExceededBlock, limitExceededError, limitExceededIgnore)
+	}
+	return nil
+}
+
+// applyFlowControl sets the configured batching and flow control settings
+// on the publisher, leaving the client defaults for those that are unset
+func applyFlowControl(publisher *pubsub.Publisher, config *Config) {
+	settings := &publisher.PublishSettings
+	if config.DelayThresholdMs > 0 {
+		settings.DelayThreshold = time.Duration(config.DelayThresholdMs) * time.Millisecond
+	}
+	if config.CountThreshold > 0 {
+		settings.CountThreshold = config.CountThreshold
+	}
+	if config.ByteThreshold > 0 {
+		settings.ByteThreshold = config.ByteThreshold
+	}
+
+	if config.MaxOutstandingMsgs == 0 && config.MaxOutstandingBytes == 0 {
+		return
+	}
+	if config.MaxOutstandingMsgs > 0 {
+		settings.FlowControlSettings.MaxOutstandingMessages = config.MaxOutstandingMsgs
+	}
+	if config.MaxOutstandingBytes > 0 {
+		settings.FlowControlSettings.MaxOutstandingBytes = config.MaxOutstandingBytes
+	}
+	switch config.LimitExceeded {
+	case limitExceededError:
+		settings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlSignalError
+	case limitExceededIgnore:
+		settings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlIgnore
+	default:
+		settings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlBlock
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+func TestParseFlowControlConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{name: "defaults", env: map[string]string{}, want: Config{LimitExceeded: limitExceededBlock}},
+		{
+			name: "configured",
+			env: map[string]string{
+				"PUBLISH_DELAY_THRESHOLD_MS":       "50",
+				"PUBLISH_COUNT_THRESHOLD":          "500",
+				"PUBLISH_BYTE_THRESHOLD":           "1000000",
+				"PUBLISH_MAX_OUTSTANDING_MESSAGES": "2000",
+				"PUBLISH_MAX_OUTSTANDING_BYTES":    "50000000",
+				"PUBLISH_LIMIT_EXCEEDED":           "error",
+			},
+			want: Config{DelayThresholdMs: 50, CountThreshold: 500, ByteThreshold: 1000000, MaxOutstandingMsgs: 2000, MaxOutstandingBytes: 50000000, LimitExceeded: limitExceededError},
+		},
+		{name: "negative delay", env: map[string]string{"PUBLISH_DELAY_THRESHOLD_MS": "-1"}, wantErr: true},
+		{name: "count over the request limit", env: map[string]string{"PUBLISH_COUNT_THRESHOLD": "1001"}, wantErr: true},
+		{name: "bytes over the request limit", env: map[string]string{"PUBLISH_BYTE_THRESHOLD": "10000001"}, wantErr: true},
+		{name: "invalid number", env: map[string]string{"PUBLISH_MAX_OUTSTANDING_MESSAGES": "many"}, wantErr: true},
+		{name: "unsupported behavior", env: map[string]string{"PUBLISH_LIMIT_EXCEEDED": "drop"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PUBLISH_DELAY_THRESHOLD_MS", "PUBLISH_COUNT_THRESHOLD", "PUBLISH_BYTE_THRESHOLD", "PUBLISH_MAX_OUTSTANDING_MESSAGES", "PUBLISH_MAX_OUTSTANDING_BYTES", "PUBLISH_LIMIT_EXCEEDED"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{StrictEnv: true}
+			err := parseFlowControlConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFlowControlConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if config.DelayThresholdMs != tt.want.DelayThresholdMs || config.CountThreshold != tt.want.CountThreshold ||
+				config.ByteThreshold != tt.want.ByteThreshold || config.MaxOutstandingMsgs != tt.want.MaxOutstandingMsgs ||
+				config.MaxOutstandingBytes != tt.want.MaxOutstandingBytes || config.LimitExceeded != tt.want.LimitExceeded {
+				t.Errorf("settings = %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFlowControl(t *testing.T) {
+	publisher := &pubsub.Publisher{PublishSettings: pubsub.DefaultPublishSettings}
+	applyFlowControl(publisher, &Config{DelayThresholdMs: 50, MaxOutstandingMsgs: 100, LimitExceeded: limitExceededBlock})
+
+	settings := publisher.PublishSettings
+	if settings.DelayThreshold != 50*time.Millisecond {
+		t.Errorf("DelayThreshold = %s, want 50ms", settings.DelayThreshold)
+	}
+	if settings.CountThreshold != pubsub.DefaultPublishSettings.CountThreshold {
+		t.Errorf("CountThreshold = %d, want the client default", settings.CountThreshold)
+	}
+	flow := settings.FlowControlSettings
+	if flow.MaxOutstandingMessages != 100 || flow.LimitExceededBehavior != pubsub.FlowControlBlock {
+		t.Errorf("flow control = %+v, want 100 messages, blocking", flow)
+	}
+}
+
+func TestApplyFlowControlUnset(t *testing.T) {
+	publisher := &pubsub.Publisher{PublishSettings: pubsub.DefaultPublishSettings}
+	applyFlowControl(publisher, &Config{LimitExceeded: limitExceededError})
+
+	if publisher.PublishSettings.FlowControlSettings != pubsub.DefaultPublishSettings.FlowControlSettings {
+		t.Errorf("flow control = %+v, want the client default without a maximum", publisher.PublishSettings.FlowControlSettings)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ALERT_GROUP_MODE values
+const (
+	groupModePerAlert = "per-alert"
+	groupModeDigest   = "digest"
+)
+
+// alertGroup holds the fields of an Alertmanager webhook notification, set
+// on the AlertData decoded from ALERT_JSON when it is a whole group
+type alertGroup struct {
+	Alerts            []AlertData       `json:"alerts,omitempty"`
+	CommonLabels      map[string]string `json:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+}
+
+// parseGroupMode reads ALERT_GROUP_MODE, how an ALERT_JSON with an alerts
+// array is published: one message per alert, or a single digest message
+func parseGroupMode(config *Config) error {
+	switch mode := os.Getenv("ALERT_GROUP_MODE"); mode {
+	case "":
+		config.GroupMode = groupModePerAlert
+	case groupModePerAlert, groupModeDigest:
+		config.GroupMode = mode
+	default:
+		return fmt.Errorf("unsupported ALERT_GROUP_MODE '%s', must be '%s' or '%s'", mode, groupModePerAlert, groupModeDigest)
+	}
+	return nil
+}
+
+// splitAlertGroup returns the alerts to publish. A single alert is returned
+// as it is. For an Alertmanager group, every alert inherits the common
+// labels and annotations and the group status it doesn't set itself;
+// per-alert mode returns all of them, digest mode one alert of the group's
+// common labels, annotations and status that carries them all.
+func splitAlertGroup(config *Config, alertData *AlertData) []*AlertData {
+	if alertData == nil || alertData.Alerts == nil {
+		return []*AlertData{alertData}
+	}
+	if len(alertData.Alerts) == 0 {
+		log.Println("ALERT_JSON contains no alerts, nothing to publish")
+		return nil
+	}
+
+	alerts := make([]*AlertData, len(alertData.Alerts))
+	for i, alert := range alertData.Alerts {
+		alert.Labels = mergeGroup(alertData.CommonLabels, alert.Labels)
+		alert.Annotations = mergeGroup(alertData.CommonAnnotations, alert.Annotations)
+		if alert.Status == "" {
+			alert.Status = alertData.Status
+		}
+		alerts[i] = &alert
+	}
+
+	if config.GroupMode == groupModeDigest {
+		log.Printf("ALERT_JSON contains %d alerts, publishing a digest (ALERT_GROUP_MODE=%s)", len(alerts), groupModeDigest)
+		digest := &AlertData{
+			Status:      alertData.Status,
+			Labels:      maps.Clone(alertData.CommonLabels),
+			Annotations: maps.Clone(alertData.CommonAnnotations),
+		}
+		for _, alert := range alerts {
+			digest.Alerts = append(digest.Alerts, *alert)
+		}
+		return []*AlertData{digest}
+	}
+	log.Printf("ALERT_JSON contains %d alerts, publishing one message per alert (ALERT_GROUP_MODE=%s)", len(alerts), groupModePerAlert)
+	return alerts
+}
+
+// mergeGroup returns the alert's own values over the group's common ones
+func mergeGroup(common, own map[string]string) map[string]string {
+	if len(common) == 0 {
+		return own
+	}
+	merged := maps.Clone(common)
+	maps.Copy(merged, own)
+	return merged
+}
+
+// topicBatch is the messages published to one topic, with the configuration
+// routed to that topic
+type topicBatch struct {
+	config   *Config
+	messages []*PubSubMessage
+	// outcomes holds how publishing each message ended, once published
+	outcomes []publishOutcome
+	// err is why the batch failed to publish, if it did
+	err error
+}
+
+// routeTopics routes every message to the topic of its route in ROUTES_JSON
+// or the topic configured for its status, keeping the messages of a topic in
+// order
+func routeTopics(config *Config, messages []*PubSubMessage) ([]*topicBatch, error) {
+	var batches []*topicBatch
+	byTopic := map[string]*topicBatch{}
+	for _, message := range messages {
+		routed := *config
+		if err := routeMessage(&routed, message); err != nil {
+			return nil, err
+		}
+		batch, ok := byTopic[routed.TopicID]
+		if !ok {
+			batch = &topicBatch{config: &routed}
+			byTopic[routed.TopicID] = batch
+			batches = append(batches, batch)
+		}
+		batch.messages = append(batch.messages, message)
+	}
+	return batches, nil
+}
+
+// dedupClaim is an alert claimed in the dedup store, released again if
+// publishing fails
+type dedupClaim struct {
+	store dedupStore
+	key   string
+}
+
+// claimMessages claims every message of the batch when deduplication is
+// configured and drops those another run already handled within
+// DEDUP_TTL_SECONDS
+func claimMessages(ctx context.Context, batch *topicBatch, claims []dedupClaim) ([]dedupClaim, error) {
+	var fresh []*PubSubMessage
+	for _, message := range batch.messages {
+		dedup, key, duplicate, err := checkDuplicate(ctx, batch.config, message.Labels, message.Status)
+		if err != nil {
+			return claims, err
+		}
+		if duplicate {
+			log.Printf("Skipping duplicate alert %s, already handled within the last %ds", message.AlertName, batch.config.DedupTTLSeconds)
+			continue
+		}
+		if dedup != nil {
+			claims = append(claims, dedupClaim{store: dedup, key: key})
+		}
+		fresh = append(fresh, message)
+	}
+	batch.messages = fresh
+	return claims, nil
+}
+
+// releaseClaims forgets the claimed alerts after publishing failed, so the
+// next notification is not skipped as a duplicate
+func releaseClaims(claims []dedupClaim) {
+	for _, claim := range claims {
+		releaseAlert(claim.store, claim.key)
+	}
+}
+
+// closeClaims closes the dedup stores of the claimed alerts
+func closeClaims(claims []dedupClaim) {
+	for _, claim := range claims {
+		claim.store.close()
+	}
+}
+
+// publishBatches publishes each batch with its own publisher, encoding the
+// messages with the topic's schema when TOPIC_SCHEMA is set. A failed batch
+// doesn't stop the others.
+func publishBatches(ctx context.Context, batches []*topicBatch) error {
+	var errs []error
+	for _, batch := range batches {
+		if len(batch.messages) == 0 {
+			continue
+		}
+		batch.err = publishBatch(ctx, batch)
+		err := batch.err
+		if err != nil && len(batches) > 1 {
+			err = fmt.Errorf("topic %s: %w", batch.config.TopicID, err)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// publishBatch creates a publisher for the batch's topic and publishes its
+// messages within a producer span named after the topic, following the
+// OpenTelemetry messaging conventions
+func publishBatch(ctx context.Context, batch *topicBatch) error {
+	names := make([]string, len(batch.messages))
+	for i, message := range batch.messages {
+		names[i] = message.AlertName
+	}
+	ctx, span := tracer().Start(ctx, "send "+batch.config.TopicID,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "gcp_pubsub"),
+			attribute.String("messaging.operation.type", "send"),
+			attribute.String("messaging.destination.name", batch.config.TopicID),
+			attribute.String("cloud.account.id", batch.config.ProjectID),
+			attribute.Int("messaging.batch.message_count", len(batch.messages)),
+			attribute.StringSlice("karo.alert.names", names),
+		))
+
+	setLogTopic(batch.config.ProjectID, batch.config.TopicID)
+	publisher, err := newPubSubPublisher(ctx, batch.config)
+	if err == nil {
+		if batch.config.Validate {
+			err = validateTopic(ctx, batch.config, publisher.client.TopicAdminClient)
+		}
+		if err == nil {
+			batch.config.Schema, err = loadTopicSchema(ctx, batch.config, publisher.client)
+		}
+		if err == nil {
+			batch.outcomes, err = publishAlerts(ctx, batch.config, publisher, batch.messages...)
+		}
+		publisher.Close()
+	}
+
+	var ids []string
+	for _, outcome := range batch.outcomes {
+		if outcome.messageID != "" {
+			ids = append(ids, outcome.messageID)
+		}
+	}
+	if len(batch.messages) == 1 && len(ids) == 1 {
+		span.SetAttributes(attribute.String("messaging.message.id", ids[0]))
+	} else if len(ids) > 0 {
+		span.SetAttributes(attribute.StringSlice("karo.message.ids", ids))
+	}
+	endSpan(span, err)
+	return err
+}
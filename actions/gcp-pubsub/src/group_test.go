@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testAlertGroup = `{
+	"status": "firing",
+	"groupLabels": {"alertname": "DiskFull"},
+	"commonLabels": {"alertname": "DiskFull", "severity": "critical"},
+	"commonAnnotations": {"runbook": "https://runbooks.example.com/disk"},
+	"alerts": [
+		{"status": "firing", "labels": {"alertname": "DiskFull", "severity": "critical", "instance": "node-1"}},
+		{"status": "resolved", "labels": {"alertname": "DiskFull", "severity": "critical", "instance": "node-2"}, "annotations": {"runbook": "https://runbooks.example.com/node-2"}}
+	]
+}`
+
+func TestParseGroupMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{mode: "", want: groupModePerAlert},
+		{mode: "per-alert", want: groupModePerAlert},
+		{mode: "digest", want: groupModeDigest},
+		{mode: "first", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("ALERT_GROUP_MODE", tt.mode)
+			config := &Config{}
+			err := parseGroupMode(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGroupMode() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.GroupMode != tt.want {
+				t.Errorf("GroupMode = %q, want %q", config.GroupMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitAlertGroup(t *testing.T) {
+	t.Setenv("ALERT_JSON", testAlertGroup)
+	alertData, err := parseAlertData()
+	if err != nil {
+		t.Fatalf("parseAlertData() unexpected error: %v", err)
+	}
+
+	t.Run("per-alert", func(t *testing.T) {
+		var alerts []*AlertData
+		captureLog(t, func() { alerts = splitAlertGroup(&Config{GroupMode: groupModePerAlert}, alertData) })
+		if len(alerts) != 2 {
+			t.Fatalf("splitAlertGroup() returned %d alerts, want 2", len(alerts))
+		}
+		if alerts[0].Status != "firing" || alerts[0].Labels["instance"] != "node-1" || alerts[0].Annotations["runbook"] != "https://runbooks.example.com/disk" {
+			t.Errorf("first alert = %+v, want node-1 with the common runbook", alerts[0])
+		}
+		if alerts[1].Status != "resolved" || alerts[1].Annotations["runbook"] != "https://runbooks.example.com/node-2" {
+			t.Errorf("second alert = %+v, want its own status and runbook", alerts[1])
+		}
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		var alerts []*AlertData
+		captureLog(t, func() { alerts = splitAlertGroup(&Config{GroupMode: groupModeDigest}, alertData) })
+		if len(alerts) != 1 {
+			t.Fatalf("splitAlertGroup() returned %d alerts, want 1", len(alerts))
+		}
+
+		var message *PubSubMessage
+		captureLog(t, func() { message, err = prepareMessage(&Config{}, alerts[0]) })
+		if err != nil {
+			t.Fatalf("prepareMessage() unexpected error: %v", err)
+		}
+		if message.AlertName != "DiskFull" || message.Severity != "critical" || message.Status != "firing" {
+			t.Errorf("digest = %s/%s/%s, want the group's DiskFull/critical/firing", message.AlertName, message.Severity, message.Status)
+		}
+		if len(message.Alerts) != 2 || message.Alerts[1].Instance != "node-2" || message.Alerts[1].Status != "resolved" {
+			t.Errorf("digest alerts = %+v, want both alerts of the group", message.Alerts)
+		}
+	})
+
+	t.Run("single alert", func(t *testing.T) {
+		single := &AlertData{Status: "firing", Labels: map[string]string{"alertname": "DiskFull"}}
+		if alerts := splitAlertGroup(&Config{GroupMode: groupModePerAlert}, single); len(alerts) != 1 || alerts[0] != single {
+			t.Errorf("splitAlertGroup() = %v, want the alert itself", alerts)
+		}
+	})
+
+	t.Run("empty group", func(t *testing.T) {
+		var alerts []*AlertData
+		captureLog(t, func() { alerts = splitAlertGroup(&Config{}, &AlertData{alertGroup: alertGroup{Alerts: []AlertData{}}}) })
+		if len(alerts) != 0 {
+			t.Errorf("splitAlertGroup() returned %d alerts, want none", len(alerts))
+		}
+	})
+}
+
+func TestRouteTopics(t *testing.T) {
+	config := &Config{TopicID: "alerts", TopicIDResolved: "alerts-quiet"}
+	messages := []*PubSubMessage{
+		{AlertName: "a", Status: "firing"},
+		{AlertName: "b", Status: "resolved"},
+		{AlertName: "c", Status: "firing"},
+	}
+
+	var batches []*topicBatch
+	var err error
+	captureLog(t, func() { batches, err = routeTopics(config, messages) })
+	if err != nil {
+		t.Fatalf("routeTopics() unexpected error: %v", err)
+	}
+	if len(batches) != 2 || batches[0].config.TopicID != "alerts" || batches[1].config.TopicID != "alerts-quiet" {
+		t.Fatalf("routeTopics() = %+v, want alerts then alerts-quiet", batches)
+	}
+	if len(batches[0].messages) != 2 || batches[0].messages[1].AlertName != "c" || len(batches[1].messages) != 1 {
+		t.Errorf("batches hold %d and %d messages, want a, c and b", len(batches[0].messages), len(batches[1].messages))
+	}
+	if config.TopicID != "alerts" {
+		t.Errorf("config.TopicID = %q, want it unchanged", config.TopicID)
+	}
+
+	config.TopicID = ""
+	captureLog(t, func() { _, err = routeTopics(config, messages) })
+	if err == nil || !strings.Contains(err.Error(), "PUBSUB_TOPIC_ID_FIRING or PUBSUB_TOPIC_ID is required") {
+		t.Errorf("routeTopics() error = %v, want a missing topic error", err)
+	}
+}
+
+func TestPublishMessageGroup(t *testing.T) {
+	config := &Config{TopicID: "alerts", TimeoutSeconds: 10, RetryMaxAttempts: 1}
+	publisher := &fakePublisher{}
+	messages := []*PubSubMessage{
+		{AlertName: "DiskFull", Status: "firing", Instance: "node-1"},
+		{AlertName: "DiskFull", Status: "firing", Instance: "node-2"},
+	}
+
+	var err error
+	captureLog(t, func() { err = publishMessage(context.Background(), config, publisher, messages...) })
+	if err != nil {
+		t.Fatalf("publishMessage() unexpected error: %v", err)
+	}
+	if len(publisher.published) != 2 {
+		t.Fatalf("published %d messages, want 2", len(publisher.published))
+	}
+	for i, msg := range publisher.published {
+		var data PubSubMessage
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			t.Fatalf("data is not a JSON message: %v", err)
+		}
+		if data.Instance != messages[i].Instance {
+			t.Errorf("message %d instance = %q, want %q", i, data.Instance, messages[i].Instance)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// idempotencyKey derives a deterministic key for the alert, so a reaction
+// that karo re-invokes for the same alert sends the same key and the
+// receiver can drop the duplicate. With IDEMPOTENCY_KEY_FIELD set, value is
+// that field of the alert; when it is empty the key covers the alert name,
+// labels, start time and status instead. The labels tell apart the alerts of
+// a rule that started firing together, e.g. on several instances.
+func idempotencyKey(field, value, alertName string, labels map[string]string, startsAt, status string) string {
+	parts := []string{alertName, fmt.Sprintf("%016x", labelsFingerprint(labels)), startsAt, status}
+	if field != "" {
+		if value != "" {
+			parts = []string{value}
+		} else {
+			log.Printf("Warning: IDEMPOTENCY_KEY_FIELD '%s' is empty, deriving the idempotency key from alertName, labels, startsAt and status", field)
+		}
+	}
+
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		// Separate the parts so e.g. ("ab", "c") and ("a", "bc") differ
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logActionName identifies this action in structured logs
+const logActionName = "gcp-pubsub"
+
+// LOG_FORMAT values
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Log levels, derived from the "Debug: ", "Warning: " and "Error: " message
+// prefixes, and the LOG_LEVEL values
+const (
+	levelDebug = "debug"
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// slogLevels maps the log levels to their slog levels
+var slogLevels = map[string]slog.Level{
+	levelDebug: slog.LevelDebug,
+	levelInfo:  slog.LevelInfo,
+	levelWarn:  slog.LevelWarn,
+	levelError: slog.LevelError,
+}
+
+// jsonLogs is the structured log writer while LOG_FORMAT=json, nil otherwise
+var jsonLogs *jsonLogWriter
+
+// minLogLevel is the least severe level logged, set from LOG_LEVEL
+var minLogLevel = slog.LevelInfo
+
+// parseLogFormat validates LOG_FORMAT, defaulting to text
+func parseLogFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return logFormatText, nil
+	case logFormatText, logFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported LOG_FORMAT '%s', must be 'text' or 'json'", format)
+	}
+}
+
+// parseLogLevel validates LOG_LEVEL, defaulting to info
+func parseLogLevel(level string) (string, error) {
+	if level == "" {
+		return levelInfo, nil
+	}
+	if _, ok := slogLevels[level]; !ok {
+		return "", fmt.Errorf("unsupported LOG_LEVEL '%s', must be '%s', '%s', '%s' or '%s'", level, levelDebug, levelInfo, levelWarn, levelError)
+	}
+	return level, nil
+}
+
+// setupLogging drops log lines below level and, when format is json,
+// switches the standard logger to one slog JSON record per line. It runs
+// before the configuration is loaded so every line is structured; an
+// invalid format or level keeps the default and is reported by loadConfig.
+func setupLogging(format, level string) {
+	if parsed, err := parseLogLevel(level); err == nil {
+		minLogLevel = slogLevels[parsed]
+	}
+
+	if format == logFormatJSON {
+		jsonLogs = newJSONLogWriter(log.Writer())
+		log.SetFlags(0)
+		log.SetOutput(jsonLogs)
+		return
+	}
+	if minLogLevel != slog.LevelInfo {
+		log.SetOutput(&levelFilterWriter{out: log.Writer()})
+	}
+}
+
+// logLevelEnabled reports whether lines of the given level are logged
+func logLevelEnabled(level string) bool {
+	return slogLevels[level] >= minLogLevel
+}
+
+// setLogAlert adds the alert name and fingerprint to subsequent structured
+// log records
+func setLogAlert(message *PubSubMessage) {
+	if jsonLogs != nil {
+		jsonLogs.update(func(fields *logFields) {
+			fields.alertName = message.AlertName
+			fields.fingerprint = message.Fingerprint
+		})
+	}
+}
+
+// setLogTopic adds the project and topic published to to subsequent
+// structured log records
+func setLogTopic(projectID, topicID string) {
+	if jsonLogs != nil {
+		jsonLogs.update(func(fields *logFields) {
+			fields.projectID = projectID
+			fields.topic = topicID
+		})
+	}
+}
+
+// logPublished logs that a message was published, with its ID as the
+// messageId field of the structured record
+func logPublished(messageID string) {
+	if jsonLogs != nil {
+		jsonLogs.update(func(fields *logFields) { fields.messageID = messageID })
+		defer jsonLogs.update(func(fields *logFields) { fields.messageID = "" })
+	}
+	log.Printf("Message published successfully with ID: %s", messageID)
+}
+
+// parseLogLine splits a log message into its level, message and error. The
+// "Debug: ", "Warning: " and "Error: " prefixes set the level, and for
+// warnings and errors the text after the first ": " is the error, as in
+// "Failed to publish: %v".
+func parseLogLine(line string) (level, msg, errText string) {
+	level, msg = levelInfo, line
+	switch {
+	case strings.HasPrefix(line, "Debug: "):
+		return levelDebug, strings.TrimPrefix(line, "Debug: "), ""
+	case strings.HasPrefix(line, "Warning: "):
+		level, msg = levelWarn, strings.TrimPrefix(line, "Warning: ")
+	case strings.HasPrefix(line, "Error: "):
+		level, msg = levelError, strings.TrimPrefix(line, "Error: ")
+	default:
+		return level, msg, ""
+	}
+
+	if i := strings.Index(msg, ": "); i > 0 {
+		msg, errText = msg[:i], msg[i+2:]
+	}
+	return level, msg, errText
+}
+
+// levelFilterWriter drops plain text log lines below LOG_LEVEL
+type levelFilterWriter struct {
+	out io.Writer
+}
+
+func (w *levelFilterWriter) Write(p []byte) (int, error) {
+	// Skip the standard logger's date and time prefix to find the level
+	level, _, _ := parseLogLine(logTimestamp.ReplaceAllString(string(p), ""))
+	if !logLevelEnabled(level) {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// logFields are the correlation fields of structured log records, so Cloud
+// Logging queries can find every line of the run that handled an alert or
+// published to a topic
+type logFields struct {
+	projectID   string
+	topic       string
+	alertName   string
+	fingerprint string
+	messageID   string
+}
+
+// jsonLogWriter turns each standard log line into a slog JSON record with
+// the action name and the correlation fields known so far
+type jsonLogWriter struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	fields logFields
+}
+
+// newJSONLogWriter writes records with lowercase levels and UTC times, the
+// format of the records before they were written with slog
+func newJSONLogWriter(out io.Writer) *jsonLogWriter {
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			switch attr.Key {
+			case slog.TimeKey:
+				return slog.String(slog.TimeKey, attr.Value.Time().UTC().Format(time.RFC3339Nano))
+			case slog.LevelKey:
+				return slog.String(slog.LevelKey, strings.ToLower(attr.Value.String()))
+			}
+			return attr
+		},
+	})
+	return &jsonLogWriter{logger: slog.New(handler).With("action", logActionName)}
+}
+
+func (w *jsonLogWriter) update(set func(fields *logFields)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	set(&w.fields)
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	level, msg, errText := parseLogLine(strings.TrimRight(string(p), "\n"))
+	if !logLevelEnabled(level) {
+		return len(p), nil
+	}
+
+	var attrs []slog.Attr
+	for _, field := range []struct{ key, value string }{
+		{"projectId", w.fields.projectID},
+		{"topic", w.fields.topic},
+		{"alertName", w.fields.alertName},
+		{"fingerprint", w.fields.fingerprint},
+		{"messageId", w.fields.messageID},
+		{"error", errText},
+	} {
+		if field.value != "" {
+			attrs = append(attrs, slog.String(field.key, field.value))
+		}
+	}
+	w.logger.LogAttrs(context.Background(), slogLevels[level], msg, attrs...)
+	return len(p), nil
+}
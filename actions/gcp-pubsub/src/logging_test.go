@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogFormat(t *testing.T) {
+	for format, want := range map[string]string{"": "text", "text": "text", "json": "json", "JSON": ""} {
+		got, err := parseLogFormat(format)
+		if (err != nil) != (want == "") || got != want {
+			t.Errorf("parseLogFormat(%q) = %q, %v, want %q", format, got, err, want)
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for level, want := range map[string]string{"": "info", "debug": "debug", "warn": "warn", "error": "error", "warning": "", "INFO": ""} {
+		got, err := parseLogLevel(level)
+		if (err != nil) != (want == "") || got != want {
+			t.Errorf("parseLogLevel(%q) = %q, %v, want %q", level, got, err, want)
+		}
+	}
+}
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantLevel string
+		wantMsg   string
+		wantErr   string
+	}{
+		{line: "Configuration loaded: project test", wantLevel: "info", wantMsg: "Configuration loaded: project test"},
+		{line: "Warning: Failed to parse ALERT_JSON: unexpected end of JSON input", wantLevel: "warn", wantMsg: "Failed to parse ALERT_JSON", wantErr: "unexpected end of JSON input"},
+		{line: "Warning: Invalid LOG_CONFIG value 'yes', using default false", wantLevel: "warn", wantMsg: "Invalid LOG_CONFIG value 'yes', using default false"},
+		{line: "Error: Configuration error: GCP_PROJECT_ID environment variable is required", wantLevel: "error", wantMsg: "Configuration error", wantErr: "GCP_PROJECT_ID environment variable is required"},
+		{line: "Debug: Message attributes: {}", wantLevel: "debug", wantMsg: "Message attributes: {}"},
+	}
+
+	for _, tt := range tests {
+		level, msg, errText := parseLogLine(tt.line)
+		if level != tt.wantLevel || msg != tt.wantMsg || errText != tt.wantErr {
+			t.Errorf("parseLogLine(%q) = %q, %q, %q, want %q, %q, %q", tt.line, level, msg, errText, tt.wantLevel, tt.wantMsg, tt.wantErr)
+		}
+	}
+}
+
+// logRecord is a structured log line
+type logRecord struct {
+	Time        string `json:"time"`
+	Level       string `json:"level"`
+	Msg         string `json:"msg"`
+	Action      string `json:"action"`
+	ProjectID   string `json:"projectId"`
+	Topic       string `json:"topic"`
+	AlertName   string `json:"alertName"`
+	Fingerprint string `json:"fingerprint"`
+	MessageID   string `json:"messageId"`
+	Error       string `json:"error"`
+}
+
+// useTestLogging sets up logging with format and level for the duration of
+// the test, returning what is logged
+func useTestLogging(t *testing.T, format, level string) *bytes.Buffer {
+	t.Helper()
+
+	var out bytes.Buffer
+	originalWriter, originalFlags := log.Writer(), log.Flags()
+	log.SetOutput(&out)
+	t.Cleanup(func() {
+		log.SetOutput(originalWriter)
+		log.SetFlags(originalFlags)
+		jsonLogs = nil
+		minLogLevel = slog.LevelInfo
+	})
+
+	setupLogging(format, level)
+	return &out
+}
+
+// logRecords decodes the JSON log lines in out
+func logRecords(t *testing.T, out *bytes.Buffer) []logRecord {
+	t.Helper()
+
+	var records []logRecord
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record logRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line is not JSON: %v\n%s", err, line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSetupLoggingJSON(t *testing.T) {
+	out := useTestLogging(t, "json", "")
+
+	log.Println("Starting up")
+	setLogAlert(&PubSubMessage{AlertName: "DiskFull"})
+	log.Printf("Warning: Failed to publish: %s", "quota exceeded")
+
+	records := logRecords(t, out)
+	if len(records) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(records), out.String())
+	}
+	if records[0].Level != "info" || records[0].Msg != "Starting up" || records[0].Action != logActionName || records[0].AlertName != "" {
+		t.Errorf("first record = %+v", records[0])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, records[0].Time); err != nil || !strings.HasSuffix(records[0].Time, "Z") {
+		t.Errorf("record time = %q, want RFC 3339 in UTC", records[0].Time)
+	}
+	want := logRecord{Level: "warn", Msg: "Failed to publish", Action: logActionName, AlertName: "DiskFull", Error: "quota exceeded"}
+	want.Time = records[1].Time
+	if records[1] != want {
+		t.Errorf("second record = %+v, want %+v", records[1], want)
+	}
+}
+
+func TestSetupLoggingJSONCorrelationFields(t *testing.T) {
+	out := useTestLogging(t, "json", "")
+
+	setLogTopic("test-project", "alerts")
+	setLogAlert(&PubSubMessage{AlertName: "DiskFull", Fingerprint: "c0ffee"})
+	logPublished("11487402538417321")
+	log.Println("Message published successfully to Pub/Sub")
+
+	records := logRecords(t, out)
+	if len(records) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(records), out.String())
+	}
+	want := logRecord{
+		Level:       "info",
+		Msg:         "Message published successfully with ID: 11487402538417321",
+		Action:      logActionName,
+		ProjectID:   "test-project",
+		Topic:       "alerts",
+		AlertName:   "DiskFull",
+		Fingerprint: "c0ffee",
+		MessageID:   "11487402538417321",
+	}
+	want.Time = records[0].Time
+	if records[0] != want {
+		t.Errorf("published record = %+v, want %+v", records[0], want)
+	}
+	if records[1].MessageID != "" || records[1].AlertName != "DiskFull" {
+		t.Errorf("later record = %+v, want the alert without the message ID", records[1])
+	}
+}
+
+func TestSetupLoggingLevel(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		t.Run(format, func(t *testing.T) {
+			out := useTestLogging(t, format, "warn")
+
+			log.Println("Debug: Message attributes: {}")
+			log.Println("Starting up")
+			log.Printf("Warning: Failed to publish: %s", "quota exceeded")
+			log.Printf("Error: Configuration error: %s", "GCP_PROJECT_ID environment variable is required")
+
+			if got := strings.Count(strings.TrimSpace(out.String()), "\n") + 1; got != 2 {
+				t.Errorf("logged %d lines, want the warning and the error: %s", got, out.String())
+			}
+			if strings.Contains(out.String(), "Starting up") || strings.Contains(out.String(), "Message attributes") {
+				t.Errorf("lines below LOG_LEVEL were logged: %s", out.String())
+			}
+		})
+	}
+}
+
+func TestSetupLoggingDebug(t *testing.T) {
+	out := useTestLogging(t, "json", "debug")
+
+	log.Println("Debug: Message attributes: {}")
+	if records := logRecords(t, out); records[0].Level != "debug" || records[0].Msg != "Message attributes: {}" {
+		t.Errorf("record = %+v, want a debug record", records[0])
+	}
+}
+
+func TestSetupLoggingTextIsUnchanged(t *testing.T) {
+	originalWriter := log.Writer()
+	setupLogging("text", "")
+	if log.Writer() != originalWriter || jsonLogs != nil {
+		t.Error("text format should leave the standard logger unchanged")
+	}
+}
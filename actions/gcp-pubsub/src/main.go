@@ -3,23 +3,30 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"cloud.google.com/go/pubsub/v2"
 	"google.golang.org/api/option"
 )
 
-// AlertData represents the structure of alert information
+// AlertData represents the structure of alert information. When ALERT_JSON
+// is an Alertmanager group notification, the group's fields are set too.
 type AlertData struct {
 	Status      string            `json:"status"`
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
 	StartsAt    string            `json:"startsAt,omitempty"`
 	EndsAt      string            `json:"endsAt,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	alertGroup
 }
 
 // PubSubMessage represents the message structure sent to Pub/Sub
@@ -32,25 +39,148 @@ type PubSubMessage struct {
 	Description string            `json:"description"`
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt,omitempty"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
 	Timestamp   string            `json:"timestamp"`
 	Source      string            `json:"source"`
+	Truncated   bool              `json:"truncated,omitempty"`
+	Alerts      []*PubSubMessage  `json:"alerts,omitempty"`
 }
 
+// maxMessageBytes is the largest message data Pub/Sub accepts (10 MB)
+const maxMessageBytes = 10 * 1000 * 1000
+
 type Config struct {
-	ProjectID          string
-	TopicID            string
-	ServiceAccountPath string
-	TimeoutSeconds     int
-	Source             string
+	ProjectID            string                `json:"GCP_PROJECT_ID"`
+	TopicID              string                `json:"PUBSUB_TOPIC_ID"`
+	TopicIDFiring        string                `json:"PUBSUB_TOPIC_ID_FIRING"`
+	TopicIDResolved      string                `json:"PUBSUB_TOPIC_ID_RESOLVED"`
+	Routes               []topicRoute          `json:"ROUTES_JSON"`
+	ServiceAccountPath   string                `json:"GOOGLE_APPLICATION_CREDENTIALS"`
+	EmulatorHost         string                `json:"PUBSUB_EMULATOR_HOST"`
+	Endpoint             string                `json:"PUBSUB_ENDPOINT"`
+	TopicSchema          bool                  `json:"TOPIC_SCHEMA"`
+	SchemaRegistryURL    string                `json:"SCHEMA_REGISTRY_URL"`
+	RegistrySubject      string                `json:"SCHEMA_REGISTRY_SUBJECT"`
+	RegistryVersion      string                `json:"SCHEMA_REGISTRY_VERSION"`
+	RegistryUsername     string                `json:"SCHEMA_REGISTRY_USERNAME"`
+	RegistryPassword     string                `json:"SCHEMA_REGISTRY_PASSWORD"`
+	Schema               *topicSchema          `json:"-"`
+	MessageTemplateFile  string                `json:"MESSAGE_TEMPLATE_FILE"`
+	MessageTemplate      *template.Template    `json:"-"`
+	Format               string                `json:"FORMAT"`
+	CloudEventsSource    string                `json:"CLOUDEVENTS_SOURCE"`
+	Compress             string                `json:"COMPRESS"`
+	TimeoutSeconds       int                   `json:"TIMEOUT_SECONDS"`
+	RetryMaxAttempts     int                   `json:"RETRY_MAX_ATTEMPTS"`
+	MaxConcurrency       int                   `json:"MAX_CONCURRENCY"`
+	RateLimitPerSecond   float64               `json:"RATE_LIMIT_PER_SECOND"`
+	DelayThresholdMs     int                   `json:"PUBLISH_DELAY_THRESHOLD_MS"`
+	CountThreshold       int                   `json:"PUBLISH_COUNT_THRESHOLD"`
+	ByteThreshold        int                   `json:"PUBLISH_BYTE_THRESHOLD"`
+	MaxOutstandingMsgs   int                   `json:"PUBLISH_MAX_OUTSTANDING_MESSAGES"`
+	MaxOutstandingBytes  int                   `json:"PUBLISH_MAX_OUTSTANDING_BYTES"`
+	LimitExceeded        string                `json:"PUBLISH_LIMIT_EXCEEDED"`
+	MaxPayloadBytes      int                   `json:"MAX_PAYLOAD_BYTES"`
+	OnOversize           string                `json:"ON_OVERSIZE"`
+	Source               string                `json:"MESSAGE_SOURCE"`
+	TimestampSource      string                `json:"TIMESTAMP_SOURCE"`
+	ComputeFingerprint   bool                  `json:"COMPUTE_FINGERPRINT"`
+	IdempotencyKeyField  string                `json:"IDEMPOTENCY_KEY_FIELD"`
+	OrderingKeyField     string                `json:"ORDERING_KEY_FIELD"`
+	OrderingConditions   []FieldMatcher        `json:"ORDERING_CONDITION"`
+	AttributeLabels      []string              `json:"PUBSUB_ATTRIBUTE_LABELS"`
+	AttributePrefix      string                `json:"ATTRIBUTE_PREFIX"`
+	AttributesMap        map[string]string     `json:"ATTRIBUTES_MAP_JSON"`
+	AllLabelAttributes   bool                  `json:"ATTRIBUTES_ALL_LABELS"`
+	MissingAlertNameMode string                `json:"MISSING_ALERTNAME_MODE"`
+	AlertNameLabels      []string              `json:"ALERTNAME_FROM_LABELS"`
+	AllowedSeverities    []string              `json:"ALLOWED_SEVERITIES"`
+	AllowedStatuses      []string              `json:"ALLOWED_STATUSES"`
+	OnInvalid            string                `json:"ON_INVALID"`
+	EnrichmentFile       string                `json:"ENRICHMENT_FILE"`
+	EnrichmentKeyField   string                `json:"ENRICHMENT_KEY_FIELD"`
+	Enrichment           map[string]Enrichment `json:"-"`
+	StrictEnv            bool                  `json:"STRICT_ENV"`
+	LogFormat            string                `json:"LOG_FORMAT"`
+	LogLevel             string                `json:"LOG_LEVEL"`
+	LogConfig            bool                  `json:"LOG_CONFIG"`
+	LogPayload           bool                  `json:"LOG_PAYLOAD"`
+	RedactFields         []string              `json:"REDACT_FIELDS"`
+	MetricsEnabled       bool                  `json:"METRICS_ENABLED"`
+	DedupRedisURL        string                `json:"DEDUP_REDIS_URL"`
+	DedupFile            string                `json:"DEDUP_FILE"`
+	DedupTTLSeconds      int                   `json:"DEDUP_TTL_SECONDS"`
+	DedupFailureMode     string                `json:"DEDUP_FAILURE_MODE"`
+	PushgatewayURL       string                `json:"PUSHGATEWAY_URL"`
+	DryRun               bool                  `json:"DRY_RUN"`
+	Sink                 string                `json:"SINK"`
+	SinkDir              string                `json:"SINK_DIR"`
+	GroupMode            string                `json:"ALERT_GROUP_MODE"`
+	SpoolFile            string                `json:"SPOOL_FILE"`
+	Replay               bool                  `json:"REPLAY"`
+	OutputFile           string                `json:"OUTPUT_FILE"`
+	ResultJSON           bool                  `json:"RESULT_JSON"`
+	Validate             bool                  `json:"VALIDATE"`
+}
+
+// FieldMatcher is a single "field=value" or "field!=value" condition
+// evaluated against the built message
+type FieldMatcher struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Negate bool   `json:"negate,omitempty"`
+}
+
+// exitCodeCancelled is the exit code used when the action is stopped by
+// SIGTERM or SIGINT, following the shell convention of 128+SIGINT
+const exitCodeCancelled = 130
+
+// newSignalContext returns a context that is cancelled when the process
+// receives SIGTERM (e.g. pod eviction) or SIGINT
+func newSignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 }
 
 func main() {
+	// Structure logs first so every line, including config errors, uses the format
+	setupLogging(os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+
 	log.Println("Starting GCP Pub/Sub publisher...")
 
+	ctx, stop := newSignalContext()
+	defer stop()
+
+	// Bridge logs to OpenTelemetry when an OTLP endpoint is configured
+	ctx, err := setupTelemetry(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to set up OpenTelemetry, logging to stderr only: %v", err)
+	}
+	defer func() { shutdownTelemetry() }()
+
+	// Push the run's outcome on exit when a Pushgateway is configured
+	setupPushgateway(os.Getenv("PUSHGATEWAY_URL"))
+	defer reactionMetrics.push(true)
+
 	// Load configuration
 	config, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Configuration error: %v", err)
+		fatalf("Configuration error: %v", err)
+	}
+
+	setLogTopic(config.ProjectID, config.TopicID)
+	if config.LogConfig {
+		logResolvedConfig(config)
+	}
+
+	setupMetrics(config.MetricsEnabled)
+
+	// Publish the messages spooled by earlier runs first if configured
+	if config.Replay {
+		if err := replaySpool(ctx, config, newSpoolPublisher); err != nil {
+			log.Printf("Warning: Failed to replay SPOOL_FILE: %v", err)
+		}
 	}
 
 	// Parse alert data
@@ -59,39 +189,222 @@ func main() {
 		log.Printf("Warning: Failed to parse alert data: %v", err)
 	}
 
-	// Build message payload
-	message := buildMessage(alertData, config.Source)
+	// Build a message for each alert to publish, or one digest of a group
+	var messages []*PubSubMessage
+	for _, alert := range splitAlertGroup(config, alertData) {
+		message, err := prepareMessage(config, alert)
+		if err != nil {
+			fatalf("Invalid alert: %v", err)
+		}
+		if message != nil {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+	setLogAlert(messages[0])
+	setSpanAlert(ctx, messages[0])
+	reactionMetrics.setAlertStatus(messages[0].Status)
+
+	// Route each alert to the topic configured for its status
+	batches, err := routeTopics(config, messages)
+	if err != nil {
+		fatalf("Configuration error: %v", err)
+	}
+
+	// Log what would be sent instead of contacting Pub/Sub if configured
+	if config.DryRun {
+		for _, batch := range batches {
+			for _, message := range batch.messages {
+				if err := logDryRun(batch.config, message); err != nil {
+					fatalf("Dry run failed: %v", err)
+				}
+			}
+		}
+		log.Println("Dry run complete, nothing was sent")
+		return
+	}
+
+	// Write to the local file sink instead of Pub/Sub if configured
+	if config.Sink == sinkFile {
+		for _, batch := range batches {
+			for _, message := range batch.messages {
+				path, err := writeFileSink(batch.config, message)
+				if err != nil {
+					fatalf("Failed to write message to file sink: %v", err)
+				}
+				log.Printf("Message written to file sink: %s", path)
+			}
+		}
+		return
+	}
+
+	// Skip alerts another run already handled within DEDUP_TTL_SECONDS
+	var claims []dedupClaim
+	pending := 0
+	for _, batch := range batches {
+		if claims, err = claimMessages(ctx, batch, claims); err != nil {
+			releaseClaims(claims)
+			fatalf("Deduplication failed: %v", err)
+		}
+		pending += len(batch.messages)
+	}
+	defer closeClaims(claims)
+	if pending == 0 {
+		return
+	}
 
 	// Publish to Pub/Sub
-	if err := publishMessage(config, message); err != nil {
-		log.Fatalf("Failed to publish message: %v", err)
+	start := time.Now()
+	err = publishBatches(ctx, batches)
+	reportResult(config, batches, err, time.Since(start))
+	reactionMetrics.observeCall(start)
+	clientMetrics.flush(context.Background())
+	if err != nil {
+		releaseClaims(claims)
+		if ctx.Err() != nil {
+			log.Printf("Publishing cancelled by signal: %v", err)
+			exit(exitCodeCancelled)
+		}
+		fatalf("Failed to publish message: %v", err)
 	}
 
+	if pending > 1 {
+		log.Printf("%d messages published successfully to Pub/Sub", pending)
+		return
+	}
 	log.Println("Message published successfully to Pub/Sub")
 }
 
+// prepareMessage builds the message for an alert and checks it, returning
+// nil for an alert that MISSING_ALERTNAME_MODE or ON_INVALID skips. The
+// message of a digest carries a message for every alert of the group.
+func prepareMessage(config *Config, alertData *AlertData) (*PubSubMessage, error) {
+	message := newMessage(config, alertData)
+	if alertData != nil {
+		for i := range alertData.Alerts {
+			message.Alerts = append(message.Alerts, newMessage(config, &alertData.Alerts[i]))
+		}
+	}
+
+	// Handle alerts without an alertname label
+	alertName, send, err := ensureAlertName(config, message.AlertName, message.Labels)
+	if err != nil {
+		return nil, err
+	}
+	if !send {
+		log.Println("Skipping alert without an alertname label (MISSING_ALERTNAME_MODE=skip)")
+		return nil, nil
+	}
+	message.AlertName = alertName
+
+	// Reject alerts with a severity or status outside the allowed sets
+	if err := validateAlert(config, message.Severity, message.Status); err != nil {
+		if config.OnInvalid == onInvalidDrop {
+			log.Printf("Dropping invalid alert (ON_INVALID=drop): %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// newMessage enriches the alert and builds its message payload
+func newMessage(config *Config, alertData *AlertData) *PubSubMessage {
+	// Merge static enrichment data into the alert
+	if config.EnrichmentFile != "" {
+		if enrichAlert(alertData, config.EnrichmentKeyField, config.Enrichment) {
+			log.Printf("Alert enriched from %s", config.EnrichmentFile)
+		}
+	}
+
+	message := buildMessage(alertData, config.Source, config.TimestampSource)
+	message.Fingerprint = alertFingerprint(message.Fingerprint, message.Labels, config.ComputeFingerprint)
+	return message
+}
+
 func loadConfig() (*Config, error) {
 	config := &Config{
 		ProjectID:          os.Getenv("GCP_PROJECT_ID"),
 		TopicID:            os.Getenv("PUBSUB_TOPIC_ID"),
+		TopicIDFiring:      os.Getenv("PUBSUB_TOPIC_ID_FIRING"),
+		TopicIDResolved:    os.Getenv("PUBSUB_TOPIC_ID_RESOLVED"),
 		ServiceAccountPath: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		EmulatorHost:       os.Getenv("PUBSUB_EMULATOR_HOST"),
+		Endpoint:           os.Getenv("PUBSUB_ENDPOINT"),
 		TimeoutSeconds:     30, // default
 		Source:             "karo",
 	}
 
+	// Parse STRICT_ENV first, since it decides how malformed values below are handled
+	strict, err := parseStrictEnv()
+	if err != nil {
+		return nil, err
+	}
+	config.StrictEnv = strict
+
 	// Validate required fields
 	if config.ProjectID == "" {
 		return nil, fmt.Errorf("GCP_PROJECT_ID environment variable is required")
 	}
-	if config.TopicID == "" {
-		return nil, fmt.Errorf("PUBSUB_TOPIC_ID environment variable is required")
+
+	// Parse the optional routing table, which may stand in for PUBSUB_TOPIC_ID
+	routes, err := parseRoutes(os.Getenv("ROUTES_JSON"))
+	if err != nil {
+		return nil, err
+	}
+	config.Routes = routes
+	if config.TopicID == "" && config.TopicIDFiring == "" && config.TopicIDResolved == "" && len(config.Routes) == 0 {
+		return nil, fmt.Errorf("PUBSUB_TOPIC_ID (or PUBSUB_TOPIC_ID_FIRING/PUBSUB_TOPIC_ID_RESOLVED or ROUTES_JSON) environment variable is required")
+	}
+	if config.EmulatorHost != "" && config.Endpoint != "" {
+		return nil, fmt.Errorf("PUBSUB_EMULATOR_HOST and PUBSUB_ENDPOINT are mutually exclusive")
+	}
+
+	// Parse whether to encode messages with the topic's schema
+	if err := parseSchemaConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional schema registry messages are encoded for
+	if err := parseRegistryConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional template the message data is rendered from
+	if err := parseTemplateConfig(config); err != nil {
+		return nil, err
 	}
 
 	// Parse optional timeout
-	if timeoutStr := os.Getenv("TIMEOUT_SECONDS"); timeoutStr != "" {
-		if timeout, err := strconv.Atoi(timeoutStr); err == nil {
-			config.TimeoutSeconds = timeout
-		}
+	if err := envInt(config.StrictEnv, "TIMEOUT_SECONDS", &config.TimeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	// Parse optional publish retry limit
+	config.RetryMaxAttempts = defaultRetryMaxAttempts
+	if err := envInt(config.StrictEnv, "RETRY_MAX_ATTEMPTS", &config.RetryMaxAttempts); err != nil {
+		return nil, err
+	}
+	if config.RetryMaxAttempts < 1 {
+		return nil, fmt.Errorf("RETRY_MAX_ATTEMPTS must be at least 1, got %d", config.RetryMaxAttempts)
+	}
+
+	// Parse the limits on parallel and rate of publishes
+	if err := parseLimitsConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the client's batching and flow control settings
+	if err := parseFlowControlConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the message size limit, Pub/Sub's maximum by default
+	config.MaxPayloadBytes = maxMessageBytes
+	if err := parseOversizeConfig(config); err != nil {
+		return nil, err
 	}
 
 	// Override source if provided
@@ -99,14 +412,245 @@ func loadConfig() (*Config, error) {
 		config.Source = source
 	}
 
+	// Parse the source of the payload timestamp
+	timestampSource, err := parseTimestampSource(os.Getenv("TIMESTAMP_SOURCE"))
+	if err != nil {
+		return nil, err
+	}
+	config.TimestampSource = timestampSource
+
+	// Parse whether to compute missing fingerprints from the labels
+	config.ComputeFingerprint = true
+	if err := envBool(config.StrictEnv, "COMPUTE_FINGERPRINT", &config.ComputeFingerprint); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional field the idempotency key is derived from
+	config.IdempotencyKeyField = os.Getenv("IDEMPOTENCY_KEY_FIELD")
+	if config.IdempotencyKeyField != "" && !isMessageField(config.IdempotencyKeyField) {
+		return nil, fmt.Errorf("invalid IDEMPOTENCY_KEY_FIELD: unknown message field '%s'", config.IdempotencyKeyField)
+	}
+
+	// Parse optional ordering key configuration
+	config.OrderingKeyField = os.Getenv("ORDERING_KEY_FIELD")
+	if config.OrderingKeyField != "" && !isMessageField(config.OrderingKeyField) {
+		return nil, fmt.Errorf("invalid ORDERING_KEY_FIELD: unknown message field '%s'", config.OrderingKeyField)
+	}
+	if conditionStr := os.Getenv("ORDERING_CONDITION"); conditionStr != "" {
+		if config.OrderingKeyField == "" {
+			return nil, fmt.Errorf("ORDERING_CONDITION requires ORDERING_KEY_FIELD to be set")
+		}
+		conditions, err := parseFieldMatchers(conditionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ORDERING_CONDITION: %w", err)
+		}
+		config.OrderingConditions = conditions
+	}
+
+	// Parse optional custom attributes copied from labels, annotations and
+	// other message fields
+	config.AttributePrefix = os.Getenv("ATTRIBUTE_PREFIX")
+	if labelsStr := os.Getenv("PUBSUB_ATTRIBUTE_LABELS"); labelsStr != "" {
+		attributeLabels, err := parseAttributeLabels(labelsStr, config.AttributePrefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PUBSUB_ATTRIBUTE_LABELS: %w", err)
+		}
+		config.AttributeLabels = attributeLabels
+	}
+	attributesMap, err := parseAttributesMap(os.Getenv("ATTRIBUTES_MAP_JSON"))
+	if err != nil {
+		return nil, err
+	}
+	config.AttributesMap = attributesMap
+	if err := envBool(config.StrictEnv, "ATTRIBUTES_ALL_LABELS", &config.AllLabelAttributes); err != nil {
+		return nil, err
+	}
+
+	// Parse how Alertmanager groups are published
+	if err := parseGroupMode(config); err != nil {
+		return nil, err
+	}
+
+	// Parse whether to publish alerts as CloudEvents or attributes only
+	if err := parseFormatConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse whether to compress the message data
+	if err := parseCompressConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse handling of alerts without an alertname label
+	mode, err := parseMissingAlertNameMode(os.Getenv("MISSING_ALERTNAME_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	config.MissingAlertNameMode = mode
+	config.AlertNameLabels = parseLabelList(os.Getenv("ALERTNAME_FROM_LABELS"))
+
+	// Parse optional severity and status validation
+	if err := parseValidationConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional enrichment lookup file
+	config.EnrichmentFile = os.Getenv("ENRICHMENT_FILE")
+	config.EnrichmentKeyField = os.Getenv("ENRICHMENT_KEY_FIELD")
+	if config.EnrichmentKeyField == "" {
+		config.EnrichmentKeyField = defaultEnrichmentKeyField
+	}
+	if err := validateEnrichmentKeyField(config.EnrichmentKeyField); err != nil {
+		return nil, err
+	}
+	if config.EnrichmentFile != "" {
+		enrichment, err := loadEnrichmentFile(config.EnrichmentFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Enrichment = enrichment
+	}
+
+	// Parse log config flag
+	if err := envBool(config.StrictEnv, "LOG_CONFIG", &config.LogConfig); err != nil {
+		return nil, err
+	}
+
+	// Parse payload logging options; the payload is logged by default
+	config.LogPayload = true
+	if err := envBool(config.StrictEnv, "LOG_PAYLOAD", &config.LogPayload); err != nil {
+		return nil, err
+	}
+	config.RedactFields = parseLabelList(os.Getenv("REDACT_FIELDS"))
+
+	// Parse log format and level, applied by setupLogging at startup
+	logFormat, err := parseLogFormat(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFormat = logFormat
+	logLevel, err := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return nil, err
+	}
+	config.LogLevel = logLevel
+
+	// PUSHGATEWAY_URL is applied before the configuration is loaded, see
+	// setupPushgateway; it is kept here so LOG_CONFIG shows it
+	config.PushgatewayURL = os.Getenv("PUSHGATEWAY_URL")
+
+	// Parse metrics flag
+	if err := envBool(config.StrictEnv, "METRICS_ENABLED", &config.MetricsEnabled); err != nil {
+		return nil, err
+	}
+
+	// Parse optional sink override
+	config.Sink = os.Getenv("SINK")
+	config.SinkDir = os.Getenv("SINK_DIR")
+	if err := validateSink(config.Sink, config.SinkDir); err != nil {
+		return nil, err
+	}
+
+	// Parse dry-run flag
+	if err := envBool(config.StrictEnv, "DRY_RUN", &config.DryRun); err != nil {
+		return nil, err
+	}
+	if config.DryRun && config.Sink != "" {
+		return nil, fmt.Errorf("DRY_RUN and SINK are mutually exclusive")
+	}
+
+	// Parse optional dead-letter spool settings
+	if err := parseSpoolConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional pre-flight check of the topic
+	if err := parsePreflightConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional publish result output
+	if err := parseOutputConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional deduplication settings
+	if err := parseDedupConfig(config); err != nil {
+		return nil, err
+	}
+
 	log.Printf("Configuration loaded - Project: %s, Topic: %s, Timeout: %ds",
 		config.ProjectID, config.TopicID, config.TimeoutSeconds)
 
 	return config, nil
 }
 
+// parseFieldMatchers parses a comma-separated list of "field=value" or
+// "field!=value" conditions, e.g. "labels.severity=critical,status=firing"
+func parseFieldMatchers(spec string) ([]FieldMatcher, error) {
+	var matchers []FieldMatcher
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		// The first "=" decides the operator, so values may contain "!=" or "="
+		idx := strings.Index(part, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("condition '%s' must be in the form field=value or field!=value", part)
+		}
+
+		matcher := FieldMatcher{Value: strings.TrimSpace(part[idx+1:])}
+		if idx > 0 && part[idx-1] == '!' {
+			matcher.Field, matcher.Negate = part[:idx-1], true
+		} else {
+			matcher.Field = part[:idx]
+		}
+
+		matcher.Field = strings.TrimSpace(matcher.Field)
+		if matcher.Field == "" {
+			return nil, fmt.Errorf("condition '%s' has an empty field name", part)
+		}
+		if !isMessageField(matcher.Field) {
+			return nil, fmt.Errorf("condition '%s' references unknown message field '%s'", part, matcher.Field)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// logResolvedConfig logs the effective configuration as a single JSON line,
+// masking the service account credentials path, the schema registry
+// password and the Redis URL, which may embed a password
+func logResolvedConfig(config *Config) {
+	redacted := *config
+	if redacted.ServiceAccountPath != "" {
+		redacted.ServiceAccountPath = "***"
+	}
+	if redacted.RegistryPassword != "" {
+		redacted.RegistryPassword = "***"
+	}
+	if redacted.DedupRedisURL != "" {
+		redacted.DedupRedisURL = "***"
+	}
+	if redacted.PushgatewayURL != "" {
+		redacted.PushgatewayURL = "***"
+	}
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		log.Printf("Warning: Failed to marshal configuration: %v", err)
+		return
+	}
+	log.Printf("Resolved configuration: %s", string(data))
+}
+
 func parseAlertData() (*AlertData, error) {
-	alertJSON := os.Getenv("ALERT_JSON")
+	alertJSON, err := readAlertJSON()
+	if err != nil {
+		return nil, err
+	}
 	if alertJSON == "" {
 		log.Println("No ALERT_JSON provided, using individual environment variables")
 		return nil, nil
@@ -120,10 +664,9 @@ func parseAlertData() (*AlertData, error) {
 	return &alertData, nil
 }
 
-func buildMessage(alert *AlertData, source string) *PubSubMessage {
+func buildMessage(alert *AlertData, source, timestampSource string) *PubSubMessage {
 	message := &PubSubMessage{
-		Source:    source,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Source: source,
 	}
 
 	// If we have parsed alert data, use it
@@ -131,6 +674,9 @@ func buildMessage(alert *AlertData, source string) *PubSubMessage {
 		message.Status = alert.Status
 		message.Labels = alert.Labels
 		message.Annotations = alert.Annotations
+		message.StartsAt = alert.StartsAt
+		message.EndsAt = alert.EndsAt
+		message.Fingerprint = alert.Fingerprint
 
 		if alert.Labels != nil {
 			message.AlertName = alert.Labels["alertname"]
@@ -163,40 +709,173 @@ func buildMessage(alert *AlertData, source string) *PubSubMessage {
 	if message.Description == "" {
 		message.Description = os.Getenv("ALERT_DESCRIPTION")
 	}
+	if message.StartsAt == "" {
+		message.StartsAt = os.Getenv("ALERT_STARTS_AT")
+	}
+	if message.EndsAt == "" {
+		message.EndsAt = os.Getenv("ALERT_ENDS_AT")
+	}
+	if message.Fingerprint == "" {
+		message.Fingerprint = os.Getenv("ALERT_FINGERPRINT")
+	}
+	message.Timestamp = alertTimestamp(timestampSource, message.Status, message.StartsAt, message.EndsAt)
 
 	return message
 }
 
-func publishMessage(config *Config, message *PubSubMessage) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.TimeoutSeconds)*time.Second)
-	defer cancel()
+// isMessageField reports whether extractMessageField can resolve the field path
+func isMessageField(fieldPath string) bool {
+	for _, prefix := range []string{"labels.", "annotations."} {
+		if key, found := strings.CutPrefix(fieldPath, prefix); found {
+			return key != ""
+		}
+	}
 
-	// Create client options
-	var clientOptions []option.ClientOption
-	if config.ServiceAccountPath != "" {
-		clientOptions = append(clientOptions, option.WithCredentialsFile(config.ServiceAccountPath))
+	switch fieldPath {
+	case "alertName", "status", "severity", "instance", "source", "fingerprint":
+		return true
 	}
-	// If no service account file is provided, the client will use Application Default Credentials
+	return false
+}
 
-	// Create Pub/Sub client
-	client, err := pubsub.NewClient(ctx, config.ProjectID, clientOptions...)
-	if err != nil {
-		return fmt.Errorf("failed to create Pub/Sub client: %w", err)
+// extractMessageField resolves a dot-notation field path against the built message
+// Examples: "alertName", "severity", "labels.instance", "annotations.team"
+func extractMessageField(message *PubSubMessage, fieldPath string) string {
+	if key, found := strings.CutPrefix(fieldPath, "labels."); found {
+		return message.Labels[key]
+	}
+	if key, found := strings.CutPrefix(fieldPath, "annotations."); found {
+		return message.Annotations[key]
 	}
-	defer client.Close()
 
-	// Get topic reference
-	publisher := client.Publisher(config.TopicID)
+	switch fieldPath {
+	case "alertName":
+		return message.AlertName
+	case "status":
+		return message.Status
+	case "severity":
+		return message.Severity
+	case "instance":
+		return message.Instance
+	case "source":
+		return message.Source
+	case "fingerprint":
+		return message.Fingerprint
+	}
+	return ""
+}
 
-	// Convert message to JSON
-	messageData, err := json.Marshal(message)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+// matchesAll reports whether every matcher holds for the message
+func matchesAll(message *PubSubMessage, matchers []FieldMatcher) bool {
+	for _, m := range matchers {
+		if (extractMessageField(message, m.Field) == m.Value) == m.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveOrderingKey returns the ordering key for the message, or an empty
+// string when ordering is not configured or the ordering condition does not hold.
+// An empty key for a message that should be ordered is an error, since
+// publishing it unordered would silently break the consumer's ordering.
+func resolveOrderingKey(config *Config, message *PubSubMessage) (string, error) {
+	if config.OrderingKeyField == "" {
+		return "", nil
+	}
+
+	if !matchesAll(message, config.OrderingConditions) {
+		log.Println("Ordering condition not met, publishing message unordered")
+		return "", nil
+	}
+
+	orderingKey := extractMessageField(message, config.OrderingKeyField)
+	if orderingKey == "" {
+		return "", fmt.Errorf("ordering key field '%s' is empty", config.OrderingKeyField)
+	}
+	return orderingKey, nil
+}
+
+// builtinAttributes are always set on published messages and cannot be
+// overridden by custom attributes
+var builtinAttributes = []string{"alertName", "status", "severity", "source", "timestamp", "idempotencyKey"}
+
+// parseAttributeLabels parses a comma-separated list of label or annotation
+// keys and checks that the resulting attribute names are usable
+func parseAttributeLabels(spec, prefix string) ([]string, error) {
+	var keys []string
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		name := prefix + key
+		if strings.HasPrefix(name, "goog") {
+			return nil, fmt.Errorf("attribute '%s' uses the reserved 'goog' prefix", name)
+		}
+		if isBuiltinAttribute(name) {
+			return nil, fmt.Errorf("attribute '%s' conflicts with a built-in attribute, set ATTRIBUTE_PREFIX", name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// addCustomAttributes copies the configured keys from the alert labels, or
+// the annotations if no label exists, into the message attributes, after
+// every label with ATTRIBUTES_ALL_LABELS and before the fields of
+// ATTRIBUTES_MAP_JSON, so later sources win. Missing or empty values are
+// skipped since Pub/Sub filters treat empty attributes differently from
+// absent ones.
+func addCustomAttributes(attributes map[string]string, config *Config, message *PubSubMessage) {
+	if config.AllLabelAttributes {
+		addLabelAttributes(attributes, config, message.Labels)
+	}
+	for _, key := range config.AttributeLabels {
+		value := message.Labels[key]
+		if value == "" {
+			value = message.Annotations[key]
+		}
+		if value == "" {
+			continue
+		}
+		attributes[config.AttributePrefix+key] = value
 	}
+	for name, field := range config.AttributesMap {
+		if value := extractMessageField(message, field); value != "" {
+			attributes[name] = value
+		}
+	}
+}
 
-	log.Printf("Publishing message to topic %s: %s", config.TopicID, string(messageData))
+// buildPubSubMessage converts the alert message into the Pub/Sub message
+// that is sent on the wire: JSON data, or data encoded with the topic's
+// schema, filterable attributes and ordering key. The data, compressed with
+// COMPRESS=gzip, is held to MAX_PAYLOAD_BYTES. FORMAT=attributes publishes
+// no data and carries the alert in the attributes instead.
+func buildPubSubMessage(config *Config, message *PubSubMessage) (*pubsub.Message, error) {
+	// Encode the message as JSON, with the topic schema or the message template
+	var messageData []byte
+	if config.Format != formatAttributes {
+		text := payloadText{
+			annotations: &message.Annotations,
+			fields:      []*string{&message.Summary, &message.Description},
+			truncated:   &message.Truncated,
+		}
+		var err error
+		messageData, err = fitPayload(config, text, func() ([]byte, error) {
+			data, err := encodeMessage(config, message)
+			if err != nil || config.Compress != compressGzip {
+				return data, err
+			}
+			return gzipData(data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message: %w", err)
+		}
+	}
 
-	// Create Pub/Sub message
 	pubsubMsg := &pubsub.Message{
 		Data: messageData,
 		Attributes: map[string]string{
@@ -208,15 +887,234 @@ func publishMessage(config *Config, message *PubSubMessage) error {
 		},
 	}
 
-	// Publish message
-	result := publisher.Publish(ctx, pubsubMsg)
+	// Subscribers drop redelivered alerts by this attribute
+	value := ""
+	if config.IdempotencyKeyField != "" {
+		value = extractMessageField(message, config.IdempotencyKeyField)
+	}
+	pubsubMsg.Attributes["idempotencyKey"] = idempotencyKey(config.IdempotencyKeyField, value, message.AlertName, message.Labels, message.StartsAt, message.Status)
+	if config.Format == formatCloudEvents {
+		addCloudEventAttributes(pubsubMsg.Attributes, config, message)
+	}
+	if config.Compress == compressGzip {
+		pubsubMsg.Attributes[contentEncodingAttribute] = compressGzip
+	}
+	if config.Format == formatAttributes {
+		addAlertAttributes(pubsubMsg.Attributes, message)
+	}
+	addCustomAttributes(pubsubMsg.Attributes, config, message)
 
-	// Wait for the result
-	messageID, err := result.Get(ctx)
+	// Only order messages that satisfy the ordering condition
+	orderingKey, err := resolveOrderingKey(config, message)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return nil, fmt.Errorf("failed to resolve ordering key: %w", err)
+	}
+	pubsubMsg.OrderingKey = orderingKey
+
+	return pubsubMsg, nil
+}
+
+// encodeMessage encodes the message with the topic schema or the message
+// template, or as JSON
+func encodeMessage(config *Config, message *PubSubMessage) ([]byte, error) {
+	if config.Schema != nil {
+		return config.Schema.encode(message)
+	}
+	if config.MessageTemplate != nil {
+		return renderMessage(config.MessageTemplate, message)
+	}
+	return json.Marshal(message)
+}
+
+// clientOptions builds the Pub/Sub client options. With PUBSUB_EMULATOR_HOST
+// set, the client library connects to the emulator without authentication,
+// so no credentials are loaded. Without a service account file the client
+// uses Application Default Credentials.
+func clientOptions(config *Config) []option.ClientOption {
+	if config.EmulatorHost != "" {
+		if config.ServiceAccountPath != "" {
+			log.Printf("Using the Pub/Sub emulator at %s, ignoring GOOGLE_APPLICATION_CREDENTIALS", config.EmulatorHost)
+		}
+		return nil
+	}
+
+	var opts []option.ClientOption
+	if config.ServiceAccountPath != "" {
+		opts = append(opts, option.WithCredentialsFile(config.ServiceAccountPath))
+	}
+	if config.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(config.Endpoint))
+	}
+	return opts
+}
+
+// routeTopic sets PUBSUB_TOPIC_ID to PUBSUB_TOPIC_ID_FIRING or
+// PUBSUB_TOPIC_ID_RESOLVED when one is set for the alert's status, e.g. to
+// send resolutions to a quieter topic
+func routeTopic(config *Config, status string) error {
+	if topic := statusOverride(config.TopicIDFiring, config.TopicIDResolved, status); topic != "" {
+		config.TopicID = topic
+		log.Printf("Routing %s alert to topic %s", strings.ToLower(status), topic)
+	}
+	if config.TopicID == "" {
+		return missingRouteError("PUBSUB_TOPIC_ID", status)
+	}
+	return nil
+}
+
+// publishMessage builds the Pub/Sub message for each alert and publishes
+// them together within TIMEOUT_SECONDS
+func publishMessage(ctx context.Context, config *Config, publisher messagePublisher, messages ...*PubSubMessage) error {
+	_, err := publishAlerts(ctx, config, publisher, messages...)
+	return err
+}
+
+// publishAlerts publishes like publishMessage and returns the outcome of
+// every message that was published. With SPOOL_FILE set, the messages that
+// still fail are spooled for a later REPLAY.
+func publishAlerts(ctx context.Context, config *Config, publisher messagePublisher, messages ...*PubSubMessage) ([]publishOutcome, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	msgs := make([]*pubsub.Message, len(messages))
+	for i, message := range messages {
+		pubsubMsg, err := buildPubSubMessage(config, message)
+		if err != nil {
+			return nil, err
+		}
+		logPublish(config, pubsubMsg)
+		msgs[i] = pubsubMsg
+	}
+
+	outcomes := publishEach(ctx, publisher, msgs, config.RetryMaxAttempts, newDeliveryLimits(config))
+	if config.SpoolFile != "" {
+		spoolMessages(config, msgs, outcomes)
+	}
+	return outcomes, publishError(msgs, outcomes)
+}
+
+// logPublish logs the message about to be published, with its data unless
+// LOG_PAYLOAD=false
+func logPublish(config *Config, pubsubMsg *pubsub.Message) {
+	if config.LogPayload && config.Schema != nil && config.Schema.binary() {
+		log.Printf("Publishing message to topic %s: <%d bytes, binary encoded with schema %s>", config.TopicID, len(pubsubMsg.Data), config.Schema.name)
+	} else if config.LogPayload && config.Compress == compressGzip {
+		log.Printf("Publishing message to topic %s: <%d bytes, gzip compressed>", config.TopicID, len(pubsubMsg.Data))
+	} else if config.LogPayload && config.Format == formatAttributes {
+		attributes, _ := json.Marshal(pubsubMsg.Attributes)
+		log.Printf("Publishing message to topic %s with attributes only: %s", config.TopicID, payloadForLog(attributes, config.RedactFields))
+	} else if config.LogPayload {
+		log.Printf("Publishing message to topic %s: %s", config.TopicID, payloadForLog(pubsubMsg.Data, config.RedactFields))
+	} else {
+		log.Printf("Publishing message to topic %s", config.TopicID)
 	}
 
-	log.Printf("Message published successfully with ID: %s", messageID)
+	if pubsubMsg.OrderingKey != "" {
+		log.Printf("Publishing with ordering key: %s", pubsubMsg.OrderingKey)
+	}
+	if config.LogPayload && config.Format != formatAttributes && logLevelEnabled(levelDebug) {
+		attributes, _ := json.Marshal(pubsubMsg.Attributes)
+		log.Printf("Debug: Message attributes: %s", payloadForLog(attributes, config.RedactFields))
+	}
+}
+
+// publishMessages publishes the messages with publishEach. A single message
+// fails with its own error; for several, the error names how many of them
+// were published.
+func publishMessages(ctx context.Context, publisher messagePublisher, msgs []*pubsub.Message, maxAttempts int, limits deliveryLimits) error {
+	return publishError(msgs, publishEach(ctx, publisher, msgs, maxAttempts, limits))
+}
+
+// publishOutcome is how publishing a message ended: the ID Pub/Sub assigned
+// it or the last error, the attempts it took and the time from the first
+// publish to the last result
+type publishOutcome struct {
+	messageID string
+	err       error
+	attempts  int
+	duration  time.Duration
+}
+
+// publishEach publishes the messages in batches of MAX_CONCURRENCY, issuing
+// every publish of a batch before waiting on any result so the client can
+// bundle them into fewer requests. Each publish waits for
+// RATE_LIMIT_PER_SECOND. Messages that fail with a transient error are
+// published again with backoff, up to maxAttempts in total. It returns the
+// outcome of every message.
+func publishEach(ctx context.Context, publisher messagePublisher, msgs []*pubsub.Message, maxAttempts int, limits deliveryLimits) []publishOutcome {
+	outcomes := make([]publishOutcome, len(msgs))
+	started := make([]time.Time, len(msgs))
+	pending := make([]int, len(msgs))
+	for i := range msgs {
+		pending[i] = i
+	}
+
+	for attempt := 1; ; attempt++ {
+		var retry []int
+		size := limits.batchSize(len(pending))
+		for first := 0; first < len(pending); first += size {
+			batch := pending[first:min(first+size, len(pending))]
+
+			start := time.Now()
+			results := make([]publishResult, len(batch))
+			for n, i := range batch {
+				if attempt == 1 {
+					started[i] = time.Now()
+				}
+				if err := limits.wait(ctx); err != nil {
+					results[n] = failedPublish{err: err}
+					continue
+				}
+				results[n] = publisher.Publish(ctx, msgs[i])
+			}
+
+			for n, i := range batch {
+				messageID, err := results[n].Get(ctx)
+				clientMetrics.record(ctx, "Publish", start, err)
+				outcomes[i] = publishOutcome{messageID: messageID, err: err, attempts: attempt, duration: time.Since(started[i])}
+				if err != nil {
+					if attempt < maxAttempts && isRetryableError(ctx, err) {
+						retry = append(retry, i)
+					}
+					continue
+				}
+				logPublished(messageID)
+			}
+		}
+		if len(retry) == 0 {
+			break
+		}
+
+		backoff := retryBackoff(attempt)
+		log.Printf("Warning: Retrying %d message(s) in %s: %v", len(retry), backoff, outcomes[retry[0]].err)
+		for _, i := range retry {
+			// A failed ordered publish pauses its key until resumed
+			if key := msgs[i].OrderingKey; key != "" {
+				publisher.ResumePublish(key)
+			}
+		}
+		if err := sleepContext(ctx, backoff); err != nil {
+			break
+		}
+		pending = retry
+	}
+	return outcomes
+}
+
+// publishError combines the errors of the messages that failed to publish
+func publishError(msgs []*pubsub.Message, outcomes []publishOutcome) error {
+	var failed []error
+	for i, outcome := range outcomes {
+		if outcome.err == nil {
+			continue
+		}
+		if len(msgs) == 1 {
+			return fmt.Errorf("failed to publish message after %d attempt(s) (code %s): %w", outcome.attempts, grpcCode(outcome.err), outcome.err)
+		}
+		failed = append(failed, fmt.Errorf("message %d after %d attempt(s) (code %s): %w", i+1, outcome.attempts, grpcCode(outcome.err), outcome.err))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("published %d of %d messages: %w", len(msgs)-len(failed), len(msgs), errors.Join(failed...))
+	}
 	return nil
 }
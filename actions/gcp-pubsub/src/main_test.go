@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// captureLog redirects the standard logger for the duration of fn
+func captureLog(t *testing.T, fn func()) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(original)
+
+	fn()
+	return buf.String()
+}
+
+func TestParseFieldMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []FieldMatcher
+		wantErr bool
+	}{
+		{
+			name: "equality",
+			spec: "labels.severity=critical",
+			want: []FieldMatcher{{Field: "labels.severity", Value: "critical"}},
+		},
+		{
+			name: "negation",
+			spec: "severity!=info",
+			want: []FieldMatcher{{Field: "severity", Value: "info", Negate: true}},
+		},
+		{
+			name: "multiple conditions with whitespace",
+			spec: " status=firing , labels.stateful = true ,",
+			want: []FieldMatcher{
+				{Field: "status", Value: "firing"},
+				{Field: "labels.stateful", Value: "true"},
+			},
+		},
+		{
+			name: "value containing operator",
+			spec: "labels.x=a!=b",
+			want: []FieldMatcher{{Field: "labels.x", Value: "a!=b"}},
+		},
+		{
+			name: "empty value",
+			spec: "labels.team=",
+			want: []FieldMatcher{{Field: "labels.team", Value: ""}},
+		},
+		{name: "missing operator", spec: "labels.severity", wantErr: true},
+		{name: "empty field", spec: "=critical", wantErr: true},
+		{name: "empty negated field", spec: "!=critical", wantErr: true},
+		{name: "unknown field", spec: "alertname=HighCPU", wantErr: true},
+		{name: "empty label key", spec: "labels.=x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFieldMatchers(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseFieldMatchers(%q) expected error, got %+v", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFieldMatchers(%q) unexpected error: %v", tt.spec, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseFieldMatchers(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("matcher %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveOrderingKey(t *testing.T) {
+	stateful := &PubSubMessage{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Severity:  "critical",
+		Labels:    map[string]string{"instance": "node-1", "stateful": "true"},
+	}
+	stateless := &PubSubMessage{
+		AlertName: "HighLatency",
+		Status:    "firing",
+		Severity:  "info",
+		Labels:    map[string]string{"instance": "node-2"},
+	}
+	noInstance := &PubSubMessage{
+		AlertName: "Watchdog",
+		Status:    "firing",
+		Severity:  "critical",
+		Labels:    map[string]string{"stateful": "true"},
+	}
+
+	tests := []struct {
+		name      string
+		keyField  string
+		condition string
+		message   *PubSubMessage
+		want      string
+		wantErr   bool
+	}{
+		{name: "ordering disabled", message: stateful, want: ""},
+		{name: "no condition orders everything", keyField: "labels.instance", message: stateless, want: "node-2"},
+		{name: "matching alert gets a key", keyField: "labels.instance", condition: "labels.stateful=true", message: stateful, want: "node-1"},
+		{name: "non-matching alert stays unordered", keyField: "labels.instance", condition: "labels.stateful=true", message: stateless, want: ""},
+		{name: "negated condition matches", keyField: "alertName", condition: "severity!=info", message: stateful, want: "DiskFull"},
+		{name: "negated condition does not match", keyField: "alertName", condition: "severity!=info", message: stateless, want: ""},
+		{name: "all conditions must hold", keyField: "labels.instance", condition: "labels.stateful=true,status=resolved", message: stateful, want: ""},
+		{name: "empty key field fails", keyField: "labels.instance", condition: "labels.stateful=true", message: noInstance, wantErr: true},
+		{name: "empty key field skipped when condition fails", keyField: "labels.instance", condition: "severity=info", message: noInstance, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{OrderingKeyField: tt.keyField}
+			if tt.condition != "" {
+				conditions, err := parseFieldMatchers(tt.condition)
+				if err != nil {
+					t.Fatalf("parseFieldMatchers(%q) unexpected error: %v", tt.condition, err)
+				}
+				config.OrderingConditions = conditions
+			}
+
+			got, err := resolveOrderingKey(config, tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveOrderingKey() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveOrderingKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigOrdering(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyField  string
+		condition string
+		wantErr   bool
+	}{
+		{name: "key field only", keyField: "labels.instance"},
+		{name: "key field with condition", keyField: "labels.instance", condition: "labels.stateful=true"},
+		{name: "condition without key field", condition: "labels.stateful=true", wantErr: true},
+		{name: "unknown key field", keyField: "alertname", wantErr: true},
+		{name: "malformed condition", keyField: "labels.instance", condition: "labels.stateful", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GCP_PROJECT_ID", "test-project")
+			t.Setenv("PUBSUB_TOPIC_ID", "test-topic")
+			t.Setenv("ORDERING_KEY_FIELD", tt.keyField)
+			t.Setenv("ORDERING_CONDITION", tt.condition)
+
+			_, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteTopic(t *testing.T) {
+	tests := []struct {
+		name     string
+		topic    string
+		firing   string
+		resolved string
+		status   string
+		want     string
+		wantErr  string
+	}{
+		{name: "generic topic", topic: "alerts", status: "resolved", want: "alerts"},
+		{name: "resolved override", topic: "alerts", resolved: "alerts-quiet", status: "resolved", want: "alerts-quiet"},
+		{name: "firing override", topic: "alerts", resolved: "alerts-quiet", firing: "alerts-loud", status: "firing", want: "alerts-loud"},
+		{name: "override for the other status", firing: "alerts-loud", status: "resolved", wantErr: "PUBSUB_TOPIC_ID_RESOLVED or PUBSUB_TOPIC_ID"},
+		{name: "other status without generic topic", firing: "alerts-loud", resolved: "alerts-quiet", status: "pending", wantErr: "PUBSUB_TOPIC_ID is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GCP_PROJECT_ID", "test-project")
+			t.Setenv("PUBSUB_TOPIC_ID", tt.topic)
+			t.Setenv("PUBSUB_TOPIC_ID_FIRING", tt.firing)
+			t.Setenv("PUBSUB_TOPIC_ID_RESOLVED", tt.resolved)
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if err != nil {
+				t.Fatalf("loadConfig() unexpected error: %v", err)
+			}
+			captureLog(t, func() { err = routeTopic(config, tt.status) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("routeTopic() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("routeTopic() unexpected error: %v", err)
+			}
+			if config.TopicID != tt.want {
+				t.Errorf("topic = %q, want %q", config.TopicID, tt.want)
+			}
+		})
+	}
+
+	t.Run("no topic at all", func(t *testing.T) {
+		t.Setenv("GCP_PROJECT_ID", "test-project")
+		t.Setenv("PUBSUB_TOPIC_ID", "")
+		if _, err := loadConfig(); err == nil {
+			t.Error("loadConfig() expected an error without any topic")
+		}
+	})
+}
+
+func TestLogResolvedConfig(t *testing.T) {
+	config := &Config{
+		ProjectID:          "my-project",
+		TopicID:            "alerts",
+		ServiceAccountPath: "/etc/gcp/service-account.json",
+		RegistryPassword:   "registry-secret",
+		TimeoutSeconds:     30,
+		Source:             "karo",
+		OrderingKeyField:   "labels.instance",
+		OrderingConditions: []FieldMatcher{{Field: "severity", Value: "info", Negate: true}},
+		LogConfig:          true,
+	}
+
+	output := captureLog(t, func() { logResolvedConfig(config) })
+
+	for _, want := range []string{
+		`"GCP_PROJECT_ID":"my-project"`,
+		`"PUBSUB_TOPIC_ID":"alerts"`,
+		`"GOOGLE_APPLICATION_CREDENTIALS":"***"`,
+		`"SCHEMA_REGISTRY_PASSWORD":"***"`,
+		`"TIMEOUT_SECONDS":30`,
+		`"ORDERING_KEY_FIELD":"labels.instance"`,
+		`"ORDERING_CONDITION":[{"field":"severity","value":"info","negate":true}]`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("logged config missing %s, got: %s", want, output)
+		}
+	}
+	if strings.Contains(output, "service-account.json") {
+		t.Errorf("logged config leaked credentials path: %s", output)
+	}
+	if strings.Contains(output, "registry-secret") {
+		t.Errorf("logged config leaked the schema registry password: %s", output)
+	}
+	if config.ServiceAccountPath != "/etc/gcp/service-account.json" {
+		t.Errorf("logResolvedConfig modified the config: %+v", config)
+	}
+}
+
+func TestLogResolvedConfigWithoutCredentials(t *testing.T) {
+	output := captureLog(t, func() { logResolvedConfig(&Config{ProjectID: "my-project"}) })
+
+	if !strings.Contains(output, `"GOOGLE_APPLICATION_CREDENTIALS":""`) {
+		t.Errorf("empty credentials path should stay empty, got: %s", output)
+	}
+}
+
+func TestLoadConfigInvalidLogConfig(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "test-project")
+	t.Setenv("PUBSUB_TOPIC_ID", "test-topic")
+	t.Setenv("LOG_CONFIG", "yes")
+
+	var config *Config
+	var err error
+	output := captureLog(t, func() { config, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+	if config.LogConfig {
+		t.Error("LogConfig should stay disabled for an invalid value")
+	}
+	if !strings.Contains(output, "Invalid LOG_CONFIG value 'yes'") {
+		t.Errorf("expected a warning for the invalid value, got: %s", output)
+	}
+}
+
+func TestParseAttributeLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		prefix  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "single key", spec: "namespace", want: []string{"namespace"}},
+		{name: "multiple keys with whitespace", spec: " namespace , cluster,,team ", want: []string{"namespace", "cluster", "team"}},
+		{name: "built-in conflict", spec: "namespace,severity", wantErr: true},
+		{name: "built-in conflict avoided by prefix", spec: "severity", prefix: "label_", want: []string{"severity"}},
+		{name: "reserved goog prefix", spec: "google_team", wantErr: true},
+		{name: "reserved goog prefix via ATTRIBUTE_PREFIX", spec: "team", prefix: "goog_", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAttributeLabels(tt.spec, tt.prefix)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseAttributeLabels(%q) expected error, got %v", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAttributeLabels(%q) unexpected error: %v", tt.spec, err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parseAttributeLabels(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPubSubMessageCustomAttributes(t *testing.T) {
+	message := &PubSubMessage{
+		AlertName:   "DiskFull",
+		Status:      "firing",
+		Severity:    "critical",
+		Labels:      map[string]string{"namespace": "payments", "team": "", "cluster": "prod-eu"},
+		Annotations: map[string]string{"team": "storage", "cluster": "ignored"},
+	}
+
+	tests := []struct {
+		name   string
+		keys   []string
+		prefix string
+		want   map[string]string
+		absent []string
+	}{
+		{
+			name: "label values",
+			keys: []string{"namespace", "cluster"},
+			want: map[string]string{"namespace": "payments", "cluster": "prod-eu"},
+		},
+		{
+			name: "annotation used when label is empty",
+			keys: []string{"team"},
+			want: map[string]string{"team": "storage"},
+		},
+		{
+			name:   "missing keys are skipped",
+			keys:   []string{"namespace", "owner"},
+			want:   map[string]string{"namespace": "payments"},
+			absent: []string{"owner"},
+		},
+		{
+			name:   "prefix applied",
+			keys:   []string{"namespace"},
+			prefix: "label_",
+			want:   map[string]string{"label_namespace": "payments"},
+			absent: []string{"namespace"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{AttributeLabels: tt.keys, AttributePrefix: tt.prefix}
+
+			pubsubMsg, err := buildPubSubMessage(config, message)
+			if err != nil {
+				t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+			}
+			for key, want := range tt.want {
+				if got := pubsubMsg.Attributes[key]; got != want {
+					t.Errorf("attribute %q = %q, want %q", key, got, want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := pubsubMsg.Attributes[key]; ok {
+					t.Errorf("attribute %q should not be set, got %+v", key, pubsubMsg.Attributes)
+				}
+			}
+			if pubsubMsg.Attributes["alertName"] != "DiskFull" {
+				t.Errorf("built-in attributes missing: %+v", pubsubMsg.Attributes)
+			}
+		})
+	}
+}
+
+func TestBuildPubSubMessageIdempotencyKeyField(t *testing.T) {
+	message := &PubSubMessage{AlertName: "DiskFull", Status: "firing", Labels: map[string]string{"incident": "INC-1"}}
+	config := &Config{IdempotencyKeyField: "labels.incident"}
+
+	pubsubMsg, err := buildPubSubMessage(config, message)
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	want := idempotencyKey("labels.incident", "INC-1", "", nil, "", "")
+	if got := pubsubMsg.Attributes["idempotencyKey"]; got != want {
+		t.Errorf("idempotencyKey attribute = %q, want %q", got, want)
+	}
+
+	t.Setenv("GCP_PROJECT_ID", "project")
+	t.Setenv("PUBSUB_TOPIC_ID", "alerts")
+	t.Setenv("IDEMPOTENCY_KEY_FIELD", "startsAt")
+	if _, err := loadConfig(); err == nil {
+		t.Error("loadConfig() should reject an unknown IDEMPOTENCY_KEY_FIELD")
+	}
+}
+
+func TestBuildPubSubMessageMaxPayloadBytes(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "project")
+	t.Setenv("PUBSUB_TOPIC_ID", "alerts")
+	t.Setenv("ON_OVERSIZE", "truncate")
+	var config *Config
+	var err error
+	captureLog(t, func() { config, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+	if config.MaxPayloadBytes != maxMessageBytes {
+		t.Errorf("MaxPayloadBytes = %d, want the Pub/Sub maximum %d", config.MaxPayloadBytes, maxMessageBytes)
+	}
+
+	huge := strings.Repeat("x", maxMessageBytes)
+	message := &PubSubMessage{AlertName: "DiskFull", Annotations: map[string]string{"description": huge}, Description: huge}
+	pubsubMsg, err := buildPubSubMessage(config, message)
+	if err != nil {
+		t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+	}
+	if len(pubsubMsg.Data) > maxMessageBytes || !message.Truncated {
+		t.Errorf("data is %d bytes, truncated %t, want at most %d and truncated", len(pubsubMsg.Data), message.Truncated, maxMessageBytes)
+	}
+}
+
+func TestNewSignalContextCancelsOnSIGTERM(t *testing.T) {
+	ctx, stop := newSignalContext()
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not cancelled by SIGTERM")
+	}
+}
+
+func TestPublishMessageCancelled(t *testing.T) {
+	newFakePubSub(t, "test-project", "alerts")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := &Config{ProjectID: "test-project", TopicID: "alerts", TimeoutSeconds: 10}
+	publisher := newTestPublisher(t, config)
+	var err error
+	captureLog(t, func() { err = publishMessage(ctx, config, publisher, &PubSubMessage{AlertName: "DiskFull"}) })
+	if err == nil {
+		t.Fatal("publishMessage() expected error for a cancelled context")
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		wantOpts int
+		wantLog  bool
+	}{
+		{name: "application default credentials", config: Config{}, wantOpts: 0},
+		{name: "credentials file and endpoint", config: Config{ServiceAccountPath: "/etc/gcp/key.json", Endpoint: "europe-west1-pubsub.googleapis.com:443"}, wantOpts: 2},
+		{name: "emulator skips credentials", config: Config{ServiceAccountPath: "/etc/gcp/key.json", EmulatorHost: "localhost:8085"}, wantOpts: 0, wantLog: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts int
+			output := captureLog(t, func() { opts = len(clientOptions(&tt.config)) })
+			if opts != tt.wantOpts {
+				t.Errorf("clientOptions() returned %d options, want %d", opts, tt.wantOpts)
+			}
+			if logged := strings.Contains(output, "ignoring GOOGLE_APPLICATION_CREDENTIALS"); logged != tt.wantLog {
+				t.Errorf("credentials skip logged = %t, want %t: %s", logged, tt.wantLog, output)
+			}
+		})
+	}
+}
+
+func TestPublishMessageToEmulatorWithoutCredentials(t *testing.T) {
+	srv := newFakePubSub(t, "test-project", "alerts")
+
+	// The key file does not exist; loading it would fail the publish
+	config := &Config{
+		ProjectID:          "test-project",
+		TopicID:            "alerts",
+		ServiceAccountPath: "/nonexistent/key.json",
+		EmulatorHost:       srv.Addr,
+		TimeoutSeconds:     10,
+		RetryMaxAttempts:   1,
+	}
+	publisher := newTestPublisher(t, config)
+	message := &PubSubMessage{AlertName: "DiskFull"}
+	var err error
+	captureLog(t, func() { err = publishMessage(context.Background(), config, publisher, message) })
+	if err != nil {
+		t.Fatalf("publishMessage() unexpected error: %v", err)
+	}
+	if got := len(srv.Messages()); got != 1 {
+		t.Errorf("emulator received %d messages, want 1", got)
+	}
+}
+
+func TestLoadConfigEndpointAndEmulatorExclusive(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "test-project")
+	t.Setenv("PUBSUB_TOPIC_ID", "test-topic")
+	t.Setenv("PUBSUB_EMULATOR_HOST", "localhost:8085")
+	t.Setenv("PUBSUB_ENDPOINT", "europe-west1-pubsub.googleapis.com:443")
+
+	if _, err := loadConfig(); err == nil {
+		t.Error("loadConfig() should reject PUBSUB_EMULATOR_HOST with PUBSUB_ENDPOINT")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// useTestMetrics installs clientMetrics backed by an in-memory reader for
+// the duration of the test
+func useTestMetrics(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	m, err := newGCPMetrics(reader, nil)
+	if err != nil {
+		t.Fatalf("newGCPMetrics() unexpected error: %v", err)
+	}
+	clientMetrics = m
+	t.Cleanup(func() { clientMetrics = nil })
+	return reader
+}
+
+// callCounts returns the gcp.client.calls value keyed by "method/grpc_code"
+func callCounts(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("Collect() unexpected error: %v", err)
+	}
+
+	counts := map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, recorded := range scope.Metrics {
+			if recorded.Name != "gcp.client.calls" {
+				continue
+			}
+			for _, point := range recorded.Data.(metricdata.Sum[int64]).DataPoints {
+				method, _ := point.Attributes.Value(attribute.Key("method"))
+				code, _ := point.Attributes.Value(attribute.Key("grpc_code"))
+				counts[method.AsString()+"/"+code.AsString()] += point.Value
+			}
+		}
+	}
+	return counts
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "success", err: nil, want: codes.OK},
+		{name: "status error", err: status.Error(codes.ResourceExhausted, "quota"), want: codes.ResourceExhausted},
+		{name: "deadline", err: context.DeadlineExceeded, want: codes.DeadlineExceeded},
+		{name: "canceled", err: context.Canceled, want: codes.Canceled},
+		{name: "plain error", err: errors.New("boom"), want: codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grpcCode(tt.err); got != tt.want {
+				t.Errorf("grpcCode(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsRecordCall(t *testing.T) {
+	reader := useTestMetrics(t)
+	ctx := context.Background()
+
+	clientMetrics.record(ctx, "Publish", time.Now(), nil)
+	clientMetrics.record(ctx, "Publish", time.Now(), status.Error(codes.ResourceExhausted, "quota exceeded"))
+	clientMetrics.record(ctx, "Publish", time.Now(), status.Error(codes.ResourceExhausted, "quota exceeded"))
+
+	counts := callCounts(t, reader)
+	if counts["Publish/OK"] != 1 {
+		t.Errorf("Publish/OK = %d, want 1 (all: %v)", counts["Publish/OK"], counts)
+	}
+	if counts["Publish/ResourceExhausted"] != 2 {
+		t.Errorf("Publish/ResourceExhausted = %d, want 2 (all: %v)", counts["Publish/ResourceExhausted"], counts)
+	}
+}
+
+func TestMetricsDisabledIsNoop(t *testing.T) {
+	var m *gcpMetrics
+	m.record(context.Background(), "Publish", time.Now(), nil)
+
+	output := captureLog(t, func() { m.flush(context.Background()) })
+	if output != "" {
+		t.Errorf("disabled metrics should not log, got: %s", output)
+	}
+}
+
+func TestMetricsExportedOnly(t *testing.T) {
+	exported := sdkmetric.NewManualReader()
+	m, err := newGCPMetrics(nil, exported)
+	if err != nil {
+		t.Fatalf("newGCPMetrics() unexpected error: %v", err)
+	}
+	m.record(context.Background(), "NewClient", time.Now(), nil)
+
+	if output := captureLog(t, func() { m.flush(context.Background()) }); output != "" {
+		t.Errorf("exported-only metrics should not log, got: %s", output)
+	}
+	if counts := callCounts(t, exported); counts["NewClient/OK"] != 1 {
+		t.Errorf("expected one exported NewClient call, got %v", counts)
+	}
+}
+
+func TestPublishMessageRecordsMetrics(t *testing.T) {
+	newFakePubSub(t, "test-project", "alerts")
+	reader := useTestMetrics(t)
+
+	config := &Config{ProjectID: "test-project", TopicID: "alerts", TimeoutSeconds: 10}
+	if err := publishMessage(context.Background(), config, newTestPublisher(t, config), &PubSubMessage{AlertName: "DiskFull", Status: "firing"}); err != nil {
+		t.Fatalf("publishMessage() unexpected error: %v", err)
+	}
+
+	if counts := callCounts(t, reader); counts["Publish/OK"] != 1 {
+		t.Errorf("expected one successful Publish call, got %v", counts)
+	}
+
+	output := captureLog(t, func() { clientMetrics.flush(context.Background()) })
+	if !strings.Contains(output, "gcp.client.call.duration{grpc_code=OK,method=Publish} count=1") {
+		t.Errorf("flush did not log the call duration, got: %s", output)
+	}
+}
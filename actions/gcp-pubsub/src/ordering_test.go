@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+)
+
+// newFakePubSub starts an in-memory Pub/Sub server with the given topic and
+// points the client library at it through PUBSUB_EMULATOR_HOST
+func newFakePubSub(t *testing.T, projectID, topicID string) *pstest.Server {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+
+	topic := &pubsubpb.Topic{Name: "projects/" + projectID + "/topics/" + topicID}
+	if _, err := srv.GServer.CreateTopic(context.Background(), topic); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	return srv
+}
+
+func TestPublishMessagePreservesOrderPerKey(t *testing.T) {
+	srv := newFakePubSub(t, "test-project", "alerts")
+
+	conditions, err := parseFieldMatchers("labels.stateful=true")
+	if err != nil {
+		t.Fatalf("parseFieldMatchers() unexpected error: %v", err)
+	}
+	config := &Config{
+		ProjectID:          "test-project",
+		TopicID:            "alerts",
+		TimeoutSeconds:     10,
+		Source:             "karo",
+		OrderingKeyField:   "labels.instance",
+		OrderingConditions: conditions,
+	}
+
+	sequence := []*PubSubMessage{
+		{AlertName: "DiskFull", Status: "firing", Labels: map[string]string{"instance": "node-1", "stateful": "true"}},
+		{AlertName: "HighLatency", Status: "firing", Labels: map[string]string{"instance": "node-2"}},
+		{AlertName: "DiskFull", Status: "resolved", Labels: map[string]string{"instance": "node-1", "stateful": "true"}},
+		{AlertName: "DiskFull", Status: "firing", Labels: map[string]string{"instance": "node-1", "stateful": "true"}},
+	}
+	for _, message := range sequence {
+		if err := publishMessage(context.Background(), config, newTestPublisher(t, config), message); err != nil {
+			t.Fatalf("publishMessage() unexpected error: %v", err)
+		}
+	}
+
+	var ordered []string
+	for _, msg := range srv.Messages() {
+		var published PubSubMessage
+		if err := json.Unmarshal(msg.Data, &published); err != nil {
+			t.Fatalf("failed to decode published message: %v", err)
+		}
+
+		switch published.AlertName {
+		case "DiskFull":
+			if msg.OrderingKey != "node-1" {
+				t.Errorf("DiskFull ordering key = %q, want %q", msg.OrderingKey, "node-1")
+			}
+			ordered = append(ordered, published.Status)
+		case "HighLatency":
+			if msg.OrderingKey != "" {
+				t.Errorf("HighLatency should be unordered, got ordering key %q", msg.OrderingKey)
+			}
+		}
+	}
+
+	want := []string{"firing", "resolved", "firing"}
+	if len(ordered) != len(want) {
+		t.Fatalf("got %d ordered messages, want %d", len(ordered), len(want))
+	}
+	for i := range want {
+		if ordered[i] != want[i] {
+			t.Errorf("ordered message %d status = %q, want %q", i, ordered[i], want[i])
+		}
+	}
+}
+
+func TestPublishMessageFailsOnEmptyOrderingKey(t *testing.T) {
+	srv := newFakePubSub(t, "test-project", "alerts")
+
+	config := &Config{
+		ProjectID:        "test-project",
+		TopicID:          "alerts",
+		TimeoutSeconds:   10,
+		OrderingKeyField: "labels.instance",
+	}
+	message := &PubSubMessage{AlertName: "Watchdog", Status: "firing", Labels: map[string]string{}}
+
+	if err := publishMessage(context.Background(), config, newTestPublisher(t, config), message); err == nil {
+		t.Fatal("publishMessage() expected error for empty ordering key")
+	}
+	if got := len(srv.Messages()); got != 0 {
+		t.Errorf("expected no published messages, got %d", got)
+	}
+}
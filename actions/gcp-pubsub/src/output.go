@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// resultOutput receives the RESULT_JSON lines
+var resultOutput io.Writer = os.Stdout
+
+// PublishResult summarizes a run for RESULT_JSON and OUTPUT_FILE, so Karo,
+// later actions and log pipelines can correlate alert deliveries with the
+// Pub/Sub message IDs without parsing log messages
+type PublishResult struct {
+	Success    bool             `json:"success"`
+	Error      string           `json:"error,omitempty"`
+	DurationMs int64            `json:"durationMs"`
+	Messages   []MessageOutcome `json:"messages"`
+}
+
+// MessageOutcome is one message's part of a PublishResult. The message ID
+// is set once Pub/Sub accepted the message.
+type MessageOutcome struct {
+	AlertName  string `json:"alertName,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Topic      string `json:"topic"`
+	MessageID  string `json:"messageId,omitempty"`
+	Attempts   int    `json:"attempts"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// parseOutputConfig reads OUTPUT_FILE and RESULT_JSON
+func parseOutputConfig(config *Config) error {
+	config.OutputFile = os.Getenv("OUTPUT_FILE")
+	return envBool(config.StrictEnv, "RESULT_JSON", &config.ResultJSON)
+}
+
+// newPublishResult summarizes the published batches. A message of a batch
+// that failed before publishing, e.g. on a missing topic, has no attempts
+// and carries the batch error.
+func newPublishResult(batches []*topicBatch, err error, duration time.Duration) PublishResult {
+	result := PublishResult{
+		Success:    err == nil,
+		DurationMs: duration.Milliseconds(),
+		Messages:   []MessageOutcome{},
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for _, batch := range batches {
+		topic := fmt.Sprintf("projects/%s/topics/%s", batch.config.ProjectID, batch.config.TopicID)
+		for i, message := range batch.messages {
+			outcome := MessageOutcome{
+				AlertName: message.AlertName,
+				Status:    message.Status,
+				Topic:     topic,
+			}
+			switch {
+			case i < len(batch.outcomes):
+				published := batch.outcomes[i]
+				outcome.MessageID = published.messageID
+				outcome.Attempts = published.attempts
+				outcome.DurationMs = published.duration.Milliseconds()
+				if published.err != nil {
+					outcome.Error = published.err.Error()
+				}
+			case batch.err != nil:
+				outcome.Error = batch.err.Error()
+			}
+			result.Messages = append(result.Messages, outcome)
+		}
+	}
+	return result
+}
+
+// writeOutputFile writes the result as JSON to path, whether the run
+// succeeded or not
+func writeOutputFile(path string, result PublishResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal OUTPUT_FILE: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write OUTPUT_FILE: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write OUTPUT_FILE: %w", err)
+	}
+
+	log.Printf("Publish result written to %s", path)
+	return nil
+}
+
+// writeRunResult writes the result as a single JSON line
+func writeRunResult(w io.Writer, result PublishResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Warning: Failed to marshal RESULT_JSON: %v", err)
+		return
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		log.Printf("Warning: Failed to write RESULT_JSON: %v", err)
+	}
+}
+
+// reportResult writes the result of the published batches to OUTPUT_FILE
+// and RESULT_JSON if configured
+func reportResult(config *Config, batches []*topicBatch, err error, duration time.Duration) {
+	if config.OutputFile == "" && !config.ResultJSON {
+		return
+	}
+	result := newPublishResult(batches, err, duration)
+	if config.OutputFile != "" {
+		if err := writeOutputFile(config.OutputFile, result); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	if config.ResultJSON {
+		writeRunResult(resultOutput, result)
+	}
+}
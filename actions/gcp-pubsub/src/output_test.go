@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+func TestPublishEachOutcomes(t *testing.T) {
+	initial := retryInitialBackoff
+	retryInitialBackoff = time.Millisecond
+	t.Cleanup(func() { retryInitialBackoff = initial })
+
+	publisher := &fakePublisher{failures: 1}
+	msgs := []*pubsub.Message{{Data: []byte("one")}, {Data: []byte("two")}}
+
+	var outcomes []publishOutcome
+	captureLog(t, func() {
+		outcomes = publishEach(context.Background(), publisher, msgs, 3, deliveryLimits{})
+	})
+	if len(outcomes) != 2 {
+		t.Fatalf("got %d outcomes, want 2", len(outcomes))
+	}
+	for i, want := range []int{2, 1} {
+		outcome := outcomes[i]
+		if outcome.err != nil || outcome.messageID != "msg-1" || outcome.attempts != want {
+			t.Errorf("outcome %d = %+v, want message ID msg-1 after %d attempt(s)", i, outcome, want)
+		}
+	}
+}
+
+func TestNewPublishResult(t *testing.T) {
+	published := &topicBatch{
+		config:   &Config{ProjectID: "test-project", TopicID: "alerts"},
+		messages: []*PubSubMessage{{AlertName: "DiskFull", Status: "firing"}, {AlertName: "HighCPU", Status: "firing"}},
+		outcomes: []publishOutcome{
+			{messageID: "msg-1", attempts: 1, duration: 120 * time.Millisecond},
+			{err: errors.New("backend unavailable"), attempts: 3, duration: 2 * time.Second},
+		},
+	}
+	missing := &topicBatch{
+		config:   &Config{ProjectID: "test-project", TopicID: "missing"},
+		messages: []*PubSubMessage{{AlertName: "DiskFull", Status: "resolved"}},
+		err:      errors.New("topic not found"),
+	}
+
+	result := newPublishResult([]*topicBatch{published, missing}, errors.New("publish failed"), 3*time.Second)
+	if result.Success || result.Error != "publish failed" || result.DurationMs != 3000 {
+		t.Errorf("result = %+v, want a failed run of 3000ms", result)
+	}
+	want := []MessageOutcome{
+		{AlertName: "DiskFull", Status: "firing", Topic: "projects/test-project/topics/alerts", MessageID: "msg-1", Attempts: 1, DurationMs: 120},
+		{AlertName: "HighCPU", Status: "firing", Topic: "projects/test-project/topics/alerts", Attempts: 3, DurationMs: 2000, Error: "backend unavailable"},
+		{AlertName: "DiskFull", Status: "resolved", Topic: "projects/test-project/topics/missing", Error: "topic not found"},
+	}
+	if len(result.Messages) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(result.Messages), len(want))
+	}
+	for i := range want {
+		if result.Messages[i] != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, result.Messages[i], want[i])
+		}
+	}
+}
+
+func TestReportResult(t *testing.T) {
+	var buf bytes.Buffer
+	resultOutput = &buf
+	defer func() { resultOutput = os.Stdout }()
+
+	path := filepath.Join(t.TempDir(), "out", "result.json")
+	config := &Config{OutputFile: path, ResultJSON: true}
+	batch := &topicBatch{
+		config:   &Config{ProjectID: "test-project", TopicID: "alerts"},
+		messages: []*PubSubMessage{{AlertName: "DiskFull", Status: "firing"}},
+		outcomes: []publishOutcome{{messageID: "msg-1", attempts: 1}},
+	}
+	captureLog(t, func() { reportResult(config, []*topicBatch{batch}, nil, time.Second) })
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("RESULT_JSON wrote %d lines, want 1: %s", len(lines), buf.String())
+	}
+	var line PublishResult
+	if err := json.Unmarshal(lines[0], &line); err != nil {
+		t.Fatalf("RESULT_JSON line is not JSON: %v", err)
+	}
+	if !line.Success || len(line.Messages) != 1 || line.Messages[0].MessageID != "msg-1" {
+		t.Errorf("RESULT_JSON = %s, want a successful run with message ID msg-1", lines[0])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read OUTPUT_FILE: %v", err)
+	}
+	var file PublishResult
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("OUTPUT_FILE is not JSON: %v", err)
+	}
+	if file.Messages[0].Topic != "projects/test-project/topics/alerts" {
+		t.Errorf("OUTPUT_FILE topic = %q, want the full topic name", file.Messages[0].Topic)
+	}
+}
+
+func TestReportResultDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	resultOutput = &buf
+	defer func() { resultOutput = os.Stdout }()
+
+	reportResult(&Config{}, nil, nil, time.Second)
+	if buf.Len() != 0 {
+		t.Errorf("RESULT_JSON = %s, want nothing without RESULT_JSON", buf.String())
+	}
+}
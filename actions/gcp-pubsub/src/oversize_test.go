@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// oversizePayload is a minimal payload with the text fitPayload trims
+type oversizePayload struct {
+	Summary     string            `json:"summary"`
+	Annotations map[string]string `json:"annotations"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+func (p *oversizePayload) fit(config *Config) ([]byte, error) {
+	text := payloadText{annotations: &p.Annotations, fields: []*string{&p.Summary}, truncated: &p.Truncated}
+	return fitPayload(config, text, func() ([]byte, error) { return json.Marshal(p) })
+}
+
+func TestParseOversizeConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes string
+		mode     string
+		wantMax  int
+		wantMode string
+		wantErr  bool
+	}{
+		{name: "defaults", wantMax: 1000, wantMode: onOversizeFail},
+		{name: "custom limit with truncate", maxBytes: "512", mode: "truncate", wantMax: 512, wantMode: onOversizeTruncate},
+		{name: "zero disables the limit", maxBytes: "0", wantMax: 0, wantMode: onOversizeFail},
+		{name: "negative limit", maxBytes: "-1", wantErr: true},
+		{name: "invalid mode", mode: "trim", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_PAYLOAD_BYTES", tt.maxBytes)
+			t.Setenv("ON_OVERSIZE", tt.mode)
+
+			config := &Config{MaxPayloadBytes: 1000}
+			err := parseOversizeConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOversizeConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && (config.MaxPayloadBytes != tt.wantMax || config.OnOversize != tt.wantMode) {
+				t.Errorf("limit, mode = %d, %q, want %d, %q", config.MaxPayloadBytes, config.OnOversize, tt.wantMax, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestFitPayloadBoundary(t *testing.T) {
+	newPayload := func() *oversizePayload {
+		return &oversizePayload{
+			Summary:     "Disk is full",
+			Annotations: map[string]string{"runbook": "https://runbooks.example.com/disk", "details": strings.Repeat("x", 200)},
+		}
+	}
+	full, err := json.Marshal(newPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(full)
+
+	t.Run("exactly at the limit", func(t *testing.T) {
+		for _, mode := range []string{onOversizeFail, onOversizeTruncate} {
+			data, err := newPayload().fit(&Config{MaxPayloadBytes: size, OnOversize: mode})
+			if err != nil {
+				t.Fatalf("fitPayload(%s) unexpected error: %v", mode, err)
+			}
+			if string(data) != string(full) {
+				t.Errorf("fitPayload(%s) changed a payload at the limit: %s", mode, data)
+			}
+		}
+	})
+
+	t.Run("one byte over fails", func(t *testing.T) {
+		_, err := newPayload().fit(&Config{MaxPayloadBytes: size - 1, OnOversize: onOversizeFail})
+		want := fmt.Sprintf("payload %d bytes exceeds limit %d", size, size-1)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("fitPayload() error = %v, want %q", err, want)
+		}
+	})
+
+	t.Run("one byte over truncates the largest annotation", func(t *testing.T) {
+		payload := newPayload()
+		original := payload.Annotations
+		data, err := payload.fit(&Config{MaxPayloadBytes: size - 1, OnOversize: onOversizeTruncate})
+		if err != nil {
+			t.Fatalf("fitPayload() unexpected error: %v", err)
+		}
+		if len(data) > size-1 {
+			t.Errorf("payload is %d bytes, want at most %d", len(data), size-1)
+		}
+		if !payload.Truncated || !strings.HasSuffix(payload.Annotations["details"], truncatedSuffix) {
+			t.Errorf("payload = %+v, want details truncated and marked", payload)
+		}
+		if payload.Annotations["runbook"] != original["runbook"] || payload.Summary != "Disk is full" {
+			t.Errorf("smaller values should be kept, got %+v", payload)
+		}
+		if len(original["details"]) != 200 {
+			t.Error("fitPayload() modified the alert's annotations")
+		}
+	})
+
+	t.Run("disabled without a limit", func(t *testing.T) {
+		if _, err := newPayload().fit(&Config{OnOversize: onOversizeFail}); err != nil {
+			t.Errorf("fitPayload() unexpected error: %v", err)
+		}
+	})
+}
+
+func TestFitPayloadDropsAnnotations(t *testing.T) {
+	payload := &oversizePayload{Annotations: map[string]string{"a": strings.Repeat("a", 40), "b": strings.Repeat("b", 40)}}
+
+	// 124 bytes, so the first annotation is too short to keep anything
+	data, err := payload.fit(&Config{MaxPayloadBytes: 95, OnOversize: onOversizeTruncate})
+	if err != nil {
+		t.Fatalf("fitPayload() unexpected error: %v", err)
+	}
+	if _, ok := payload.Annotations["a"]; ok || len(data) > 95 || payload.Annotations["b"] != strings.Repeat("b", 40) {
+		t.Errorf("payload = %s, want at most 95 bytes with annotation a dropped", data)
+	}
+
+	_, err = payload.fit(&Config{MaxPayloadBytes: 10, OnOversize: onOversizeTruncate})
+	if err == nil || !strings.Contains(err.Error(), "even with every annotation dropped") {
+		t.Errorf("fitPayload() error = %v, want an error once nothing is left to trim", err)
+	}
+}
+
+func TestTrimValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		excess int
+		want   string
+	}{
+		{name: "trims from the end", value: strings.Repeat("a", 30), excess: 5, want: strings.Repeat("a", 30-5-len(truncatedSuffix)) + truncatedSuffix},
+		{name: "drops short values", value: "short", excess: 1, want: ""},
+		{name: "keeps whole runes", value: strings.Repeat("é", 20), excess: 3, want: strings.Repeat("é", 11) + truncatedSuffix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimValue(tt.value, tt.excess)
+			if got != tt.want {
+				t.Errorf("trimValue() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("trimValue() = %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+)
+
+// Permissions checked by VALIDATE
+const (
+	publishPermission  = "pubsub.topics.publish"
+	getTopicPermission = "pubsub.topics.get"
+)
+
+// topicAdmin is the part of the topic admin client used to validate a
+// topic, satisfied by the TopicAdminClient of *pubsub.Client
+type topicAdmin interface {
+	GetTopic(ctx context.Context, req *pubsubpb.GetTopicRequest, opts ...gax.CallOption) (*pubsubpb.Topic, error)
+	TestIamPermissions(ctx context.Context, req *iampb.TestIamPermissionsRequest, opts ...gax.CallOption) (*iampb.TestIamPermissionsResponse, error)
+}
+
+// parsePreflightConfig reads VALIDATE. The checks contact Pub/Sub, so they
+// can't be combined with DRY_RUN or SINK.
+func parsePreflightConfig(config *Config) error {
+	if err := envBool(config.StrictEnv, "VALIDATE", &config.Validate); err != nil {
+		return err
+	}
+	if config.Validate && (config.DryRun || config.Sink != "") {
+		return fmt.Errorf("VALIDATE cannot be combined with DRY_RUN or SINK, which don't contact Pub/Sub")
+	}
+	return nil
+}
+
+// validateTopic checks before publishing that the topic exists and that
+// the caller may publish to it, so a wrong topic or a missing role fails
+// with an error saying what to fix instead of an opaque publish failure.
+// With pubsub.topics.get it also logs the topic's encryption, CMEK or
+// Google-managed, and its message storage regions.
+func validateTopic(ctx context.Context, config *Config, admin topicAdmin) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	topicName := fmt.Sprintf("projects/%s/topics/%s", config.ProjectID, config.TopicID)
+	resp, err := admin.TestIamPermissions(ctx, &iampb.TestIamPermissionsRequest{
+		Resource:    topicName,
+		Permissions: []string{publishPermission, getTopicPermission},
+	})
+	switch grpcCode(err) {
+	case codes.OK:
+	case codes.NotFound:
+		return fmt.Errorf("topic %s does not exist; create it or correct GCP_PROJECT_ID and PUBSUB_TOPIC_ID", topicName)
+	case codes.Unimplemented:
+		// The emulator has no IAM, so there is nothing to check
+		log.Printf("Warning: Skipping the permission check of topic %s, which the endpoint doesn't support: %v", topicName, err)
+		return nil
+	default:
+		return fmt.Errorf("failed to check permissions on topic %s: %w", topicName, err)
+	}
+
+	granted := resp.GetPermissions()
+	if !slices.Contains(granted, publishPermission) {
+		return fmt.Errorf("the credentials lack %s on topic %s; grant roles/pubsub.publisher on the topic or project", publishPermission, topicName)
+	}
+	if !slices.Contains(granted, getTopicPermission) {
+		log.Printf("Topic %s exists and may be published to; grant %s to have its encryption reported", topicName, getTopicPermission)
+		return nil
+	}
+
+	topic, err := admin.GetTopic(ctx, &pubsubpb.GetTopicRequest{Topic: topicName})
+	if err != nil {
+		return fmt.Errorf("failed to get topic %s: %w", topicName, err)
+	}
+	log.Printf("Topic %s exists and may be published to; %s", topicName, describeTopic(topic))
+	return nil
+}
+
+// describeTopic summarizes the encryption and message storage regions of a
+// topic for VALIDATE
+func describeTopic(topic *pubsubpb.Topic) string {
+	description := "encrypted with a Google-managed key"
+	if key := topic.GetKmsKeyName(); key != "" {
+		description = fmt.Sprintf("encrypted with CMEK key %s, which the Pub/Sub service agent must be able to use", key)
+	}
+	if regions := topic.GetMessageStoragePolicy().GetAllowedPersistenceRegions(); len(regions) > 0 {
+		description += fmt.Sprintf(", messages stored in %s", strings.Join(regions, ", "))
+	}
+	return description
+}
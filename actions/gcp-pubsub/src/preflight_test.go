@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeTopicAdmin answers the permission check with granted and returns
+// topic, or fails the check with err
+type fakeTopicAdmin struct {
+	granted []string
+	topic   *pubsubpb.Topic
+	err     error
+}
+
+func (a *fakeTopicAdmin) GetTopic(ctx context.Context, req *pubsubpb.GetTopicRequest, opts ...gax.CallOption) (*pubsubpb.Topic, error) {
+	return a.topic, nil
+}
+
+func (a *fakeTopicAdmin) TestIamPermissions(ctx context.Context, req *iampb.TestIamPermissionsRequest, opts ...gax.CallOption) (*iampb.TestIamPermissionsResponse, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &iampb.TestIamPermissionsResponse{Permissions: a.granted}, nil
+}
+
+func TestParsePreflightConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate string
+		config   Config
+		want     bool
+		wantErr  bool
+	}{
+		{name: "unset"},
+		{name: "enabled", validate: "true", want: true},
+		{name: "with dry run", validate: "true", config: Config{DryRun: true}, wantErr: true},
+		{name: "with sink", validate: "true", config: Config{Sink: sinkFile}, wantErr: true},
+		{name: "disabled with dry run", validate: "false", config: Config{DryRun: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VALIDATE", tt.validate)
+
+			config := tt.config
+			err := parsePreflightConfig(&config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePreflightConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.Validate != tt.want {
+				t.Errorf("Validate = %t, want %t", config.Validate, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTopic(t *testing.T) {
+	cmek := &pubsubpb.Topic{
+		Name:                 "projects/test-project/topics/alerts",
+		KmsKeyName:           "projects/test-project/locations/europe-west1/keyRings/alerts/cryptoKeys/pubsub",
+		MessageStoragePolicy: &pubsubpb.MessageStoragePolicy{AllowedPersistenceRegions: []string{"europe-west1"}},
+	}
+
+	tests := []struct {
+		name    string
+		admin   *fakeTopicAdmin
+		wantErr string
+		wantLog string
+	}{
+		{
+			name:    "cmek topic",
+			admin:   &fakeTopicAdmin{granted: []string{publishPermission, getTopicPermission}, topic: cmek},
+			wantLog: "encrypted with CMEK key projects/test-project/locations/europe-west1/keyRings/alerts/cryptoKeys/pubsub, which the Pub/Sub service agent must be able to use, messages stored in europe-west1",
+		},
+		{
+			name:    "google-managed key",
+			admin:   &fakeTopicAdmin{granted: []string{publishPermission, getTopicPermission}, topic: &pubsubpb.Topic{Name: cmek.Name}},
+			wantLog: "encrypted with a Google-managed key",
+		},
+		{
+			name:    "publish only",
+			admin:   &fakeTopicAdmin{granted: []string{publishPermission}},
+			wantLog: "grant pubsub.topics.get to have its encryption reported",
+		},
+		{
+			name:    "missing publish permission",
+			admin:   &fakeTopicAdmin{granted: []string{getTopicPermission}},
+			wantErr: "lack pubsub.topics.publish",
+		},
+		{
+			name:    "missing topic",
+			admin:   &fakeTopicAdmin{err: status.Error(codes.NotFound, "Resource not found")},
+			wantErr: "does not exist",
+		},
+		{
+			name:    "emulator without IAM",
+			admin:   &fakeTopicAdmin{err: status.Error(codes.Unimplemented, "unknown service google.iam.v1.IAMPolicy")},
+			wantLog: "Skipping the permission check",
+		},
+		{
+			name:    "other error",
+			admin:   &fakeTopicAdmin{err: status.Error(codes.Unavailable, "connection refused")},
+			wantErr: "failed to check permissions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ProjectID: "test-project", TopicID: "alerts", TimeoutSeconds: 10}
+
+			var err error
+			output := captureLog(t, func() { err = validateTopic(context.Background(), config, tt.admin) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateTopic() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateTopic() unexpected error: %v", err)
+			}
+			if !strings.Contains(output, tt.wantLog) {
+				t.Errorf("log = %q, want %q", output, tt.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protoSchemaFile is the file name the schema definition is compiled as,
+// which compile errors are reported against
+const protoSchemaFile = "schema.proto"
+
+// parseProtoSchema compiles a protocol buffer schema definition, which must
+// declare exactly one top-level message. Only the well-known types, e.g.
+// google/protobuf/timestamp.proto, can be imported.
+func parseProtoSchema(definition string) (protoreflect.MessageDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{protoSchemaFile: definition}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), protoSchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protocol buffer schema: %w", err)
+	}
+	messages := files[0].Messages()
+	if messages.Len() != 1 {
+		return nil, fmt.Errorf("invalid protocol buffer schema: expected one top-level message, got %d", messages.Len())
+	}
+	return messages.Get(0), nil
+}
+
+// newProtoMessage returns a message of the type filled from an alert value.
+// A well-known type, e.g. a google.protobuf.Timestamp, is read from its JSON
+// form, so a timestamp field can be filled from startsAt.
+func newProtoMessage(descriptor protoreflect.MessageDescriptor, value interface{}) (protoreflect.Message, error) {
+	message := dynamicpb.NewMessage(descriptor)
+	if descriptor.ParentFile().Package() == "google.protobuf" {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := protojson.Unmarshal(data, message); err != nil {
+			return nil, err
+		}
+		return message, nil
+	}
+
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", value)
+	}
+	descriptors := descriptor.Fields()
+	for i := 0; i < descriptors.Len(); i++ {
+		field := descriptors.Get(i)
+		if err := protoSetField(message, field, fields); err != nil {
+			return nil, fmt.Errorf("field '%s': %w", field.Name(), err)
+		}
+	}
+	return message, nil
+}
+
+// protoSetField sets the field from the alert value of the same name or JSON
+// name, so alert_name is filled from the alert's alertName. A field the
+// alert doesn't have is left unset.
+func protoSetField(message protoreflect.Message, field protoreflect.FieldDescriptor, fields map[string]interface{}) error {
+	value, ok := fields[string(field.Name())]
+	if !ok {
+		value, ok = fields[field.JSONName()]
+	}
+	if !ok || value == nil {
+		return nil
+	}
+
+	switch {
+	case field.IsMap():
+		entries, isMap := value.(map[string]interface{})
+		if !isMap {
+			return fmt.Errorf("expected an object, got %T", value)
+		}
+		converted := message.Mutable(field).Map()
+		for key, entry := range entries {
+			k, err := protoValue(field.MapKey(), key)
+			if err != nil {
+				return fmt.Errorf("key '%s': %w", key, err)
+			}
+			v, err := protoValue(field.MapValue(), entry)
+			if err != nil {
+				return fmt.Errorf("key '%s': %w", key, err)
+			}
+			converted.Set(k.MapKey(), v)
+		}
+	case field.IsList():
+		items, isList := value.([]interface{})
+		if !isList {
+			return fmt.Errorf("expected a list, got %T", value)
+		}
+		converted := message.Mutable(field).List()
+		for i, item := range items {
+			v, err := protoValue(field, item)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+			converted.Append(v)
+		}
+	default:
+		v, err := protoValue(field, value)
+		if err != nil {
+			return err
+		}
+		message.Set(field, v)
+	}
+	return nil
+}
+
+// protoValue converts an alert value to a single value of the field
+func protoValue(field protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, error) {
+	s, isString := value.(string)
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		message, err := newProtoMessage(field.Message(), value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(message), nil
+	case protoreflect.BoolKind:
+		if b, ok := value.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+		if isString {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return protoreflect.ValueOfBool(b), nil
+			}
+		}
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		switch v := value.(type) {
+		case bool, float64:
+			s, isString = fmt.Sprint(v), true
+		}
+		if isString && field.Kind() == protoreflect.BytesKind {
+			return protoreflect.ValueOfBytes([]byte(s)), nil
+		}
+		if isString {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.DoubleKind, protoreflect.FloatKind:
+		f, isNumber := value.(float64)
+		if isString {
+			var err error
+			f, err = strconv.ParseFloat(s, 64)
+			isNumber = err == nil
+		}
+		if isNumber && field.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+		if isNumber {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case protoreflect.EnumKind:
+		if isString {
+			if v := field.Enum().Values().ByName(protoreflect.Name(s)); v != nil {
+				return protoreflect.ValueOfEnum(v.Number()), nil
+			}
+			return protoreflect.Value{}, fmt.Errorf("'%s' is not a value of the enum", s)
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if u, ok := protoUint(value, 32); ok {
+			return protoreflect.ValueOfUint32(uint32(u)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if u, ok := protoUint(value, 64); ok {
+			return protoreflect.ValueOfUint64(u), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if i, ok := protoInt(value, 32); ok {
+			return protoreflect.ValueOfInt32(int32(i)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if i, ok := protoInt(value, 64); ok {
+			return protoreflect.ValueOfInt64(i), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot convert %v to %s", value, field.Kind())
+}
+
+// protoInt converts a whole number or numeric string to a signed integer of
+// the given bit size
+func protoInt(value interface{}, bitSize int) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		limit := math.Ldexp(1, bitSize-1)
+		return int64(v), v == math.Trunc(v) && v >= -limit && v < limit
+	case string:
+		i, err := strconv.ParseInt(v, 10, bitSize)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// protoUint converts a non-negative whole number or numeric string to an
+// unsigned integer of the given bit size
+func protoUint(value interface{}, bitSize int) (uint64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return uint64(v), v == math.Trunc(v) && v >= 0 && v < math.Ldexp(1, bitSize)
+	case string:
+		u, err := strconv.ParseUint(v, 10, bitSize)
+		return u, err == nil
+	}
+	return 0, false
+}
+
+// encodeProtoBinary appends the message in the protocol buffer wire format.
+// Map entries are sorted by key, so the same alert always encodes to the
+// same bytes.
+func encodeProtoBinary(b []byte, message protoreflect.Message) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.MarshalAppend(b, message.Interface())
+}
+
+// encodeProtoJSON returns the message in the proto3 JSON encoding: fields by
+// JSON name, 64-bit integers as strings, enums by name and bytes in base64.
+// protojson varies its whitespace between builds, so it is compacted.
+func encodeProtoJSON(message protoreflect.Message) ([]byte, error) {
+	data, err := protojson.Marshal(message.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, data); err != nil {
+		return nil, err
+	}
+	return compacted.Bytes(), nil
+}
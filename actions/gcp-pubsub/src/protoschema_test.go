@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testProtoSchema = `
+syntax = "proto3";
+
+package karo;
+
+// Alert is a single alert
+message Alert {
+  string alert_name = 1;
+  Severity severity = 2;
+  map<string, string> labels = 3;
+  int64 count = 4;
+  repeated Link links = 5;
+
+  enum Severity {
+    UNKNOWN = 0;
+    critical = 1;
+    warning = 2;
+  }
+
+  message Link {
+    string url = 1 [json_name = "url"];
+  }
+}
+`
+
+func TestParseProtoSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		wantFields int
+		wantErr    string
+	}{
+		{name: "nested enum", definition: `syntax = "proto3"; message Alert { string alert_name = 1; Severity severity = 2; enum Severity { UNKNOWN = 0; critical = 1; } }`, wantFields: 2},
+		{name: "oneof", definition: `message Alert { oneof target { string instance = 1; string job = 2; } }`, wantFields: 2},
+		{name: "schema", definition: testProtoSchema, wantFields: 5},
+		{name: "two messages", definition: `syntax = "proto3"; message Alert { string alert_name = 1; } message Link { string url = 1; }`, wantErr: "expected one top-level message, got 2"},
+		{name: "well-known import", definition: `syntax = "proto3"; import "google/protobuf/timestamp.proto"; message Alert { google.protobuf.Timestamp starts_at = 1; }`, wantFields: 1},
+		{name: "unknown type", definition: `syntax = "proto3"; message Alert { google.protobuf.Timestamp at = 1; }`, wantErr: "unknown type google.protobuf.Timestamp"},
+		{name: "other import", definition: `syntax = "proto3"; import "karo/alert.proto"; message Alert {}`, wantErr: `could not resolve path "karo/alert.proto"`},
+		{name: "invalid number", definition: `syntax = "proto3"; message Alert { string alert_name = x; }`, wantErr: "expecting int literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := parseProtoSchema(tt.definition)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseProtoSchema() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProtoSchema() unexpected error: %v", err)
+			}
+			if message.Fields().Len() != tt.wantFields {
+				t.Errorf("parseProtoSchema() parsed %d fields, want %d", message.Fields().Len(), tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestProtoEncoding(t *testing.T) {
+	message, err := parseProtoSchema(testProtoSchema)
+	if err != nil {
+		t.Fatalf("parseProtoSchema() unexpected error: %v", err)
+	}
+	fields := map[string]interface{}{
+		"alertName": "DiskFull",
+		"severity":  "critical",
+		"labels":    map[string]interface{}{"team": "db"},
+		"links":     []interface{}{map[string]interface{}{"url": "u"}},
+		"status":    "firing",
+	}
+
+	want := []byte{
+		0x0a, 0x08, 'D', 'i', 's', 'k', 'F', 'u', 'l', 'l',
+		0x10, 0x01,
+		0x1a, 0x0a, 0x0a, 0x04, 't', 'e', 'a', 'm', 0x12, 0x02, 'd', 'b',
+		0x2a, 0x03, 0x0a, 0x01, 'u',
+	}
+	filled, err := newProtoMessage(message, fields)
+	if err != nil {
+		t.Fatalf("newProtoMessage() unexpected error: %v", err)
+	}
+	got, err := encodeProtoBinary(nil, filled)
+	if err != nil {
+		t.Fatalf("encodeProtoBinary() unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeProtoBinary() = %x, want %x", got, want)
+	}
+
+	data, err := encodeProtoJSON(filled)
+	if err != nil {
+		t.Fatalf("encodeProtoJSON() unexpected error: %v", err)
+	}
+	if want := `{"alertName":"DiskFull","severity":"critical","labels":{"team":"db"},"links":[{"url":"u"}]}`; string(data) != want {
+		t.Errorf("encodeProtoJSON() = %s, want %s", data, want)
+	}
+
+	fields["count"] = "many"
+	if _, err := newProtoMessage(message, fields); err == nil || !strings.Contains(err.Error(), "field 'count': cannot convert many to int64") {
+		t.Errorf("newProtoMessage() error = %v, want a conversion error for count", err)
+	}
+}
+
+func TestProtoWellKnownType(t *testing.T) {
+	message, err := parseProtoSchema(`syntax = "proto3";
+import "google/protobuf/timestamp.proto";
+message Alert {
+  string alert_name = 1;
+  google.protobuf.Timestamp starts_at = 2;
+}`)
+	if err != nil {
+		t.Fatalf("parseProtoSchema() unexpected error: %v", err)
+	}
+
+	filled, err := newProtoMessage(message, map[string]interface{}{"alertName": "DiskFull", "startsAt": "2024-01-02T03:04:05Z"})
+	if err != nil {
+		t.Fatalf("newProtoMessage() unexpected error: %v", err)
+	}
+	data, err := encodeProtoJSON(filled)
+	if err != nil {
+		t.Fatalf("encodeProtoJSON() unexpected error: %v", err)
+	}
+	if want := `{"alertName":"DiskFull","startsAt":"2024-01-02T03:04:05Z"}`; string(data) != want {
+		t.Errorf("encodeProtoJSON() = %s, want %s", data, want)
+	}
+
+	if _, err := newProtoMessage(message, map[string]interface{}{"startsAt": "yesterday"}); err == nil || !strings.Contains(err.Error(), "field 'starts_at'") {
+		t.Errorf("newProtoMessage() error = %v, want an error for starts_at", err)
+	}
+}
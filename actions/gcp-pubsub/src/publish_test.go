@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+func TestPublishMessages(t *testing.T) {
+	srv := newFakePubSub(t, "test-project", "alerts")
+
+	tests := []struct {
+		name      string
+		topicID   string
+		count     int
+		wantErr   string
+		wantCount int
+	}{
+		{name: "batch is published", topicID: "alerts", count: 3, wantCount: 3},
+		{name: "single message", topicID: "alerts", count: 1, wantCount: 1},
+		{name: "batch failure names the count", topicID: "missing", count: 3, wantErr: "published 0 of 3 messages"},
+		{name: "single failure keeps its error", topicID: "missing", count: 1, wantErr: "failed to publish message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.ClearMessages()
+
+			client, err := pubsub.NewClient(context.Background(), "test-project")
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			defer client.Close()
+			publisher := &pubsubPublisher{client: client, publisher: client.Publisher(tt.topicID)}
+			defer publisher.publisher.Stop()
+
+			msgs := make([]*pubsub.Message, tt.count)
+			for i := range msgs {
+				msgs[i] = &pubsub.Message{Data: []byte(fmt.Sprintf(`{"alertName":"alert-%d"}`, i))}
+			}
+
+			captureLog(t, func() { err = publishMessages(context.Background(), publisher, msgs, 1, deliveryLimits{}) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("publishMessages() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("publishMessages() unexpected error: %v", err)
+			}
+			if got := len(srv.Messages()); got != tt.wantCount {
+				t.Errorf("published %d messages, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+// pendingResult counts the publishes whose result has not been read yet
+type pendingResult struct {
+	publisher *batchPublisher
+}
+
+func (r pendingResult) Get(ctx context.Context) (string, error) {
+	r.publisher.pending--
+	return "msg-1", nil
+}
+
+// batchPublisher records how many publishes were in flight at most
+type batchPublisher struct {
+	fakePublisher
+	pending, maxPending int
+}
+
+func (p *batchPublisher) Publish(ctx context.Context, msg *pubsub.Message) publishResult {
+	p.published = append(p.published, msg)
+	p.pending++
+	p.maxPending = max(p.maxPending, p.pending)
+	return pendingResult{publisher: p}
+}
+
+func TestPublishMessagesMaxConcurrency(t *testing.T) {
+	msgs := make([]*pubsub.Message, 5)
+	for i := range msgs {
+		msgs[i] = &pubsub.Message{Data: []byte(`{}`)}
+	}
+
+	tests := []struct {
+		name        string
+		limits      deliveryLimits
+		wantPending int
+	}{
+		{name: "unlimited publishes all at once", wantPending: 5},
+		{name: "batches of MAX_CONCURRENCY", limits: newDeliveryLimits(&Config{MaxConcurrency: 2}), wantPending: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &batchPublisher{}
+
+			var err error
+			captureLog(t, func() { err = publishMessages(context.Background(), publisher, msgs, 1, tt.limits) })
+			if err != nil {
+				t.Fatalf("publishMessages() unexpected error: %v", err)
+			}
+			if len(publisher.published) != len(msgs) || publisher.maxPending != tt.wantPending {
+				t.Errorf("published %d with at most %d in flight, want %d with %d", len(publisher.published), publisher.maxPending, len(msgs), tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestPublishMessagesRateLimitCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	publisher := &fakePublisher{}
+	limits := newDeliveryLimits(&Config{MaxConcurrency: 1, RateLimitPerSecond: 1})
+
+	var err error
+	msgs := []*pubsub.Message{{Data: []byte(`{}`)}}
+	captureLog(t, func() { err = publishMessages(ctx, publisher, msgs, 1, limits) })
+	if err == nil || len(publisher.published) != 0 {
+		t.Errorf("publishMessages() error = %v with %d published, want a failure without publishing", err, len(publisher.published))
+	}
+}
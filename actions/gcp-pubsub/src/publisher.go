@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// publishResult is the eventual outcome of a publish, satisfied by
+// *pubsub.PublishResult
+type publishResult interface {
+	Get(ctx context.Context) (serverID string, err error)
+}
+
+// failedPublish is the result of a publish that was never issued
+type failedPublish struct {
+	err error
+}
+
+func (r failedPublish) Get(ctx context.Context) (string, error) {
+	return "", r.err
+}
+
+// messagePublisher is the part of the Pub/Sub client used to publish alerts,
+// so the path from an alert to the published message can be tested with a
+// fake instead of the API or the emulator
+type messagePublisher interface {
+	Publish(ctx context.Context, msg *pubsub.Message) publishResult
+	// ResumePublish resumes an ordering key paused by a failed publish
+	ResumePublish(orderingKey string)
+	Close() error
+}
+
+// pubsubPublisher publishes to a topic through the Pub/Sub client
+type pubsubPublisher struct {
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+}
+
+// newPubSubPublisher creates the Pub/Sub client and the publisher for
+// PUBSUB_TOPIC_ID
+func newPubSubPublisher(ctx context.Context, config *Config) (*pubsubPublisher, error) {
+	_, span := tracer().Start(ctx, "create client", trace.WithAttributes(attribute.String("cloud.account.id", config.ProjectID)))
+	start := time.Now()
+	client, err := pubsub.NewClient(ctx, config.ProjectID, clientOptions(config)...)
+	clientMetrics.record(ctx, "NewClient", start, err)
+	if err != nil {
+		err = fmt.Errorf("failed to create Pub/Sub client: %w", err)
+		endSpan(span, err)
+		return nil, err
+	}
+	span.End()
+
+	publisher := client.Publisher(config.TopicID)
+	applyFlowControl(publisher, config)
+	return &pubsubPublisher{client: client, publisher: publisher}, nil
+}
+
+// Publish enables message ordering on the first message with an ordering
+// key, since the client rejects ordering keys otherwise
+func (p *pubsubPublisher) Publish(ctx context.Context, msg *pubsub.Message) publishResult {
+	if msg.OrderingKey != "" {
+		p.publisher.EnableMessageOrdering = true
+	}
+	return p.publisher.Publish(ctx, msg)
+}
+
+func (p *pubsubPublisher) ResumePublish(orderingKey string) {
+	p.publisher.ResumePublish(orderingKey)
+}
+
+// Close flushes the publisher and closes the client
+func (p *pubsubPublisher) Close() error {
+	p.publisher.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestPublisher creates the real publisher for config, e.g. against the
+// fake server started by newFakePubSub
+func newTestPublisher(t *testing.T, config *Config) messagePublisher {
+	t.Helper()
+
+	publisher, err := newPubSubPublisher(context.Background(), config)
+	if err != nil {
+		t.Fatalf("newPubSubPublisher() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { publisher.Close() })
+	return publisher
+}
+
+// fakeResult is a publish result that is already known
+type fakeResult struct {
+	id  string
+	err error
+}
+
+func (r fakeResult) Get(ctx context.Context) (string, error) {
+	return r.id, r.err
+}
+
+// fakePublisher records published messages and fails the first failures
+// publishes with Unavailable
+type fakePublisher struct {
+	published []*pubsub.Message
+	resumed   []string
+	failures  int
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msg *pubsub.Message) publishResult {
+	if p.failures > 0 {
+		p.failures--
+		return fakeResult{err: status.Error(codes.Unavailable, "backend unavailable")}
+	}
+	p.published = append(p.published, msg)
+	return fakeResult{id: "msg-1"}
+}
+
+func (p *fakePublisher) ResumePublish(orderingKey string) {
+	p.resumed = append(p.resumed, orderingKey)
+}
+
+func (p *fakePublisher) Close() error {
+	return nil
+}
+
+func TestPublishMessageFromAlertJSON(t *testing.T) {
+	t.Setenv("ALERT_JSON", `{"status":"firing","labels":{"alertname":"DiskFull","severity":"critical","instance":"node-1","team":"storage"},"annotations":{"summary":"Disk is full"}}`)
+	alertData, err := parseAlertData()
+	if err != nil {
+		t.Fatalf("parseAlertData() unexpected error: %v", err)
+	}
+
+	config := &Config{
+		TopicID:          "alerts",
+		TimeoutSeconds:   10,
+		RetryMaxAttempts: 1,
+		OrderingKeyField: "labels.instance",
+		AttributeLabels:  []string{"team"},
+		AttributePrefix:  "label_",
+	}
+	publisher := &fakePublisher{}
+	message := buildMessage(alertData, "prod-cluster", "")
+
+	captureLog(t, func() { err = publishMessage(context.Background(), config, publisher, message) })
+	if err != nil {
+		t.Fatalf("publishMessage() unexpected error: %v", err)
+	}
+	if len(publisher.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(publisher.published))
+	}
+	msg := publisher.published[0]
+
+	if msg.OrderingKey != "node-1" {
+		t.Errorf("ordering key = %q, want node-1", msg.OrderingKey)
+	}
+	assertAttributes(t, msg.Attributes, map[string]string{
+		"alertName":      "DiskFull",
+		"status":         "firing",
+		"severity":       "critical",
+		"source":         "prod-cluster",
+		"label_team":     "storage",
+		"idempotencyKey": idempotencyKey("", "", "DiskFull", message.Labels, "", "firing"),
+	})
+
+	var data PubSubMessage
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		t.Fatalf("data is not a JSON message: %v", err)
+	}
+	if data.AlertName != "DiskFull" || data.Summary != "Disk is full" || data.Instance != "node-1" {
+		t.Errorf("unexpected message data: %+v", data)
+	}
+}
+
+func TestPublishMessagesResumesOrderingKeyOnRetry(t *testing.T) {
+	initial := retryInitialBackoff
+	retryInitialBackoff = time.Millisecond
+	t.Cleanup(func() { retryInitialBackoff = initial })
+
+	publisher := &fakePublisher{failures: 1}
+	msgs := []*pubsub.Message{{Data: []byte(`{}`), OrderingKey: "node-1"}}
+
+	var err error
+	captureLog(t, func() { err = publishMessages(context.Background(), publisher, msgs, 2, deliveryLimits{}) })
+	if err != nil {
+		t.Fatalf("publishMessages() unexpected error: %v", err)
+	}
+	if len(publisher.published) != 1 || len(publisher.resumed) != 1 || publisher.resumed[0] != "node-1" {
+		t.Errorf("published %d, resumed %v, want one publish after resuming node-1", len(publisher.published), publisher.resumed)
+	}
+}
+
+// assertAttributes checks that got contains every attribute in want
+func assertAttributes(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("attribute %s = %q, want %q", key, got[key], value)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushgatewayJob is the job label of every push
+const pushgatewayJob = "karo_reactions"
+
+// pushTimeout bounds the push so an unreachable Pushgateway cannot delay
+// the exit of the action
+const pushTimeout = 5 * time.Second
+
+// reactionMetrics is pushed to PUSHGATEWAY_URL just before the action
+// exits. It is nil unless PUSHGATEWAY_URL is set, and all methods are no-ops
+// on a nil receiver.
+var reactionMetrics *pushMetrics
+
+// pushMetrics tracks the outcome of a run for the Pushgateway, since a
+// short-lived action cannot be scraped
+type pushMetrics struct {
+	url          string
+	alertStatus  string
+	callDuration time.Duration
+	called       bool
+	pushed       bool
+}
+
+// setupPushgateway enables reactionMetrics when PUSHGATEWAY_URL is set. It
+// reads the variable directly so configuration errors are pushed as
+// failures too.
+func setupPushgateway(url string) {
+	if url == "" {
+		return
+	}
+	reactionMetrics = &pushMetrics{url: url}
+}
+
+// setAlertStatus sets the alert_status grouping label of the push
+func (m *pushMetrics) setAlertStatus(status string) {
+	if m == nil {
+		return
+	}
+	m.alertStatus = status
+}
+
+// observeCall records the latency of the external call that started at start
+func (m *pushMetrics) observeCall(start time.Time) {
+	if m == nil {
+		return
+	}
+	m.callDuration = time.Since(start)
+	m.called = true
+}
+
+// push sends the run's outcome once. Failures are only logged, since the
+// metrics must never fail the reaction itself.
+func (m *pushMetrics) push(success bool) {
+	if m == nil || m.pushed {
+		return
+	}
+	m.pushed = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	if err := m.pusher(success).PushContext(ctx); err != nil {
+		log.Printf("Warning: Failed to push metrics to PUSHGATEWAY_URL: %v", err)
+	}
+}
+
+// pusher builds the push for the run, grouped by action and alert status so
+// runs of other actions and statuses don't replace each other's metrics.
+// Each push replaces the previous one in its group, so the counters reflect
+// the latest run and push_time_seconds tells when it happened.
+func (m *pushMetrics) pusher(success bool) *push.Pusher {
+	successes := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "karo_reaction_success_total",
+		Help: "Number of reactions that completed successfully",
+	})
+	failures := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "karo_reaction_failure_total",
+		Help: "Number of reactions that failed",
+	})
+	if success {
+		successes.Inc()
+	} else {
+		failures.Inc()
+	}
+
+	alertStatus := m.alertStatus
+	if alertStatus == "" {
+		alertStatus = "unknown"
+	}
+	pusher := push.New(m.url, pushgatewayJob).
+		Grouping("action", logActionName).
+		Grouping("alert_status", alertStatus).
+		Collector(successes).
+		Collector(failures)
+
+	if m.called {
+		duration := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "karo_reaction_duration_seconds",
+			Help: "Duration of the external call made by the reaction",
+		})
+		duration.Set(m.callDuration.Seconds())
+		pusher = pusher.Collector(duration)
+	}
+	return pusher
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// pushedMetrics decodes a push request into metric values by name
+func pushedMetrics(t *testing.T, r *http.Request) map[string]float64 {
+	t.Helper()
+	values := map[string]float64{}
+	decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+	for {
+		var family dto.MetricFamily
+		err := decoder.Decode(&family)
+		if errors.Is(err, io.EOF) {
+			return values
+		}
+		if err != nil {
+			t.Errorf("failed to decode pushed metrics: %v", err)
+			return values
+		}
+		for _, m := range family.GetMetric() {
+			switch {
+			case m.GetCounter() != nil:
+				values[family.GetName()] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[family.GetName()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		alertStatus string
+		success     bool
+		called      bool
+		wantStatus  string
+		wantSuccess float64
+		wantFailure float64
+	}{
+		{
+			name:        "success with call latency",
+			alertStatus: "firing",
+			success:     true,
+			called:      true,
+			wantStatus:  "firing",
+			wantSuccess: 1,
+		},
+		{
+			name:        "failure before the alert was parsed",
+			wantStatus:  "unknown",
+			wantFailure: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotPath string
+			var got map[string]float64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotMethod = r.Method
+				gotPath = r.URL.Path
+				got = pushedMetrics(t, r)
+			}))
+			defer server.Close()
+
+			m := &pushMetrics{url: server.URL}
+			m.setAlertStatus(tt.alertStatus)
+			if tt.called {
+				m.observeCall(time.Now().Add(-time.Second))
+			}
+			output := captureLog(t, func() {
+				m.push(tt.success)
+				m.push(tt.success)
+			})
+			if output != "" {
+				t.Errorf("unexpected log output: %s", output)
+			}
+
+			if gotMethod != http.MethodPut || !strings.HasPrefix(gotPath, "/metrics/job/karo_reactions/") ||
+				!strings.Contains(gotPath, "/action/gcp-pubsub") || !strings.Contains(gotPath, "/alert_status/"+tt.wantStatus) {
+				t.Errorf("push = %s %s, want PUT grouped by action and alert_status=%s", gotMethod, gotPath, tt.wantStatus)
+			}
+			if got["karo_reaction_success_total"] != tt.wantSuccess || got["karo_reaction_failure_total"] != tt.wantFailure {
+				t.Errorf("pushed metrics = %v, want success %v and failure %v", got, tt.wantSuccess, tt.wantFailure)
+			}
+			if _, ok := got["karo_reaction_duration_seconds"]; ok != tt.called {
+				t.Errorf("duration pushed = %t, want %t", ok, tt.called)
+			}
+		})
+	}
+}
+
+func TestPushMetricsFailureOnlyWarns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	m := &pushMetrics{url: server.URL}
+	output := captureLog(t, func() { m.push(true) })
+	if !strings.Contains(output, "Warning: Failed to push metrics") {
+		t.Errorf("expected a push warning, got: %s", output)
+	}
+
+	// Without PUSHGATEWAY_URL nothing is pushed
+	var disabled *pushMetrics
+	disabled.setAlertStatus("firing")
+	disabled.observeCall(time.Now())
+	disabled.push(true)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+)
+
+// registryMagicByte starts the Confluent wire format, followed by the
+// 4-byte big-endian schema ID and the Avro binary data
+const registryMagicByte = 0
+
+// registrySchema is a subject version returned by a Confluent-compatible
+// schema registry
+type registrySchema struct {
+	Subject    string `json:"subject"`
+	Version    int    `json:"version"`
+	ID         int    `json:"id"`
+	SchemaType string `json:"schemaType"`
+	Schema     string `json:"schema"`
+}
+
+// parseRegistryConfig reads SCHEMA_REGISTRY_URL and the subject, version
+// and basic auth credentials used to look up the schema. The registered
+// schema decides the data layout, so it can't be combined with
+// TOPIC_SCHEMA.
+func parseRegistryConfig(config *Config) error {
+	config.SchemaRegistryURL = strings.TrimRight(os.Getenv("SCHEMA_REGISTRY_URL"), "/")
+	config.RegistrySubject = os.Getenv("SCHEMA_REGISTRY_SUBJECT")
+	config.RegistryVersion = os.Getenv("SCHEMA_REGISTRY_VERSION")
+	config.RegistryUsername = os.Getenv("SCHEMA_REGISTRY_USERNAME")
+	config.RegistryPassword = os.Getenv("SCHEMA_REGISTRY_PASSWORD")
+
+	if config.SchemaRegistryURL == "" {
+		if config.RegistrySubject != "" || config.RegistryVersion != "" || config.RegistryUsername != "" {
+			return fmt.Errorf("SCHEMA_REGISTRY_SUBJECT, SCHEMA_REGISTRY_VERSION and SCHEMA_REGISTRY_USERNAME require SCHEMA_REGISTRY_URL")
+		}
+		return nil
+	}
+	parsed, err := url.Parse(config.SchemaRegistryURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("SCHEMA_REGISTRY_URL must be an http or https URL")
+	}
+	if config.TopicSchema {
+		return fmt.Errorf("SCHEMA_REGISTRY_URL and TOPIC_SCHEMA are mutually exclusive")
+	}
+
+	switch version := config.RegistryVersion; version {
+	case "":
+		config.RegistryVersion = "latest"
+	case "latest":
+	default:
+		if n, err := strconv.Atoi(version); err != nil || n < 1 {
+			return fmt.Errorf("unsupported SCHEMA_REGISTRY_VERSION '%s', must be 'latest' or a version number", version)
+		}
+	}
+	return nil
+}
+
+// registrySubject returns SCHEMA_REGISTRY_SUBJECT, or the topic's value
+// subject, "<topic>-value", as named by the registry's default
+// TopicNameStrategy
+func registrySubject(config *Config) string {
+	if config.RegistrySubject != "" {
+		return config.RegistrySubject
+	}
+	return config.TopicID + "-value"
+}
+
+// loadRegistrySchema looks up the Avro schema registered for the subject,
+// which messages are then encoded with in the Confluent wire format
+func loadRegistrySchema(ctx context.Context, config *Config) (*topicSchema, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	subject := registrySubject(config)
+	endpoint := fmt.Sprintf("%s/subjects/%s/versions/%s", config.SchemaRegistryURL, url.PathEscape(subject), config.RegistryVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if config.RegistryUsername != "" {
+		req.SetBasicAuth(config.RegistryUsername, config.RegistryPassword)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subject %s from the schema registry: %w", subject, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subject %s from the schema registry: %w", subject, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get version %s of subject %s from the schema registry: %s: %s", config.RegistryVersion, subject, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var registered registrySchema
+	if err := json.Unmarshal(body, &registered); err != nil {
+		return nil, fmt.Errorf("failed to parse subject %s from the schema registry: %w", subject, err)
+	}
+	parsed, err := newRegistrySchema(&registered)
+	if err != nil {
+		return nil, fmt.Errorf("subject %s version %d: %w", subject, registered.Version, err)
+	}
+	log.Printf("Encoding messages with subject %s version %d (schema ID %d, Avro, Confluent wire format)", subject, registered.Version, registered.ID)
+	return parsed, nil
+}
+
+// newRegistrySchema parses a registered schema. Only Avro is supported; the
+// registry leaves schemaType out for Avro schemas.
+func newRegistrySchema(registered *registrySchema) (*topicSchema, error) {
+	if registered.SchemaType != "" && registered.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("unsupported schema type %s, must be AVRO", registered.SchemaType)
+	}
+	if registered.ID < 1 {
+		return nil, fmt.Errorf("invalid schema ID %d", registered.ID)
+	}
+	schema, err := parseAvroSchema(registered.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return &topicSchema{
+		name:       fmt.Sprintf("%s version %d", registered.Subject, registered.Version),
+		encoding:   pubsubpb.Encoding_BINARY,
+		avro:       schema,
+		registryID: registered.ID,
+	}, nil
+}
+
+// appendRegistryHeader appends the Confluent wire format header, the magic
+// byte and the schema ID, which consumers use to look up the schema
+func appendRegistryHeader(b []byte, id int) []byte {
+	b = append(b, registryMagicByte)
+	return binary.BigEndian.AppendUint32(b, uint32(id))
+}
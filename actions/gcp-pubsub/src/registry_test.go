@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRegistryConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		config      Config
+		wantVersion string
+		wantErr     bool
+	}{
+		{name: "unset", env: map[string]string{}},
+		{name: "default version", env: map[string]string{"SCHEMA_REGISTRY_URL": "https://registry.example.com/"}, wantVersion: "latest"},
+		{name: "pinned version", env: map[string]string{"SCHEMA_REGISTRY_URL": "http://registry:8081", "SCHEMA_REGISTRY_VERSION": "3"}, wantVersion: "3"},
+		{name: "invalid version", env: map[string]string{"SCHEMA_REGISTRY_URL": "http://registry:8081", "SCHEMA_REGISTRY_VERSION": "v3"}, wantErr: true},
+		{name: "not a url", env: map[string]string{"SCHEMA_REGISTRY_URL": "registry:8081"}, wantErr: true},
+		{name: "subject without url", env: map[string]string{"SCHEMA_REGISTRY_SUBJECT": "alerts-value"}, wantErr: true},
+		{name: "with topic schema", env: map[string]string{"SCHEMA_REGISTRY_URL": "http://registry:8081"}, config: Config{TopicSchema: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SCHEMA_REGISTRY_URL", "SCHEMA_REGISTRY_SUBJECT", "SCHEMA_REGISTRY_VERSION", "SCHEMA_REGISTRY_USERNAME", "SCHEMA_REGISTRY_PASSWORD"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := tt.config
+			err := parseRegistryConfig(&config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRegistryConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.RegistryVersion != tt.wantVersion {
+				t.Errorf("RegistryVersion = %q, want %q", config.RegistryVersion, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrySchema(t *testing.T) {
+	var gotPath, gotUser, gotPassword string
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUser, gotPassword, _ = r.BasicAuth()
+		json.NewEncoder(w).Encode(registrySchema{Subject: "alerts-value", Version: 2, ID: 258, Schema: testAvroSchema})
+	}))
+	defer registry.Close()
+
+	config := &Config{
+		TopicID:           "alerts",
+		SchemaRegistryURL: registry.URL,
+		RegistryVersion:   "latest",
+		RegistryUsername:  "key",
+		RegistryPassword:  "secret",
+		TimeoutSeconds:    10,
+	}
+	schema, err := loadTopicSchema(context.Background(), config, nil)
+	if err != nil {
+		t.Fatalf("loadTopicSchema() unexpected error: %v", err)
+	}
+	if gotPath != "/subjects/alerts-value/versions/latest" {
+		t.Errorf("request path = %q, want the topic's value subject", gotPath)
+	}
+	if gotUser != "key" || gotPassword != "secret" {
+		t.Errorf("basic auth = %q:%q, want key:secret", gotUser, gotPassword)
+	}
+
+	data, err := schema.encode(&PubSubMessage{AlertName: "DiskFull", Severity: "critical", Labels: map[string]string{"team": "db"}})
+	if err != nil {
+		t.Fatalf("encode() unexpected error: %v", err)
+	}
+	// Magic byte, schema ID 258 big-endian, then the Avro binary data
+	want := "\x00\x00\x00\x01\x02" + "\x10DiskFull\x00\x00\x01\x10\x08team\x04db\x00"
+	if string(data) != want {
+		t.Errorf("encode() = %q, want %q", data, want)
+	}
+}
+
+func TestLoadRegistrySchemaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "missing subject",
+			status:  http.StatusNotFound,
+			body:    `{"error_code":40401,"message":"Subject 'alerts-value' not found."}`,
+			wantErr: "404 Not Found",
+		},
+		{
+			name:    "protobuf schema",
+			status:  http.StatusOK,
+			body:    `{"subject":"alerts-value","version":1,"id":7,"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";"}`,
+			wantErr: "unsupported schema type PROTOBUF",
+		},
+		{
+			name:    "invalid schema",
+			status:  http.StatusOK,
+			body:    `{"subject":"alerts-value","version":1,"id":7,"schema":"\"string\""}`,
+			wantErr: "subject alerts-value version 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer registry.Close()
+
+			config := &Config{TopicID: "alerts", SchemaRegistryURL: registry.URL, RegistryVersion: "latest", TimeoutSeconds: 10}
+			_, err := loadRegistrySchema(context.Background(), config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("loadRegistrySchema() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// defaultRetryMaxAttempts is the default RETRY_MAX_ATTEMPTS, counting the
+// first attempt
+const defaultRetryMaxAttempts = 3
+
+// Backoff between publish attempts, doubling from the initial delay up to
+// the maximum. Variables so tests can shorten them.
+var (
+	retryInitialBackoff = 500 * time.Millisecond
+	retryMaxBackoff     = 10 * time.Second
+)
+
+// isRetryableError reports whether a publish error is transient and may
+// succeed on another attempt. Errors about the request itself, such as
+// NotFound or PermissionDenied, fail the same way every time.
+func isRetryableError(ctx context.Context, err error) bool {
+	// The run's own deadline or cancellation leaves no time to retry
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch grpcCode(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryBackoff returns the delay before the given retry, counting from 1
+func retryBackoff(retry int) time.Duration {
+	backoff := retryInitialBackoff
+	for i := 1; i < retry && backoff < retryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > retryMaxBackoff {
+		return retryMaxBackoff
+	}
+	return backoff
+}
+
+// sleepContext waits for d, returning early with the context's error when it
+// is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
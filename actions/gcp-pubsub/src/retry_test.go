@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "unavailable", ctx: context.Background(), err: status.Error(codes.Unavailable, "blip"), want: true},
+		{name: "deadline exceeded", ctx: context.Background(), err: status.Error(codes.DeadlineExceeded, "slow"), want: true},
+		{name: "publish timeout", ctx: context.Background(), err: context.DeadlineExceeded, want: true},
+		{name: "internal", ctx: context.Background(), err: status.Error(codes.Internal, "oops"), want: true},
+		{name: "resource exhausted", ctx: context.Background(), err: status.Error(codes.ResourceExhausted, "quota"), want: true},
+		{name: "not found", ctx: context.Background(), err: status.Error(codes.NotFound, "no topic"), want: false},
+		{name: "permission denied", ctx: context.Background(), err: status.Error(codes.PermissionDenied, "denied"), want: false},
+		{name: "unknown", ctx: context.Background(), err: errors.New("boom"), want: false},
+		{name: "run cancelled", ctx: cancelled, err: status.Error(codes.Unavailable, "blip"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("isRetryableError(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := retryBackoff(i + 1); got != w {
+			t.Errorf("retryBackoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+// failPublishes fails every Publish call with Unavailable
+type failPublishes struct{}
+
+func (failPublishes) React(_ interface{}) (bool, interface{}, error) {
+	return true, nil, status.Error(codes.Unavailable, "blip")
+}
+
+func TestPublishMessagesRetries(t *testing.T) {
+	initial := retryInitialBackoff
+	retryInitialBackoff = time.Millisecond
+	t.Cleanup(func() { retryInitialBackoff = initial })
+
+	tests := []struct {
+		name        string
+		topicID     string
+		failing     bool
+		maxAttempts int
+		wantErr     []string
+		wantRetries bool
+	}{
+		{name: "transient error is retried", topicID: "alerts", failing: true, maxAttempts: 3, wantErr: []string{"after 3 attempt(s)"}, wantRetries: true},
+		{name: "retries disabled", topicID: "alerts", failing: true, maxAttempts: 1, wantErr: []string{"after 1 attempt(s)"}},
+		{name: "not found is not retried", topicID: "missing", maxAttempts: 3, wantErr: []string{"after 1 attempt(s)", "code NotFound"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []pstest.ServerReactorOption
+			if tt.failing {
+				opts = append(opts, pstest.ServerReactorOption{FuncName: "Publish", Reactor: failPublishes{}})
+			}
+			srv := pstest.NewServer(opts...)
+			t.Cleanup(func() { srv.Close() })
+			t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+			if _, err := srv.GServer.CreateTopic(context.Background(), &pubsubpb.Topic{Name: "projects/test-project/topics/alerts"}); err != nil {
+				t.Fatalf("failed to create topic: %v", err)
+			}
+
+			client, err := pubsub.NewClient(context.Background(), "test-project")
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			defer client.Close()
+			publisher := &pubsubPublisher{client: client, publisher: client.Publisher(tt.topicID)}
+			defer publisher.publisher.Stop()
+			// Give up on the client's own retries quickly so the error surfaces
+			publisher.publisher.PublishSettings.Timeout = 50 * time.Millisecond
+
+			msgs := []*pubsub.Message{{Data: []byte(`{"alertName":"DiskFull"}`)}}
+			output := captureLog(t, func() { err = publishMessages(context.Background(), publisher, msgs, tt.maxAttempts, deliveryLimits{}) })
+
+			for _, want := range tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("publishMessages() error = %v, want it to contain %q", err, want)
+				}
+			}
+			if got := strings.Contains(output, "Retrying 1 message(s)"); got != tt.wantRetries {
+				t.Errorf("retried = %t, want %t:\n%s", got, tt.wantRetries, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// topicRoute is an entry of ROUTES_JSON: the topic that alerts matching all
+// of its matchers are published to
+type topicRoute struct {
+	Name string `json:"name,omitempty"`
+	// Match maps message fields, e.g. severity or labels.team, to regular
+	// expressions their whole value must match; a route without matchers
+	// matches every alert
+	Match    map[string]string `json:"match,omitempty"`
+	Topic    string            `json:"topic"`
+	matchers map[string]*regexp.Regexp
+}
+
+// parseRoutes parses ROUTES_JSON, a JSON array of routes evaluated in order,
+// naming unnamed routes by position
+func parseRoutes(spec string) ([]topicRoute, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var routes []topicRoute
+	decoder := json.NewDecoder(strings.NewReader(spec))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&routes); err != nil {
+		return nil, fmt.Errorf("failed to parse ROUTES_JSON: %w", err)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("ROUTES_JSON must contain at least one route")
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+		if route.Topic == "" {
+			return nil, fmt.Errorf("ROUTES_JSON route '%s' is missing topic", route.Name)
+		}
+
+		route.matchers = make(map[string]*regexp.Regexp, len(route.Match))
+		for field, pattern := range route.Match {
+			if !isMessageField(field) {
+				return nil, fmt.Errorf("ROUTES_JSON route '%s' matches unknown message field '%s'", route.Name, field)
+			}
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("ROUTES_JSON route '%s' has an invalid pattern for %s: %w", route.Name, field, err)
+			}
+			route.matchers[field] = re
+		}
+	}
+	return routes, nil
+}
+
+// matches reports whether every matcher of the route matches the message
+func (r topicRoute) matches(message *PubSubMessage) bool {
+	for field, re := range r.matchers {
+		if !re.MatchString(extractMessageField(message, field)) {
+			return false
+		}
+	}
+	return true
+}
+
+// routeMessage sets PUBSUB_TOPIC_ID to the topic of the first route of
+// ROUTES_JSON that matches the alert, e.g. to publish critical alerts to
+// the on-call team's topic. Alerts no route matches fall back to routeTopic.
+func routeMessage(config *Config, message *PubSubMessage) error {
+	for _, route := range config.Routes {
+		if !route.matches(message) {
+			continue
+		}
+		config.TopicID = route.Topic
+		log.Printf("Routing alert to topic %s by route %s of ROUTES_JSON", route.Topic, route.Name)
+		return nil
+	}
+
+	err := routeTopic(config, message.Status)
+	if err != nil && len(config.Routes) > 0 {
+		return fmt.Errorf("no route in ROUTES_JSON matches the alert, and %w", err)
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    int
+		wantErr string
+	}{
+		{name: "unset", spec: ""},
+		{name: "routes", spec: `[{"match": {"severity": "critical"}, "topic": "ops-critical"}, {"topic": "alerts"}]`, want: 2},
+		{name: "empty", spec: `[]`, wantErr: "at least one route"},
+		{name: "missing topic", spec: `[{"match": {"severity": "critical"}}]`, wantErr: "route 'route-1' is missing topic"},
+		{name: "unknown field", spec: `[{"match": {"priority": "P1"}, "topic": "ops-critical"}]`, wantErr: "unknown message field 'priority'"},
+		{name: "invalid pattern", spec: `[{"match": {"severity": "("}, "topic": "ops-critical"}]`, wantErr: "invalid pattern for severity"},
+		{name: "unknown key", spec: `[{"matches": {"severity": "critical"}, "topic": "ops-critical"}]`, wantErr: "failed to parse ROUTES_JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := parseRoutes(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRoutes() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoutes() unexpected error: %v", err)
+			}
+			if len(routes) != tt.want {
+				t.Errorf("parseRoutes() returned %d routes, want %d", len(routes), tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteMessage(t *testing.T) {
+	routes, err := parseRoutes(`[
+		{"name": "critical", "match": {"severity": "critical"}, "topic": "ops-critical"},
+		{"match": {"labels.team": "payments"}, "topic": "payments-alerts"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseRoutes() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		message  PubSubMessage
+		fallback string
+		want     string
+		wantErr  string
+	}{
+		{name: "severity", message: PubSubMessage{Severity: "critical", Labels: map[string]string{"team": "payments"}}, want: "ops-critical"},
+		{name: "label", message: PubSubMessage{Severity: "warning", Labels: map[string]string{"team": "payments"}}, want: "payments-alerts"},
+		{name: "whole value", message: PubSubMessage{Severity: "critical-ish", Status: "firing"}, fallback: "alerts", want: "alerts"},
+		{name: "no match", message: PubSubMessage{Severity: "info", Status: "firing"}, wantErr: "no route in ROUTES_JSON matches the alert, and PUBSUB_TOPIC_ID_FIRING or PUBSUB_TOPIC_ID is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Routes: routes, TopicID: tt.fallback}
+
+			var err error
+			captureLog(t, func() { err = routeMessage(config, &tt.message) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("routeMessage() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("routeMessage() unexpected error: %v", err)
+			}
+			if config.TopicID != tt.want {
+				t.Errorf("TopicID = %q, want %q", config.TopicID, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRoutesOnly(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "test-project")
+	t.Setenv("PUBSUB_TOPIC_ID", "")
+	t.Setenv("ROUTES_JSON", `[{"match": {"severity": "critical"}, "topic": "ops-critical"}, {"topic": "alerts"}]`)
+
+	var config *Config
+	var err error
+	captureLog(t, func() { config, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+	if len(config.Routes) != 2 {
+		t.Errorf("loadConfig() parsed %d routes, want 2", len(config.Routes))
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Alert statuses that can be routed to their own destination
+const (
+	statusFiring   = "firing"
+	statusResolved = "resolved"
+)
+
+// statusOverride returns the destination configured for status by the
+// _FIRING or _RESOLVED variant of a variable, or "" when there is none and
+// the generic variable applies
+func statusOverride(firing, resolved, status string) string {
+	switch strings.ToLower(status) {
+	case statusFiring:
+		return firing
+	case statusResolved:
+		return resolved
+	}
+	return ""
+}
+
+// missingRouteError reports that neither the status-specific variant of name
+// nor name itself is set for an alert with status
+func missingRouteError(name, status string) error {
+	switch s := strings.ToLower(status); s {
+	case statusFiring, statusResolved:
+		return fmt.Errorf("%s_%s or %s is required for %s alerts", name, strings.ToUpper(s), name, s)
+	}
+	return fmt.Errorf("%s is required for alerts with status '%s'", name, status)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	vkit "cloud.google.com/go/pubsub/v2/apiv1"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// topicSchema is the schema attached to the topic, or registered in the
+// schema registry, which published message data must conform to
+type topicSchema struct {
+	name     string
+	encoding pubsubpb.Encoding
+	avro     *avro.RecordSchema
+	proto    protoreflect.MessageDescriptor
+
+	// registryID is the schema registry ID prefixed to the data in the
+	// Confluent wire format, 0 for a Pub/Sub schema
+	registryID int
+}
+
+// parseSchemaConfig reads TOPIC_SCHEMA. Looking up the schema needs
+// pubsub.topics.get and pubsub.schemas.get, which the publisher role lacks,
+// so it is opt-in.
+func parseSchemaConfig(config *Config) error {
+	return envBool(config.StrictEnv, "TOPIC_SCHEMA", &config.TopicSchema)
+}
+
+// loadTopicSchema looks up the schema of PUBSUB_TOPIC_ID with TOPIC_SCHEMA
+// set, or the registered schema with SCHEMA_REGISTRY_URL set. It returns nil
+// for a topic without a schema, whose messages stay JSON.
+func loadTopicSchema(ctx context.Context, config *Config, client *pubsub.Client) (*topicSchema, error) {
+	if config.SchemaRegistryURL != "" {
+		return loadRegistrySchema(ctx, config)
+	}
+	if !config.TopicSchema {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	topicName := fmt.Sprintf("projects/%s/topics/%s", config.ProjectID, config.TopicID)
+	topic, err := client.TopicAdminClient.GetTopic(ctx, &pubsubpb.GetTopicRequest{Topic: topicName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topic %s: %w", topicName, err)
+	}
+	settings := topic.GetSchemaSettings()
+	if settings.GetSchema() == "" {
+		log.Printf("Topic %s has no schema, publishing JSON", config.TopicID)
+		return nil, nil
+	}
+
+	schemaClient, err := vkit.NewSchemaClient(ctx, schemaClientOptions(config)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema client: %w", err)
+	}
+	defer schemaClient.Close()
+
+	schema, err := schemaClient.GetSchema(ctx, &pubsubpb.GetSchemaRequest{Name: settings.GetSchema(), View: pubsubpb.SchemaView_FULL})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema %s: %w", settings.GetSchema(), err)
+	}
+
+	parsed, err := newTopicSchema(schema, settings.GetEncoding())
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", settings.GetSchema(), err)
+	}
+	log.Printf("Encoding messages with schema %s (%s, %s)", schema.GetName(), schema.GetType(), settings.GetEncoding())
+	return parsed, nil
+}
+
+// schemaClientOptions returns the client options for the schema service.
+// Unlike pubsub.NewClient, the generated client doesn't read
+// PUBSUB_EMULATOR_HOST, so the emulator connection is set up here.
+func schemaClientOptions(config *Config) []option.ClientOption {
+	if config.EmulatorHost == "" {
+		return clientOptions(config)
+	}
+	return []option.ClientOption{
+		option.WithEndpoint(config.EmulatorHost),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithoutAuthentication(),
+	}
+}
+
+// newTopicSchema parses the definition of an Avro or protocol buffer schema
+func newTopicSchema(schema *pubsubpb.Schema, encoding pubsubpb.Encoding) (*topicSchema, error) {
+	if encoding != pubsubpb.Encoding_JSON && encoding != pubsubpb.Encoding_BINARY {
+		return nil, fmt.Errorf("unsupported message encoding %s", encoding)
+	}
+
+	parsed := &topicSchema{name: schema.GetName(), encoding: encoding}
+	var err error
+	switch schema.GetType() {
+	case pubsubpb.Schema_AVRO:
+		parsed.avro, err = parseAvroSchema(schema.GetDefinition())
+	case pubsubpb.Schema_PROTOCOL_BUFFER:
+		parsed.proto, err = parseProtoSchema(schema.GetDefinition())
+	default:
+		err = fmt.Errorf("unsupported schema type %s", schema.GetType())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// binary reports whether messages are encoded in binary rather than JSON
+func (s *topicSchema) binary() bool {
+	return s.encoding == pubsubpb.Encoding_BINARY
+}
+
+// encode encodes the message with the schema. Schema fields are filled from
+// the message fields of the same name, e.g. alertName or labels; a message
+// that can't satisfy the schema fails with an error naming the field.
+func (s *topicSchema) encode(message *PubSubMessage) ([]byte, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	if s.avro != nil {
+		values, err := avroConvertRecord(s.avro, fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map alert to Avro schema: %w", err)
+		}
+		switch {
+		case s.registryID != 0:
+			data, err = encodeAvroBinary(appendRegistryHeader(nil, s.registryID), s.avro, values)
+		case s.binary():
+			data, err = encodeAvroBinary(nil, s.avro, values)
+		default:
+			data, err = json.Marshal(avroJSONValue(s.avro, values))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode alert with Avro schema: %w", err)
+		}
+		return data, nil
+	}
+
+	filled, err := newProtoMessage(s.proto, fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map alert to protocol buffer schema: %w", err)
+	}
+	if s.binary() {
+		data, err = encodeProtoBinary(nil, filled)
+	} else {
+		data, err = encodeProtoJSON(filled)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode alert with protocol buffer schema: %w", err)
+	}
+	return data, nil
+}
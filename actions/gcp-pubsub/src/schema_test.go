@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+)
+
+func TestTopicSchemaEncode(t *testing.T) {
+	message := &PubSubMessage{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Severity:  "critical",
+		Labels:    map[string]string{"team": "db"},
+	}
+
+	tests := []struct {
+		name     string
+		schema   *pubsubpb.Schema
+		encoding pubsubpb.Encoding
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "avro json",
+			schema:   &pubsubpb.Schema{Type: pubsubpb.Schema_AVRO, Definition: testAvroSchema},
+			encoding: pubsubpb.Encoding_JSON,
+			want:     `{"alertName":"DiskFull","count":null,"labels":{"team":"db"},"severity":"critical"}`,
+		},
+		{
+			name:     "avro binary",
+			schema:   &pubsubpb.Schema{Type: pubsubpb.Schema_AVRO, Definition: testAvroSchema},
+			encoding: pubsubpb.Encoding_BINARY,
+			want:     "\x10DiskFull\x00\x00\x01\x10\x08team\x04db\x00",
+		},
+		{
+			name:     "protocol buffer json",
+			schema:   &pubsubpb.Schema{Type: pubsubpb.Schema_PROTOCOL_BUFFER, Definition: testProtoSchema},
+			encoding: pubsubpb.Encoding_JSON,
+			want:     `{"alertName":"DiskFull","severity":"critical","labels":{"team":"db"}}`,
+		},
+		{
+			name:     "unmappable field",
+			schema:   &pubsubpb.Schema{Type: pubsubpb.Schema_AVRO, Definition: `{"type": "record", "name": "Alert", "fields": [{"name": "priority", "type": "int"}]}`},
+			encoding: pubsubpb.Encoding_JSON,
+			wantErr:  "failed to map alert to Avro schema: field 'priority'",
+		},
+		{
+			name:     "unspecified encoding",
+			schema:   &pubsubpb.Schema{Type: pubsubpb.Schema_AVRO, Definition: testAvroSchema},
+			encoding: pubsubpb.Encoding_ENCODING_UNSPECIFIED,
+			wantErr:  "unsupported message encoding",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := newTopicSchema(tt.schema, tt.encoding)
+			var data []byte
+			if err == nil {
+				data, err = schema.encode(message)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("encode() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encode() unexpected error: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("encode() = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// sinkFile writes messages to a local directory instead of Pub/Sub
+const sinkFile = "file"
+
+// FileSinkRecord is the content written by the file sink for each alert.
+// It mirrors the Pub/Sub message that would have been published.
+type FileSinkRecord struct {
+	Topic       string            `json:"topic"`
+	Data        json.RawMessage   `json:"data"`
+	Attributes  map[string]string `json:"attributes"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+// validateSink checks the SINK and SINK_DIR settings
+func validateSink(sink, dir string) error {
+	switch sink {
+	case "":
+		return nil
+	case sinkFile:
+		if dir == "" {
+			return fmt.Errorf("SINK_DIR is required when SINK=%s", sinkFile)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported SINK '%s', must be '%s' or unset", sink, sinkFile)
+	}
+}
+
+// writeFileSink writes the message that would have been published to a new
+// file in the sink directory and returns its path
+func writeFileSink(config *Config, message *PubSubMessage) (string, error) {
+	record, err := buildSinkRecord(config, message)
+	if err != nil {
+		return "", err
+	}
+
+	return writeSinkFile(config.SinkDir, sinkFileName(message.AlertName, message.Status, time.Now()), record)
+}
+
+// buildSinkRecord builds the record of the message that would have been
+// published, shared by the file sink and DRY_RUN
+func buildSinkRecord(config *Config, message *PubSubMessage) (FileSinkRecord, error) {
+	pubsubMsg, err := buildPubSubMessage(config, message)
+	if err != nil {
+		return FileSinkRecord{}, err
+	}
+
+	return FileSinkRecord{
+		Topic:       fmt.Sprintf("projects/%s/topics/%s", config.ProjectID, config.TopicID),
+		Data:        sinkData(pubsubMsg.Data),
+		Attributes:  pubsubMsg.Attributes,
+		OrderingKey: pubsubMsg.OrderingKey,
+	}, nil
+}
+
+// sinkData returns the message data as it is written to the record: JSON
+// as it is, text, e.g. a MESSAGE_TEMPLATE rendering plain text, as a JSON
+// string, and binary data, e.g. with COMPRESS=gzip, as a base64 string
+func sinkData(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	if utf8.Valid(data) {
+		quoted, _ := json.Marshal(string(data))
+		return quoted
+	}
+	encoded, _ := json.Marshal(data)
+	return encoded
+}
+
+// sinkFileName builds a file name that sorts chronologically and is safe on
+// any filesystem, e.g. 20240101T120000.000000000Z-HighCPU-firing.json
+func sinkFileName(alertName, status string, now time.Time) string {
+	return fmt.Sprintf("%s-%s-%s.json",
+		now.UTC().Format("20060102T150405.000000000Z"),
+		sanitizeFileComponent(alertName, "alert"),
+		sanitizeFileComponent(status, "unknown"))
+}
+
+// sanitizeFileComponent replaces characters that are not safe in file names
+func sanitizeFileComponent(value, fallback string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+
+	sanitized = strings.Trim(sanitized, "._")
+	if sanitized == "" {
+		return fallback
+	}
+	return sanitized
+}
+
+// writeSinkFile writes the record as indented JSON, refusing to overwrite an
+// existing file
+func writeSinkFile(dir, name string, record interface{}) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create sink directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal sink record: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sink file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write sink file: %w", err)
+	}
+
+	return path, nil
+}
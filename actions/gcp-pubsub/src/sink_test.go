@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "unset", sink: ""},
+		{name: "file with directory", sink: "file", dir: "/tmp/out"},
+		{name: "file without directory", sink: "file", wantErr: true},
+		{name: "unknown sink", sink: "s3", dir: "/tmp/out", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSink(tt.sink, tt.dir); (err != nil) != tt.wantErr {
+				t.Errorf("validateSink(%q, %q) error = %v, wantErr %t", tt.sink, tt.dir, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSinkFileName(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name      string
+		alertName string
+		status    string
+		want      string
+	}{
+		{name: "plain", alertName: "HighCPU", status: "firing", want: "20240102T020405.000000006Z-HighCPU-firing.json"},
+		{name: "unsafe characters", alertName: "../disk full/", status: "resolved", want: "20240102T020405.000000006Z-disk_full-resolved.json"},
+		{name: "empty values", want: "20240102T020405.000000006Z-alert-unknown.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sinkFileName(tt.alertName, tt.status, now); got != tt.want {
+				t.Errorf("sinkFileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sink")
+	config := &Config{
+		ProjectID:        "test-project",
+		TopicID:          "alerts",
+		OrderingKeyField: "labels.instance",
+		SinkDir:          dir,
+	}
+	message := &PubSubMessage{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Severity:  "critical",
+		Labels:    map[string]string{"instance": "node-1"},
+		Source:    "karo",
+	}
+
+	path, err := writeFileSink(config, message)
+	if err != nil {
+		t.Fatalf("writeFileSink() unexpected error: %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("file written to %s, want directory %s", path, dir)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read sink file: %v", err)
+	}
+
+	var record struct {
+		Topic       string            `json:"topic"`
+		Data        PubSubMessage     `json:"data"`
+		Attributes  map[string]string `json:"attributes"`
+		OrderingKey string            `json:"orderingKey"`
+	}
+	if err := json.Unmarshal(content, &record); err != nil {
+		t.Fatalf("sink file is not valid JSON: %v\n%s", err, content)
+	}
+
+	if record.Topic != "projects/test-project/topics/alerts" {
+		t.Errorf("topic = %q", record.Topic)
+	}
+	if record.Data.AlertName != "DiskFull" || record.Data.Labels["instance"] != "node-1" {
+		t.Errorf("data = %+v", record.Data)
+	}
+	if record.Attributes["alertName"] != "DiskFull" || record.Attributes["severity"] != "critical" {
+		t.Errorf("attributes = %+v", record.Attributes)
+	}
+	if record.OrderingKey != "node-1" {
+		t.Errorf("orderingKey = %q, want %q", record.OrderingKey, "node-1")
+	}
+
+	// A second alert gets its own file
+	second, err := writeFileSink(config, message)
+	if err != nil {
+		t.Fatalf("writeFileSink() second call unexpected error: %v", err)
+	}
+	if second == path {
+		t.Errorf("second alert overwrote %s", path)
+	}
+}
+
+func TestWriteSinkFileRefusesOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := writeSinkFile(dir, "alert.json", map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("writeSinkFile() unexpected error: %v", err)
+	}
+	if _, err := writeSinkFile(dir, "alert.json", map[string]string{"a": "c"}); err == nil {
+		t.Error("writeSinkFile() should refuse to overwrite an existing file")
+	}
+}
+
+func TestSinkData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "json", data: []byte(`{"alertName":"DiskFull"}`), want: `{"alertName":"DiskFull"}`},
+		{name: "text", data: []byte(`DiskFull is firing`), want: `"DiskFull is firing"`},
+		{name: "binary", data: []byte{0x1f, 0x8b, 0xff}, want: `"H4v/"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(sinkData(tt.data)); got != tt.want {
+				t.Errorf("sinkData() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+// SpoolRecord is a message that failed to publish, as appended to
+// SPOOL_FILE. Data is kept as the bytes that were published, so a message
+// encoded with a topic schema is replayed unchanged.
+type SpoolRecord struct {
+	Project     string            `json:"project"`
+	Topic       string            `json:"topic"`
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+	Error       string            `json:"error"`
+	SpooledAt   string            `json:"spooledAt"`
+}
+
+// parseSpoolConfig reads SPOOL_FILE, the file failed messages are appended
+// to, and REPLAY, whether the run publishes the spooled messages again first
+func parseSpoolConfig(config *Config) error {
+	config.SpoolFile = os.Getenv("SPOOL_FILE")
+	if err := envBool(config.StrictEnv, "REPLAY", &config.Replay); err != nil {
+		return err
+	}
+	if config.Replay && config.SpoolFile == "" {
+		return fmt.Errorf("REPLAY requires SPOOL_FILE")
+	}
+	if config.Replay && (config.DryRun || config.Sink != "") {
+		return fmt.Errorf("REPLAY can't be combined with DRY_RUN or SINK")
+	}
+	return nil
+}
+
+// spoolMessages appends the messages that failed to publish to SPOOL_FILE.
+// A spool that can't be written only logs a warning, the run fails with the
+// publish error either way.
+func spoolMessages(config *Config, msgs []*pubsub.Message, outcomes []publishOutcome) {
+	var records []SpoolRecord
+	for i, outcome := range outcomes {
+		if outcome.err == nil {
+			continue
+		}
+		records = append(records, SpoolRecord{
+			Project:     config.ProjectID,
+			Topic:       config.TopicID,
+			Data:        msgs[i].Data,
+			Attributes:  msgs[i].Attributes,
+			OrderingKey: msgs[i].OrderingKey,
+			Error:       outcome.err.Error(),
+			SpooledAt:   time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	if err := appendSpool(config.SpoolFile, records); err != nil {
+		log.Printf("Warning: Failed to spool %d message(s): %v", len(records), err)
+		return
+	}
+	log.Printf("Spooled %d message(s) to %s for replay", len(records), config.SpoolFile)
+}
+
+// appendSpool appends the records as JSON lines, holding an exclusive lock
+// so concurrent runs don't interleave their writes
+func appendSpool(path string, records []SpoolRecord) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open SPOOL_FILE: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock SPOOL_FILE: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write SPOOL_FILE: %w", err)
+	}
+	return nil
+}
+
+// replaySpool publishes the messages in SPOOL_FILE again, each to the
+// project and topic it was spooled for, and rewrites the spool with only
+// those that failed again. The spool stays locked meanwhile, so a
+// concurrent run neither replays the same messages nor loses what it
+// spools. Lines that can't be decoded are kept as they are.
+func replaySpool(ctx context.Context, config *Config, newPublisher func(config *Config) (messagePublisher, error)) error {
+	file, err := os.OpenFile(config.SpoolFile, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open SPOOL_FILE: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock SPOOL_FILE: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	var kept [][]byte
+	var topics []*spoolTopic
+	byTopic := map[string]*spoolTopic{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 2*maxMessageBytes)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record SpoolRecord
+		if err := json.Unmarshal(line, &record); err != nil || record.Topic == "" {
+			log.Printf("Warning: Keeping line %d of SPOOL_FILE, which can't be replayed", n)
+			kept = append(kept, bytes.Clone(line))
+			continue
+		}
+		key := record.Project + "/" + record.Topic
+		topic, ok := byTopic[key]
+		if !ok {
+			topic = &spoolTopic{project: record.Project, topic: record.Topic}
+			byTopic[key] = topic
+			topics = append(topics, topic)
+		}
+		topic.records = append(topic.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read SPOOL_FILE: %w", err)
+	}
+
+	total, replayed := 0, 0
+	for _, topic := range topics {
+		total += len(topic.records)
+		failed := topic.replay(ctx, config, newPublisher)
+		replayed += len(topic.records) - len(failed)
+		for _, record := range failed {
+			line, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			kept = append(kept, line)
+		}
+	}
+	if total == 0 && len(kept) == 0 {
+		return nil
+	}
+
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to rewrite SPOOL_FILE: %w", err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return fmt.Errorf("failed to rewrite SPOOL_FILE: %w", err)
+	}
+	for _, line := range kept {
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to rewrite SPOOL_FILE: %w", err)
+		}
+	}
+
+	log.Printf("Replayed %d of %d spooled message(s), %d left in %s", replayed, total, len(kept), config.SpoolFile)
+	return nil
+}
+
+// spoolTopic is the spooled messages of one topic, in the order they were
+// spooled
+type spoolTopic struct {
+	project string
+	topic   string
+	records []SpoolRecord
+}
+
+// replay publishes the topic's spooled messages and returns those that
+// failed again, with their new error
+func (t *spoolTopic) replay(ctx context.Context, config *Config, newPublisher func(config *Config) (messagePublisher, error)) []SpoolRecord {
+	topicConfig := *config
+	topicConfig.ProjectID, topicConfig.TopicID = t.project, t.topic
+	publisher, err := newPublisher(&topicConfig)
+	if err != nil {
+		log.Printf("Warning: Failed to replay %d spooled message(s) to topic %s: %v", len(t.records), t.topic, err)
+		return t.records
+	}
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	msgs := make([]*pubsub.Message, len(t.records))
+	for i, record := range t.records {
+		msgs[i] = &pubsub.Message{Data: record.Data, Attributes: record.Attributes, OrderingKey: record.OrderingKey}
+	}
+	log.Printf("Replaying %d spooled message(s) to topic %s", len(msgs), t.topic)
+	outcomes := publishEach(ctx, publisher, msgs, config.RetryMaxAttempts, newDeliveryLimits(config))
+
+	var failed []SpoolRecord
+	for i, outcome := range outcomes {
+		if outcome.err != nil {
+			record := t.records[i]
+			record.Error = outcome.err.Error()
+			failed = append(failed, record)
+		}
+	}
+	return failed
+}
+
+// newSpoolPublisher creates the Pub/Sub publisher messages are replayed with
+func newSpoolPublisher(config *Config) (messagePublisher, error) {
+	publisher, err := newPubSubPublisher(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	return publisher, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSpoolConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		replay  string
+		dryRun  bool
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "spool only", file: "/var/spool/alerts.jsonl"},
+		{name: "replay", file: "/var/spool/alerts.jsonl", replay: "true"},
+		{name: "replay without file", replay: "true", wantErr: "REPLAY requires SPOOL_FILE"},
+		{name: "replay with dry run", file: "/var/spool/alerts.jsonl", replay: "true", dryRun: true, wantErr: "can't be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SPOOL_FILE", tt.file)
+			t.Setenv("REPLAY", tt.replay)
+
+			config := &Config{DryRun: tt.dryRun}
+			err := parseSpoolConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseSpoolConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSpoolConfig() unexpected error: %v", err)
+			}
+			if config.SpoolFile != tt.file || config.Replay != (tt.replay == "true") {
+				t.Errorf("config = %q, %t", config.SpoolFile, config.Replay)
+			}
+		})
+	}
+}
+
+func TestPublishMessageSpoolsFailures(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "spool.jsonl")
+	config := &Config{
+		ProjectID:        "test-project",
+		TopicID:          "alerts",
+		TimeoutSeconds:   10,
+		RetryMaxAttempts: 1,
+		OrderingKeyField: "labels.instance",
+		SpoolFile:        spool,
+	}
+	publisher := &fakePublisher{failures: 1}
+	first := &PubSubMessage{AlertName: "DiskFull", Status: "firing", Labels: map[string]string{"instance": "node-1"}}
+	second := &PubSubMessage{AlertName: "HighCPU", Status: "firing", Labels: map[string]string{"instance": "node-2"}}
+
+	var err error
+	captureLog(t, func() { err = publishMessage(context.Background(), config, publisher, first, second) })
+	if err == nil || !strings.Contains(err.Error(), "published 1 of 2 messages") {
+		t.Fatalf("publishMessage() error = %v, want 1 of 2 published", err)
+	}
+
+	records := readSpool(t, spool)
+	if len(records) != 1 {
+		t.Fatalf("spooled %d records, want 1", len(records))
+	}
+	record := records[0]
+	if record.Project != "test-project" || record.Topic != "alerts" || record.OrderingKey != "node-1" || record.Attributes["alertName"] != "DiskFull" {
+		t.Errorf("unexpected spool record: %+v", record)
+	}
+	if !strings.Contains(record.Error, "backend unavailable") || record.SpooledAt == "" {
+		t.Errorf("spool record error = %q at %q", record.Error, record.SpooledAt)
+	}
+	var data PubSubMessage
+	if err := json.Unmarshal(record.Data, &data); err != nil || data.AlertName != "DiskFull" {
+		t.Errorf("spooled data = %s, %v", record.Data, err)
+	}
+}
+
+func TestReplaySpool(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "spool.jsonl")
+	records := []SpoolRecord{
+		{Project: "test-project", Topic: "alerts", Data: []byte(`{"alertName":"DiskFull"}`), OrderingKey: "node-1", Attributes: map[string]string{"alertName": "DiskFull"}},
+		{Project: "test-project", Topic: "down", Data: []byte(`{"alertName":"HighCPU"}`)},
+		{Project: "test-project", Topic: "alerts", Data: []byte(`{"alertName":"NodeDown"}`)},
+	}
+	if err := appendSpool(spool, records); err != nil {
+		t.Fatalf("appendSpool() unexpected error: %v", err)
+	}
+	f, err := os.OpenFile(spool, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, "not json")
+	f.Close()
+
+	publishers := map[string]*fakePublisher{"alerts": {}, "down": {failures: 1}}
+	newPublisher := func(config *Config) (messagePublisher, error) {
+		return publishers[config.TopicID], nil
+	}
+
+	config := &Config{SpoolFile: spool, TimeoutSeconds: 10, RetryMaxAttempts: 1}
+	captureLog(t, func() { err = replaySpool(context.Background(), config, newPublisher) })
+	if err != nil {
+		t.Fatalf("replaySpool() unexpected error: %v", err)
+	}
+
+	published := publishers["alerts"].published
+	if len(published) != 2 || string(published[0].Data) != `{"alertName":"DiskFull"}` || string(published[1].Data) != `{"alertName":"NodeDown"}` {
+		t.Fatalf("published %d messages to alerts, want DiskFull then NodeDown", len(published))
+	}
+	if published[0].OrderingKey != "node-1" || published[0].Attributes["alertName"] != "DiskFull" {
+		t.Errorf("replayed message = %+v, want its ordering key and attributes", published[0])
+	}
+
+	data, err := os.ReadFile(spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != "not json" {
+		t.Fatalf("spool after replay = %q, want the undecodable line and the failed message", data)
+	}
+	var left SpoolRecord
+	if err := json.Unmarshal([]byte(lines[1]), &left); err != nil || left.Topic != "down" || !strings.Contains(left.Error, "backend unavailable") {
+		t.Errorf("left in spool = %+v, %v", left, err)
+	}
+}
+
+func TestReplaySpoolEmpty(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "spool.jsonl")
+	config := &Config{SpoolFile: spool, TimeoutSeconds: 10, RetryMaxAttempts: 1}
+	newPublisher := func(config *Config) (messagePublisher, error) {
+		t.Fatal("no publisher should be created for an empty spool")
+		return nil, nil
+	}
+
+	var err error
+	captureLog(t, func() { err = replaySpool(context.Background(), config, newPublisher) })
+	if err != nil {
+		t.Fatalf("replaySpool() unexpected error: %v", err)
+	}
+}
+
+// readSpool decodes the records of a spool file
+func readSpool(t *testing.T, path string) []SpoolRecord {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read spool: %v", err)
+	}
+	var records []SpoolRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record SpoolRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid spool line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
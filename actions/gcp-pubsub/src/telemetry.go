@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// telemetryServiceName identifies this action in exported telemetry
+const telemetryServiceName = "karo-gcp-pubsub"
+
+// shutdownTelemetry flushes and stops telemetry. It is a no-op until
+// setupTelemetry enables OpenTelemetry.
+var shutdownTelemetry = func() {}
+
+// telemetryMetricReader exports the client metrics over OTLP. It is nil
+// until setupTelemetry enables OpenTelemetry, and setupMetrics registers it.
+var telemetryMetricReader sdkmetric.Reader
+
+// otelConfigured reports whether an OTLP endpoint is set using the standard
+// OpenTelemetry environment variables
+func otelConfigured() bool {
+	for _, name := range []string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT",
+	} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// telemetryResource identifies this action in exported logs, traces and
+// metrics
+func telemetryResource() *resource.Resource {
+	return resource.NewSchemaless(attribute.String("service.name", telemetryServiceName))
+}
+
+// setupTelemetry starts a span for the run and bridges the standard logger
+// to the OpenTelemetry logs SDK when an OTLP endpoint is configured, so log
+// records carry the run's trace and span IDs. The client calls get spans of
+// their own and their metrics are exported alongside. Otherwise logging is
+// left on stderr and ctx is returned unchanged.
+func setupTelemetry(ctx context.Context) (context.Context, error) {
+	if !otelConfigured() {
+		return ctx, nil
+	}
+
+	logExporter, err := otlploghttp.New(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	traceExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	return startTelemetry(ctx, sdklog.NewBatchProcessor(logExporter), sdkmetric.NewPeriodicReader(metricExporter), sdktrace.WithBatcher(traceExporter)), nil
+}
+
+// startTelemetry installs the providers, starts the run span and tees the
+// standard logger into the logs SDK. A nil metricReader leaves the client
+// metrics unexported. shutdownTelemetry undoes all of it.
+func startTelemetry(ctx context.Context, logProcessor sdklog.Processor, metricReader sdkmetric.Reader, traceOptions ...sdktrace.TracerProviderOption) context.Context {
+	res := telemetryResource()
+	telemetryMetricReader = metricReader
+
+	loggerProvider := sdklog.NewLoggerProvider(sdklog.WithResource(res), sdklog.WithProcessor(logProcessor))
+	tracerProvider := sdktrace.NewTracerProvider(append(traceOptions, sdktrace.WithResource(res))...)
+	otel.SetTracerProvider(tracerProvider)
+
+	ctx, span := tracerProvider.Tracer(telemetryServiceName).Start(ctx, "reaction")
+
+	original := log.Writer()
+	log.SetOutput(io.MultiWriter(original, &logBridge{ctx: ctx, logger: loggerProvider.Logger(telemetryServiceName)}))
+
+	shutdownTelemetry = func() {
+		span.End()
+		log.SetOutput(original)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: Failed to flush traces: %v", err)
+		}
+		if metricReader != nil {
+			if err := metricReader.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Warning: Failed to flush metrics: %v", err)
+			}
+			telemetryMetricReader = nil
+		}
+		if err := loggerProvider.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: Failed to flush logs: %v", err)
+		}
+		shutdownTelemetry = func() {}
+	}
+
+	return ctx
+}
+
+// tracer starts the spans of the client calls as children of the run span.
+// Its spans are no-ops until setupTelemetry installs a tracer provider.
+func tracer() trace.Tracer {
+	return otel.Tracer(telemetryServiceName)
+}
+
+// setSpanAlert adds the alert to the run span so traces can be found by the
+// alert that triggered them
+func setSpanAlert(ctx context.Context, message *PubSubMessage) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("karo.alert.name", message.AlertName),
+		attribute.String("karo.alert.status", message.Status),
+	)
+}
+
+// endSpan marks the span as failed with err, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// fatalf logs the message at error level, flushes telemetry and exits with
+// status 1. It replaces log.Fatalf so the final log records are exported.
+func fatalf(format string, v ...interface{}) {
+	log.Printf("Error: "+format, v...)
+	exit(1)
+}
+
+// exit pushes the run's metrics and flushes telemetry before exiting with
+// the given code
+func exit(code int) {
+	reactionMetrics.push(code == 0)
+	shutdownTelemetry()
+	os.Exit(code)
+}
+
+// logTimestamp matches the date and time prefix of the standard logger
+var logTimestamp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+// logBridge emits each standard log line as an OpenTelemetry log record
+// attached to the run's span context
+type logBridge struct {
+	ctx    context.Context
+	logger otellog.Logger
+}
+
+func (b *logBridge) Write(p []byte) (int, error) {
+	message := logTimestamp.ReplaceAllString(strings.TrimRight(string(p), "\n"), "")
+	level, _, _ := parseLogLine(message)
+	if !logLevelEnabled(level) {
+		return len(p), nil
+	}
+
+	var record otellog.Record
+	record.SetTimestamp(time.Now())
+	record.SetBody(otellog.StringValue(message))
+	switch level {
+	case levelError:
+		record.SetSeverity(otellog.SeverityError)
+		record.SetSeverityText("ERROR")
+	case levelWarn:
+		record.SetSeverity(otellog.SeverityWarn)
+		record.SetSeverityText("WARN")
+	case levelDebug:
+		record.SetSeverity(otellog.SeverityDebug)
+		record.SetSeverityText("DEBUG")
+	default:
+		record.SetSeverity(otellog.SeverityInfo)
+		record.SetSeverityText("INFO")
+	}
+
+	b.logger.Emit(b.ctx, record)
+	return len(p), nil
+}
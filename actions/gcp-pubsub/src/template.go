@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helper functions available in MESSAGE_TEMPLATE
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default returns fallback when value is empty, e.g.
+	// {{ .Severity | default "warning" }}
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	// json encodes a value as JSON, for embedding strings or maps safely in
+	// a JSON body, e.g. {"text": {{ .Summary | json }}}
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// parseMessageTemplate parses the message template from MESSAGE_TEMPLATE or
+// the file named by MESSAGE_TEMPLATE_FILE. It returns nil when neither is
+// set, in which case the built-in JSON message is published.
+func parseMessageTemplate(text, file string) (*template.Template, error) {
+	if text != "" && file != "" {
+		return nil, fmt.Errorf("MESSAGE_TEMPLATE and MESSAGE_TEMPLATE_FILE are mutually exclusive")
+	}
+
+	name := "MESSAGE_TEMPLATE"
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MESSAGE_TEMPLATE_FILE: %w", err)
+		}
+		text = string(data)
+		name = file
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// parseTemplateConfig reads MESSAGE_TEMPLATE or MESSAGE_TEMPLATE_FILE. A
+// topic or registry schema decides the data layout itself, so it can't be
+// combined with a template.
+func parseTemplateConfig(config *Config) error {
+	config.MessageTemplateFile = os.Getenv("MESSAGE_TEMPLATE_FILE")
+	tmpl, err := parseMessageTemplate(os.Getenv("MESSAGE_TEMPLATE"), config.MessageTemplateFile)
+	if err != nil {
+		return err
+	}
+	if tmpl != nil && config.TopicSchema {
+		return fmt.Errorf("MESSAGE_TEMPLATE/MESSAGE_TEMPLATE_FILE and TOPIC_SCHEMA are mutually exclusive")
+	}
+	if tmpl != nil && config.SchemaRegistryURL != "" {
+		return fmt.Errorf("MESSAGE_TEMPLATE/MESSAGE_TEMPLATE_FILE and SCHEMA_REGISTRY_URL are mutually exclusive")
+	}
+	config.MessageTemplate = tmpl
+	return nil
+}
+
+// renderMessage renders the message template with the message, so every
+// field of the alert, e.g. {{ .Labels.team }} or, in a digest,
+// {{ range .Alerts }}, is available to it
+func renderMessage(tmpl *template.Template, message *PubSubMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, message); err != nil {
+		return nil, fmt.Errorf("failed to render message template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestParseMessageTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "message.tmpl")
+	if err := os.WriteFile(file, []byte(`{"name": "{{ .AlertName }}"}`), 0o644); err != nil {
+		t.Fatalf("failed to write template file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		file    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "unset", wantNil: true},
+		{name: "inline", text: `{"name": "{{ .AlertName }}"}`},
+		{name: "file", file: file},
+		{name: "both set", text: "{{ .AlertName }}", file: file, wantErr: true},
+		{name: "syntax error", text: "{{ .AlertName ", wantErr: true},
+		{name: "unknown function", text: "{{ .AlertName | shout }}", wantErr: true},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.tmpl"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseMessageTemplate(tt.text, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMessageTemplate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && (tmpl == nil) != tt.wantNil {
+				t.Errorf("parseMessageTemplate() = %v, want nil %t", tmpl, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestParseTemplateConfigWithSchema(t *testing.T) {
+	t.Setenv("MESSAGE_TEMPLATE", `{"name": "{{ .AlertName }}"}`)
+	t.Setenv("MESSAGE_TEMPLATE_FILE", "")
+
+	err := parseTemplateConfig(&Config{TopicSchema: true})
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("parseTemplateConfig() error = %v, want mutually exclusive", err)
+	}
+}
+
+func TestParseTemplateConfigWithRegistry(t *testing.T) {
+	t.Setenv("MESSAGE_TEMPLATE", `{"name": "{{ .AlertName }}"}`)
+	t.Setenv("MESSAGE_TEMPLATE_FILE", "")
+
+	err := parseTemplateConfig(&Config{SchemaRegistryURL: "http://registry:8081"})
+	if err == nil || !strings.Contains(err.Error(), "SCHEMA_REGISTRY_URL") {
+		t.Fatalf("parseTemplateConfig() error = %v, want SCHEMA_REGISTRY_URL to be rejected", err)
+	}
+}
+
+func TestBuildPubSubMessageTemplate(t *testing.T) {
+	message := &PubSubMessage{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Summary:   `Disk "/" is full`,
+		Labels:    map[string]string{"instance": "node-1", "team": "storage"},
+		Source:    "karo",
+	}
+	digest := &PubSubMessage{
+		Status: "firing",
+		Alerts: []*PubSubMessage{{AlertName: "DiskFull"}, {AlertName: "HighCPU"}},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		message  *PubSubMessage
+		want     string
+	}{
+		{
+			name:     "consumer schema with helpers",
+			template: `{"event": "{{ .AlertName | lower }}", "state": "{{ .Status | upper }}", "owner": "{{ .Labels.team }}", "priority": "{{ .Severity | default "P3" }}"}`,
+			message:  message,
+			want:     `{"event": "diskfull", "state": "FIRING", "owner": "storage", "priority": "P3"}`,
+		},
+		{
+			name:     "json helper escapes values",
+			template: `{"text": {{ .Summary | json }}}`,
+			message:  message,
+			want:     `{"text": "Disk \"/\" is full"}`,
+		},
+		{
+			name:     "plain text",
+			template: `{{ .AlertName }} on {{ .Instance | default .Labels.instance }}`,
+			message:  message,
+			want:     `DiskFull on node-1`,
+		},
+		{
+			name:     "digest",
+			template: `{{ range .Alerts }}{{ .AlertName }};{{ end }}`,
+			message:  digest,
+			want:     `DiskFull;HighCPU;`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{MessageTemplate: template.Must(template.New("test").Funcs(templateFuncs).Parse(tt.template))}
+			pubsubMsg, err := buildPubSubMessage(config, tt.message)
+			if err != nil {
+				t.Fatalf("buildPubSubMessage() unexpected error: %v", err)
+			}
+			if string(pubsubMsg.Data) != tt.want {
+				t.Errorf("data = %s, want %s", pubsubMsg.Data, tt.want)
+			}
+			if pubsubMsg.Attributes["alertName"] != tt.message.AlertName {
+				t.Errorf("alertName attribute = %q, want %q", pubsubMsg.Attributes["alertName"], tt.message.AlertName)
+			}
+		})
+	}
+}
+
+func TestBuildPubSubMessageTemplateError(t *testing.T) {
+	config := &Config{MessageTemplate: template.Must(template.New("test").Funcs(templateFuncs).Parse(`{{ .Labels.team.name }}`))}
+	_, err := buildPubSubMessage(config, &PubSubMessage{AlertName: "DiskFull", Labels: map[string]string{"team": "storage"}})
+	if err == nil || !strings.Contains(err.Error(), "failed to render message template") {
+		t.Fatalf("buildPubSubMessage() error = %v, want a render error", err)
+	}
+}
+
+func TestBuildSinkRecordPlainTextTemplate(t *testing.T) {
+	config := &Config{
+		ProjectID:       "test-project",
+		TopicID:         "alerts",
+		MessageTemplate: template.Must(template.New("test").Funcs(templateFuncs).Parse(`{{ .AlertName }} is {{ .Status }}`)),
+	}
+	record, err := buildSinkRecord(config, &PubSubMessage{AlertName: "DiskFull", Status: "firing"})
+	if err != nil {
+		t.Fatalf("buildSinkRecord() unexpected error: %v", err)
+	}
+	if _, err := json.Marshal(record); err != nil {
+		t.Fatalf("sink record is not valid JSON: %v", err)
+	}
+	if string(record.Data) != `"DiskFull is firing"` {
+		t.Errorf("record data = %s, want the text as a JSON string", record.Data)
+	}
+}
@@ -47,6 +47,20 @@ else
     echo "✅ Missing PUBSUB_TOPIC_ID test passed (correctly failed)"
 fi
 
+# Test ORDERING_CONDITION without ORDERING_KEY_FIELD
+echo "Testing ORDERING_CONDITION without ORDERING_KEY_FIELD..."
+if docker run --rm \
+    -e GCP_PROJECT_ID="test-project" \
+    -e PUBSUB_TOPIC_ID="test-topic" \
+    -e ORDERING_CONDITION="labels.stateful=true" \
+    -e ALERT_NAME="ConfigTest" \
+    "$IMAGE_NAME" 2>&1; then
+    echo "❌ Expected error but action succeeded with ORDERING_CONDITION and no ORDERING_KEY_FIELD"
+    exit 1
+else
+    echo "✅ Ordering configuration test passed (correctly failed)"
+fi
+
 # Test 3: Test JSON parsing (without actual GCP connection)
 echo "=== Running JSON Parsing Tests ==="
 echo "Testing alert JSON parsing with invalid project (should fail at GCP connection, not parsing)..."
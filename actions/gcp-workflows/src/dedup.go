@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DEDUP_FAILURE_MODE values
+const (
+	dedupFailOpen   = "fail-open"
+	dedupFailClosed = "fail-closed"
+)
+
+// defaultDedupTTLSeconds is how long an alert is remembered by default
+const defaultDedupTTLSeconds = 300
+
+// dedupStore remembers which alerts have already been handled
+type dedupStore interface {
+	// claim records the key for ttl and reports whether it was not yet
+	// recorded, i.e. whether this run should handle the alert
+	claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// release forgets the key, so a failed run can be retried
+	release(ctx context.Context, key string) error
+	close() error
+}
+
+// parseDedupFailureMode validates DEDUP_FAILURE_MODE, defaulting to fail-open
+func parseDedupFailureMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return dedupFailOpen, nil
+	case dedupFailOpen, dedupFailClosed:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported DEDUP_FAILURE_MODE '%s', must be '%s' or '%s'", mode, dedupFailOpen, dedupFailClosed)
+	}
+}
+
+// parseDedupConfig reads the deduplication settings. Deduplication is off
+// unless DEDUP_REDIS_URL or DEDUP_FILE is set.
+func parseDedupConfig(config *Config) error {
+	config.DedupRedisURL = os.Getenv("DEDUP_REDIS_URL")
+	config.DedupFile = os.Getenv("DEDUP_FILE")
+	if config.DedupRedisURL != "" {
+		if _, err := redis.ParseURL(config.DedupRedisURL); err != nil {
+			return fmt.Errorf("invalid DEDUP_REDIS_URL: %w", err)
+		}
+	}
+
+	config.DedupTTLSeconds = defaultDedupTTLSeconds
+	if err := envInt(config.StrictEnv, "DEDUP_TTL_SECONDS", &config.DedupTTLSeconds); err != nil {
+		return err
+	}
+	if config.DedupTTLSeconds <= 0 {
+		return fmt.Errorf("DEDUP_TTL_SECONDS must be positive, got %d", config.DedupTTLSeconds)
+	}
+
+	mode, err := parseDedupFailureMode(os.Getenv("DEDUP_FAILURE_MODE"))
+	if err != nil {
+		return err
+	}
+	config.DedupFailureMode = mode
+	return nil
+}
+
+// newDedupStore returns the Redis store when DEDUP_REDIS_URL is set, the
+// file store when DEDUP_FILE is set, and nil when deduplication is off
+func newDedupStore(config *Config) (dedupStore, error) {
+	switch {
+	case config.DedupRedisURL != "":
+		opts, err := redis.ParseURL(config.DedupRedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEDUP_REDIS_URL: %w", err)
+		}
+		return &redisDedupStore{client: redis.NewClient(opts)}, nil
+	case config.DedupFile != "":
+		return &fileDedupStore{path: config.DedupFile}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// dedupKey identifies an alert by its label fingerprint and status, so the
+// resolved notification of a firing alert is not treated as a duplicate
+func dedupKey(labels map[string]string, status string) string {
+	return fmt.Sprintf("karo:dedup:%s:%016x:%s", logActionName, labelsFingerprint(labels), status)
+}
+
+// checkDuplicate claims the alert when deduplication is configured. It
+// returns the store and key to release if handling the alert fails, and
+// whether the alert is a duplicate that should be skipped.
+func checkDuplicate(ctx context.Context, config *Config, labels map[string]string, status string) (dedupStore, string, bool, error) {
+	store, err := newDedupStore(config)
+	if err != nil || store == nil {
+		return nil, "", false, err
+	}
+
+	key := dedupKey(labels, status)
+	duplicate, err := claimAlert(ctx, store, config, key)
+	if err != nil || duplicate {
+		store.close()
+		return nil, "", duplicate, err
+	}
+	return store, key, false, nil
+}
+
+// releaseAlert forgets a claimed alert after handling it failed, so the next
+// notification is not skipped as a duplicate
+func releaseAlert(store dedupStore, key string) {
+	if store == nil {
+		return
+	}
+	if err := store.release(context.Background(), key); err != nil {
+		log.Printf("Warning: Failed to release deduplication key: %v", err)
+	}
+}
+
+// claimAlert claims the alert in the dedup store and reports whether it is a
+// duplicate. When the store cannot be reached, fail-open handles the alert
+// anyway and fail-closed returns the error.
+func claimAlert(ctx context.Context, store dedupStore, config *Config, key string) (bool, error) {
+	claimed, err := store.claim(ctx, key, time.Duration(config.DedupTTLSeconds)*time.Second)
+	if err != nil {
+		if config.DedupFailureMode == dedupFailClosed {
+			return false, fmt.Errorf("failed to check for duplicates (DEDUP_FAILURE_MODE=%s): %w", dedupFailClosed, err)
+		}
+		log.Printf("Warning: Failed to check for duplicates, handling the alert (DEDUP_FAILURE_MODE=%s): %v", dedupFailOpen, err)
+		return false, nil
+	}
+	return !claimed, nil
+}
+
+// redisDedupStore shares dedup state between action instances with SET NX
+type redisDedupStore struct {
+	client *redis.Client
+}
+
+func (s *redisDedupStore) claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, time.Now().UTC().Format(time.RFC3339), ttl).Result()
+}
+
+func (s *redisDedupStore) release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+func (s *redisDedupStore) close() error {
+	return s.client.Close()
+}
+
+// fileDedupStore keeps dedup state in a local JSON file mapping keys to
+// their expiry. It is meant for a single instance with a persistent volume;
+// concurrent runs sharing the file can race.
+type fileDedupStore struct {
+	path string
+}
+
+func (s *fileDedupStore) claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	entries, err := s.load()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if expiry, ok := entries[key]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	entries[key] = now.Add(ttl)
+	return true, s.save(entries, now)
+}
+
+func (s *fileDedupStore) release(ctx context.Context, key string) error {
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(entries, key)
+	return s.save(entries, time.Now())
+}
+
+func (s *fileDedupStore) close() error {
+	return nil
+}
+
+func (s *fileDedupStore) load() (map[string]time.Time, error) {
+	entries := map[string]time.Time{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DEDUP_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse DEDUP_FILE: %w", err)
+	}
+	return entries, nil
+}
+
+// save writes the entries that have not expired, replacing the file
+// atomically so a crash never leaves it truncated
+func (s *fileDedupStore) save(entries map[string]time.Time, now time.Time) error {
+	for key, expiry := range entries {
+		if !now.Before(expiry) {
+			delete(entries, key)
+		}
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal DEDUP_FILE: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create DEDUP_FILE directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write DEDUP_FILE: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write DEDUP_FILE: %w", err)
+	}
+	return nil
+}
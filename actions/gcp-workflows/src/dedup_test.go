@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestParseDedupConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantTTL  int
+		wantMode string
+		wantErr  bool
+	}{
+		{name: "defaults", env: map[string]string{}, wantTTL: defaultDedupTTLSeconds, wantMode: dedupFailOpen},
+		{name: "redis", env: map[string]string{"DEDUP_REDIS_URL": "redis://localhost:6379/0", "DEDUP_TTL_SECONDS": "60", "DEDUP_FAILURE_MODE": "fail-closed"}, wantTTL: 60, wantMode: dedupFailClosed},
+		{name: "invalid redis URL", env: map[string]string{"DEDUP_REDIS_URL": "http://localhost"}, wantErr: true},
+		{name: "invalid failure mode", env: map[string]string{"DEDUP_FAILURE_MODE": "open"}, wantErr: true},
+		{name: "non-positive TTL", env: map[string]string{"DEDUP_TTL_SECONDS": "0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DEDUP_REDIS_URL", "DEDUP_FILE", "DEDUP_TTL_SECONDS", "DEDUP_FAILURE_MODE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseDedupConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDedupConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && (config.DedupTTLSeconds != tt.wantTTL || config.DedupFailureMode != tt.wantMode) {
+				t.Errorf("TTL = %d, mode = %s, want %d, %s", config.DedupTTLSeconds, config.DedupFailureMode, tt.wantTTL, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestCheckDuplicateRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	config := &Config{DedupRedisURL: "redis://" + server.Addr(), DedupTTLSeconds: 60, DedupFailureMode: dedupFailOpen}
+	labels := map[string]string{"alertname": "DiskFull", "instance": "node-1"}
+
+	store, key, duplicate, err := checkDuplicate(context.Background(), config, labels, "firing")
+	if err != nil || duplicate || store == nil {
+		t.Fatalf("first claim: store = %v, duplicate = %t, err = %v", store, duplicate, err)
+	}
+	defer store.close()
+	if ttl := server.TTL(key); ttl != 60*time.Second {
+		t.Errorf("key TTL = %s, want 60s", ttl)
+	}
+
+	_, _, duplicate, err = checkDuplicate(context.Background(), config, labels, "firing")
+	if err != nil || !duplicate {
+		t.Errorf("second claim: duplicate = %t, err = %v, want a duplicate", duplicate, err)
+	}
+
+	_, _, duplicate, err = checkDuplicate(context.Background(), config, labels, "resolved")
+	if err != nil || duplicate {
+		t.Errorf("resolved claim: duplicate = %t, err = %v, want a new alert", duplicate, err)
+	}
+
+	releaseAlert(store, key)
+	if server.Exists(key) {
+		t.Error("releaseAlert() should delete the key")
+	}
+
+	server.FastForward(61 * time.Second)
+	_, _, duplicate, _ = checkDuplicate(context.Background(), config, labels, "resolved")
+	if duplicate {
+		t.Error("claim after the TTL expired should not be a duplicate")
+	}
+}
+
+func TestCheckDuplicateRedisUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	tests := []struct {
+		name    string
+		mode    string
+		wantErr bool
+	}{
+		{name: "fail-open handles the alert", mode: dedupFailOpen},
+		{name: "fail-closed returns the error", mode: dedupFailClosed, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{DedupRedisURL: "redis://" + addr, DedupTTLSeconds: 60, DedupFailureMode: tt.mode}
+
+			var duplicate bool
+			var err error
+			output := captureLog(t, func() {
+				_, _, duplicate, err = checkDuplicate(context.Background(), config, map[string]string{"alertname": "DiskFull"}, "firing")
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkDuplicate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if duplicate {
+				t.Error("an unreachable store should never report a duplicate")
+			}
+			if !tt.wantErr && !strings.Contains(output, "Warning: Failed to check for duplicates") {
+				t.Errorf("expected a fail-open warning, got: %s", output)
+			}
+		})
+	}
+}
+
+func TestCheckDuplicateFile(t *testing.T) {
+	config := &Config{DedupFile: filepath.Join(t.TempDir(), "state", "dedup.json"), DedupTTLSeconds: 60, DedupFailureMode: dedupFailOpen}
+	labels := map[string]string{"alertname": "DiskFull"}
+
+	store, key, duplicate, err := checkDuplicate(context.Background(), config, labels, "firing")
+	if err != nil || duplicate {
+		t.Fatalf("first claim: duplicate = %t, err = %v", duplicate, err)
+	}
+	if _, _, duplicate, _ := checkDuplicate(context.Background(), config, labels, "firing"); !duplicate {
+		t.Error("second claim should be a duplicate")
+	}
+
+	releaseAlert(store, key)
+	if _, _, duplicate, _ := checkDuplicate(context.Background(), config, labels, "firing"); duplicate {
+		t.Error("claim after release should not be a duplicate")
+	}
+}
+
+func TestCheckDuplicateDisabled(t *testing.T) {
+	store, _, duplicate, err := checkDuplicate(context.Background(), &Config{}, map[string]string{"alertname": "DiskFull"}, "firing")
+	if store != nil || duplicate || err != nil {
+		t.Errorf("checkDuplicate() = %v, %t, %v, want deduplication off", store, duplicate, err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// logDryRun logs the execution request that would have been sent for each
+// workflow, in the same shape as a file sink record, without contacting
+// Workflows
+func logDryRun(config *Config, workflowNames []string, input *WorkflowInput) error {
+	for _, workflowName := range workflowNames {
+		record, err := buildSinkRecord(config, workflowName, input)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dry-run record: %w", err)
+		}
+		log.Printf("Dry run, would execute: %s", payloadForLog(data, config.RedactFields))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogDryRun(t *testing.T) {
+	config := &Config{ProjectID: "test-project", Location: "us-central1", RedactFields: []string{"token"}}
+	input := &WorkflowInput{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Labels:    map[string]string{"instance": "node-1", "token": "s3cret"},
+	}
+
+	var err error
+	output := captureLog(t, func() { err = logDryRun(config, []string{"restart-pod", "scale-up"}, input) })
+	if err != nil {
+		t.Fatalf("logDryRun() unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`"workflow":"projects/test-project/locations/us-central1/workflows/restart-pod"`,
+		`"workflow":"projects/test-project/locations/us-central1/workflows/scale-up"`,
+		`"instance":"node-1"`,
+		`"token":"***"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %s, got: %s", want, output)
+		}
+	}
+	if strings.Contains(output, "s3cret") {
+		t.Errorf("output leaks a REDACT_FIELDS value: %s", output)
+	}
+}
+
+func TestLoadConfigDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		dryRun  string
+		sink    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "unset", want: false},
+		{name: "enabled", dryRun: "true", want: true},
+		{name: "with SINK", dryRun: "true", sink: sinkFile, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GCP_PROJECT_ID", "test-project")
+			t.Setenv("WORKFLOW_NAME", "restart-pod")
+			t.Setenv("DRY_RUN", tt.dryRun)
+			t.Setenv("SINK", tt.sink)
+			t.Setenv("SINK_DIR", t.TempDir())
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.DryRun != tt.want {
+				t.Errorf("DryRun = %t, want %t", config.DryRun, tt.want)
+			}
+		})
+	}
+}
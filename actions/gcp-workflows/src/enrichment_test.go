@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeEnrichmentFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write enrichment file: %v", err)
+	}
+	return path
+}
+
+func TestLoadEnrichmentFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantTeam string
+		wantErr  bool
+	}{
+		{
+			name:     "yaml",
+			file:     "owners.yaml",
+			content:  "node-1:\n  labels:\n    team: storage\n  annotations:\n    runbook: https://runbooks/disk\n",
+			wantTeam: "storage",
+		},
+		{
+			name:     "json",
+			file:     "owners.json",
+			content:  `{"node-1": {"labels": {"team": "storage"}}}`,
+			wantTeam: "storage",
+		},
+		{name: "empty file", file: "owners.yaml", content: ""},
+		{name: "unknown section", file: "owners.yaml", content: "node-1:\n  lables:\n    team: storage\n", wantErr: true},
+		{name: "malformed", file: "owners.json", content: `{"node-1": `, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := loadEnrichmentFile(writeEnrichmentFile(t, tt.file, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadEnrichmentFile() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got := table["node-1"].Labels["team"]; got != tt.wantTeam {
+				t.Errorf("node-1 team = %q, want %q", got, tt.wantTeam)
+			}
+		})
+	}
+}
+
+func TestLoadEnrichmentFileMissing(t *testing.T) {
+	if _, err := loadEnrichmentFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("loadEnrichmentFile() expected error for a missing file")
+	}
+}
+
+func TestValidateEnrichmentKeyField(t *testing.T) {
+	for field, wantErr := range map[string]bool{
+		"labels.instance":   false,
+		"annotations.owner": false,
+		"labels.":           true,
+		"instance":          true,
+		"status":            true,
+	} {
+		if err := validateEnrichmentKeyField(field); (err != nil) != wantErr {
+			t.Errorf("validateEnrichmentKeyField(%q) error = %v, wantErr %t", field, err, wantErr)
+		}
+	}
+}
+
+func TestEnrichAlert(t *testing.T) {
+	table := map[string]Enrichment{
+		"node-1": {
+			Labels:      map[string]string{"team": "storage", "severity": "info"},
+			Annotations: map[string]string{"runbook": "https://runbooks/disk"},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		keyField        string
+		alert           *AlertData
+		wantMatch       bool
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+	}{
+		{
+			name:            "matched key merges labels and annotations",
+			keyField:        "labels.instance",
+			alert:           &AlertData{Labels: map[string]string{"instance": "node-1", "severity": "critical"}},
+			wantMatch:       true,
+			wantLabels:      map[string]string{"instance": "node-1", "severity": "critical", "team": "storage"},
+			wantAnnotations: map[string]string{"runbook": "https://runbooks/disk"},
+		},
+		{
+			name:       "unmatched key leaves alert unchanged",
+			keyField:   "labels.instance",
+			alert:      &AlertData{Labels: map[string]string{"instance": "node-2"}},
+			wantLabels: map[string]string{"instance": "node-2"},
+		},
+		{
+			name:       "missing key field leaves alert unchanged",
+			keyField:   "labels.instance",
+			alert:      &AlertData{Labels: map[string]string{"job": "api"}},
+			wantLabels: map[string]string{"job": "api"},
+		},
+		{
+			name:      "keyed by annotation",
+			keyField:  "annotations.host",
+			alert:     &AlertData{Annotations: map[string]string{"host": "node-1"}},
+			wantMatch: true,
+			wantLabels: map[string]string{
+				"team":     "storage",
+				"severity": "info",
+			},
+			wantAnnotations: map[string]string{"host": "node-1", "runbook": "https://runbooks/disk"},
+		},
+		{name: "no alert", keyField: "labels.instance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := enrichAlert(tt.alert, tt.keyField, table); got != tt.wantMatch {
+				t.Errorf("enrichAlert() = %t, want %t", got, tt.wantMatch)
+			}
+			if tt.alert == nil {
+				return
+			}
+			assertStringMap(t, "labels", tt.alert.Labels, tt.wantLabels)
+			assertStringMap(t, "annotations", tt.alert.Annotations, tt.wantAnnotations)
+		})
+	}
+}
+
+func assertStringMap(t *testing.T, name string, got, want map[string]string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+		return
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s[%q] = %q, want %q", name, key, got[key], value)
+		}
+	}
+}
+
+func TestEnrichAlertUpdatesWorkflowNameField(t *testing.T) {
+	alert := parseTestAlert(t, `{"labels":{"alertname":"DiskFull","instance":"node-1"}}`)
+	table := map[string]Enrichment{"node-1": {Labels: map[string]string{"workflow": "storage-oncall"}}}
+
+	if !enrichAlert(alert, "labels.instance", table) {
+		t.Fatal("enrichAlert() expected a match")
+	}
+	if got := extractFieldFromAlert(alert, "labels.workflow"); got != "storage-oncall" {
+		t.Errorf("extractFieldFromAlert(labels.workflow) = %q, want %q", got, "storage-oncall")
+	}
+	if got := extractFieldFromAlert(alert, "labels.alertname"); got != "DiskFull" {
+		t.Errorf("existing labels lost after enrichment, alertname = %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	executionspb "cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// executionCreator is the part of the Workflows executions client used to
+// start an execution, so regional failover can be tested without the API
+type executionCreator interface {
+	CreateExecution(ctx context.Context, req *executionspb.CreateExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error)
+}
+
+// isFailoverError reports whether err is an infrastructure error that may be
+// specific to one region. Errors about the request itself, such as NotFound
+// or PermissionDenied, would fail the same way in every region. Only
+// Unavailable means the region did not accept the request; after
+// DeadlineExceeded or Internal the execution may be running there already.
+func isFailoverError(ctx context.Context, err error) bool {
+	// The run's own deadline or cancellation applies to every region
+	if ctx.Err() != nil {
+		return false
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	return s.Code() == codes.Unavailable
+}
+
+// createExecution starts the workflow in GCP_LOCATION, failing over to each
+// FALLBACK_LOCATIONS entry in order while the error is regional once the
+// retries in a location are exhausted. It returns the execution and the
+// location it was created in.
+func createExecution(ctx context.Context, client executionCreator, config *Config, workflowName string, input *WorkflowInput) (*executionspb.Execution, string, error) {
+	locations := append([]string{config.Location}, config.FallbackLocations...)
+
+	var err error
+	for i, location := range locations {
+		var req *executionspb.CreateExecutionRequest
+		req, err = buildExecutionRequest(config, location, workflowName, input)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if i == 0 && config.LogPayload {
+			log.Printf("Executing workflow '%s' in %s with input: %s", workflowName, location,
+				payloadForLog([]byte(req.Execution.Argument), config.RedactFields))
+		} else if i == 0 {
+			log.Printf("Executing workflow '%s' in %s", workflowName, location)
+		} else {
+			log.Printf("Failing over to %s for workflow '%s'", location, workflowName)
+		}
+
+		var execution *executionspb.Execution
+		execution, err = createWithRetry(ctx, client, config, req)
+		if err == nil {
+			return execution, location, nil
+		}
+
+		if !isFailoverError(ctx, err) {
+			break
+		}
+		if i < len(locations)-1 {
+			log.Printf("Warning: Workflows unavailable in %s: %v", location, err)
+		}
+	}
+
+	return nil, "", fmt.Errorf("failed to create workflow execution: %w", err)
+}
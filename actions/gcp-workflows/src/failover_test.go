@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	executionspb "cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeCreator fails CreateExecution with the configured error per location
+// and records the locations that were tried
+type fakeCreator struct {
+	errs  map[string]error
+	tried []string
+}
+
+func (f *fakeCreator) CreateExecution(ctx context.Context, req *executionspb.CreateExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error) {
+	location := strings.Split(req.Parent, "/")[3]
+	f.tried = append(f.tried, location)
+	if err := f.errs[location]; err != nil {
+		return nil, err
+	}
+	return &executionspb.Execution{Name: req.Parent + "/executions/1"}, nil
+}
+
+func TestCreateExecutionFailover(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "region unavailable")
+
+	tests := []struct {
+		name         string
+		fallbacks    []string
+		errs         map[string]error
+		wantLocation string
+		wantTried    []string
+		wantErr      bool
+	}{
+		{
+			name:         "primary succeeds",
+			fallbacks:    []string{"europe-west1"},
+			wantLocation: "us-central1",
+			wantTried:    []string{"us-central1"},
+		},
+		{
+			name:         "primary unavailable, fallback succeeds",
+			fallbacks:    []string{"europe-west1"},
+			errs:         map[string]error{"us-central1": unavailable},
+			wantLocation: "europe-west1",
+			wantTried:    []string{"us-central1", "europe-west1"},
+		},
+		{
+			name:         "fallbacks tried in order",
+			fallbacks:    []string{"europe-west1", "asia-east1"},
+			errs:         map[string]error{"us-central1": unavailable, "europe-west1": unavailable},
+			wantLocation: "asia-east1",
+			wantTried:    []string{"us-central1", "europe-west1", "asia-east1"},
+		},
+		{
+			// The execution may be running in the primary already
+			name:      "deadline exceeded does not fail over",
+			fallbacks: []string{"europe-west1"},
+			errs:      map[string]error{"us-central1": status.Error(codes.DeadlineExceeded, "slow")},
+			wantTried: []string{"us-central1"},
+			wantErr:   true,
+		},
+		{
+			name:      "internal does not fail over",
+			fallbacks: []string{"europe-west1"},
+			errs:      map[string]error{"us-central1": status.Error(codes.Internal, "oops")},
+			wantTried: []string{"us-central1"},
+			wantErr:   true,
+		},
+		{
+			name:      "every region unavailable",
+			fallbacks: []string{"europe-west1"},
+			errs:      map[string]error{"us-central1": unavailable, "europe-west1": unavailable},
+			wantTried: []string{"us-central1", "europe-west1"},
+			wantErr:   true,
+		},
+		{
+			name:      "not found does not fail over",
+			fallbacks: []string{"europe-west1"},
+			errs:      map[string]error{"us-central1": status.Error(codes.NotFound, "workflow not found")},
+			wantTried: []string{"us-central1"},
+			wantErr:   true,
+		},
+		{
+			name:      "permission denied does not fail over",
+			fallbacks: []string{"europe-west1"},
+			errs:      map[string]error{"us-central1": status.Error(codes.PermissionDenied, "denied")},
+			wantTried: []string{"us-central1"},
+			wantErr:   true,
+		},
+		{
+			name:      "no fallbacks configured",
+			errs:      map[string]error{"us-central1": unavailable},
+			wantTried: []string{"us-central1"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ProjectID: "test-project", Location: "us-central1", FallbackLocations: tt.fallbacks}
+			client := &fakeCreator{errs: tt.errs}
+
+			var location string
+			var err error
+			output := captureLog(t, func() {
+				_, location, err = createExecution(context.Background(), client, config, "alert-handler", &WorkflowInput{})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createExecution() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if location != tt.wantLocation {
+				t.Errorf("location = %q, want %q", location, tt.wantLocation)
+			}
+			if strings.Join(client.tried, ",") != strings.Join(tt.wantTried, ",") {
+				t.Errorf("tried %v, want %v", client.tried, tt.wantTried)
+			}
+			if len(tt.wantTried) > 1 && !strings.Contains(output, "Failing over to "+tt.wantTried[1]) {
+				t.Errorf("failover was not logged: %s", output)
+			}
+		})
+	}
+}
+
+func TestIsFailoverErrorCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if isFailoverError(ctx, status.Error(codes.Unavailable, "unavailable")) {
+		t.Error("a cancelled run should not fail over")
+	}
+	if isFailoverError(context.Background(), errors.New("not a gRPC status")) {
+		t.Error("non-status errors should not fail over")
+	}
+}
+
+func TestLoadConfigFallbackLocations(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "list", value: "europe-west1, asia-east1", want: []string{"europe-west1", "asia-east1"}},
+		{name: "includes primary", value: "europe-west1,us-central1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GCP_PROJECT_ID", "test-project")
+			t.Setenv("GCP_LOCATION", "us-central1")
+			t.Setenv("WORKFLOW_NAME", "alert-handler")
+			t.Setenv("FALLBACK_LOCATIONS", tt.value)
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && strings.Join(config.FallbackLocations, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("FallbackLocations = %v, want %v", config.FallbackLocations, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	executionspb "cloud.google.com/go/workflows/executions/apiv1/executionspb"
+)
+
+// FAILURE_MODE values
+const (
+	failureModeAny = "any"
+	failureModeAll = "all"
+)
+
+// executionsClient is the part of the Workflows executions client used to
+// launch and wait for executions. main wires in *executions.Client; tests
+// inject a fake.
+type executionsClient interface {
+	executionCreator
+	executionGetter
+}
+
+// FanoutResult is one workflow's entry in FANOUT_RESULT_PATH. Name and State
+// are empty when the execution could not be created.
+type FanoutResult struct {
+	Workflow string `json:"workflow"`
+	ExecutionOutput
+}
+
+// FanoutReport is written to FANOUT_RESULT_PATH once every execution of a
+// fan-out has finished
+type FanoutReport struct {
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []FanoutResult `json:"results"`
+}
+
+// parseFailureMode validates FAILURE_MODE, defaulting to any
+func parseFailureMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return failureModeAny, nil
+	case failureModeAny, failureModeAll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported FAILURE_MODE '%s', must be 'any' or 'all'", mode)
+	}
+}
+
+// executeWorkflows launches every workflow in WORKFLOW_NAMES, waits for them
+// when configured, and writes the aggregated outcome to FANOUT_RESULT_PATH
+func executeWorkflows(ctx context.Context, config *Config, client executionsClient, workflowNames []string, input *WorkflowInput) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	return runFanout(ctx, client, config, workflowNames, input)
+}
+
+// runFanout starts all executions before waiting on any of them, so the
+// workflows run concurrently, then aggregates the results under FAILURE_MODE
+func runFanout(ctx context.Context, client executionsClient, config *Config, workflowNames []string, input *WorkflowInput) error {
+	results := make([]FanoutResult, len(workflowNames))
+	errs := make([]error, len(workflowNames))
+	launched := make([]*executionspb.Execution, len(workflowNames))
+
+	for i, workflowName := range workflowNames {
+		results[i].Workflow = workflowName
+		execution, location, err := createExecution(ctx, client, config, workflowName, input)
+		if err != nil {
+			log.Printf("Warning: Failed to start workflow '%s': %v", workflowName, err)
+			results[i].Error = err.Error()
+			errs[i] = err
+			continue
+		}
+		log.Printf("Workflow execution created in %s: %s", location, execution.Name)
+		launched[i] = execution
+		results[i].ExecutionOutput = newExecutionOutput(execution)
+	}
+
+	if config.WaitForCompletion {
+		interval := time.Duration(config.PollIntervalSeconds) * time.Second
+		for i, execution := range launched {
+			if execution == nil {
+				continue
+			}
+			final, err := waitForExecution(ctx, client, execution.Name, interval)
+			if final != nil {
+				results[i].ExecutionOutput = newExecutionOutput(final)
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+				errs[i] = err
+			}
+		}
+	}
+
+	report := FanoutReport{Results: results}
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			report.Failed++
+			failed = append(failed, fmt.Errorf("workflow %s: %w", workflowNames[i], err))
+		} else {
+			report.Succeeded++
+		}
+	}
+	log.Printf("Fan-out finished: %d succeeded, %d failed", report.Succeeded, report.Failed)
+
+	if config.FanoutResultPath != "" {
+		if err := writeJSONFile(config.FanoutResultPath, report); err != nil {
+			return fmt.Errorf("failed to write FANOUT_RESULT_PATH: %w", err)
+		}
+		log.Printf("Fan-out results written to %s", config.FanoutResultPath)
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	if config.FailureMode == failureModeAll && len(failed) < len(workflowNames) {
+		log.Printf("Warning: %d of %d workflows failed (FAILURE_MODE=all)", len(failed), len(workflowNames))
+		return nil
+	}
+	return errors.Join(failed...)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	executionspb "cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeFanout creates one execution per workflow and reports the final
+// execution configured for it
+type fakeFanout struct {
+	createErrs map[string]error
+	final      map[string]*executionspb.Execution
+}
+
+func (f *fakeFanout) CreateExecution(ctx context.Context, req *executionspb.CreateExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error) {
+	workflow := req.Parent[strings.LastIndex(req.Parent, "/")+1:]
+	if err := f.createErrs[workflow]; err != nil {
+		return nil, err
+	}
+	return &executionspb.Execution{Name: req.Parent + "/executions/1", State: executionspb.Execution_ACTIVE}, nil
+}
+
+func (f *fakeFanout) GetExecution(ctx context.Context, req *executionspb.GetExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error) {
+	workflow := strings.Split(req.Name, "/")[5]
+	execution := f.final[workflow]
+	execution.Name = req.Name
+	return execution, nil
+}
+
+func TestRunFanoutWritesAggregatedResults(t *testing.T) {
+	client := &fakeFanout{
+		createErrs: map[string]error{"notify": status.Error(codes.NotFound, "workflow not found")},
+		final: map[string]*executionspb.Execution{
+			"restart-pod": {State: executionspb.Execution_SUCCEEDED, Result: `{"restarted":true}`},
+			"scale-up":    {State: executionspb.Execution_FAILED, Error: &executionspb.Execution_Error{Payload: "quota exceeded"}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "results", "fanout.json")
+
+	tests := []struct {
+		name        string
+		failureMode string
+		wantErr     bool
+	}{
+		{name: "any fails on a partial failure", failureMode: failureModeAny, wantErr: true},
+		{name: "all tolerates a partial failure", failureMode: failureModeAll},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				ProjectID:           "test-project",
+				Location:            "us-central1",
+				WaitForCompletion:   true,
+				PollIntervalSeconds: 1,
+				FailureMode:         tt.failureMode,
+				FanoutResultPath:    path,
+			}
+
+			var err error
+			captureLog(t, func() {
+				err = runFanout(context.Background(), client, config, []string{"restart-pod", "scale-up", "notify"}, &WorkflowInput{})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runFanout() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read FANOUT_RESULT_PATH: %v", err)
+			}
+			var report FanoutReport
+			if err := json.Unmarshal(content, &report); err != nil {
+				t.Fatalf("FANOUT_RESULT_PATH is not valid JSON: %v\n%s", err, content)
+			}
+
+			if report.Succeeded != 1 || report.Failed != 2 || len(report.Results) != 3 {
+				t.Fatalf("report = %+v", report)
+			}
+
+			succeeded := report.Results[0]
+			if succeeded.Workflow != "restart-pod" || succeeded.State != "SUCCEEDED" || !strings.Contains(string(succeeded.Result), `"restarted": true`) {
+				t.Errorf("restart-pod result = %+v", succeeded)
+			}
+			if !strings.HasSuffix(succeeded.Name, "/workflows/restart-pod/executions/1") {
+				t.Errorf("restart-pod execution name = %q", succeeded.Name)
+			}
+
+			failed := report.Results[1]
+			if failed.Workflow != "scale-up" || failed.State != "FAILED" || !strings.Contains(failed.Error, "quota exceeded") {
+				t.Errorf("scale-up result = %+v", failed)
+			}
+
+			notStarted := report.Results[2]
+			if notStarted.Workflow != "notify" || notStarted.Name != "" || !strings.Contains(notStarted.Error, "workflow not found") {
+				t.Errorf("notify result = %+v", notStarted)
+			}
+		})
+	}
+}
+
+func TestRunFanoutAllFailed(t *testing.T) {
+	client := &fakeFanout{createErrs: map[string]error{
+		"a": status.Error(codes.PermissionDenied, "denied"),
+		"b": status.Error(codes.PermissionDenied, "denied"),
+	}}
+	config := &Config{ProjectID: "test-project", Location: "us-central1", FailureMode: failureModeAll}
+
+	var err error
+	captureLog(t, func() { err = runFanout(context.Background(), client, config, []string{"a", "b"}, &WorkflowInput{}) })
+	if err == nil {
+		t.Error("runFanout() should fail when every workflow failed, even with FAILURE_MODE=all")
+	}
+}
+
+func TestLoadConfigWorkflowNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{name: "fan-out", env: map[string]string{"WORKFLOW_NAMES": "restart-pod, scale-up", "FANOUT_RESULT_PATH": "/tmp/fanout.json"}, want: []string{"restart-pod", "scale-up"}},
+		{name: "with WORKFLOW_NAME", env: map[string]string{"WORKFLOW_NAMES": "a,b", "WORKFLOW_NAME": "c"}, wantErr: true},
+		{name: "with OUTPUT_FILE", env: map[string]string{"WORKFLOW_NAMES": "a,b", "OUTPUT_FILE": "/tmp/out.json"}, wantErr: true},
+		{name: "result path without fan-out", env: map[string]string{"WORKFLOW_NAME": "c", "FANOUT_RESULT_PATH": "/tmp/fanout.json"}, wantErr: true},
+		{name: "invalid failure mode", env: map[string]string{"WORKFLOW_NAMES": "a,b", "FAILURE_MODE": "most"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GCP_PROJECT_ID", "test-project")
+			for _, key := range []string{"WORKFLOW_NAME", "WORKFLOW_NAME_FIELD", "WORKFLOW_NAMES", "OUTPUT_FILE", "FANOUT_RESULT_PATH", "FAILURE_MODE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && strings.Join(config.WorkflowNames, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("WorkflowNames = %v, want %v", config.WorkflowNames, tt.want)
+			}
+		})
+	}
+}
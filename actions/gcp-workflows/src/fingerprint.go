@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+// alertFingerprint returns the fingerprint sent by Alertmanager, or with
+// COMPUTE_FINGERPRINT one computed from the labels the same way when the
+// alert has none, so downstream deduplication works for alerts from other
+// sources too. Alerts without labels get no computed fingerprint, since
+// they would all share one.
+func alertFingerprint(fingerprint string, labels map[string]string, compute bool) string {
+	if fingerprint != "" || !compute || len(labels) == 0 {
+		return fingerprint
+	}
+	return fmt.Sprintf("%016x", labelsFingerprint(labels))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	labels := map[string]string{"alertname": "DiskFull", "instance": "node-1"}
+	base := idempotencyKey("", "", "DiskFull", labels, "2024-01-01T12:00:00Z", "firing")
+
+	tests := []struct {
+		name     string
+		field    string
+		value    string
+		labels   map[string]string
+		status   string
+		wantBase bool
+		wantWarn bool
+	}{
+		{name: "same alert", labels: map[string]string{"instance": "node-1", "alertname": "DiskFull"}, status: "firing", wantBase: true},
+		{name: "other status", labels: labels, status: "resolved"},
+		{name: "other labels", labels: map[string]string{"alertname": "DiskFull", "instance": "node-2"}, status: "firing"},
+		{name: "field value", field: "labels.incident", value: "INC-1", labels: labels, status: "firing"},
+		{name: "empty field value falls back", field: "labels.incident", labels: labels, status: "firing", wantBase: true, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			output := captureLog(t, func() {
+				got = idempotencyKey(tt.field, tt.value, "DiskFull", tt.labels, "2024-01-01T12:00:00Z", tt.status)
+			})
+			if len(got) != 32 || strings.Trim(got, "0123456789abcdef") != "" {
+				t.Errorf("idempotencyKey() = %q, want 32 lowercase hex characters", got)
+			}
+			if (got == base) != tt.wantBase {
+				t.Errorf("idempotencyKey() = %s, base %s, want equal %t", got, base, tt.wantBase)
+			}
+			if strings.Contains(output, "Warning:") != tt.wantWarn {
+				t.Errorf("warning logged = %t, want %t: %s", !tt.wantWarn, tt.wantWarn, output)
+			}
+		})
+	}
+
+	if idempotencyKey("", "", "ab", nil, "c", "") == idempotencyKey("", "", "a", nil, "bc", "") {
+		t.Error("idempotencyKey() should separate its parts")
+	}
+}
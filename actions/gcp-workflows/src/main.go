@@ -3,15 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	executions "cloud.google.com/go/workflows/executions/apiv1"
 	"cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/option"
 )
 
@@ -22,62 +27,236 @@ type AlertData struct {
 	Annotations map[string]string `json:"annotations"`
 	StartsAt    string            `json:"startsAt,omitempty"`
 	EndsAt      string            `json:"endsAt,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+
+	// Raw holds the complete parsed ALERT_JSON for field path lookups
+	Raw map[string]interface{} `json:"-"`
 }
 
 // WorkflowInput represents the data structure sent to the workflow
 type WorkflowInput struct {
-	AlertName   string            `json:"alertName"`
-	Status      string            `json:"status"`
-	Severity    string            `json:"severity"`
-	Instance    string            `json:"instance"`
-	Summary     string            `json:"summary"`
-	Description string            `json:"description"`
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
-	Timestamp   string            `json:"timestamp"`
-	Source      string            `json:"source"`
+	AlertName      string            `json:"alertName"`
+	Status         string            `json:"status"`
+	Severity       string            `json:"severity"`
+	Instance       string            `json:"instance"`
+	Summary        string            `json:"summary"`
+	Description    string            `json:"description"`
+	Labels         map[string]string `json:"labels"`
+	Annotations    map[string]string `json:"annotations"`
+	StartsAt       string            `json:"startsAt,omitempty"`
+	EndsAt         string            `json:"endsAt,omitempty"`
+	Fingerprint    string            `json:"fingerprint,omitempty"`
+	Timestamp      string            `json:"timestamp"`
+	Source         string            `json:"source"`
+	IdempotencyKey string            `json:"idempotencyKey"`
+	Truncated      bool              `json:"truncated,omitempty"`
 }
 
+// maxArgumentBytes is the largest execution argument Workflows accepts (32 KB)
+const maxArgumentBytes = 32 * 1024
+
 type Config struct {
-	ProjectID          string
-	Location           string
-	WorkflowName       string
-	WorkflowNameField  string
-	ServiceAccountPath string
-	TimeoutSeconds     int
-	Source             string
-	WaitForCompletion  bool
+	ProjectID            string                `json:"GCP_PROJECT_ID"`
+	Location             string                `json:"GCP_LOCATION"`
+	FallbackLocations    []string              `json:"FALLBACK_LOCATIONS"`
+	WorkflowName         string                `json:"WORKFLOW_NAME"`
+	WorkflowNameFiring   string                `json:"WORKFLOW_NAME_FIRING"`
+	WorkflowNameResolved string                `json:"WORKFLOW_NAME_RESOLVED"`
+	WorkflowNameField    string                `json:"WORKFLOW_NAME_FIELD"`
+	WorkflowNameDefault  string                `json:"WORKFLOW_NAME_DEFAULT"`
+	WorkflowNames        []string              `json:"WORKFLOW_NAMES"`
+	FailureMode          string                `json:"FAILURE_MODE"`
+	FanoutResultPath     string                `json:"FANOUT_RESULT_PATH"`
+	ServiceAccountPath   string                `json:"GOOGLE_APPLICATION_CREDENTIALS"`
+	Endpoint             string                `json:"WORKFLOWS_ENDPOINT"`
+	TimeoutSeconds       int                   `json:"TIMEOUT_SECONDS"`
+	RetryMaxAttempts     int                   `json:"RETRY_MAX_ATTEMPTS"`
+	PollIntervalSeconds  int                   `json:"POLL_INTERVAL_SECONDS"`
+	Source               string                `json:"WORKFLOW_SOURCE"`
+	TimestampSource      string                `json:"TIMESTAMP_SOURCE"`
+	ComputeFingerprint   bool                  `json:"COMPUTE_FINGERPRINT"`
+	IdempotencyKeyField  string                `json:"IDEMPOTENCY_KEY_FIELD"`
+	MaxPayloadBytes      int                   `json:"MAX_PAYLOAD_BYTES"`
+	OnOversize           string                `json:"ON_OVERSIZE"`
+	WaitForCompletion    bool                  `json:"WAIT_FOR_COMPLETION"`
+	MissingAlertNameMode string                `json:"MISSING_ALERTNAME_MODE"`
+	AlertNameLabels      []string              `json:"ALERTNAME_FROM_LABELS"`
+	OutputFile           string                `json:"OUTPUT_FILE"`
+	AllowedSeverities    []string              `json:"ALLOWED_SEVERITIES"`
+	AllowedStatuses      []string              `json:"ALLOWED_STATUSES"`
+	OnInvalid            string                `json:"ON_INVALID"`
+	EnrichmentFile       string                `json:"ENRICHMENT_FILE"`
+	EnrichmentKeyField   string                `json:"ENRICHMENT_KEY_FIELD"`
+	Enrichment           map[string]Enrichment `json:"-"`
+	StrictEnv            bool                  `json:"STRICT_ENV"`
+	LogFormat            string                `json:"LOG_FORMAT"`
+	LogConfig            bool                  `json:"LOG_CONFIG"`
+	LogPayload           bool                  `json:"LOG_PAYLOAD"`
+	RedactFields         []string              `json:"REDACT_FIELDS"`
+	MetricsEnabled       bool                  `json:"METRICS_ENABLED"`
+	DedupRedisURL        string                `json:"DEDUP_REDIS_URL"`
+	DedupFile            string                `json:"DEDUP_FILE"`
+	DedupTTLSeconds      int                   `json:"DEDUP_TTL_SECONDS"`
+	DedupFailureMode     string                `json:"DEDUP_FAILURE_MODE"`
+	PushgatewayURL       string                `json:"PUSHGATEWAY_URL"`
+	DryRun               bool                  `json:"DRY_RUN"`
+	Sink                 string                `json:"SINK"`
+	SinkDir              string                `json:"SINK_DIR"`
+}
+
+// exitCodeCancelled is the exit code used when the action is stopped by
+// SIGTERM or SIGINT, following the shell convention of 128+SIGINT
+const exitCodeCancelled = 130
+
+// newSignalContext returns a context that is cancelled when the process
+// receives SIGTERM (e.g. pod eviction) or SIGINT
+func newSignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 }
 
 func main() {
+	// Structure logs first so every line, including config errors, uses the format
+	setupLogging(os.Getenv("LOG_FORMAT"))
+
 	log.Println("Starting GCP Workflows executor...")
 
+	ctx, stop := newSignalContext()
+	defer stop()
+
+	// Bridge logs to OpenTelemetry when an OTLP endpoint is configured
+	ctx, err := setupTelemetry(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to set up OpenTelemetry, logging to stderr only: %v", err)
+	}
+	defer func() { shutdownTelemetry() }()
+
+	// Push the run's outcome on exit when a Pushgateway is configured
+	setupPushgateway(os.Getenv("PUSHGATEWAY_URL"))
+	defer reactionMetrics.push(true)
+
 	// Load configuration
 	config, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Configuration error: %v", err)
+		fatalf("Configuration error: %v", err)
 	}
 
+	if config.LogConfig {
+		logResolvedConfig(config)
+	}
+
+	setupMetrics(config.MetricsEnabled)
+
 	// Parse alert data
 	alertData, err := parseAlertData()
 	if err != nil {
 		log.Printf("Warning: Failed to parse alert data: %v", err)
 	}
 
-	// Determine the workflow name
-	workflowName, err := resolveWorkflowName(config, alertData)
+	// Merge static enrichment data into the alert
+	if config.EnrichmentFile != "" {
+		if enrichAlert(alertData, config.EnrichmentKeyField, config.Enrichment) {
+			log.Printf("Alert enriched from %s", config.EnrichmentFile)
+		}
+	}
+
+	// Build input payload
+	input := buildWorkflowInput(alertData, config.Source, config.TimestampSource)
+	input.Fingerprint = alertFingerprint(input.Fingerprint, input.Labels, config.ComputeFingerprint)
+
+	// Route the alert to the workflow configured for its status
+	if err := routeWorkflow(config, input.Status); err != nil {
+		fatalf("Configuration error: %v", err)
+	}
+
+	// Determine the workflow name, or the fan-out list
+	workflowNames := config.WorkflowNames
+	if len(workflowNames) == 0 {
+		workflowName, err := resolveWorkflowName(config, alertData)
+		if err != nil {
+			fatalf("Failed to resolve workflow name: %v", err)
+		}
+		workflowNames = []string{workflowName}
+	}
+
+	log.Printf("Resolved workflow name: %s", strings.Join(workflowNames, ", "))
+
+	// Handle alerts without an alertname label
+	alertName, send, err := ensureAlertName(config, input.AlertName, input.Labels)
 	if err != nil {
-		log.Fatalf("Failed to resolve workflow name: %v", err)
+		fatalf("Invalid alert: %v", err)
+	}
+	if !send {
+		log.Println("Skipping alert without an alertname label (MISSING_ALERTNAME_MODE=skip)")
+		return
+	}
+	setLogAlertName(alertName)
+	reactionMetrics.setAlertStatus(input.Status)
+	input.AlertName = alertName
+	input.IdempotencyKey = resolveIdempotencyKey(config, alertData, input)
+
+	// Reject alerts with a severity or status outside the allowed sets
+	if err := validateAlert(config, input.Severity, input.Status); err != nil {
+		if config.OnInvalid == onInvalidDrop {
+			log.Printf("Dropping invalid alert (ON_INVALID=drop): %v", err)
+			return
+		}
+		fatalf("Invalid alert: %v", err)
 	}
 
-	log.Printf("Resolved workflow name: %s", workflowName)
+	// Log what would be sent instead of contacting Workflows if configured
+	if config.DryRun {
+		if err := logDryRun(config, workflowNames, input); err != nil {
+			fatalf("Dry run failed: %v", err)
+		}
+		log.Println("Dry run complete, nothing was sent")
+		return
+	}
 
-	// Build input payload
-	input := buildWorkflowInput(alertData, config.Source)
+	// Write to the local file sink instead of Workflows if configured
+	if config.Sink == sinkFile {
+		for _, workflowName := range workflowNames {
+			path, err := writeFileSink(config, workflowName, input)
+			if err != nil {
+				fatalf("Failed to write execution request to file sink: %v", err)
+			}
+			log.Printf("Execution request written to file sink: %s", path)
+		}
+		return
+	}
 
-	// Execute workflow
-	if err := executeWorkflow(config, workflowName, input); err != nil {
-		log.Fatalf("Failed to execute workflow: %v", err)
+	// Skip alerts another run already handled within DEDUP_TTL_SECONDS
+	dedup, key, duplicate, err := checkDuplicate(ctx, config, input.Labels, input.Status)
+	if err != nil {
+		fatalf("Deduplication failed: %v", err)
+	}
+	if duplicate {
+		log.Printf("Skipping duplicate alert, already handled within the last %ds", config.DedupTTLSeconds)
+		return
+	}
+	if dedup != nil {
+		defer dedup.close()
+	}
+
+	// Execute the workflow, or every workflow of a fan-out
+	start := time.Now()
+	client, err := newExecutionsClient(ctx, config)
+	if err == nil {
+		if len(config.WorkflowNames) > 0 {
+			err = executeWorkflows(ctx, config, client, workflowNames, input)
+		} else {
+			err = executeWorkflow(ctx, config, client, workflowNames[0], input)
+		}
+		client.Close()
+	}
+	reactionMetrics.observeCall(start)
+	clientMetrics.flush(context.Background())
+	if err != nil {
+		releaseAlert(dedup, key)
+		if ctx.Err() != nil {
+			log.Printf("Workflow execution cancelled by signal: %v", err)
+			exit(exitCodeCancelled)
+		}
+		fatalf("Failed to execute workflow: %v", err)
 	}
 
 	log.Println("Workflow execution completed successfully")
@@ -85,15 +264,27 @@ func main() {
 
 func loadConfig() (*Config, error) {
 	config := &Config{
-		ProjectID:          os.Getenv("GCP_PROJECT_ID"),
-		Location:           os.Getenv("GCP_LOCATION"),
-		WorkflowName:       os.Getenv("WORKFLOW_NAME"),
-		WorkflowNameField:  os.Getenv("WORKFLOW_NAME_FIELD"),
-		ServiceAccountPath: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
-		TimeoutSeconds:     300, // default 5 minutes
-		Source:             "karo",
-		WaitForCompletion:  true,
+		ProjectID:            os.Getenv("GCP_PROJECT_ID"),
+		Location:             os.Getenv("GCP_LOCATION"),
+		WorkflowName:         os.Getenv("WORKFLOW_NAME"),
+		WorkflowNameFiring:   os.Getenv("WORKFLOW_NAME_FIRING"),
+		WorkflowNameResolved: os.Getenv("WORKFLOW_NAME_RESOLVED"),
+		WorkflowNameField:    os.Getenv("WORKFLOW_NAME_FIELD"),
+		WorkflowNameDefault:  os.Getenv("WORKFLOW_NAME_DEFAULT"),
+		ServiceAccountPath:   os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		Endpoint:             os.Getenv("WORKFLOWS_ENDPOINT"),
+		TimeoutSeconds:       300, // default 5 minutes
+		PollIntervalSeconds:  5,
+		Source:               "karo",
+		WaitForCompletion:    true,
+	}
+
+	// Parse STRICT_ENV first, since it decides how malformed values below are handled
+	strict, err := parseStrictEnv()
+	if err != nil {
+		return nil, err
 	}
+	config.StrictEnv = strict
 
 	// Validate required fields
 	if config.ProjectID == "" {
@@ -104,19 +295,62 @@ func loadConfig() (*Config, error) {
 		log.Printf("GCP_LOCATION not specified, using default: %s", config.Location)
 	}
 
+	// Parse optional failover regions, tried in order if GCP_LOCATION is unavailable
+	for _, location := range parseLabelList(os.Getenv("FALLBACK_LOCATIONS")) {
+		if location == config.Location {
+			return nil, fmt.Errorf("FALLBACK_LOCATIONS must not include GCP_LOCATION '%s'", location)
+		}
+		config.FallbackLocations = append(config.FallbackLocations, location)
+	}
+
 	// Validate workflow name configuration
-	if config.WorkflowName == "" && config.WorkflowNameField == "" {
-		return nil, fmt.Errorf("either WORKFLOW_NAME (static) or WORKFLOW_NAME_FIELD (from alert) must be specified")
+	config.WorkflowNames = parseLabelList(os.Getenv("WORKFLOW_NAMES"))
+	if config.WorkflowName == "" && config.WorkflowNameField == "" && len(config.WorkflowNames) == 0 &&
+		config.WorkflowNameFiring == "" && config.WorkflowNameResolved == "" {
+		return nil, fmt.Errorf("either WORKFLOW_NAME (static), WORKFLOW_NAME_FIELD (from alert), WORKFLOW_NAMES (fan-out) or WORKFLOW_NAME_FIRING/WORKFLOW_NAME_RESOLVED (per status) must be specified")
 	}
 	if config.WorkflowName != "" && config.WorkflowNameField != "" {
 		return nil, fmt.Errorf("WORKFLOW_NAME and WORKFLOW_NAME_FIELD are mutually exclusive, specify only one")
 	}
+	if len(config.WorkflowNames) > 0 && (config.WorkflowName != "" || config.WorkflowNameField != "") {
+		return nil, fmt.Errorf("WORKFLOW_NAMES is mutually exclusive with WORKFLOW_NAME and WORKFLOW_NAME_FIELD")
+	}
+	if config.WorkflowNameDefault != "" && config.WorkflowNameField == "" {
+		return nil, fmt.Errorf("WORKFLOW_NAME_DEFAULT requires WORKFLOW_NAME_FIELD")
+	}
+
+	// Parse fan-out options
+	failureMode, err := parseFailureMode(os.Getenv("FAILURE_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	config.FailureMode = failureMode
+	config.FanoutResultPath = os.Getenv("FANOUT_RESULT_PATH")
+	if config.FanoutResultPath != "" && len(config.WorkflowNames) == 0 {
+		return nil, fmt.Errorf("FANOUT_RESULT_PATH requires WORKFLOW_NAMES, use OUTPUT_FILE for a single workflow")
+	}
 
 	// Parse optional timeout
-	if timeoutStr := os.Getenv("TIMEOUT_SECONDS"); timeoutStr != "" {
-		if timeout, err := strconv.Atoi(timeoutStr); err == nil {
-			config.TimeoutSeconds = timeout
+	if err := envInt(config.StrictEnv, "TIMEOUT_SECONDS", &config.TimeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	// Parse optional CreateExecution retry limit
+	config.RetryMaxAttempts = defaultRetryMaxAttempts
+	if err := envInt(config.StrictEnv, "RETRY_MAX_ATTEMPTS", &config.RetryMaxAttempts); err != nil {
+		return nil, err
+	}
+	if config.RetryMaxAttempts < 1 {
+		return nil, fmt.Errorf("RETRY_MAX_ATTEMPTS must be at least 1, got %d", config.RetryMaxAttempts)
+	}
+
+	// Parse optional poll interval
+	if intervalStr := os.Getenv("POLL_INTERVAL_SECONDS"); intervalStr != "" {
+		interval, err := strconv.Atoi(intervalStr)
+		if err != nil || interval < 1 {
+			return nil, fmt.Errorf("invalid POLL_INTERVAL_SECONDS '%s': must be an integer of at least 1", intervalStr)
 		}
+		config.PollIntervalSeconds = interval
 	}
 
 	// Override source if provided
@@ -124,11 +358,113 @@ func loadConfig() (*Config, error) {
 		config.Source = source
 	}
 
+	// Parse the source of the payload timestamp
+	timestampSource, err := parseTimestampSource(os.Getenv("TIMESTAMP_SOURCE"))
+	if err != nil {
+		return nil, err
+	}
+	config.TimestampSource = timestampSource
+
+	// Parse whether to compute missing fingerprints from the labels
+	config.ComputeFingerprint = true
+	if err := envBool(config.StrictEnv, "COMPUTE_FINGERPRINT", &config.ComputeFingerprint); err != nil {
+		return nil, err
+	}
+	config.IdempotencyKeyField = os.Getenv("IDEMPOTENCY_KEY_FIELD")
+
+	// Parse the input size limit, the Workflows argument maximum by default
+	config.MaxPayloadBytes = maxArgumentBytes
+	if err := parseOversizeConfig(config); err != nil {
+		return nil, err
+	}
+
 	// Parse wait for completion flag
-	if waitStr := os.Getenv("WAIT_FOR_COMPLETION"); waitStr != "" {
-		if wait, err := strconv.ParseBool(waitStr); err == nil {
-			config.WaitForCompletion = wait
+	if err := envBool(config.StrictEnv, "WAIT_FOR_COMPLETION", &config.WaitForCompletion); err != nil {
+		return nil, err
+	}
+
+	// Parse handling of alerts without an alertname label
+	mode, err := parseMissingAlertNameMode(os.Getenv("MISSING_ALERTNAME_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	config.MissingAlertNameMode = mode
+	config.AlertNameLabels = parseLabelList(os.Getenv("ALERTNAME_FROM_LABELS"))
+
+	// Parse optional severity and status validation
+	if err := parseValidationConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional output file for chaining the execution result
+	config.OutputFile = os.Getenv("OUTPUT_FILE")
+	if config.OutputFile != "" && len(config.WorkflowNames) > 0 {
+		return nil, fmt.Errorf("OUTPUT_FILE is not supported with WORKFLOW_NAMES, use FANOUT_RESULT_PATH")
+	}
+
+	// Parse optional enrichment lookup file
+	config.EnrichmentFile = os.Getenv("ENRICHMENT_FILE")
+	config.EnrichmentKeyField = os.Getenv("ENRICHMENT_KEY_FIELD")
+	if config.EnrichmentKeyField == "" {
+		config.EnrichmentKeyField = defaultEnrichmentKeyField
+	}
+	if err := validateEnrichmentKeyField(config.EnrichmentKeyField); err != nil {
+		return nil, err
+	}
+	if config.EnrichmentFile != "" {
+		enrichment, err := loadEnrichmentFile(config.EnrichmentFile)
+		if err != nil {
+			return nil, err
 		}
+		config.Enrichment = enrichment
+	}
+
+	// Parse log config flag
+	if err := envBool(config.StrictEnv, "LOG_CONFIG", &config.LogConfig); err != nil {
+		return nil, err
+	}
+
+	// Parse payload logging options; the payload is logged by default
+	config.LogPayload = true
+	if err := envBool(config.StrictEnv, "LOG_PAYLOAD", &config.LogPayload); err != nil {
+		return nil, err
+	}
+	config.RedactFields = parseLabelList(os.Getenv("REDACT_FIELDS"))
+
+	// Parse log format, applied by setupLogging at startup
+	logFormat, err := parseLogFormat(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFormat = logFormat
+
+	// PUSHGATEWAY_URL is applied before the configuration is loaded, see
+	// setupPushgateway; it is kept here so LOG_CONFIG shows it
+	config.PushgatewayURL = os.Getenv("PUSHGATEWAY_URL")
+
+	// Parse metrics flag
+	if err := envBool(config.StrictEnv, "METRICS_ENABLED", &config.MetricsEnabled); err != nil {
+		return nil, err
+	}
+
+	// Parse optional sink override
+	config.Sink = os.Getenv("SINK")
+	config.SinkDir = os.Getenv("SINK_DIR")
+	if err := validateSink(config.Sink, config.SinkDir); err != nil {
+		return nil, err
+	}
+
+	// Parse dry-run flag
+	if err := envBool(config.StrictEnv, "DRY_RUN", &config.DryRun); err != nil {
+		return nil, err
+	}
+	if config.DryRun && config.Sink != "" {
+		return nil, fmt.Errorf("DRY_RUN and SINK are mutually exclusive")
+	}
+
+	// Parse optional deduplication settings
+	if err := parseDedupConfig(config); err != nil {
+		return nil, err
 	}
 
 	log.Printf("Configuration loaded - Project: %s, Location: %s, Timeout: %ds, Wait: %t",
@@ -137,21 +473,78 @@ func loadConfig() (*Config, error) {
 	return config, nil
 }
 
+// logResolvedConfig logs the effective configuration as a single JSON line,
+// masking the service account credentials path and the Redis URL, which may
+// embed a password
+func logResolvedConfig(config *Config) {
+	redacted := *config
+	if redacted.ServiceAccountPath != "" {
+		redacted.ServiceAccountPath = "***"
+	}
+	if redacted.DedupRedisURL != "" {
+		redacted.DedupRedisURL = "***"
+	}
+	if redacted.PushgatewayURL != "" {
+		redacted.PushgatewayURL = "***"
+	}
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		log.Printf("Warning: Failed to marshal configuration: %v", err)
+		return
+	}
+	log.Printf("Resolved configuration: %s", string(data))
+}
+
 func parseAlertData() (*AlertData, error) {
-	alertJSON := os.Getenv("ALERT_JSON")
+	alertJSON, err := readAlertJSON()
+	if err != nil {
+		return nil, err
+	}
 	if alertJSON == "" {
 		log.Println("No ALERT_JSON provided, using individual environment variables")
 		return nil, nil
 	}
 
+	// Decode the raw document with UseNumber so numeric values used in
+	// field lookups keep their original representation
+	var raw interface{}
+	decoder := json.NewDecoder(strings.NewReader(alertJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ALERT_JSON: %w", err)
+	}
+	rawObject, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ALERT_JSON must be a JSON object, got %T", raw)
+	}
+
 	var alertData AlertData
 	if err := json.Unmarshal([]byte(alertJSON), &alertData); err != nil {
-		return nil, fmt.Errorf("failed to parse ALERT_JSON: %w", err)
+		return nil, fmt.Errorf("ALERT_JSON does not match the expected alert structure: %w", err)
 	}
+	alertData.Raw = rawObject
 
 	return &alertData, nil
 }
 
+// routeWorkflow runs WORKFLOW_NAME_FIRING or WORKFLOW_NAME_RESOLVED when one
+// is set for the alert's status, e.g. to handle resolutions with a lighter
+// workflow. The override replaces WORKFLOW_NAME, WORKFLOW_NAME_FIELD and
+// WORKFLOW_NAMES for that status.
+func routeWorkflow(config *Config, status string) error {
+	if name := statusOverride(config.WorkflowNameFiring, config.WorkflowNameResolved, status); name != "" {
+		config.WorkflowName = name
+		config.WorkflowNameField = ""
+		config.WorkflowNames = nil
+		log.Printf("Routing %s alert to workflow %s", strings.ToLower(status), name)
+	}
+	if config.WorkflowName == "" && config.WorkflowNameField == "" && len(config.WorkflowNames) == 0 {
+		return missingRouteError("WORKFLOW_NAME", status)
+	}
+	return nil
+}
+
 func resolveWorkflowName(config *Config, alert *AlertData) (string, error) {
 	// If static workflow name is provided, use it
 	if config.WorkflowName != "" {
@@ -175,6 +568,14 @@ func resolveWorkflowName(config *Config, alert *AlertData) (string, error) {
 		workflowName = extractFieldFromEnv(config.WorkflowNameField)
 	}
 
+	// Route alerts without the field to the catch-all workflow if configured
+	source := fmt.Sprintf("field '%s'", config.WorkflowNameField)
+	if workflowName == "" && config.WorkflowNameDefault != "" {
+		log.Printf("Alert field '%s' is empty, using WORKFLOW_NAME_DEFAULT", config.WorkflowNameField)
+		workflowName = config.WorkflowNameDefault
+		source = "WORKFLOW_NAME_DEFAULT"
+	}
+
 	if workflowName == "" {
 		return "", fmt.Errorf("workflow name not found in alert field '%s'", config.WorkflowNameField)
 	}
@@ -183,40 +584,76 @@ func resolveWorkflowName(config *Config, alert *AlertData) (string, error) {
 	workflowName = sanitizeWorkflowName(workflowName)
 
 	if workflowName == "" {
-		return "", fmt.Errorf("workflow name from field '%s' is invalid after sanitization", config.WorkflowNameField)
+		return "", fmt.Errorf("workflow name from %s is invalid after sanitization", source)
 	}
 
 	return workflowName, nil
 }
 
-func extractFieldFromAlert(alert *AlertData, fieldPath string) string {
-	// Support dot notation for nested fields
-	// Examples: "labels.workflow", "annotations.workflow_name", "status"
-	parts := strings.Split(fieldPath, ".")
-
-	if len(parts) == 1 {
-		// Direct field access
-		switch parts[0] {
-		case "status":
-			return alert.Status
+// resolveIdempotencyKey derives the idempotency key of the alert, reading
+// IDEMPOTENCY_KEY_FIELD from the alert or the environment like
+// WORKFLOW_NAME_FIELD
+func resolveIdempotencyKey(config *Config, alert *AlertData, input *WorkflowInput) string {
+	value := ""
+	if config.IdempotencyKeyField != "" {
+		if alert != nil {
+			value = extractFieldFromAlert(alert, config.IdempotencyKeyField)
 		}
+		if value == "" {
+			value = extractFieldFromEnv(config.IdempotencyKeyField)
+		}
+	}
+	return idempotencyKey(config.IdempotencyKeyField, value, input.AlertName, input.Labels, input.StartsAt, input.Status)
+}
+
+func extractFieldFromAlert(alert *AlertData, fieldPath string) string {
+	// Support dot notation for nested fields of any depth
+	// Examples: "labels.workflow", "annotations.workflow_name", "status",
+	// "labels.k8s.io/component" (label key containing dots)
+	if alert.Raw == nil {
 		return ""
 	}
 
-	if len(parts) == 2 {
-		switch parts[0] {
-		case "labels":
-			if alert.Labels != nil {
-				return alert.Labels[parts[1]]
-			}
-		case "annotations":
-			if alert.Annotations != nil {
-				return alert.Annotations[parts[1]]
-			}
+	value := lookupPath(alert.Raw, strings.Split(fieldPath, "."))
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		// Only scalar values can be used as a workflow name
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// lookupPath walks the parsed JSON following the path segments. Because map
+// keys may themselves contain dots (e.g. "k8s.io/component"), at each level
+// the longest run of remaining segments that exists as a literal key wins.
+func lookupPath(node interface{}, parts []string) interface{} {
+	if len(parts) == 0 {
+		return node
+	}
+
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	for i := len(parts); i > 0; i-- {
+		child, exists := m[strings.Join(parts[:i], ".")]
+		if !exists {
+			continue
+		}
+		if value := lookupPath(child, parts[i:]); value != nil {
+			return value
 		}
 	}
 
-	return ""
+	return nil
 }
 
 func extractFieldFromEnv(fieldPath string) string {
@@ -241,15 +678,14 @@ func extractFieldFromEnv(fieldPath string) string {
 
 func sanitizeWorkflowName(name string) string {
 	// GCP Workflow names must match ^[a-zA-Z_][a-zA-Z0-9_-]*$
-	// Convert to lowercase and replace invalid characters
-	name = strings.ToLower(name)
+	// Replace separators and drop invalid characters, preserving case
 	name = strings.ReplaceAll(name, " ", "-")
 	name = strings.ReplaceAll(name, ".", "-")
 
 	// Remove any characters that aren't alphanumeric, underscore, or hyphen
 	var result strings.Builder
 	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
 			result.WriteRune(r)
 		}
 	}
@@ -257,7 +693,7 @@ func sanitizeWorkflowName(name string) string {
 	sanitized := result.String()
 
 	// Ensure it starts with a letter or underscore
-	if len(sanitized) > 0 && sanitized[0] >= '0' && sanitized[0] <= '9' {
+	if len(sanitized) > 0 && ((sanitized[0] >= '0' && sanitized[0] <= '9') || sanitized[0] == '-') {
 		sanitized = "_" + sanitized
 	}
 
@@ -269,10 +705,9 @@ func sanitizeWorkflowName(name string) string {
 	return sanitized
 }
 
-func buildWorkflowInput(alert *AlertData, source string) *WorkflowInput {
+func buildWorkflowInput(alert *AlertData, source, timestampSource string) *WorkflowInput {
 	input := &WorkflowInput{
-		Source:    source,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Source: source,
 	}
 
 	// If we have parsed alert data, use it
@@ -280,6 +715,9 @@ func buildWorkflowInput(alert *AlertData, source string) *WorkflowInput {
 		input.Status = alert.Status
 		input.Labels = alert.Labels
 		input.Annotations = alert.Annotations
+		input.StartsAt = alert.StartsAt
+		input.EndsAt = alert.EndsAt
+		input.Fingerprint = alert.Fingerprint
 
 		if alert.Labels != nil {
 			input.AlertName = alert.Labels["alertname"]
@@ -312,106 +750,268 @@ func buildWorkflowInput(alert *AlertData, source string) *WorkflowInput {
 	if input.Description == "" {
 		input.Description = os.Getenv("ALERT_DESCRIPTION")
 	}
+	if input.StartsAt == "" {
+		input.StartsAt = os.Getenv("ALERT_STARTS_AT")
+	}
+	if input.EndsAt == "" {
+		input.EndsAt = os.Getenv("ALERT_ENDS_AT")
+	}
+	if input.Fingerprint == "" {
+		input.Fingerprint = os.Getenv("ALERT_FINGERPRINT")
+	}
+	input.Timestamp = alertTimestamp(timestampSource, input.Status, input.StartsAt, input.EndsAt)
 
 	return input
 }
 
-func executeWorkflow(config *Config, workflowName string, input *WorkflowInput) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.TimeoutSeconds)*time.Second)
-	defer cancel()
-
-	// Create client options
-	var clientOptions []option.ClientOption
-	if config.ServiceAccountPath != "" {
-		clientOptions = append(clientOptions, option.WithCredentialsFile(config.ServiceAccountPath))
-	}
-	// If no service account file is provided, the client will use Application Default Credentials
-
-	// Create Workflows client
-	client, err := executions.NewClient(ctx, clientOptions...)
-	if err != nil {
-		return fmt.Errorf("failed to create Workflows client: %w", err)
-	}
-	defer client.Close()
-
+// buildExecutionRequest builds the CreateExecution request for the workflow
+// with the alert input as its JSON argument. The Executions API has no
+// client-specified execution ID, so the idempotency key is set as the
+// idempotency-key label to find earlier executions for the same alert,
+// alongside labels identifying the alert. The argument is held to
+// MAX_PAYLOAD_BYTES.
+func buildExecutionRequest(config *Config, location, workflowName string, input *WorkflowInput) (*executionspb.CreateExecutionRequest, error) {
 	// Convert input to JSON
-	inputData, err := json.Marshal(input)
+	text := payloadText{
+		annotations: &input.Annotations,
+		fields:      []*string{&input.Summary, &input.Description},
+		truncated:   &input.Truncated,
+	}
+	inputData, err := fitPayload(config, text, func() ([]byte, error) { return json.Marshal(input) })
 	if err != nil {
-		return fmt.Errorf("failed to marshal workflow input: %w", err)
+		return nil, fmt.Errorf("failed to marshal workflow input: %w", err)
 	}
 
-	log.Printf("Executing workflow '%s' with input: %s", workflowName, string(inputData))
-
 	// Construct the workflow path
-	workflowPath := fmt.Sprintf("projects/%s/locations/%s/workflows/%s", config.ProjectID, config.Location, workflowName)
+	workflowPath := fmt.Sprintf("projects/%s/locations/%s/workflows/%s", config.ProjectID, location, workflowName)
 
-	// Create execution request
-	req := &executionspb.CreateExecutionRequest{
+	return &executionspb.CreateExecutionRequest{
 		Parent: workflowPath,
 		Execution: &executionspb.Execution{
 			Argument: string(inputData),
+			Labels:   executionLabels(input),
 		},
+	}, nil
+}
+
+// executionLabels returns the labels of the execution, so executions can be
+// filtered and attributed by alert in the console and ListExecutions: the
+// alert name, severity, source and fingerprint, and the idempotency key.
+// Empty values are left out; nil when there are none.
+func executionLabels(input *WorkflowInput) map[string]string {
+	labels := map[string]string{}
+	for _, label := range []struct{ key, value string }{
+		{"alertname", input.AlertName},
+		{"severity", input.Severity},
+		{"source", input.Source},
+		{"fingerprint", input.Fingerprint},
+		{"idempotency-key", input.IdempotencyKey},
+	} {
+		if value := sanitizeLabelValue(label.value); value != "" {
+			labels[label.key] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// sanitizeLabelValue makes value a valid GCP label value: lowercase
+// letters, digits, underscores and hyphens, at most 63 characters. Other
+// characters become underscores, e.g. "HighCPU" becomes "highcpu" and
+// "karo/gcp-workflows" becomes "karo_gcp-workflows".
+func sanitizeLabelValue(value string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, value)
+
+	// Trim to maximum length (GCP limit is 63 characters)
+	if len(sanitized) > 63 {
+		sanitized = sanitized[:63]
+	}
+	return sanitized
+}
+
+// newExecutionsClient creates the Workflows executions client
+func newExecutionsClient(ctx context.Context, config *Config) (*executions.Client, error) {
+	client, err := executions.NewClient(ctx, clientOptions(config)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Workflows client: %w", err)
+	}
+	return client, nil
+}
+
+// clientOptions builds the Workflows client options, using the service
+// account key file if configured and Application Default Credentials
+// otherwise, and WORKFLOWS_ENDPOINT instead of the default host if set
+func clientOptions(config *Config) []option.ClientOption {
+	var opts []option.ClientOption
+	if config.ServiceAccountPath != "" {
+		opts = append(opts, option.WithCredentialsFile(config.ServiceAccountPath))
+	}
+	if config.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(config.Endpoint))
 	}
+	return opts
+}
+
+// executeWorkflow starts the workflow within TIMEOUT_SECONDS and, when
+// configured, waits for it to finish
+func executeWorkflow(ctx context.Context, config *Config, client executionsClient, workflowName string, input *WorkflowInput) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
 
-	// Execute workflow
-	execution, err := client.CreateExecution(ctx, req)
+	// Execute workflow, failing over to FALLBACK_LOCATIONS on regional errors
+	execution, location, err := createExecution(ctx, client, config, workflowName, input)
 	if err != nil {
-		return fmt.Errorf("failed to create workflow execution: %w", err)
+		return err
 	}
 
-	log.Printf("Workflow execution created: %s", execution.Name)
+	log.Printf("Workflow execution created in %s: %s", location, execution.Name)
 
 	// If configured to wait for completion, poll for result
 	if config.WaitForCompletion {
-		return waitForExecution(ctx, client, execution.Name)
+		final, err := waitForExecution(ctx, client, execution.Name, time.Duration(config.PollIntervalSeconds)*time.Second)
+		if config.OutputFile != "" && final != nil {
+			if writeErr := writeExecutionOutput(config.OutputFile, final); writeErr != nil {
+				if err != nil {
+					log.Printf("Warning: %v", writeErr)
+					return err
+				}
+				return writeErr
+			}
+		}
+		return err
+	}
+
+	if config.OutputFile != "" {
+		if err := writeExecutionOutput(config.OutputFile, execution); err != nil {
+			return err
+		}
 	}
 
 	log.Println("Workflow execution started successfully (not waiting for completion)")
 	return nil
 }
 
-func waitForExecution(ctx context.Context, client *executions.Client, executionName string) error {
-	log.Println("Waiting for workflow execution to complete...")
+// ExecutionOutput is written to OUTPUT_FILE so the execution outcome can be
+// consumed by a subsequent action
+type ExecutionOutput struct {
+	Name   string          `json:"name"`
+	State  string          `json:"state"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
 
-	ticker := time.NewTicker(5 * time.Second)
+// writeExecutionOutput writes the execution name, state and result or error
+// payload as JSON to path
+func writeExecutionOutput(path string, execution *executionspb.Execution) error {
+	if err := writeJSONFile(path, newExecutionOutput(execution)); err != nil {
+		return fmt.Errorf("failed to write OUTPUT_FILE: %w", err)
+	}
+
+	log.Printf("Execution output written to %s", path)
+	return nil
+}
+
+// newExecutionOutput summarizes an execution for OUTPUT_FILE and
+// FANOUT_RESULT_PATH
+func newExecutionOutput(execution *executionspb.Execution) ExecutionOutput {
+	output := ExecutionOutput{
+		Name:  execution.Name,
+		State: execution.State.String(),
+		Error: execution.Error.GetPayload(),
+	}
+
+	// Workflows returns the result as serialized JSON; keep it structured
+	// when possible so consumers don't have to decode it twice
+	if execution.Result != "" {
+		if json.Valid([]byte(execution.Result)) {
+			output.Result = json.RawMessage(execution.Result)
+		} else {
+			quoted, _ := json.Marshal(execution.Result)
+			output.Result = quoted
+		}
+	}
+	return output
+}
+
+// writeJSONFile writes v as indented JSON, creating the parent directory
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// executionGetter is the part of the Workflows executions client used to
+// poll an execution, so polling can be tested without the API
+type executionGetter interface {
+	GetExecution(ctx context.Context, req *executionspb.GetExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error)
+}
+
+// waitForExecution polls until the execution reaches a final state and
+// returns it. Failed and cancelled executions are returned along with the
+// error so their outcome can still be reported.
+func waitForExecution(ctx context.Context, client executionGetter, executionName string, interval time.Duration) (*executionspb.Execution, error) {
+	log.Printf("Waiting for workflow execution to complete (polling every %s)...", interval)
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Check immediately so short workflows don't wait a full interval
 	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("timeout waiting for workflow execution to complete")
-		case <-ticker.C:
-			// Get execution status
-			req := &executionspb.GetExecutionRequest{
-				Name: executionName,
-			}
+		// Get execution status
+		req := &executionspb.GetExecutionRequest{
+			Name: executionName,
+		}
 
-			execution, err := client.GetExecution(ctx, req)
-			if err != nil {
-				return fmt.Errorf("failed to get execution status: %w", err)
-			}
+		start := time.Now()
+		execution, err := client.GetExecution(ctx, req)
+		clientMetrics.record(ctx, "GetExecution", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get execution status: %w", err)
+		}
 
-			log.Printf("Execution state: %s", execution.State.String())
+		log.Printf("Execution state: %s", execution.State.String())
 
-			switch execution.State {
-			case executionspb.Execution_SUCCEEDED:
-				log.Println("Workflow execution completed successfully")
-				if execution.Result != "" {
-					log.Printf("Execution result: %s", execution.Result)
-				}
-				return nil
-			case executionspb.Execution_FAILED:
-				log.Printf("Workflow execution failed: %s", execution.Error.GetPayload())
-				return fmt.Errorf("workflow execution failed: %s", execution.Error.GetPayload())
-			case executionspb.Execution_CANCELLED:
-				return fmt.Errorf("workflow execution was cancelled")
-			case executionspb.Execution_ACTIVE:
-				// Continue polling
-				continue
-			default:
-				log.Printf("Unknown execution state: %s", execution.State.String())
-				continue
+		switch execution.State {
+		case executionspb.Execution_SUCCEEDED:
+			log.Println("Workflow execution completed successfully")
+			if execution.Result != "" {
+				log.Printf("Execution result: %s", execution.Result)
 			}
+			return execution, nil
+		case executionspb.Execution_FAILED:
+			log.Printf("Workflow execution failed: %s", execution.Error.GetPayload())
+			return execution, fmt.Errorf("workflow execution failed: %s", execution.Error.GetPayload())
+		case executionspb.Execution_CANCELLED:
+			return execution, fmt.Errorf("workflow execution was cancelled")
+		case executionspb.Execution_ACTIVE:
+			// Continue polling
+		default:
+			log.Printf("Unknown execution state: %s", execution.State.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil, fmt.Errorf("cancelled while waiting for workflow execution to complete: %w", ctx.Err())
+			}
+			return nil, fmt.Errorf("timeout waiting for workflow execution to complete")
+		case <-ticker.C:
 		}
 	}
 }
@@ -0,0 +1,643 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"github.com/googleapis/gax-go/v2"
+)
+
+// captureLog redirects the standard logger for the duration of fn
+func captureLog(t *testing.T, fn func()) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(original)
+
+	fn()
+	return buf.String()
+}
+
+const testAlertJSON = `{
+	"status": "firing",
+	"labels": {
+		"alertname": "HighCPUUsage",
+		"workflow": "cpu-alert-handler",
+		"k8s.io/component": "ingress-handler",
+		"k8s.io": "should-not-match"
+	},
+	"annotations": {"workflow_name": "cpu-incident-response"},
+	"metadata": {
+		"routing": {"team": {"workflow": "team-workflow"}},
+		"replicas": 3,
+		"threshold": 1000000000000000000000,
+		"paused": false,
+		"targets": ["a", "b"]
+	}
+}`
+
+func parseTestAlert(t *testing.T, alertJSON string) *AlertData {
+	t.Helper()
+	t.Setenv("ALERT_JSON", alertJSON)
+
+	alert, err := parseAlertData()
+	if err != nil {
+		t.Fatalf("parseAlertData() unexpected error: %v", err)
+	}
+	return alert
+}
+
+func TestExtractFieldFromAlert(t *testing.T) {
+	alert := parseTestAlert(t, testAlertJSON)
+
+	tests := []struct {
+		name      string
+		fieldPath string
+		want      string
+	}{
+		{name: "top-level field", fieldPath: "status", want: "firing"},
+		{name: "label", fieldPath: "labels.workflow", want: "cpu-alert-handler"},
+		{name: "annotation", fieldPath: "annotations.workflow_name", want: "cpu-incident-response"},
+		{name: "label key containing dots", fieldPath: "labels.k8s.io/component", want: "ingress-handler"},
+		{name: "label key that is a dotted prefix", fieldPath: "labels.k8s.io", want: "should-not-match"},
+		{name: "nested more than two levels", fieldPath: "metadata.routing.team.workflow", want: "team-workflow"},
+		{name: "integer value", fieldPath: "metadata.replicas", want: "3"},
+		{name: "large numeric value", fieldPath: "metadata.threshold", want: "1000000000000000000000"},
+		{name: "boolean value", fieldPath: "metadata.paused", want: "false"},
+		{name: "map value", fieldPath: "metadata.routing", want: ""},
+		{name: "array value", fieldPath: "metadata.targets", want: ""},
+		{name: "missing label", fieldPath: "labels.missing", want: ""},
+		{name: "path through scalar", fieldPath: "status.value", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractFieldFromAlert(alert, tt.fieldPath); got != tt.want {
+				t.Errorf("extractFieldFromAlert(%q) = %q, want %q", tt.fieldPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeWorkflowName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "mixed case is preserved", input: "HandlePagerDutyEscalation", want: "HandlePagerDutyEscalation"},
+		{name: "spaces and dots become hyphens", input: "Alert Handler.v2", want: "Alert-Handler-v2"},
+		{name: "invalid characters removed", input: "High CPU Alert!", want: "High-CPU-Alert"},
+		{name: "starts with a digit", input: "123-critical", want: "_123-critical"},
+		{name: "starts with a hyphen", input: " restart", want: "_-restart"},
+		{name: "empty after sanitization", input: "!!!", want: ""},
+		{name: "truncated to 63 characters", input: strings.Repeat("A", 70), want: strings.Repeat("A", 63)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeWorkflowName(tt.input); got != tt.want {
+				t.Errorf("sanitizeWorkflowName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWorkflowNameDefault(t *testing.T) {
+	alert := &AlertData{Raw: map[string]interface{}{
+		"annotations": map[string]interface{}{"routing": map[string]interface{}{"workflow": "disk cleanup"}},
+	}}
+
+	tests := []struct {
+		name         string
+		field        string
+		defaultName  string
+		want         string
+		wantErr      bool
+		wantFallback bool
+	}{
+		{name: "field found", field: "annotations.routing.workflow", defaultName: "catch-all", want: "disk-cleanup"},
+		{name: "missing field uses the default", field: "annotations.routing.team", defaultName: "Catch All", want: "Catch-All", wantFallback: true},
+		{name: "missing field without default", field: "annotations.routing.team", wantErr: true},
+		{name: "default invalid after sanitization", field: "annotations.routing.team", defaultName: "!!!", wantErr: true, wantFallback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{WorkflowNameField: tt.field, WorkflowNameDefault: tt.defaultName}
+
+			var got string
+			var err error
+			output := captureLog(t, func() { got, err = resolveWorkflowName(config, alert) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveWorkflowName() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveWorkflowName() = %q, want %q", got, tt.want)
+			}
+			if fallback := strings.Contains(output, "using WORKFLOW_NAME_DEFAULT"); fallback != tt.wantFallback {
+				t.Errorf("fallback logged = %t, want %t", fallback, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestRouteWorkflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		status  string
+		want    Config
+		wantErr string
+	}{
+		{name: "generic workflow", config: Config{WorkflowName: "remediate"}, status: "resolved", want: Config{WorkflowName: "remediate"}},
+		{
+			name:   "resolved override replaces the name field",
+			config: Config{WorkflowNameField: "labels.workflow", WorkflowNameResolved: "notify-resolved"},
+			status: "resolved",
+			want:   Config{WorkflowName: "notify-resolved", WorkflowNameResolved: "notify-resolved"},
+		},
+		{
+			name:   "firing override replaces the fan-out",
+			config: Config{WorkflowNames: []string{"a", "b"}, WorkflowNameFiring: "page"},
+			status: "firing",
+			want:   Config{WorkflowName: "page", WorkflowNameFiring: "page"},
+		},
+		{name: "override for the other status", config: Config{WorkflowNameFiring: "page"}, status: "resolved", wantErr: "WORKFLOW_NAME_RESOLVED or WORKFLOW_NAME"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			var err error
+			captureLog(t, func() { err = routeWorkflow(&config, tt.status) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("routeWorkflow() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("routeWorkflow() unexpected error: %v", err)
+			}
+			if config.WorkflowName != tt.want.WorkflowName || config.WorkflowNameField != tt.want.WorkflowNameField || len(config.WorkflowNames) != len(tt.want.WorkflowNames) {
+				t.Errorf("routed config = %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupPathBacktracks(t *testing.T) {
+	// "a.b" exists as a literal key but does not contain "c", so the lookup
+	// must fall back to walking "a" -> "b" -> "c"
+	node := map[string]interface{}{
+		"a.b": map[string]interface{}{"other": "x"},
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{"c": "found"},
+		},
+	}
+
+	if got := lookupPath(node, []string{"a", "b", "c"}); got != "found" {
+		t.Errorf("lookupPath() = %v, want %q", got, "found")
+	}
+}
+
+func TestParseAlertDataErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		alertJSON string
+	}{
+		{name: "syntax error", alertJSON: `{"status":`},
+		{name: "top-level array", alertJSON: `[{"status":"firing"}]`},
+		{name: "top-level scalar", alertJSON: `"firing"`},
+		{name: "wrong field type", alertJSON: `{"labels":{"replicas":3}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALERT_JSON", tt.alertJSON)
+			if _, err := parseAlertData(); err == nil {
+				t.Errorf("parseAlertData(%s) expected error", tt.alertJSON)
+			}
+		})
+	}
+}
+
+func TestLogResolvedConfig(t *testing.T) {
+	config := &Config{
+		ProjectID:          "my-project",
+		Location:           "europe-west1",
+		WorkflowNameField:  "labels.workflow",
+		ServiceAccountPath: "/etc/gcp/service-account.json",
+		TimeoutSeconds:     300,
+		Source:             "karo",
+		WaitForCompletion:  true,
+		LogConfig:          true,
+	}
+
+	output := captureLog(t, func() { logResolvedConfig(config) })
+
+	for _, want := range []string{
+		`"GCP_PROJECT_ID":"my-project"`,
+		`"GCP_LOCATION":"europe-west1"`,
+		`"WORKFLOW_NAME_FIELD":"labels.workflow"`,
+		`"GOOGLE_APPLICATION_CREDENTIALS":"***"`,
+		`"TIMEOUT_SECONDS":300`,
+		`"WAIT_FOR_COMPLETION":true`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("logged config missing %s, got: %s", want, output)
+		}
+	}
+	if strings.Contains(output, "service-account.json") {
+		t.Errorf("logged config leaked credentials path: %s", output)
+	}
+	if config.ServiceAccountPath != "/etc/gcp/service-account.json" {
+		t.Errorf("logResolvedConfig modified the config: %+v", config)
+	}
+}
+
+func TestLoadConfigInvalidLogConfig(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "test-project")
+	t.Setenv("WORKFLOW_NAME", "test-workflow")
+	t.Setenv("LOG_CONFIG", "yes")
+
+	var config *Config
+	var err error
+	output := captureLog(t, func() { config, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+	if config.LogConfig {
+		t.Error("LogConfig should stay disabled for an invalid value")
+	}
+	if !strings.Contains(output, "Invalid LOG_CONFIG value 'yes'") {
+		t.Errorf("expected a warning for the invalid value, got: %s", output)
+	}
+}
+
+// fakeExecutions returns the given states in order, repeating the last one
+type fakeExecutions struct {
+	states []executionspb.Execution_State
+	calls  int
+}
+
+func (f *fakeExecutions) GetExecution(ctx context.Context, req *executionspb.GetExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error) {
+	state := f.states[len(f.states)-1]
+	if f.calls < len(f.states) {
+		state = f.states[f.calls]
+	}
+	f.calls++
+	return &executionspb.Execution{
+		Name:  req.Name,
+		State: state,
+		Error: &executionspb.Execution_Error{Payload: "boom"},
+	}, nil
+}
+
+func TestWaitForExecution(t *testing.T) {
+	active := executionspb.Execution_ACTIVE
+	succeeded := executionspb.Execution_SUCCEEDED
+	failed := executionspb.Execution_FAILED
+
+	tests := []struct {
+		name      string
+		states    []executionspb.Execution_State
+		interval  time.Duration
+		timeout   time.Duration
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "immediate success without waiting an interval", states: []executionspb.Execution_State{succeeded}, interval: time.Hour, timeout: time.Second, wantCalls: 1},
+		{name: "polls until success", states: []executionspb.Execution_State{active, active, succeeded}, interval: time.Millisecond, timeout: time.Second, wantCalls: 3},
+		{name: "failure", states: []executionspb.Execution_State{active, failed}, interval: time.Millisecond, timeout: time.Second, wantCalls: 2, wantErr: true},
+		{name: "timeout", states: []executionspb.Execution_State{active}, interval: time.Hour, timeout: 20 * time.Millisecond, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			client := &fakeExecutions{states: tt.states}
+			var err error
+			captureLog(t, func() { _, err = waitForExecution(ctx, client, "executions/1", tt.interval) })
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("waitForExecution() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("GetExecution called %d times, want %d", client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPollInterval(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 5},
+		{value: "1", want: 1},
+		{value: "30", want: 30},
+		{value: "0", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("GCP_PROJECT_ID", "test-project")
+			t.Setenv("WORKFLOW_NAME", "test-workflow")
+			t.Setenv("POLL_INTERVAL_SECONDS", tt.value)
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && config.PollIntervalSeconds != tt.want {
+				t.Errorf("PollIntervalSeconds = %d, want %d", config.PollIntervalSeconds, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteExecutionOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		execution *executionspb.Execution
+		want      string
+	}{
+		{
+			name: "succeeded with JSON result",
+			execution: &executionspb.Execution{
+				Name:   "projects/p/locations/l/workflows/w/executions/1",
+				State:  executionspb.Execution_SUCCEEDED,
+				Result: `{"ticket":"INC-42"}`,
+			},
+			want: `{"name":"projects/p/locations/l/workflows/w/executions/1","state":"SUCCEEDED","result":{"ticket":"INC-42"}}`,
+		},
+		{
+			name: "succeeded with non-JSON result",
+			execution: &executionspb.Execution{
+				Name:   "executions/2",
+				State:  executionspb.Execution_SUCCEEDED,
+				Result: "done",
+			},
+			want: `{"name":"executions/2","state":"SUCCEEDED","result":"done"}`,
+		},
+		{
+			name: "failed",
+			execution: &executionspb.Execution{
+				Name:  "executions/3",
+				State: executionspb.Execution_FAILED,
+				Error: &executionspb.Execution_Error{Payload: `{"message":"boom"}`},
+			},
+			want: `{"name":"executions/3","state":"FAILED","error":"{\"message\":\"boom\"}"}`,
+		},
+		{
+			name:      "started without waiting",
+			execution: &executionspb.Execution{Name: "executions/4", State: executionspb.Execution_ACTIVE},
+			want:      `{"name":"executions/4","state":"ACTIVE"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out", "result.json")
+
+			var err error
+			captureLog(t, func() { err = writeExecutionOutput(path, tt.execution) })
+			if err != nil {
+				t.Fatalf("writeExecutionOutput() unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read output file: %v", err)
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, content); err != nil {
+				t.Fatalf("output is not valid JSON: %v\n%s", err, content)
+			}
+			if compact.String() != tt.want {
+				t.Errorf("output = %s, want %s", compact.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSignalContextCancelsOnSIGTERM(t *testing.T) {
+	ctx, stop := newSignalContext()
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not cancelled by SIGTERM")
+	}
+}
+
+func TestWaitForExecutionCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := &fakeExecutions{states: []executionspb.Execution_State{executionspb.Execution_ACTIVE}}
+	var err error
+	captureLog(t, func() { _, err = waitForExecution(ctx, client, "executions/1", time.Hour) })
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("waitForExecution() error = %v, want a cancellation error", err)
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		wantOpts int
+	}{
+		{name: "application default credentials", config: Config{}, wantOpts: 0},
+		{name: "credentials file", config: Config{ServiceAccountPath: "/etc/gcp/key.json"}, wantOpts: 1},
+		{name: "credentials file and endpoint", config: Config{ServiceAccountPath: "/etc/gcp/key.json", Endpoint: "workflowexecutions.europe-west1.rep.googleapis.com:443"}, wantOpts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(clientOptions(&tt.config)); got != tt.wantOpts {
+				t.Errorf("clientOptions() returned %d options, want %d", got, tt.wantOpts)
+			}
+		})
+	}
+}
+
+func TestExecuteWorkflowWithFakeClient(t *testing.T) {
+	client := &fakeFanout{final: map[string]*executionspb.Execution{
+		"restart-pod": {State: executionspb.Execution_SUCCEEDED, Result: `{"restarted":true}`},
+	}}
+	config := &Config{
+		ProjectID:           "my-project",
+		Location:            "us-central1",
+		TimeoutSeconds:      10,
+		PollIntervalSeconds: 1,
+		RetryMaxAttempts:    1,
+		WaitForCompletion:   true,
+		OutputFile:          filepath.Join(t.TempDir(), "output.json"),
+	}
+
+	input := &WorkflowInput{AlertName: "PodCrash"}
+	var err error
+	captureLog(t, func() { err = executeWorkflow(context.Background(), config, client, "restart-pod", input) })
+	if err != nil {
+		t.Fatalf("executeWorkflow() unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(config.OutputFile)
+	if err != nil {
+		t.Fatalf("failed to read OUTPUT_FILE: %v", err)
+	}
+	var output ExecutionOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatalf("OUTPUT_FILE is not valid JSON: %v", err)
+	}
+	var result bytes.Buffer
+	json.Compact(&result, output.Result)
+	wantName := "projects/my-project/locations/us-central1/workflows/restart-pod/executions/1"
+	if output.Name != wantName || output.State != "SUCCEEDED" || result.String() != `{"restarted":true}` {
+		t.Errorf("output = %+v, want the succeeded execution %s", output, wantName)
+	}
+}
+
+func TestResolveIdempotencyKey(t *testing.T) {
+	alert := &AlertData{Raw: map[string]interface{}{"labels": map[string]interface{}{"incident": "INC-1"}}}
+	input := &WorkflowInput{AlertName: "PodCrash", Labels: map[string]string{"pod": "api-0"}, StartsAt: "2024-01-01T12:00:00Z", Status: "firing"}
+
+	tests := []struct {
+		name  string
+		field string
+		alert *AlertData
+		want  string
+	}{
+		{name: "alert name, labels, start time and status", alert: alert, want: idempotencyKey("", "", "PodCrash", map[string]string{"pod": "api-0"}, "2024-01-01T12:00:00Z", "firing")},
+		{name: "alert field", field: "labels.incident", alert: alert, want: idempotencyKey("labels.incident", "INC-1", "", nil, "", "")},
+		{name: "environment fallback", field: "labels.incident", want: idempotencyKey("labels.incident", "INC-2", "", nil, "", "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LABELS_INCIDENT", "INC-2")
+			config := &Config{IdempotencyKeyField: tt.field}
+			if got := resolveIdempotencyKey(config, tt.alert, input); got != tt.want {
+				t.Errorf("resolveIdempotencyKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	request, err := buildExecutionRequest(&Config{ProjectID: "my-project"}, "us-central1", "restart-pod", &WorkflowInput{IdempotencyKey: "abc123"})
+	if err != nil {
+		t.Fatalf("buildExecutionRequest() unexpected error: %v", err)
+	}
+	if got := request.Execution.Labels["idempotency-key"]; got != "abc123" {
+		t.Errorf("idempotency-key label = %q, want abc123", got)
+	}
+}
+
+func TestExecutionLabels(t *testing.T) {
+	input := &WorkflowInput{
+		AlertName:      "HighCPU",
+		Severity:       "critical",
+		Source:         "karo/gcp-workflows",
+		Fingerprint:    "4c3b2a1f0e9d8c7b",
+		IdempotencyKey: "abc123",
+	}
+	request, err := buildExecutionRequest(&Config{ProjectID: "my-project"}, "us-central1", "restart-pod", input)
+	if err != nil {
+		t.Fatalf("buildExecutionRequest() unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"alertname":       "highcpu",
+		"severity":        "critical",
+		"source":          "karo_gcp-workflows",
+		"fingerprint":     "4c3b2a1f0e9d8c7b",
+		"idempotency-key": "abc123",
+	}
+	if !maps.Equal(request.Execution.Labels, want) {
+		t.Errorf("labels = %v, want %v", request.Execution.Labels, want)
+	}
+
+	if labels := executionLabels(&WorkflowInput{}); labels != nil {
+		t.Errorf("executionLabels() = %v, want nil without alert metadata", labels)
+	}
+}
+
+func TestSanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "lowercased", input: "KubePodCrashLooping", want: "kubepodcrashlooping"},
+		{name: "invalid characters replaced", input: "Disk Full: /var", want: "disk_full___var"},
+		{name: "hyphens and underscores kept", input: "node_exporter-down", want: "node_exporter-down"},
+		{name: "truncated to 63 characters", input: strings.Repeat("a", 70), want: strings.Repeat("a", 63)},
+		{name: "empty", input: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeLabelValue(tt.input); got != tt.want {
+				t.Errorf("sanitizeLabelValue(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildExecutionRequestMaxPayloadBytes(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "my-project")
+	t.Setenv("WORKFLOW_NAME", "restart-pod")
+	t.Setenv("MAX_PAYLOAD_BYTES", "")
+	var config *Config
+	var err error
+	captureLog(t, func() { config, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+	if config.MaxPayloadBytes != maxArgumentBytes || config.OnOversize != onOversizeFail {
+		t.Fatalf("limit, mode = %d, %q, want the Workflows maximum %d and fail", config.MaxPayloadBytes, config.OnOversize, maxArgumentBytes)
+	}
+
+	input := &WorkflowInput{AlertName: "DiskFull", Annotations: map[string]string{"description": strings.Repeat("x", maxArgumentBytes)}}
+	_, err = buildExecutionRequest(config, "us-central1", "restart-pod", input)
+	if err == nil || !strings.Contains(err.Error(), "exceeds limit 32768") {
+		t.Errorf("buildExecutionRequest() error = %v, want the argument rejected", err)
+	}
+
+	config.OnOversize = onOversizeTruncate
+	request, err := buildExecutionRequest(config, "us-central1", "restart-pod", input)
+	if err != nil {
+		t.Fatalf("buildExecutionRequest() unexpected error: %v", err)
+	}
+	if len(request.Execution.Argument) > maxArgumentBytes || !input.Truncated {
+		t.Errorf("argument is %d bytes, truncated %t, want at most %d and truncated", len(request.Execution.Argument), input.Truncated, maxArgumentBytes)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clientMetrics records GCP client calls. It is nil unless METRICS_ENABLED
+// is set, and all methods are no-ops on a nil receiver.
+var clientMetrics *gcpMetrics
+
+// gcpMetrics holds per-call duration and count instruments for GCP API
+// calls, labeled by method and gRPC status code
+type gcpMetrics struct {
+	reader   *sdkmetric.ManualReader
+	duration metric.Float64Histogram
+	calls    metric.Int64Counter
+}
+
+// newGCPMetrics creates the instruments on a meter provider backed by reader
+func newGCPMetrics(reader *sdkmetric.ManualReader) (*gcpMetrics, error) {
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("karo-reactions/gcp-workflows")
+
+	duration, err := meter.Float64Histogram("gcp.client.call.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of GCP API calls"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create duration histogram: %w", err)
+	}
+
+	calls, err := meter.Int64Counter("gcp.client.calls",
+		metric.WithDescription("Number of GCP API calls"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create call counter: %w", err)
+	}
+
+	return &gcpMetrics{reader: reader, duration: duration, calls: calls}, nil
+}
+
+// record observes a finished call that started at start
+func (m *gcpMetrics) record(ctx context.Context, method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	attrs := metric.WithAttributes(
+		attribute.String("method", method),
+		attribute.String("grpc_code", grpcCode(err).String()),
+	)
+	m.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	m.calls.Add(ctx, 1, attrs)
+}
+
+// flush collects the recorded metrics and writes them to the log
+func (m *gcpMetrics) flush(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	var data metricdata.ResourceMetrics
+	if err := m.reader.Collect(ctx, &data); err != nil {
+		log.Printf("Warning: Failed to collect metrics: %v", err)
+		return
+	}
+
+	for _, scope := range data.ScopeMetrics {
+		for _, recorded := range scope.Metrics {
+			switch points := recorded.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for _, point := range points.DataPoints {
+					log.Printf("Metric %s{%s} count=%d sum=%.3f%s", recorded.Name, formatAttributes(point.Attributes),
+						point.Count, point.Sum, recorded.Unit)
+				}
+			case metricdata.Sum[int64]:
+				for _, point := range points.DataPoints {
+					log.Printf("Metric %s{%s} value=%d", recorded.Name, formatAttributes(point.Attributes), point.Value)
+				}
+			}
+		}
+	}
+}
+
+// grpcCode maps a call error to its gRPC status code, treating context
+// errors as DeadlineExceeded/Canceled
+func grpcCode(err error) codes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return status.FromContextError(err).Code()
+}
+
+func formatAttributes(set attribute.Set) string {
+	var parts []string
+	for _, kv := range set.ToSlice() {
+		parts = append(parts, fmt.Sprintf("%s=%s", kv.Key, kv.Value.Emit()))
+	}
+	return strings.Join(parts, ",")
+}
+
+// setupMetrics enables clientMetrics when METRICS_ENABLED is set
+func setupMetrics(enabled bool) {
+	if !enabled {
+		return
+	}
+
+	m, err := newGCPMetrics(sdkmetric.NewManualReader())
+	if err != nil {
+		log.Printf("Warning: Failed to set up metrics, continuing without them: %v", err)
+		return
+	}
+	clientMetrics = m
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// useTestMetrics installs clientMetrics backed by an in-memory reader for
+// the duration of the test
+func useTestMetrics(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	m, err := newGCPMetrics(reader)
+	if err != nil {
+		t.Fatalf("newGCPMetrics() unexpected error: %v", err)
+	}
+	clientMetrics = m
+	t.Cleanup(func() { clientMetrics = nil })
+	return reader
+}
+
+// callCounts returns the gcp.client.calls value keyed by "method/grpc_code"
+func callCounts(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("Collect() unexpected error: %v", err)
+	}
+
+	counts := map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, recorded := range scope.Metrics {
+			if recorded.Name != "gcp.client.calls" {
+				continue
+			}
+			for _, point := range recorded.Data.(metricdata.Sum[int64]).DataPoints {
+				method, _ := point.Attributes.Value(attribute.Key("method"))
+				code, _ := point.Attributes.Value(attribute.Key("grpc_code"))
+				counts[method.AsString()+"/"+code.AsString()] += point.Value
+			}
+		}
+	}
+	return counts
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "success", err: nil, want: codes.OK},
+		{name: "status error", err: status.Error(codes.ResourceExhausted, "quota"), want: codes.ResourceExhausted},
+		{name: "deadline", err: context.DeadlineExceeded, want: codes.DeadlineExceeded},
+		{name: "canceled", err: context.Canceled, want: codes.Canceled},
+		{name: "plain error", err: errors.New("boom"), want: codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grpcCode(tt.err); got != tt.want {
+				t.Errorf("grpcCode(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsRecordCall(t *testing.T) {
+	reader := useTestMetrics(t)
+	ctx := context.Background()
+
+	clientMetrics.record(ctx, "CreateExecution", time.Now(), nil)
+	clientMetrics.record(ctx, "CreateExecution", time.Now(), status.Error(codes.ResourceExhausted, "quota exceeded"))
+	clientMetrics.record(ctx, "CreateExecution", time.Now(), status.Error(codes.ResourceExhausted, "quota exceeded"))
+
+	counts := callCounts(t, reader)
+	if counts["CreateExecution/OK"] != 1 {
+		t.Errorf("CreateExecution/OK = %d, want 1 (all: %v)", counts["CreateExecution/OK"], counts)
+	}
+	if counts["CreateExecution/ResourceExhausted"] != 2 {
+		t.Errorf("CreateExecution/ResourceExhausted = %d, want 2 (all: %v)", counts["CreateExecution/ResourceExhausted"], counts)
+	}
+}
+
+func TestMetricsDisabledIsNoop(t *testing.T) {
+	var m *gcpMetrics
+	m.record(context.Background(), "CreateExecution", time.Now(), nil)
+
+	output := captureLog(t, func() { m.flush(context.Background()) })
+	if output != "" {
+		t.Errorf("disabled metrics should not log, got: %s", output)
+	}
+}
+
+func TestMetricsFlushLogsCalls(t *testing.T) {
+	useTestMetrics(t)
+	ctx := context.Background()
+
+	clientMetrics.record(ctx, "CreateExecution", time.Now(), nil)
+	clientMetrics.record(ctx, "GetExecution", time.Now(), status.Error(codes.Unavailable, "connection reset"))
+
+	output := captureLog(t, func() { clientMetrics.flush(ctx) })
+	for _, want := range []string{
+		"gcp.client.call.duration{grpc_code=OK,method=CreateExecution} count=1",
+		"gcp.client.calls{grpc_code=Unavailable,method=GetExecution} value=1",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("flush output missing %q, got: %s", want, output)
+		}
+	}
+}
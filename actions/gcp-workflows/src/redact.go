@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// redactedValue replaces the values of REDACT_FIELDS keys in log output
+const redactedValue = "***"
+
+// payloadForLog returns a payload as it should appear in the logs. The values
+// of REDACT_FIELDS keys are masked at any depth, which covers the labels and
+// annotations maps whatever the payload layout. A payload that is not JSON
+// cannot be redacted, so only its size is logged.
+func payloadForLog(data []byte, fields []string) string {
+	if len(fields) == 0 {
+		return string(data)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON so REDACT_FIELDS cannot be applied>", len(data))
+	}
+
+	redact := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		redact[field] = true
+	}
+	redactValue(value, redact)
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("<%d bytes, redaction failed>", len(data))
+	}
+	return string(redacted)
+}
+
+// redactValue masks, in place, the values of the given keys in every JSON
+// object within value
+func redactValue(value interface{}, redact map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if redact[key] {
+				v[key] = redactedValue
+				continue
+			}
+			redactValue(child, redact)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactValue(child, redact)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestPayloadForLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		fields []string
+		want   string
+	}{
+		{
+			name: "no fields logs the payload as-is",
+			data: `{"labels":{"token":"s3cret"}}`,
+			want: `{"labels":{"token":"s3cret"}}`,
+		},
+		{
+			name:   "labels and annotations are masked",
+			data:   `{"alertName":"DiskFull","labels":{"token":"s3cret","severity":"critical"},"annotations":{"password":"hunter2"}}`,
+			fields: []string{"token", "password"},
+			want:   `{"alertName":"DiskFull","annotations":{"password":"***"},"labels":{"severity":"critical","token":"***"}}`,
+		},
+		{
+			name:   "nested in arrays",
+			data:   `{"alerts":[{"labels":{"token":"a"}},{"labels":{"token":"b"}}]}`,
+			fields: []string{"token"},
+			want:   `{"alerts":[{"labels":{"token":"***"}},{"labels":{"token":"***"}}]}`,
+		},
+		{
+			name:   "numbers are preserved",
+			data:   `{"value":12345678901234567890,"token":1}`,
+			fields: []string{"token"},
+			want:   `{"token":"***","value":12345678901234567890}`,
+		},
+		{
+			name:   "non-JSON payload is not logged",
+			data:   `alert DiskFull token=s3cret`,
+			fields: []string{"token"},
+			want:   `<27 bytes, not JSON so REDACT_FIELDS cannot be applied>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := payloadForLog([]byte(tt.data), tt.fields); got != tt.want {
+				t.Errorf("payloadForLog() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
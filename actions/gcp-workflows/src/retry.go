@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	executionspb "cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultRetryMaxAttempts is the default RETRY_MAX_ATTEMPTS, counting the
+// first CreateExecution attempt
+const defaultRetryMaxAttempts = 3
+
+// Backoff between CreateExecution attempts, doubling from the initial delay up to
+// the maximum. Variables so tests can shorten them.
+var (
+	retryInitialBackoff = 500 * time.Millisecond
+	retryMaxBackoff     = 10 * time.Second
+)
+
+// isRetryableError reports whether a CreateExecution error is transient and may
+// succeed on another attempt. Errors about the request itself, such as
+// NotFound or PermissionDenied, fail the same way every time. Only
+// Unavailable and ResourceExhausted mean the request was not accepted; after
+// DeadlineExceeded or Internal the execution may have been created, and the
+// API takes no execution ID to make another attempt idempotent.
+func isRetryableError(ctx context.Context, err error) bool {
+	// The run's own deadline or cancellation leaves no time to retry
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch grpcCode(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryBackoff returns the delay before the given retry, counting from 1
+func retryBackoff(retry int) time.Duration {
+	backoff := retryInitialBackoff
+	for i := 1; i < retry && backoff < retryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > retryMaxBackoff {
+		return retryMaxBackoff
+	}
+	return backoff
+}
+
+// sleepContext waits for d, returning early with the context's error when it
+// is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// createWithRetry calls CreateExecution, retrying transient errors with
+// backoff up to RETRY_MAX_ATTEMPTS. Only calls that returned an error are
+// retried, so an execution that was created is never created again.
+func createWithRetry(ctx context.Context, client executionCreator, config *Config, req *executionspb.CreateExecutionRequest) (*executionspb.Execution, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		execution, err := client.CreateExecution(ctx, req)
+		clientMetrics.record(ctx, "CreateExecution", start, err)
+		if err == nil || attempt >= config.RetryMaxAttempts || !isRetryableError(ctx, err) {
+			return execution, err
+		}
+
+		backoff := retryBackoff(attempt)
+		log.Printf("Warning: Retrying CreateExecution in %s after attempt %d of %d: %v", backoff, attempt, config.RetryMaxAttempts, err)
+		if sleepContext(ctx, backoff) != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	executionspb "cloud.google.com/go/workflows/executions/apiv1/executionspb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "unavailable", ctx: context.Background(), err: status.Error(codes.Unavailable, "maintenance"), want: true},
+		{name: "deadline exceeded", ctx: context.Background(), err: status.Error(codes.DeadlineExceeded, "slow"), want: false},
+		{name: "internal", ctx: context.Background(), err: status.Error(codes.Internal, "oops"), want: false},
+		{name: "resource exhausted", ctx: context.Background(), err: status.Error(codes.ResourceExhausted, "quota"), want: true},
+		{name: "not found", ctx: context.Background(), err: status.Error(codes.NotFound, "workflow not found"), want: false},
+		{name: "invalid argument", ctx: context.Background(), err: status.Error(codes.InvalidArgument, "bad input"), want: false},
+		{name: "unknown", ctx: context.Background(), err: errors.New("boom"), want: false},
+		{name: "run cancelled", ctx: cancelled, err: status.Error(codes.Unavailable, "maintenance"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("isRetryableError(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// flakyCreator fails the first len(errs) CreateExecution calls with errs in
+// order, then creates the execution
+type flakyCreator struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyCreator) CreateExecution(ctx context.Context, req *executionspb.CreateExecutionRequest, opts ...gax.CallOption) (*executionspb.Execution, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return &executionspb.Execution{Name: req.Parent + "/executions/1"}, nil
+}
+
+func TestCreateExecutionRetries(t *testing.T) {
+	initial := retryInitialBackoff
+	retryInitialBackoff = time.Millisecond
+	t.Cleanup(func() { retryInitialBackoff = initial })
+
+	unavailable := status.Error(codes.Unavailable, "maintenance")
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		errs        []error
+		wantCalls   int
+		wantErr     bool
+	}{
+		{name: "created on the first attempt", maxAttempts: 3, wantCalls: 1},
+		{name: "transient errors are retried", maxAttempts: 3, errs: []error{unavailable, status.Error(codes.ResourceExhausted, "quota")}, wantCalls: 3},
+		{name: "attempts are bounded", maxAttempts: 3, errs: []error{unavailable, unavailable, unavailable, unavailable}, wantCalls: 3, wantErr: true},
+		{name: "retries disabled", maxAttempts: 1, errs: []error{unavailable}, wantCalls: 1, wantErr: true},
+		{name: "not found is not retried", maxAttempts: 3, errs: []error{status.Error(codes.NotFound, "workflow not found")}, wantCalls: 1, wantErr: true},
+		// The execution may have been created, so creating it again could run the workflow twice
+		{name: "deadline exceeded is not retried", maxAttempts: 3, errs: []error{status.Error(codes.DeadlineExceeded, "slow")}, wantCalls: 1, wantErr: true},
+		{name: "internal is not retried", maxAttempts: 3, errs: []error{status.Error(codes.Internal, "oops")}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ProjectID: "test-project", Location: "us-central1", RetryMaxAttempts: tt.maxAttempts}
+			client := &flakyCreator{errs: tt.errs}
+
+			var execution *executionspb.Execution
+			var err error
+			output := captureLog(t, func() {
+				execution, _, err = createExecution(context.Background(), client, config, "alert-handler", &WorkflowInput{})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createExecution() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("CreateExecution called %d times, want %d", client.calls, tt.wantCalls)
+			}
+			if !tt.wantErr && execution == nil {
+				t.Error("createExecution() returned no execution")
+			}
+			if retried := strings.Contains(output, "Retrying CreateExecution"); retried != (tt.wantCalls > 1) {
+				t.Errorf("retry logged = %t, want %t:\n%s", retried, tt.wantCalls > 1, output)
+			}
+		})
+	}
+}
+
+func TestCreateExecutionRetriesBeforeFailover(t *testing.T) {
+	initial := retryInitialBackoff
+	retryInitialBackoff = time.Millisecond
+	t.Cleanup(func() { retryInitialBackoff = initial })
+
+	config := &Config{ProjectID: "test-project", Location: "us-central1", FallbackLocations: []string{"europe-west1"}, RetryMaxAttempts: 2}
+	client := &fakeCreator{errs: map[string]error{"us-central1": status.Error(codes.Unavailable, "maintenance")}}
+
+	var location string
+	var err error
+	captureLog(t, func() {
+		_, location, err = createExecution(context.Background(), client, config, "alert-handler", &WorkflowInput{})
+	})
+	if err != nil {
+		t.Fatalf("createExecution() unexpected error: %v", err)
+	}
+	if location != "europe-west1" {
+		t.Errorf("location = %q, want europe-west1", location)
+	}
+	if got := strings.Join(client.tried, ","); got != "us-central1,us-central1,europe-west1" {
+		t.Errorf("tried %s, want the primary twice before failing over", got)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestStatusOverride(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{status: "firing", want: "alerts-firing"},
+		{status: "Resolved", want: "alerts-resolved"},
+		{status: "pending", want: ""},
+		{status: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := statusOverride("alerts-firing", "alerts-resolved", tt.status); got != tt.want {
+			t.Errorf("statusOverride(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestMissingRouteError(t *testing.T) {
+	tests := map[string]string{
+		"resolved": "TARGET_RESOLVED or TARGET is required for resolved alerts",
+		"FIRING":   "TARGET_FIRING or TARGET is required for firing alerts",
+		"pending":  "TARGET is required for alerts with status 'pending'",
+	}
+	for status, want := range tests {
+		if got := missingRouteError("TARGET", status).Error(); got != want {
+			t.Errorf("missingRouteError(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
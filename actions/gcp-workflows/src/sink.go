@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sinkFile writes messages to a local directory instead of Workflows
+const sinkFile = "file"
+
+// FileSinkRecord is the content written by the file sink for each alert.
+// It mirrors the CreateExecution request that would have been sent.
+type FileSinkRecord struct {
+	Workflow string          `json:"workflow"`
+	Argument json.RawMessage `json:"argument"`
+}
+
+// validateSink checks the SINK and SINK_DIR settings
+func validateSink(sink, dir string) error {
+	switch sink {
+	case "":
+		return nil
+	case sinkFile:
+		if dir == "" {
+			return fmt.Errorf("SINK_DIR is required when SINK=%s", sinkFile)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported SINK '%s', must be '%s' or unset", sink, sinkFile)
+	}
+}
+
+// writeFileSink writes the execution request that would have been sent to a
+// new file in the sink directory and returns its path
+func writeFileSink(config *Config, workflowName string, input *WorkflowInput) (string, error) {
+	record, err := buildSinkRecord(config, workflowName, input)
+	if err != nil {
+		return "", err
+	}
+
+	return writeSinkFile(config.SinkDir, sinkFileName(input.AlertName, input.Status, time.Now()), record)
+}
+
+// buildSinkRecord builds the record of the execution request that would have
+// been sent, shared by the file sink and DRY_RUN
+func buildSinkRecord(config *Config, workflowName string, input *WorkflowInput) (FileSinkRecord, error) {
+	req, err := buildExecutionRequest(config, config.Location, workflowName, input)
+	if err != nil {
+		return FileSinkRecord{}, err
+	}
+
+	return FileSinkRecord{
+		Workflow: req.Parent,
+		Argument: json.RawMessage(req.Execution.Argument),
+	}, nil
+}
+
+// sinkFileName builds a file name that sorts chronologically and is safe on
+// any filesystem, e.g. 20240101T120000.000000000Z-HighCPU-firing.json
+func sinkFileName(alertName, status string, now time.Time) string {
+	return fmt.Sprintf("%s-%s-%s.json",
+		now.UTC().Format("20060102T150405.000000000Z"),
+		sanitizeFileComponent(alertName, "alert"),
+		sanitizeFileComponent(status, "unknown"))
+}
+
+// sanitizeFileComponent replaces characters that are not safe in file names
+func sanitizeFileComponent(value, fallback string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+
+	sanitized = strings.Trim(sanitized, "._")
+	if sanitized == "" {
+		return fallback
+	}
+	return sanitized
+}
+
+// writeSinkFile writes the record as indented JSON, refusing to overwrite an
+// existing file
+func writeSinkFile(dir, name string, record interface{}) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create sink directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal sink record: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sink file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write sink file: %w", err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "unset", sink: ""},
+		{name: "file with directory", sink: "file", dir: "/tmp/out"},
+		{name: "file without directory", sink: "file", wantErr: true},
+		{name: "unknown sink", sink: "s3", dir: "/tmp/out", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSink(tt.sink, tt.dir); (err != nil) != tt.wantErr {
+				t.Errorf("validateSink(%q, %q) error = %v, wantErr %t", tt.sink, tt.dir, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSinkFileName(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name      string
+		alertName string
+		status    string
+		want      string
+	}{
+		{name: "plain", alertName: "HighCPU", status: "firing", want: "20240102T020405.000000006Z-HighCPU-firing.json"},
+		{name: "unsafe characters", alertName: "../disk full/", status: "resolved", want: "20240102T020405.000000006Z-disk_full-resolved.json"},
+		{name: "empty values", want: "20240102T020405.000000006Z-alert-unknown.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sinkFileName(tt.alertName, tt.status, now); got != tt.want {
+				t.Errorf("sinkFileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sink")
+	config := &Config{
+		ProjectID: "test-project",
+		Location:  "us-central1",
+		SinkDir:   dir,
+	}
+	input := &WorkflowInput{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Severity:  "critical",
+		Labels:    map[string]string{"instance": "node-1"},
+		Source:    "karo",
+	}
+
+	path, err := writeFileSink(config, "disk-handler", input)
+	if err != nil {
+		t.Fatalf("writeFileSink() unexpected error: %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("file written to %s, want directory %s", path, dir)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read sink file: %v", err)
+	}
+
+	var record struct {
+		Workflow string        `json:"workflow"`
+		Argument WorkflowInput `json:"argument"`
+	}
+	if err := json.Unmarshal(content, &record); err != nil {
+		t.Fatalf("sink file is not valid JSON: %v\n%s", err, content)
+	}
+
+	if record.Workflow != "projects/test-project/locations/us-central1/workflows/disk-handler" {
+		t.Errorf("workflow = %q", record.Workflow)
+	}
+	if record.Argument.AlertName != "DiskFull" || record.Argument.Labels["instance"] != "node-1" {
+		t.Errorf("argument = %+v", record.Argument)
+	}
+
+	// A second alert gets its own file
+	second, err := writeFileSink(config, "disk-handler", input)
+	if err != nil {
+		t.Fatalf("writeFileSink() second call unexpected error: %v", err)
+	}
+	if second == path {
+		t.Errorf("second alert overwrote %s", path)
+	}
+}
+
+func TestWriteSinkFileRefusesOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := writeSinkFile(dir, "alert.json", map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("writeSinkFile() unexpected error: %v", err)
+	}
+	if _, err := writeSinkFile(dir, "alert.json", map[string]string{"a": "c"}); err == nil {
+		t.Error("writeSinkFile() should refuse to overwrite an existing file")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"testing"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// memoryLogExporter keeps exported log records in memory
+type memoryLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		e.records = append(e.records, record.Clone())
+	}
+	return nil
+}
+
+func (e *memoryLogExporter) Shutdown(ctx context.Context) error   { return nil }
+func (e *memoryLogExporter) ForceFlush(ctx context.Context) error { return nil }
+
+func TestSetupTelemetryWithoutOTel(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "")
+
+	ctx := context.Background()
+	got, err := setupTelemetry(ctx)
+	if err != nil {
+		t.Fatalf("setupTelemetry() unexpected error: %v", err)
+	}
+	if got != ctx {
+		t.Error("setupTelemetry() should return the context unchanged when OTel is not configured")
+	}
+	if trace.SpanContextFromContext(got).IsValid() {
+		t.Error("no span should be started when OTel is not configured")
+	}
+}
+
+func TestLogBridgeAttachesTraceContext(t *testing.T) {
+	var stderr bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&stderr)
+	defer log.SetOutput(original)
+
+	exporter := &memoryLogExporter{}
+	ctx := startTelemetry(context.Background(), sdklog.NewSimpleProcessor(exporter))
+	spanContext := trace.SpanContextFromContext(ctx)
+
+	log.Printf("Executing workflow %s", "incident-response")
+	log.Printf("Warning: Failed to parse alert data: %s", "boom")
+	shutdownTelemetry()
+
+	if !spanContext.IsValid() {
+		t.Fatal("startTelemetry() did not start a run span")
+	}
+	if len(exporter.records) != 2 {
+		t.Fatalf("exported %d log records, want 2", len(exporter.records))
+	}
+
+	for _, record := range exporter.records {
+		if record.TraceID() != spanContext.TraceID() || record.SpanID() != spanContext.SpanID() {
+			t.Errorf("record %q has trace %s span %s, want trace %s span %s", record.Body().AsString(),
+				record.TraceID(), record.SpanID(), spanContext.TraceID(), spanContext.SpanID())
+		}
+	}
+	if got := exporter.records[0].Body().AsString(); got != "Executing workflow incident-response" {
+		t.Errorf("record body = %q, want the message without the log timestamp", got)
+	}
+	if got := exporter.records[1].SeverityText(); got != "WARN" {
+		t.Errorf("warning severity = %q, want WARN", got)
+	}
+
+	// Logs still reach stderr, and the bridge is removed on shutdown
+	if !bytes.Contains(stderr.Bytes(), []byte("Executing workflow incident-response")) {
+		t.Errorf("log line missing from stderr: %s", stderr.String())
+	}
+	if log.Writer() != &stderr {
+		t.Error("shutdownTelemetry() did not restore the original log output")
+	}
+}
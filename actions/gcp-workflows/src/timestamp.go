@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// TIMESTAMP_SOURCE values
+const (
+	timestampStartsAt = "starts_at"
+	timestampEndsAt   = "ends_at"
+	timestampNow      = "now"
+)
+
+// parseTimestampSource validates TIMESTAMP_SOURCE. An empty value picks the
+// source from the alert status, see alertTimestamp.
+func parseTimestampSource(source string) (string, error) {
+	switch source {
+	case "", timestampStartsAt, timestampEndsAt, timestampNow:
+		return source, nil
+	default:
+		return "", fmt.Errorf("unsupported TIMESTAMP_SOURCE '%s', must be one of starts_at, ends_at, now", source)
+	}
+}
+
+// alertTimestamp returns the timestamp of the alert as RFC3339 in UTC, so it
+// can be correlated with the alert source. Without TIMESTAMP_SOURCE it uses
+// endsAt for resolved alerts and startsAt otherwise. It falls back to the
+// current time when the chosen field is empty, unset (Alertmanager sends
+// the zero time for alerts that haven't ended) or not RFC3339.
+func alertTimestamp(source, status, startsAt, endsAt string) string {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	if source == "" {
+		source = timestampStartsAt
+		if strings.EqualFold(status, "resolved") {
+			source = timestampEndsAt
+		}
+	}
+
+	value := startsAt
+	switch source {
+	case timestampNow:
+		return now
+	case timestampEndsAt:
+		value = endsAt
+	}
+	if value == "" {
+		return now
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		log.Printf("Warning: Alert %s '%s' is not an RFC3339 time, using the current time", source, value)
+		return now
+	}
+	if t.IsZero() {
+		return now
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ON_INVALID values
+const (
+	onInvalidFail = "fail"
+	onInvalidDrop = "drop"
+)
+
+// parseOnInvalid validates ON_INVALID, defaulting to fail
+func parseOnInvalid(mode string) (string, error) {
+	switch mode {
+	case "":
+		return onInvalidFail, nil
+	case onInvalidFail, onInvalidDrop:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported ON_INVALID '%s', must be '%s' or '%s'", mode, onInvalidFail, onInvalidDrop)
+	}
+}
+
+// parseValidationConfig reads ALLOWED_SEVERITIES, ALLOWED_STATUSES and
+// ON_INVALID. An unset list allows any value.
+func parseValidationConfig(config *Config) error {
+	config.AllowedSeverities = parseLabelList(os.Getenv("ALLOWED_SEVERITIES"))
+	config.AllowedStatuses = parseLabelList(os.Getenv("ALLOWED_STATUSES"))
+
+	mode, err := parseOnInvalid(os.Getenv("ON_INVALID"))
+	if err != nil {
+		return err
+	}
+	config.OnInvalid = mode
+	return nil
+}
+
+// validateAlert checks the alert's severity and status against the allowed
+// sets. Values are compared exactly, so a typo such as "critcal" or a
+// different case is rejected.
+func validateAlert(config *Config, severity, status string) error {
+	if len(config.AllowedSeverities) > 0 && !contains(config.AllowedSeverities, severity) {
+		return fmt.Errorf("severity '%s' is not in ALLOWED_SEVERITIES (%s)", severity, strings.Join(config.AllowedSeverities, ", "))
+	}
+	if len(config.AllowedStatuses) > 0 && !contains(config.AllowedStatuses, status) {
+		return fmt.Errorf("status '%s' is not in ALLOWED_STATUSES (%s)", status, strings.Join(config.AllowedStatuses, ", "))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestValidateAlert(t *testing.T) {
+	config := &Config{
+		AllowedSeverities: []string{"critical", "warning"},
+		AllowedStatuses:   []string{"firing", "resolved"},
+	}
+
+	tests := []struct {
+		name     string
+		config   *Config
+		severity string
+		status   string
+		wantErr  bool
+	}{
+		{name: "allowed", config: config, severity: "critical", status: "firing"},
+		{name: "typo'd severity", config: config, severity: "critcal", status: "firing", wantErr: true},
+		{name: "different case", config: config, severity: "Critical", status: "firing", wantErr: true},
+		{name: "empty severity", config: config, severity: "", status: "firing", wantErr: true},
+		{name: "unknown status", config: config, severity: "warning", status: "pending", wantErr: true},
+		{name: "unset lists allow anything", config: &Config{}, severity: "critcal", status: "pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlert(tt.config, tt.severity, tt.status)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAlert() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseValidationConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantMode  string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "defaults", env: map[string]string{}, wantMode: onInvalidFail},
+		{name: "drop", env: map[string]string{"ALLOWED_SEVERITIES": "critical, warning", "ON_INVALID": "drop"}, wantMode: onInvalidDrop, wantCount: 2},
+		{name: "invalid mode", env: map[string]string{"ON_INVALID": "skip"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"ALLOWED_SEVERITIES", "ALLOWED_STATUSES", "ON_INVALID"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseValidationConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseValidationConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && (config.OnInvalid != tt.wantMode || len(config.AllowedSeverities) != tt.wantCount) {
+				t.Errorf("OnInvalid = %s, AllowedSeverities = %v", config.OnInvalid, config.AllowedSeverities)
+			}
+		})
+	}
+}
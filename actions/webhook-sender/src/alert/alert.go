@@ -0,0 +1,168 @@
+// Package alert defines the payload delivered by the webhook sender and the
+// helpers a Go receiver needs to verify and decode it.
+//
+// Every request carries the SHA-256 of its body in ContentHashHeader. When
+// the sender is configured with WEBHOOK_SIGNING_SECRET, the body is also
+// signed with HMAC-SHA256 and the signature sent in SignatureHeader as
+// "sha256=<hex>". Both cover the bytes on the wire, so a gzip-compressed body
+// is verified before it is decompressed. A receiver verifies and decodes
+// the body with ParsePayload:
+//
+//	payload, err := alert.ParsePayload(body, r.Header, secret)
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusUnauthorized)
+//		return
+//	}
+package alert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Headers set by the webhook sender
+const (
+	ContentHashHeader = "X-Karo-Content-SHA256"
+	SignatureHeader   = "X-Karo-Signature"
+	// IdempotencyKeyHeader carries a key derived from the alert that is the
+	// same for every delivery of that alert, so duplicates can be dropped
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// signaturePrefix names the HMAC algorithm in SignatureHeader
+const signaturePrefix = "sha256="
+
+var (
+	// ErrContentHashMismatch is returned when the body does not match its
+	// content hash
+	ErrContentHashMismatch = errors.New("content hash mismatch")
+	// ErrInvalidSignature is returned when the signature is missing,
+	// malformed or does not match the body
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Payload is the JSON body sent by the webhook sender when no body template
+// is configured
+type Payload struct {
+	AlertName   string            `json:"alertName"`
+	Status      string            `json:"status"`
+	Severity    string            `json:"severity"`
+	Instance    string            `json:"instance"`
+	Summary     string            `json:"summary"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt,omitempty"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Timestamp   string            `json:"timestamp"`
+	// Truncated is set when annotations were trimmed to fit the
+	// sender's MAX_PAYLOAD_BYTES
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ContentHash returns the hex-encoded SHA-256 of body
+func ContentHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign returns the SignatureHeader value for body: "sha256=" followed by the
+// hex-encoded HMAC-SHA256 of body keyed with secret
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyContentHash checks body against a ContentHashHeader value
+func VerifyContentHash(body []byte, hash string) error {
+	if !strings.EqualFold(ContentHash(body), strings.TrimSpace(hash)) {
+		return ErrContentHashMismatch
+	}
+	return nil
+}
+
+// VerifySignature checks body against a SignatureHeader value using a
+// constant-time comparison
+func VerifySignature(body []byte, signature, secret string) error {
+	signature = strings.TrimSpace(signature)
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParseOption configures ParsePayload
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	signatureHeader string
+}
+
+// WithSignatureHeader reads the signature from name instead of
+// SignatureHeader, for senders configured with WEBHOOK_SIGNATURE_HEADER
+func WithSignatureHeader(name string) ParseOption {
+	return func(o *parseOptions) {
+		o.signatureHeader = name
+	}
+}
+
+// ParsePayload verifies a received body against its headers and decodes it.
+// The content hash is checked when ContentHashHeader is present. When secret
+// is non-empty, a valid signature in SignatureHeader, or the header set with
+// WithSignatureHeader, is required. Bodies sent with Content-Encoding: gzip
+// are decompressed after verification.
+func ParsePayload(body []byte, header http.Header, secret string, opts ...ParseOption) (*Payload, error) {
+	options := parseOptions{signatureHeader: SignatureHeader}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if hash := header.Get(ContentHashHeader); hash != "" {
+		if err := VerifyContentHash(body, hash); err != nil {
+			return nil, err
+		}
+	}
+	if secret != "" {
+		if err := VerifySignature(body, header.Get(options.signatureHeader), secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress alert payload: %w", err)
+		}
+		defer reader.Close()
+		if body, err = io.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("failed to decompress alert payload: %w", err)
+		}
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode alert payload: %w", err)
+	}
+	return &payload, nil
+}
@@ -0,0 +1,120 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const testBody = `{"alertName":"DiskFull","status":"firing","labels":{"instance":"node-1"}}`
+
+func TestSignThenVerifySignature(t *testing.T) {
+	body := []byte(testBody)
+	signature := Sign(body, "s3cret")
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		secret    string
+		wantErr   bool
+	}{
+		{name: "valid", body: body, signature: signature, secret: "s3cret"},
+		{name: "wrong secret", body: body, signature: signature, secret: "other", wantErr: true},
+		{name: "tampered body", body: []byte(`{"alertName":"Fake"}`), signature: signature, secret: "s3cret", wantErr: true},
+		{name: "missing prefix", body: body, signature: signature[len("sha256="):], secret: "s3cret", wantErr: true},
+		{name: "not hex", body: body, signature: "sha256=zz", secret: "s3cret", wantErr: true},
+		{name: "empty", body: body, secret: "s3cret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.body, tt.signature, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifySignature() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifySignature() error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestContentHashThenVerify(t *testing.T) {
+	body := []byte(testBody)
+	hash := ContentHash(body)
+
+	if err := VerifyContentHash(body, hash); err != nil {
+		t.Errorf("VerifyContentHash() unexpected error: %v", err)
+	}
+	if err := VerifyContentHash([]byte(testBody+" "), hash); !errors.Is(err, ErrContentHashMismatch) {
+		t.Errorf("VerifyContentHash() tampered body error = %v, want ErrContentHashMismatch", err)
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	body := []byte(testBody)
+	signed := http.Header{}
+	signed.Set(ContentHashHeader, ContentHash(body))
+	signed.Set(SignatureHeader, Sign(body, "s3cret"))
+
+	hashOnly := http.Header{}
+	hashOnly.Set(ContentHashHeader, ContentHash(body))
+
+	badHash := http.Header{}
+	badHash.Set(ContentHashHeader, ContentHash([]byte("other")))
+
+	tests := []struct {
+		name    string
+		body    []byte
+		header  http.Header
+		secret  string
+		wantErr error
+	}{
+		{name: "signed", body: body, header: signed, secret: "s3cret"},
+		{name: "content hash only", body: body, header: hashOnly},
+		{name: "no headers without secret", body: body, header: http.Header{}},
+		{name: "secret requires signature", body: body, header: hashOnly, secret: "s3cret", wantErr: ErrInvalidSignature},
+		{name: "wrong secret", body: body, header: signed, secret: "other", wantErr: ErrInvalidSignature},
+		{name: "content hash mismatch", body: body, header: badHash, wantErr: ErrContentHashMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := ParsePayload(tt.body, tt.header, tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePayload() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if payload.AlertName != "DiskFull" || payload.Labels["instance"] != "node-1" {
+				t.Errorf("ParsePayload() = %+v", payload)
+			}
+		})
+	}
+}
+
+func TestParsePayloadCustomSignatureHeader(t *testing.T) {
+	body := []byte(testBody)
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", Sign(body, "s3cret"))
+
+	payload, err := ParsePayload(body, header, "s3cret", WithSignatureHeader("X-Hub-Signature-256"))
+	if err != nil {
+		t.Fatalf("ParsePayload() unexpected error: %v", err)
+	}
+	if payload.AlertName != "DiskFull" {
+		t.Errorf("ParsePayload() = %+v", payload)
+	}
+
+	if _, err := ParsePayload(body, header, "s3cret"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParsePayload() without the option error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestParsePayloadInvalidJSON(t *testing.T) {
+	if _, err := ParsePayload([]byte("not json"), http.Header{}, ""); err == nil {
+		t.Error("ParsePayload() expected error for a non-JSON body")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadAlertJSON(t *testing.T) {
+	dir := t.TempDir()
+	alertFile := filepath.Join(dir, "alert.json")
+	if err := os.WriteFile(alertFile, []byte(`{"status":"firing"}`), 0o644); err != nil {
+		t.Fatalf("failed to write alert file: %v", err)
+	}
+	emptyFile := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o644); err != nil {
+		t.Fatalf("failed to write alert file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		env     string
+		want    string
+		wantErr string
+		wantLog string
+	}{
+		{name: "neither set", want: ""},
+		{name: "environment variable", env: `{"status":"resolved"}`, want: `{"status":"resolved"}`},
+		{name: "file", file: alertFile, want: `{"status":"firing"}`, wantLog: "Reading the alert from ALERT_JSON_FILE"},
+		{name: "file wins over the variable", file: alertFile, env: `{"status":"resolved"}`, want: `{"status":"firing"}`, wantLog: "Both ALERT_JSON_FILE and ALERT_JSON are set"},
+		{name: "missing file", file: filepath.Join(dir, "missing.json"), wantErr: "does not exist"},
+		{name: "empty file", file: emptyFile, wantErr: "is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALERT_JSON_FILE", tt.file)
+			t.Setenv("ALERT_JSON", tt.env)
+
+			var got string
+			var err error
+			output := captureLog(t, func() { got, err = readAlertJSON() })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readAlertJSON() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readAlertJSON() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("readAlertJSON() = %s, want %s", got, tt.want)
+			}
+			if !strings.Contains(output, tt.wantLog) {
+				t.Errorf("log output should contain %q, got: %s", tt.wantLog, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// MISSING_ALERTNAME_MODE values
+const (
+	missingAlertNameDerive = "derive"
+	missingAlertNameSkip   = "skip"
+	missingAlertNameFail   = "fail"
+)
+
+// parseMissingAlertNameMode validates MISSING_ALERTNAME_MODE, defaulting to derive
+func parseMissingAlertNameMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return missingAlertNameDerive, nil
+	case missingAlertNameDerive, missingAlertNameSkip, missingAlertNameFail:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported MISSING_ALERTNAME_MODE '%s', must be one of derive, skip, fail", mode)
+	}
+}
+
+// parseLabelList parses a comma-separated list of label keys
+func parseLabelList(spec string) []string {
+	var keys []string
+	for _, key := range strings.Split(spec, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ensureAlertName applies MISSING_ALERTNAME_MODE when an alert has no name.
+// It returns the name to use and whether the alert should be sent at all.
+func ensureAlertName(config *Config, alertName string, labels map[string]string) (string, bool, error) {
+	if alertName != "" {
+		return alertName, true, nil
+	}
+
+	switch config.MissingAlertNameMode {
+	case missingAlertNameSkip:
+		return "", false, nil
+	case missingAlertNameFail:
+		return "", false, fmt.Errorf("alert has no alertname label and MISSING_ALERTNAME_MODE is fail")
+	default:
+		return deriveAlertName(labels, config.AlertNameLabels), true, nil
+	}
+}
+
+// deriveAlertName uses the first non-empty label from fromLabels, falling
+// back to a stable name built from the fingerprint of all labels
+func deriveAlertName(labels map[string]string, fromLabels []string) string {
+	for _, key := range fromLabels {
+		if value := labels[key]; value != "" {
+			return value
+		}
+	}
+	return fmt.Sprintf("alert-%016x", labelsFingerprint(labels))
+}
+
+// labelsFingerprint hashes the sorted label set with FNV-64a, the same way
+// Alertmanager fingerprints alerts
+func labelsFingerprint(labels map[string]string) uint64 {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0xff})
+		hash.Write([]byte(labels[key]))
+		hash.Write([]byte{0xff})
+	}
+	return hash.Sum64()
+}
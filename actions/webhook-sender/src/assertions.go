@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// assertionError is a response that has an accepted status code but whose
+// body misses the target's bodyContains or jsonFields assertions, e.g. a
+// receiver that answers 200 with {"ok": false}. Unlike a rejected status,
+// the receiver may accept the same request on another attempt.
+type assertionError struct {
+	err error
+}
+
+func (e *assertionError) Error() string { return e.err.Error() }
+
+func (e *assertionError) Unwrap() error { return e.err }
+
+// parseResponseAssertions reads SUCCESS_STATUS, RESPONSE_BODY_CONTAINS and
+// RESPONSE_JSON_FIELDS, the success criteria of targets that don't set
+// their own in WEBHOOK_TARGETS
+func parseResponseAssertions(config *Config) error {
+	for _, value := range parseLabelList(os.Getenv("SUCCESS_STATUS")) {
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("invalid SUCCESS_STATUS code '%s', must be between 100 and 599", value)
+		}
+		config.SuccessStatus = append(config.SuccessStatus, code)
+	}
+
+	config.ResponseBodyContains = os.Getenv("RESPONSE_BODY_CONTAINS")
+
+	if spec := os.Getenv("RESPONSE_JSON_FIELDS"); spec != "" {
+		if err := json.Unmarshal([]byte(spec), &config.ResponseJSONFields); err != nil {
+			return fmt.Errorf("failed to parse RESPONSE_JSON_FIELDS, must be a JSON object of paths to expected values: %w", err)
+		}
+	}
+	return nil
+}
+
+// withDefaultAssertions fills the success criteria the target doesn't set
+// from the action-wide settings
+func withDefaultAssertions(config *Config, target WebhookTarget) WebhookTarget {
+	if len(target.SuccessStatus) == 0 {
+		target.SuccessStatus = config.SuccessStatus
+	}
+	if target.BodyContains == "" {
+		target.BodyContains = config.ResponseBodyContains
+	}
+	if len(target.JSONFields) == 0 {
+		target.JSONFields = config.ResponseJSONFields
+	}
+	return target
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseResponseAssertions(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantStatus []int
+		wantFields map[string]string
+		wantErr    string
+	}{
+		{name: "unset", env: map[string]string{}},
+		{
+			name:       "all assertions",
+			env:        map[string]string{"SUCCESS_STATUS": "200, 202", "RESPONSE_BODY_CONTAINS": "queued", "RESPONSE_JSON_FIELDS": `{"ok":"true"}`},
+			wantStatus: []int{200, 202},
+			wantFields: map[string]string{"ok": "true"},
+		},
+		{name: "status not a number", env: map[string]string{"SUCCESS_STATUS": "2xx"}, wantErr: "invalid SUCCESS_STATUS code '2xx'"},
+		{name: "status out of range", env: map[string]string{"SUCCESS_STATUS": "2000"}, wantErr: "invalid SUCCESS_STATUS code '2000'"},
+		{name: "fields not an object", env: map[string]string{"RESPONSE_JSON_FIELDS": `["ok"]`}, wantErr: "failed to parse RESPONSE_JSON_FIELDS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SUCCESS_STATUS", "RESPONSE_BODY_CONTAINS", "RESPONSE_JSON_FIELDS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseResponseAssertions(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseResponseAssertions() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseResponseAssertions() unexpected error: %v", err)
+			}
+			if len(config.SuccessStatus) != len(tt.wantStatus) {
+				t.Fatalf("SuccessStatus = %v, want %v", config.SuccessStatus, tt.wantStatus)
+			}
+			for i, code := range tt.wantStatus {
+				if config.SuccessStatus[i] != code {
+					t.Errorf("SuccessStatus = %v, want %v", config.SuccessStatus, tt.wantStatus)
+				}
+			}
+			if config.ResponseBodyContains != tt.env["RESPONSE_BODY_CONTAINS"] {
+				t.Errorf("ResponseBodyContains = %q, want %q", config.ResponseBodyContains, tt.env["RESPONSE_BODY_CONTAINS"])
+			}
+			assertStringMap(t, "ResponseJSONFields", config.ResponseJSONFields, tt.wantFields)
+		})
+	}
+}
+
+func TestWithDefaultAssertions(t *testing.T) {
+	config := &Config{
+		SuccessStatus:        []int{200},
+		ResponseBodyContains: "queued",
+		ResponseJSONFields:   map[string]string{"ok": "true"},
+	}
+
+	target := withDefaultAssertions(config, WebhookTarget{URL: "https://example.com"})
+	if len(target.SuccessStatus) != 1 || target.BodyContains != "queued" || target.JSONFields["ok"] != "true" {
+		t.Errorf("target without criteria = %+v, want the defaults", target)
+	}
+
+	own := WebhookTarget{URL: "https://example.com", SuccessStatus: []int{202}, BodyContains: "accepted"}
+	target = withDefaultAssertions(config, own)
+	if target.SuccessStatus[0] != 202 || target.BodyContains != "accepted" || target.JSONFields["ok"] != "true" {
+		t.Errorf("target with own criteria = %+v, want them kept", target)
+	}
+}
+
+func TestSendWebhookRetriesErrorInBody(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The receiver accepts the request but fails to process it at first
+		if requests.Add(1) == 1 {
+			w.Write([]byte(`{"ok":false,"error":"upstream unavailable"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:            []WebhookTarget{{URL: server.URL}},
+		ResponseJSONFields: map[string]string{"ok": "true"},
+		TimeoutSeconds:     5,
+		RetryCount:         2,
+		RetryBackoffMs:     1,
+		RetryMaxBackoffMs:  5,
+	}
+
+	var err error
+	output := captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("received %d requests, want 2", got)
+	}
+	if !strings.Contains(output, `response field ok is "false"`) {
+		t.Errorf("the failed assertion was not logged: %s", output)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// authSettings are the mutually exclusive ways to authenticate requests
+type authSettings struct {
+	Header      string // AUTH_HEADER, sent verbatim
+	BearerToken string // WEBHOOK_BEARER_TOKEN
+	BasicUser   string // WEBHOOK_BASIC_USER
+	BasicPass   string // WEBHOOK_BASIC_PASS
+}
+
+// secretEnv reads a credential from the environment variable name or from
+// the file named by name_FILE, so it can come from a mounted Kubernetes
+// secret. A single trailing newline, as left by most editors, is dropped.
+func secretEnv(name string) (string, error) {
+	value, file := os.Getenv(name), os.Getenv(name+"_FILE")
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("%s and %s_FILE are mutually exclusive", name, name)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	value = strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// resolveAuthHeader returns the Authorization header value for the
+// configured auth method, or an empty string when none is configured
+func resolveAuthHeader(auth authSettings) (string, error) {
+	var configured []string
+	if auth.Header != "" {
+		configured = append(configured, "AUTH_HEADER")
+	}
+	if auth.BearerToken != "" {
+		configured = append(configured, "WEBHOOK_BEARER_TOKEN")
+	}
+	if auth.BasicUser != "" || auth.BasicPass != "" {
+		configured = append(configured, "WEBHOOK_BASIC_USER/WEBHOOK_BASIC_PASS")
+	}
+	if len(configured) > 1 {
+		return "", fmt.Errorf("%s are mutually exclusive, configure only one auth method", strings.Join(configured, ", "))
+	}
+
+	switch {
+	case auth.BearerToken != "":
+		return "Bearer " + auth.BearerToken, nil
+	case auth.BasicUser != "" || auth.BasicPass != "":
+		if auth.BasicUser == "" {
+			return "", fmt.Errorf("WEBHOOK_BASIC_USER is required when WEBHOOK_BASIC_PASS is set")
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(auth.BasicUser + ":" + auth.BasicPass))
+		return "Basic " + credentials, nil
+	default:
+		return auth.Header, nil
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveAuthHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    authSettings
+		want    string
+		wantErr bool
+	}{
+		{name: "none", want: ""},
+		{name: "raw header", auth: authSettings{Header: "Token abc"}, want: "Token abc"},
+		{name: "bearer token", auth: authSettings{BearerToken: "abc123"}, want: "Bearer abc123"},
+		{name: "basic auth", auth: authSettings{BasicUser: "karo", BasicPass: "s3cret"}, want: "Basic a2FybzpzM2NyZXQ="},
+		{name: "basic auth without password", auth: authSettings{BasicUser: "karo"}, want: "Basic a2Fybzo="},
+		{name: "basic password without user", auth: authSettings{BasicPass: "s3cret"}, wantErr: true},
+		{name: "header and bearer", auth: authSettings{Header: "Token abc", BearerToken: "abc123"}, wantErr: true},
+		{name: "bearer and basic", auth: authSettings{BearerToken: "abc123", BasicUser: "karo"}, wantErr: true},
+		{name: "header and basic", auth: authSettings{Header: "Token abc", BasicPass: "s3cret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveAuthHeader(tt.auth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveAuthHeader() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveAuthHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretEnv(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "password")
+	if err := os.WriteFile(file, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{name: "unset", want: ""},
+		{name: "value", value: "s3cret", want: "s3cret"},
+		{name: "file drops trailing newline", file: file, want: "s3cret"},
+		{name: "value and file", value: "s3cret", file: file, wantErr: "mutually exclusive"},
+		{name: "missing file", file: filepath.Join(dir, "missing"), wantErr: "failed to read WEBHOOK_BASIC_PASS_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_BASIC_PASS", tt.value)
+			t.Setenv("WEBHOOK_BASIC_PASS_FILE", tt.file)
+
+			got, err := secretEnv("WEBHOOK_BASIC_PASS")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("secretEnv() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("secretEnv() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("secretEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAuthIsNotLogged(t *testing.T) {
+	t.Setenv("WEBHOOK_URL", "https://example.com/hook")
+	t.Setenv("WEBHOOK_BASIC_USER", "karo")
+	t.Setenv("WEBHOOK_BASIC_PASS", "basic-secret")
+
+	var config *Config
+	var err error
+	output := captureLog(t, func() {
+		config, err = loadConfig()
+		if err == nil {
+			logResolvedConfig(config)
+		}
+	})
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(config.Targets[0].AuthHeader, "Basic ") {
+		t.Errorf("target auth header = %q, want Basic credentials", config.Targets[0].AuthHeader)
+	}
+	for _, secret := range []string{"basic-secret", config.AuthHeader} {
+		if strings.Contains(output, secret) {
+			t.Errorf("logged config leaked %q: %s", secret, output)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Defaults of CIRCUIT_BREAKER_THRESHOLD and CIRCUIT_BREAKER_COOLDOWN_SECONDS
+const (
+	defaultCircuitBreakerThreshold = 5
+	defaultCircuitBreakerCooldown  = 300
+)
+
+// circuitOpenError reports that a delivery was not attempted because the
+// circuit breaker is open
+type circuitOpenError struct {
+	failures int
+	until    time.Time
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open after %d consecutive failed deliveries, skipping delivery until %s", e.failures, e.until.UTC().Format(time.RFC3339))
+}
+
+// circuitState is the content of CIRCUIT_BREAKER_FILE
+type circuitState struct {
+	// Failures counts the consecutive failed deliveries
+	Failures int `json:"failures"`
+	// OpenUntil is when the next delivery may be attempted once Failures
+	// reached the threshold
+	OpenUntil *time.Time `json:"openUntil,omitempty"`
+}
+
+// circuitBreaker stops delivering to a receiver that keeps failing. Its
+// state is kept in CIRCUIT_BREAKER_FILE so it carries over between runs that
+// mount the same volume. After CIRCUIT_BREAKER_THRESHOLD consecutive failed
+// deliveries the breaker opens and runs fail fast for the cooldown. The
+// first run after the cooldown tries again: success closes the breaker,
+// failure opens it for another cooldown. Runs take an exclusive lock on the
+// file while they read and update it.
+type circuitBreaker struct {
+	path      string
+	threshold int
+	cooldown  time.Duration
+}
+
+// parseCircuitBreakerConfig reads CIRCUIT_BREAKER_FILE, which turns the
+// breaker on, CIRCUIT_BREAKER_THRESHOLD and CIRCUIT_BREAKER_COOLDOWN_SECONDS
+func parseCircuitBreakerConfig(config *Config) error {
+	config.CircuitBreakerFile = os.Getenv("CIRCUIT_BREAKER_FILE")
+
+	config.FailureThreshold = defaultCircuitBreakerThreshold
+	if err := envInt(config.StrictEnv, "CIRCUIT_BREAKER_THRESHOLD", &config.FailureThreshold); err != nil {
+		return err
+	}
+	if config.FailureThreshold < 1 {
+		return fmt.Errorf("CIRCUIT_BREAKER_THRESHOLD must be at least 1, got %d", config.FailureThreshold)
+	}
+
+	config.CooldownSeconds = defaultCircuitBreakerCooldown
+	if err := envInt(config.StrictEnv, "CIRCUIT_BREAKER_COOLDOWN_SECONDS", &config.CooldownSeconds); err != nil {
+		return err
+	}
+	if config.CooldownSeconds < 1 {
+		return fmt.Errorf("CIRCUIT_BREAKER_COOLDOWN_SECONDS must be at least 1, got %d", config.CooldownSeconds)
+	}
+	return nil
+}
+
+// newCircuitBreaker returns the breaker configured by CIRCUIT_BREAKER_FILE,
+// or nil when there is none
+func newCircuitBreaker(config *Config) *circuitBreaker {
+	if config.CircuitBreakerFile == "" {
+		return nil
+	}
+	return &circuitBreaker{
+		path:      config.CircuitBreakerFile,
+		threshold: config.FailureThreshold,
+		cooldown:  time.Duration(config.CooldownSeconds) * time.Second,
+	}
+}
+
+// allow returns a circuitOpenError while the breaker is open at now. Once
+// the cooldown has passed, the run that is let through holds off the others
+// for another cooldown until it records its outcome.
+func (b *circuitBreaker) allow(now time.Time) error {
+	var openErr error
+	err := b.update(func(state *circuitState) {
+		if state.Failures < b.threshold {
+			return
+		}
+		if state.OpenUntil != nil && now.Before(*state.OpenUntil) {
+			openErr = &circuitOpenError{failures: state.Failures, until: *state.OpenUntil}
+			return
+		}
+		log.Println("Circuit breaker cooldown has passed, trying the delivery again")
+		until := now.Add(b.cooldown)
+		state.OpenUntil = &until
+	})
+	if err != nil {
+		return err
+	}
+	return openErr
+}
+
+// record updates the breaker with the outcome of a delivery at now: a
+// success closes it, a failure counts towards the threshold and opens it
+// once that is reached
+func (b *circuitBreaker) record(now time.Time, deliveryErr error) error {
+	return b.update(func(state *circuitState) {
+		if deliveryErr == nil {
+			if state.Failures >= b.threshold {
+				log.Println("Circuit breaker closed, the delivery succeeded")
+			}
+			*state = circuitState{}
+			return
+		}
+		state.Failures++
+		if state.Failures >= b.threshold {
+			until := now.Add(b.cooldown)
+			state.OpenUntil = &until
+			log.Printf("Circuit breaker opened after %d consecutive failed deliveries, skipping deliveries for %s", state.Failures, b.cooldown)
+		}
+	})
+}
+
+// update applies fn to the state in the file under an exclusive lock. A
+// missing, empty or unreadable state counts as a closed breaker.
+func (b *circuitBreaker) update(fn func(state *circuitState)) error {
+	file, err := os.OpenFile(b.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open CIRCUIT_BREAKER_FILE: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock CIRCUIT_BREAKER_FILE: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read CIRCUIT_BREAKER_FILE: %w", err)
+	}
+	var state circuitState
+	if err := json.Unmarshal(data, &state); err != nil {
+		state = circuitState{}
+	}
+
+	fn(&state)
+
+	data, err = json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode CIRCUIT_BREAKER_FILE: %w", err)
+	}
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write CIRCUIT_BREAKER_FILE: %w", err)
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write CIRCUIT_BREAKER_FILE: %w", err)
+	}
+	return nil
+}
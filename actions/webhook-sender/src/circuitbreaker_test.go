@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCircuitBreakerConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		wantThreshold int
+		wantCooldown  int
+		wantErr       string
+	}{
+		{name: "defaults", env: map[string]string{}, wantThreshold: 5, wantCooldown: 300},
+		{name: "custom", env: map[string]string{"CIRCUIT_BREAKER_FILE": "/var/breaker", "CIRCUIT_BREAKER_THRESHOLD": "3", "CIRCUIT_BREAKER_COOLDOWN_SECONDS": "60"}, wantThreshold: 3, wantCooldown: 60},
+		{name: "zero threshold", env: map[string]string{"CIRCUIT_BREAKER_THRESHOLD": "0"}, wantErr: "CIRCUIT_BREAKER_THRESHOLD must be at least 1"},
+		{name: "zero cooldown", env: map[string]string{"CIRCUIT_BREAKER_COOLDOWN_SECONDS": "0"}, wantErr: "CIRCUIT_BREAKER_COOLDOWN_SECONDS must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CIRCUIT_BREAKER_FILE", "CIRCUIT_BREAKER_THRESHOLD", "CIRCUIT_BREAKER_COOLDOWN_SECONDS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseCircuitBreakerConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseCircuitBreakerConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCircuitBreakerConfig() unexpected error: %v", err)
+			}
+			if config.CircuitBreakerFile != tt.env["CIRCUIT_BREAKER_FILE"] || config.FailureThreshold != tt.wantThreshold || config.CooldownSeconds != tt.wantCooldown {
+				t.Errorf("config = %q, %d, %d, want %q, %d, %d", config.CircuitBreakerFile, config.FailureThreshold, config.CooldownSeconds, tt.env["CIRCUIT_BREAKER_FILE"], tt.wantThreshold, tt.wantCooldown)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := &circuitBreaker{path: filepath.Join(t.TempDir(), "breaker"), threshold: 2, cooldown: time.Minute}
+	now := time.Unix(1700000000, 0)
+	failure := errors.New("status 503")
+
+	captureLog(t, func() {
+		// A single failure stays below the threshold
+		if err := breaker.allow(now); err != nil {
+			t.Fatalf("allow() on a new breaker = %v, want nil", err)
+		}
+		if err := breaker.record(now, failure); err != nil {
+			t.Fatalf("record() unexpected error: %v", err)
+		}
+		if err := breaker.allow(now); err != nil {
+			t.Fatalf("allow() after one failure = %v, want nil", err)
+		}
+
+		// The second one opens the breaker for the cooldown
+		if err := breaker.record(now, failure); err != nil {
+			t.Fatalf("record() unexpected error: %v", err)
+		}
+		var openErr *circuitOpenError
+		if err := breaker.allow(now.Add(30 * time.Second)); !errors.As(err, &openErr) {
+			t.Fatalf("allow() during the cooldown = %v, want a circuitOpenError", err)
+		}
+
+		// After the cooldown one run is let through, and holds off the others
+		if err := breaker.allow(now.Add(time.Minute)); err != nil {
+			t.Fatalf("allow() after the cooldown = %v, want nil", err)
+		}
+		if err := breaker.allow(now.Add(time.Minute + time.Second)); !errors.As(err, &openErr) {
+			t.Fatalf("allow() while the trial delivery runs = %v, want a circuitOpenError", err)
+		}
+
+		// Its failure opens the breaker again, its success closes it
+		if err := breaker.record(now.Add(time.Minute), failure); err != nil {
+			t.Fatalf("record() unexpected error: %v", err)
+		}
+		if err := breaker.allow(now.Add(90 * time.Second)); !errors.As(err, &openErr) {
+			t.Fatalf("allow() after the trial failed = %v, want a circuitOpenError", err)
+		}
+		if err := breaker.record(now.Add(2*time.Minute), nil); err != nil {
+			t.Fatalf("record() unexpected error: %v", err)
+		}
+		if err := breaker.allow(now.Add(2 * time.Minute)); err != nil {
+			t.Fatalf("allow() after a success = %v, want nil", err)
+		}
+	})
+}
+
+func TestCircuitBreakerCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breaker")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	breaker := &circuitBreaker{path: path, threshold: 1, cooldown: time.Minute}
+	if err := breaker.allow(time.Now()); err != nil {
+		t.Errorf("allow() = %v, want a corrupt file to count as a closed breaker", err)
+	}
+}
+
+func TestHandleAlertCircuitBreakerOpen(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:            []WebhookTarget{{URL: server.URL}},
+		TimeoutSeconds:     5,
+		CircuitBreakerFile: filepath.Join(t.TempDir(), "breaker"),
+		FailureThreshold:   1,
+		CooldownSeconds:    60,
+	}
+	alertData := AlertData{Status: "firing", Labels: map[string]string{"alertname": "DiskFull"}}
+
+	// The first failure opens the breaker, so the next run sends nothing
+	var first, second error
+	output := captureLog(t, func() {
+		first = handleAlert(context.Background(), config, alertData)
+		second = handleAlert(context.Background(), config, alertData)
+	})
+	var openErr *circuitOpenError
+	if first == nil || errors.As(first, &openErr) {
+		t.Fatalf("first handleAlert() error = %v, want the delivery failure", first)
+	}
+	if !errors.As(second, &openErr) {
+		t.Fatalf("second handleAlert() error = %v, want a circuitOpenError", second)
+	}
+	if requests != 1 {
+		t.Errorf("webhook received %d requests, want 1", requests)
+	}
+	if !strings.Contains(output, "Circuit breaker opened after 1 consecutive failed deliveries") {
+		t.Errorf("expected the breaker opening in the logs, got:\n%s", output)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WEBHOOK_FORMAT values
+const (
+	webhookFormatKaro        = "karo"
+	webhookFormatCloudEvents = "cloudevents"
+	webhookFormatForm        = "form"
+)
+
+// CLOUDEVENTS_MODE values
+const (
+	cloudEventsStructured = "structured"
+	cloudEventsBinary     = "binary"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	// cloudEventsContentType is the media type of a structured-mode event
+	cloudEventsContentType = "application/cloudevents+json"
+	// defaultCloudEventsSource identifies this action when CLOUDEVENTS_SOURCE
+	// is unset
+	defaultCloudEventsSource = "karo/webhook-sender"
+	// cloudEventTypePrefix is suffixed with the alert status, e.g.
+	// com.karo.alert.firing
+	cloudEventTypePrefix = "com.karo.alert"
+)
+
+// CloudEvent is a CloudEvents 1.0 event in the JSON format used by
+// structured mode
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// parseCloudEventsConfig reads WEBHOOK_FORMAT, CLOUDEVENTS_MODE and
+// CLOUDEVENTS_SOURCE. The event data and the form fields are the built-in
+// payload, so neither format can be combined with a body template.
+func parseCloudEventsConfig(config *Config) error {
+	switch format := os.Getenv("WEBHOOK_FORMAT"); format {
+	case "", webhookFormatKaro:
+		config.WebhookFormat = webhookFormatKaro
+	case webhookFormatCloudEvents, webhookFormatForm, webhookFormatGraphQL:
+		config.WebhookFormat = format
+	default:
+		return fmt.Errorf("unsupported WEBHOOK_FORMAT '%s', must be '%s', '%s', '%s' or '%s'", format, webhookFormatKaro, webhookFormatCloudEvents, webhookFormatForm, webhookFormatGraphQL)
+	}
+	if config.WebhookFormat == webhookFormatForm && config.BodyTemplate != nil {
+		return fmt.Errorf("WEBHOOK_FORMAT=%s cannot be combined with a body template", webhookFormatForm)
+	}
+
+	mode := os.Getenv("CLOUDEVENTS_MODE")
+	switch mode {
+	case "":
+		mode = cloudEventsStructured
+	case cloudEventsStructured, cloudEventsBinary:
+	default:
+		return fmt.Errorf("unsupported CLOUDEVENTS_MODE '%s', must be '%s' or '%s'", mode, cloudEventsStructured, cloudEventsBinary)
+	}
+
+	if config.WebhookFormat != webhookFormatCloudEvents {
+		if os.Getenv("CLOUDEVENTS_MODE") != "" || os.Getenv("CLOUDEVENTS_SOURCE") != "" {
+			log.Printf("Warning: CLOUDEVENTS_MODE and CLOUDEVENTS_SOURCE are ignored unless WEBHOOK_FORMAT=%s", webhookFormatCloudEvents)
+		}
+		return nil
+	}
+	if config.BodyTemplate != nil {
+		return fmt.Errorf("WEBHOOK_FORMAT=%s cannot be combined with a body template", webhookFormatCloudEvents)
+	}
+
+	config.CloudEventsMode = mode
+	config.CloudEventsSource = os.Getenv("CLOUDEVENTS_SOURCE")
+	if config.CloudEventsSource == "" {
+		config.CloudEventsSource = defaultCloudEventsSource
+	}
+	return nil
+}
+
+// cloudEventType derives the event type from the alert status, e.g.
+// com.karo.alert.firing, falling back to the bare prefix without a status
+func cloudEventType(status string) string {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "" {
+		return cloudEventTypePrefix
+	}
+	return cloudEventTypePrefix + "." + status
+}
+
+// encodeCloudEvent wraps the payload JSON in data as a CloudEvent with a new
+// ID. Structured mode returns the whole event as the body; binary mode
+// returns data as the body and the event attributes as ce-* headers. The
+// returned header also carries the Content-Type to send.
+func encodeCloudEvent(config *Config, payload WebhookPayload, data []byte) ([]byte, http.Header, error) {
+	event := CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Type:            cloudEventType(payload.Status),
+		Source:          config.CloudEventsSource,
+		ID:              uuid.NewString(),
+		Time:            payload.Timestamp,
+		DataContentType: defaultContentType,
+		Data:            data,
+	}
+
+	header := http.Header{}
+	if config.CloudEventsMode == cloudEventsBinary {
+		header.Set("ce-specversion", event.SpecVersion)
+		header.Set("ce-type", event.Type)
+		header.Set("ce-source", event.Source)
+		header.Set("ce-id", event.ID)
+		if event.Time != "" {
+			header.Set("ce-time", event.Time)
+		}
+		header.Set("Content-Type", event.DataContentType)
+		return data, header, nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal CloudEvent: %w", err)
+	}
+	header.Set("Content-Type", cloudEventsContentType)
+	return body, header, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+
+	"github.com/google/uuid"
+)
+
+func TestParseCloudEventsConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		template   bool
+		wantFormat string
+		wantMode   string
+		wantSource string
+		wantErr    bool
+	}{
+		{name: "defaults", env: map[string]string{}, wantFormat: webhookFormatKaro},
+		{name: "structured by default", env: map[string]string{"WEBHOOK_FORMAT": "cloudevents"}, wantFormat: webhookFormatCloudEvents, wantMode: cloudEventsStructured, wantSource: defaultCloudEventsSource},
+		{name: "binary with source", env: map[string]string{"WEBHOOK_FORMAT": "cloudevents", "CLOUDEVENTS_MODE": "binary", "CLOUDEVENTS_SOURCE": "/clusters/prod"}, wantFormat: webhookFormatCloudEvents, wantMode: cloudEventsBinary, wantSource: "/clusters/prod"},
+		{name: "invalid format", env: map[string]string{"WEBHOOK_FORMAT": "cloudevent"}, wantErr: true},
+		{name: "invalid mode", env: map[string]string{"WEBHOOK_FORMAT": "cloudevents", "CLOUDEVENTS_MODE": "batch"}, wantErr: true},
+		{name: "body template is rejected", env: map[string]string{"WEBHOOK_FORMAT": "cloudevents"}, template: true, wantErr: true},
+		{name: "form", env: map[string]string{"WEBHOOK_FORMAT": "form"}, wantFormat: webhookFormatForm},
+		{name: "form with body template is rejected", env: map[string]string{"WEBHOOK_FORMAT": "form"}, template: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"WEBHOOK_FORMAT", "CLOUDEVENTS_MODE", "CLOUDEVENTS_SOURCE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			if tt.template {
+				config.BodyTemplate = template.Must(template.New("body").Parse(`{{ .AlertName }}`))
+			}
+			err := parseCloudEventsConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCloudEventsConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if config.WebhookFormat != tt.wantFormat || config.CloudEventsMode != tt.wantMode || config.CloudEventsSource != tt.wantSource {
+				t.Errorf("format, mode, source = %q, %q, %q, want %q, %q, %q",
+					config.WebhookFormat, config.CloudEventsMode, config.CloudEventsSource, tt.wantFormat, tt.wantMode, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestCloudEventType(t *testing.T) {
+	tests := map[string]string{
+		"firing":   "com.karo.alert.firing",
+		"Resolved": "com.karo.alert.resolved",
+		"":         "com.karo.alert",
+	}
+	for status, want := range tests {
+		if got := cloudEventType(status); got != want {
+			t.Errorf("cloudEventType(%q) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestSendWebhookCloudEvents(t *testing.T) {
+	payload := WebhookPayload{AlertName: "DiskFull", Status: "firing", Timestamp: "2024-01-01T12:00:00Z"}
+
+	tests := []struct {
+		name string
+		mode string
+	}{
+		{name: "structured", mode: cloudEventsStructured},
+		{name: "binary", mode: cloudEventsBinary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHeader http.Header
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header
+				gotBody, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:           []WebhookTarget{{URL: server.URL}},
+				WebhookFormat:     webhookFormatCloudEvents,
+				CloudEventsMode:   tt.mode,
+				CloudEventsSource: "/clusters/prod",
+				TimeoutSeconds:    5,
+			}
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, payload) })
+			if err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+
+			var event CloudEvent
+			var data []byte
+			if tt.mode == cloudEventsBinary {
+				event = CloudEvent{
+					SpecVersion: gotHeader.Get("ce-specversion"),
+					Type:        gotHeader.Get("ce-type"),
+					Source:      gotHeader.Get("ce-source"),
+					ID:          gotHeader.Get("ce-id"),
+					Time:        gotHeader.Get("ce-time"),
+				}
+				data = gotBody
+				if got := gotHeader.Get("Content-Type"); got != defaultContentType {
+					t.Errorf("Content-Type = %s, want %s", got, defaultContentType)
+				}
+			} else {
+				if err := json.Unmarshal(gotBody, &event); err != nil {
+					t.Fatalf("body is not a CloudEvent: %v\n%s", err, gotBody)
+				}
+				data = event.Data
+				if got := gotHeader.Get("Content-Type"); got != cloudEventsContentType {
+					t.Errorf("Content-Type = %s, want %s", got, cloudEventsContentType)
+				}
+				if gotHeader.Get("ce-id") != "" {
+					t.Error("structured mode should not send ce-* headers")
+				}
+			}
+
+			if event.SpecVersion != "1.0" || event.Type != "com.karo.alert.firing" || event.Source != "/clusters/prod" || event.Time != payload.Timestamp {
+				t.Errorf("unexpected event attributes: %+v", event)
+			}
+			if _, err := uuid.Parse(event.ID); err != nil {
+				t.Errorf("id = %q, want a UUID: %v", event.ID, err)
+			}
+
+			var gotPayload WebhookPayload
+			if err := json.Unmarshal(data, &gotPayload); err != nil {
+				t.Fatalf("data is not the payload: %v\n%s", err, data)
+			}
+			if gotPayload.AlertName != payload.AlertName || gotPayload.Status != payload.Status {
+				t.Errorf("data = %+v, want the alert payload", gotPayload)
+			}
+		})
+	}
+}
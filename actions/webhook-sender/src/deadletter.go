@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// DeadLetterRecord is written to DEAD_LETTER_DIR for an alert that could not
+// be delivered: why delivery failed and the requests that were attempted,
+// redacted like the file sink's records, so the alert can be inspected and
+// replayed later
+type DeadLetterRecord struct {
+	Error    string           `json:"error"`
+	FailedAt string           `json:"failedAt"`
+	Requests []FileSinkRecord `json:"requests"`
+}
+
+// parseDeadLetterConfig reads DEAD_LETTER_URL and DEAD_LETTER_DIR, where an
+// alert goes once its delivery has failed for good, and the optional
+// DEAD_LETTER_AUTH_HEADER (or DEAD_LETTER_AUTH_HEADER_FILE) sent to the URL
+func parseDeadLetterConfig(config *Config) error {
+	authHeader, err := secretEnv("DEAD_LETTER_AUTH_HEADER")
+	if err != nil {
+		return err
+	}
+
+	config.DeadLetterDir = os.Getenv("DEAD_LETTER_DIR")
+	config.DeadLetterURL = os.Getenv("DEAD_LETTER_URL")
+	if config.DeadLetterURL == "" {
+		if authHeader != "" {
+			return fmt.Errorf("DEAD_LETTER_AUTH_HEADER requires DEAD_LETTER_URL")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(config.DeadLetterURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid DEAD_LETTER_URL, must be an http or https URL")
+	}
+	config.DeadLetterAuthHeader = authHeader
+	return nil
+}
+
+// deadLetter hands an alert whose delivery failed to the dead-letter
+// destinations, so it isn't lost with the failed run. The run fails either
+// way; destinations that fail too are only logged.
+func deadLetter(ctx context.Context, config *Config, payload WebhookPayload, cause error) {
+	if config.DeadLetterURL != "" {
+		if err := sendDeadLetter(ctx, config, payload); err != nil {
+			log.Printf("Warning: Failed to send alert to DEAD_LETTER_URL: %v", err)
+		} else {
+			log.Printf("Alert sent to DEAD_LETTER_URL: %s", redactURL(config.DeadLetterURL))
+		}
+	}
+
+	if config.DeadLetterDir != "" {
+		path, err := writeDeadLetterFile(config, payload, cause, time.Now())
+		if err != nil {
+			log.Printf("Warning: Failed to write alert to DEAD_LETTER_DIR: %v", err)
+		} else {
+			log.Printf("Alert written to DEAD_LETTER_DIR: %s", path)
+		}
+	}
+}
+
+// sendDeadLetter POSTs the body the webhook would have received to
+// DEAD_LETTER_URL, with the same format, custom headers and signature, but
+// only its own Authorization header, no OAuth2, JWT or SigV4 credentials,
+// and any 2xx accepted
+func sendDeadLetter(ctx context.Context, config *Config, payload WebhookPayload) error {
+	deadLetter := *config
+	deadLetter.Targets = []WebhookTarget{{URL: config.DeadLetterURL, AuthHeader: config.DeadLetterAuthHeader}}
+	deadLetter.OAuthTokenSource = nil
+	deadLetter.SigV4 = nil
+	deadLetter.HTTPMethod = http.MethodPost
+	deadLetter.MethodByStatus = nil
+	deadLetter.SuccessStatus = nil
+	deadLetter.ResponseBodyContains = ""
+	deadLetter.ResponseJSONFields = nil
+	deadLetter.OutputFile = ""
+	deadLetter.ResultJSON = false
+	return sendWebhook(ctx, &deadLetter, payload)
+}
+
+// writeDeadLetterFile writes the failed requests and their error to a new
+// file in DEAD_LETTER_DIR, named like the file sink's files
+func writeDeadLetterFile(config *Config, payload WebhookPayload, cause error, now time.Time) (string, error) {
+	requests, err := buildSinkRecords(config, payload)
+	if err != nil {
+		return "", err
+	}
+
+	record := DeadLetterRecord{
+		Error:    cause.Error(),
+		FailedAt: now.UTC().Format(time.RFC3339),
+		Requests: requests,
+	}
+	return writeSinkFile(config.DeadLetterDir, sinkFileName(payload.AlertName, payload.Status, now), record)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDeadLetterConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unset", env: map[string]string{}},
+		{name: "url and dir", env: map[string]string{"DEAD_LETTER_URL": "https://dlq.example.com/alerts", "DEAD_LETTER_DIR": "/var/dlq", "DEAD_LETTER_AUTH_HEADER": "Bearer abc"}},
+		{name: "invalid url", env: map[string]string{"DEAD_LETTER_URL": "dlq.example.com"}, wantErr: "invalid DEAD_LETTER_URL"},
+		{name: "auth header without url", env: map[string]string{"DEAD_LETTER_DIR": "/var/dlq", "DEAD_LETTER_AUTH_HEADER": "Bearer abc"}, wantErr: "requires DEAD_LETTER_URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DEAD_LETTER_URL", "DEAD_LETTER_DIR", "DEAD_LETTER_AUTH_HEADER", "DEAD_LETTER_AUTH_HEADER_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseDeadLetterConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseDeadLetterConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDeadLetterConfig() unexpected error: %v", err)
+			}
+			if config.DeadLetterURL != tt.env["DEAD_LETTER_URL"] || config.DeadLetterDir != tt.env["DEAD_LETTER_DIR"] || config.DeadLetterAuthHeader != tt.env["DEAD_LETTER_AUTH_HEADER"] {
+				t.Errorf("config = %q, %q, %q, want %q", config.DeadLetterURL, config.DeadLetterDir, config.DeadLetterAuthHeader, tt.env)
+			}
+		})
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer webhook.Close()
+
+	var dlqMethod, dlqAuth string
+	var dlqBody []byte
+	dlq := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dlqMethod = r.Method
+		dlqAuth = r.Header.Get("Authorization")
+		dlqBody, _ = io.ReadAll(r.Body)
+	}))
+	defer dlq.Close()
+
+	dir := t.TempDir()
+	config := &Config{
+		Targets:              []WebhookTarget{{URL: webhook.URL + "/hook?token=secret", AuthHeader: "Bearer webhook"}},
+		MethodByStatus:       map[string]string{"resolved": http.MethodDelete},
+		SuccessStatus:        []int{http.StatusAccepted},
+		TimeoutSeconds:       5,
+		DeadLetterURL:        dlq.URL,
+		DeadLetterAuthHeader: "Bearer dlq",
+		DeadLetterDir:        dir,
+	}
+	payload := WebhookPayload{AlertName: "DiskFull", Status: "resolved"}
+
+	var err error
+	output := captureLog(t, func() {
+		err = sendWebhook(context.Background(), config, payload)
+		if err != nil {
+			deadLetter(context.Background(), config, payload, err)
+		}
+	})
+	if err == nil {
+		t.Fatal("sendWebhook() expected an error")
+	}
+
+	// The URL receives the body with its own auth, even for a DELETE
+	if dlqMethod != http.MethodPost || dlqAuth != "Bearer dlq" {
+		t.Errorf("dead-letter request = %s with Authorization %q, want POST with %q", dlqMethod, dlqAuth, "Bearer dlq")
+	}
+	var received WebhookPayload
+	if err := json.Unmarshal(dlqBody, &received); err != nil || received.AlertName != "DiskFull" {
+		t.Errorf("dead-letter body = %s, want the payload", dlqBody)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-DiskFull-resolved.json"))
+	if len(files) != 1 {
+		t.Fatalf("dead-letter files = %v, want one\n%s", files, output)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read dead-letter file: %v", err)
+	}
+	var record DeadLetterRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("invalid dead-letter file: %v", err)
+	}
+	if !strings.Contains(record.Error, "503") || len(record.Requests) != 1 {
+		t.Errorf("dead-letter record = %+v", record)
+	}
+	if request := record.Requests[0]; strings.Contains(request.URL, "secret") || request.Headers["Authorization"] != "***" {
+		t.Errorf("dead-letter request is not redacted: %+v", request)
+	}
+}
+
+func TestDeadLetterFailureIsLogged(t *testing.T) {
+	dlq := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer dlq.Close()
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Targets:        []WebhookTarget{{URL: "https://example.com/hook"}},
+		TimeoutSeconds: 5,
+		DeadLetterURL:  dlq.URL,
+		DeadLetterDir:  filepath.Join(file, "dlq"),
+	}
+
+	output := captureLog(t, func() {
+		deadLetter(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}, errors.New("status 503"))
+	})
+	for _, want := range []string{"Failed to send alert to DEAD_LETTER_URL", "Failed to write alert to DEAD_LETTER_DIR"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in the logs, got:\n%s", want, output)
+		}
+	}
+}
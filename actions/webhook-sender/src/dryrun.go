@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// logDryRun logs the request that would have been sent to each target, in
+// the same shape as a file sink record, without contacting the webhook
+func logDryRun(config *Config, payload WebhookPayload) error {
+	records, err := buildSinkRecords(config, payload)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dry-run record: %w", err)
+		}
+		log.Printf("Dry run, would send: %s", payloadForLog(data, config.RedactFields))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogDryRun(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:      []WebhookTarget{{URL: server.URL + "/hook?token=abc", AuthHeader: "Bearer abc123"}},
+		RedactFields: []string{"token"},
+	}
+	payload := WebhookPayload{AlertName: "DiskFull", Status: "firing", Labels: map[string]string{"instance": "node-1", "token": "s3cret"}}
+
+	var err error
+	output := captureLog(t, func() { err = logDryRun(config, payload) })
+	if err != nil {
+		t.Fatalf("logDryRun() unexpected error: %v", err)
+	}
+	if called {
+		t.Error("dry run should not contact the webhook")
+	}
+
+	for _, want := range []string{`"method":"POST"`, `"url":"` + redactURL(server.URL) + `"`, `"instance":"node-1"`, `"token":"***"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %s, got: %s", want, output)
+		}
+	}
+	for _, secret := range []string{"s3cret", "abc123"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("output leaks %q: %s", secret, output)
+		}
+	}
+}
+
+func TestLoadConfigDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		dryRun  string
+		sink    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "unset", want: false},
+		{name: "enabled", dryRun: "true", want: true},
+		{name: "with SINK", dryRun: "true", sink: sinkFile, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", "https://example.com/hook")
+			t.Setenv("DRY_RUN", tt.dryRun)
+			t.Setenv("SINK", tt.sink)
+			t.Setenv("SINK_DIR", t.TempDir())
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.DryRun != tt.want {
+				t.Errorf("DryRun = %t, want %t", config.DryRun, tt.want)
+			}
+		})
+	}
+}
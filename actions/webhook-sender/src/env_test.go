@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		strict      bool
+		want        int
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "unset keeps default", value: "", want: 30},
+		{name: "valid", value: "45", want: 45},
+		{name: "surrounding whitespace", value: " 45 ", want: 45},
+		{name: "valid strict", value: "45", strict: true, want: 45},
+		{name: "malformed lenient keeps default", value: "30s", want: 30, wantWarning: true},
+		{name: "malformed strict", value: "30s", strict: true, want: 30, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIMEOUT_SECONDS", tt.value)
+
+			got := 30
+			var err error
+			output := captureLog(t, func() { err = envInt(tt.strict, "TIMEOUT_SECONDS", &got) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envInt() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "TIMEOUT_SECONDS '30s'") {
+				t.Errorf("error %q should name the variable and value", err)
+			}
+			if got != tt.want {
+				t.Errorf("value = %d, want %d", got, tt.want)
+			}
+			if gotWarning := strings.Contains(output, "Invalid TIMEOUT_SECONDS value '30s'"); gotWarning != tt.wantWarning {
+				t.Errorf("warning logged = %t, want %t (output: %s)", gotWarning, tt.wantWarning, output)
+			}
+		})
+	}
+}
+
+func TestEnvBool(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		strict  bool
+		want    bool
+		wantErr bool
+	}{
+		{name: "unset keeps default", value: "", want: false},
+		{name: "true", value: "true", want: true},
+		{name: "numeric", value: "1", strict: true, want: true},
+		{name: "malformed lenient keeps default", value: "yes", want: false},
+		{name: "malformed strict", value: "yes", strict: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_CONFIG", tt.value)
+
+			got := false
+			var err error
+			captureLog(t, func() { err = envBool(tt.strict, "LOG_CONFIG", &got) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envBool() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("value = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFloat(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		strict  bool
+		want    float64
+		wantErr bool
+	}{
+		{name: "unset keeps default", value: "", want: 0},
+		{name: "fraction", value: "0.5", want: 0.5},
+		{name: "integer", value: " 10 ", strict: true, want: 10},
+		{name: "malformed lenient keeps default", value: "10/s", want: 0},
+		{name: "malformed strict", value: "10/s", strict: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RATE_LIMIT_PER_SECOND", tt.value)
+
+			var got float64
+			var err error
+			captureLog(t, func() { err = envFloat(tt.strict, "RATE_LIMIT_PER_SECOND", &got) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envFloat() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("value = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigStrictEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  string
+		timeout string
+		wantErr string
+	}{
+		{name: "lenient by default", timeout: "30s"},
+		{name: "strict rejects malformed timeout", strict: "true", timeout: "30s", wantErr: "invalid TIMEOUT_SECONDS '30s'"},
+		{name: "strict accepts valid timeout", strict: "true", timeout: "30"},
+		{name: "malformed STRICT_ENV", strict: "on", wantErr: "invalid STRICT_ENV 'on'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", "https://example.com/hook")
+			t.Setenv("STRICT_ENV", tt.strict)
+			t.Setenv("TIMEOUT_SECONDS", tt.timeout)
+
+			var err error
+			captureLog(t, func() { _, err = loadConfig() })
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("loadConfig() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
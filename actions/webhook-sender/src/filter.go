@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+)
+
+// LABEL_FILTER_MODE values
+const (
+	filterModeDrop = "drop"
+	filterModeMask = "mask"
+)
+
+// keyFilter selects label or annotation keys by patterns that must match
+// the whole key. With include patterns only matching keys are kept, and
+// keys matching an exclude pattern are filtered either way.
+type keyFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// parseFilterConfig reads LABELS_INCLUDE, LABELS_EXCLUDE,
+// ANNOTATIONS_INCLUDE and ANNOTATIONS_EXCLUDE, comma-separated regular
+// expressions, and LABEL_FILTER_MODE, whether filtered keys are dropped or
+// sent with a masked value
+func parseFilterConfig(config *Config) error {
+	var err error
+	if config.LabelsInclude, config.LabelFilter.include, err = parseKeyPatterns("LABELS_INCLUDE"); err != nil {
+		return err
+	}
+	if config.LabelsExclude, config.LabelFilter.exclude, err = parseKeyPatterns("LABELS_EXCLUDE"); err != nil {
+		return err
+	}
+	if config.AnnotationsInclude, config.AnnotationFilter.include, err = parseKeyPatterns("ANNOTATIONS_INCLUDE"); err != nil {
+		return err
+	}
+	if config.AnnotationsExclude, config.AnnotationFilter.exclude, err = parseKeyPatterns("ANNOTATIONS_EXCLUDE"); err != nil {
+		return err
+	}
+
+	filtering := !config.LabelFilter.empty() || !config.AnnotationFilter.empty()
+	switch mode := os.Getenv("LABEL_FILTER_MODE"); mode {
+	case "":
+		config.LabelFilterMode = filterModeDrop
+	case filterModeDrop, filterModeMask:
+		config.LabelFilterMode = mode
+		if !filtering {
+			log.Println("Warning: LABEL_FILTER_MODE is ignored without LABELS_INCLUDE, LABELS_EXCLUDE, ANNOTATIONS_INCLUDE or ANNOTATIONS_EXCLUDE")
+		}
+	default:
+		return fmt.Errorf("unsupported LABEL_FILTER_MODE '%s', must be '%s' or '%s'", mode, filterModeDrop, filterModeMask)
+	}
+
+	// A passthrough body is forwarded as received, so it can't be filtered
+	if filtering && config.GroupMode == groupModePassthrough {
+		return fmt.Errorf("label and annotation filters cannot be combined with ALERT_GROUP_MODE=%s", groupModePassthrough)
+	}
+	return nil
+}
+
+// parseKeyPatterns compiles the comma-separated patterns in the named
+// variable, anchored so that each must match a whole key
+func parseKeyPatterns(name string) ([]string, []*regexp.Regexp, error) {
+	patterns := parseLabelList(os.Getenv(name))
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s pattern '%s': %w", name, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return patterns, compiled, nil
+}
+
+// empty reports whether the filter has no patterns at all
+func (f keyFilter) empty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// allows reports whether the key passes the filter
+func (f keyFilter) allows(key string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(key) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterKeys returns a copy of values without the keys the filter rejects,
+// or with their values masked
+func filterKeys(values map[string]string, filter keyFilter, mask bool) map[string]string {
+	if values == nil || filter.empty() {
+		return values
+	}
+
+	filtered := make(map[string]string, len(values))
+	for key, value := range values {
+		switch {
+		case filter.allows(key):
+			filtered[key] = value
+		case mask:
+			filtered[key] = redactedValue
+		}
+	}
+	return filtered
+}
+
+// filterAlert applies the label and annotation filters to the alert before
+// its payload is built, so fields derived from a filtered key, such as
+// severity from the severity label, are filtered too
+func filterAlert(config *Config, alertData *AlertData) {
+	mask := config.LabelFilterMode == filterModeMask
+	alertData.Labels = filterKeys(alertData.Labels, config.LabelFilter, mask)
+	alertData.Annotations = filterKeys(alertData.Annotations, config.AnnotationFilter, mask)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseFilterConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		groupMode string
+		wantMode  string
+		wantErr   string
+	}{
+		{name: "unset", env: map[string]string{}, wantMode: filterModeDrop},
+		{name: "exclude with mask", env: map[string]string{"LABELS_EXCLUDE": "internal_.*,token", "LABEL_FILTER_MODE": "mask"}, wantMode: filterModeMask},
+		{name: "invalid pattern", env: map[string]string{"ANNOTATIONS_INCLUDE": "summary,(runbook"}, wantErr: "invalid ANNOTATIONS_INCLUDE pattern '(runbook'"},
+		{name: "invalid mode", env: map[string]string{"LABELS_EXCLUDE": "token", "LABEL_FILTER_MODE": "hash"}, wantErr: "unsupported LABEL_FILTER_MODE 'hash'"},
+		{name: "passthrough", env: map[string]string{"LABELS_INCLUDE": "alertname"}, groupMode: groupModePassthrough, wantErr: "ALERT_GROUP_MODE=passthrough"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"LABELS_INCLUDE", "LABELS_EXCLUDE", "ANNOTATIONS_INCLUDE", "ANNOTATIONS_EXCLUDE", "LABEL_FILTER_MODE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{GroupMode: tt.groupMode}
+			err := parseFilterConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFilterConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFilterConfig() unexpected error: %v", err)
+			}
+			if config.LabelFilterMode != tt.wantMode {
+				t.Errorf("LabelFilterMode = %q, want %q", config.LabelFilterMode, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestFilterKeys(t *testing.T) {
+	labels := map[string]string{"alertname": "DiskFull", "severity": "critical", "internal_team": "sre", "token": "abc123", "tokens": "5"}
+
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		mask    bool
+		want    map[string]string
+	}{
+		{name: "no patterns", want: labels},
+		{
+			name:    "exclude matches whole keys",
+			exclude: "internal_.*,token",
+			want:    map[string]string{"alertname": "DiskFull", "severity": "critical", "tokens": "5"},
+		},
+		{
+			name:    "exclude masked",
+			exclude: "internal_.*,token",
+			mask:    true,
+			want:    map[string]string{"alertname": "DiskFull", "severity": "critical", "internal_team": "***", "token": "***", "tokens": "5"},
+		},
+		{
+			name:    "include",
+			include: "alertname,severity",
+			want:    map[string]string{"alertname": "DiskFull", "severity": "critical"},
+		},
+		{
+			name:    "exclude wins over include",
+			include: "alertname,severity",
+			exclude: "severity",
+			want:    map[string]string{"alertname": "DiskFull"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LABELS_INCLUDE", tt.include)
+			t.Setenv("LABELS_EXCLUDE", tt.exclude)
+			config := &Config{}
+			if err := parseFilterConfig(config); err != nil {
+				t.Fatalf("parseFilterConfig() unexpected error: %v", err)
+			}
+
+			got := filterKeys(labels, config.LabelFilter, tt.mask)
+			assertStringMap(t, "labels", got, tt.want)
+		})
+	}
+	if len(labels) != 5 {
+		t.Errorf("filterKeys() modified its input: %v", labels)
+	}
+}
+
+func TestHandleAlertFiltersLabels(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	t.Setenv("LABELS_EXCLUDE", "instance,internal_.*")
+	t.Setenv("ANNOTATIONS_INCLUDE", "summary")
+	config := &Config{Targets: []WebhookTarget{{URL: server.URL}}, TimeoutSeconds: 5}
+	if err := parseFilterConfig(config); err != nil {
+		t.Fatalf("parseFilterConfig() unexpected error: %v", err)
+	}
+
+	alertData := AlertData{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "DiskFull", "instance": "10.0.0.12:9100", "internal_owner": "jdoe"},
+		Annotations: map[string]string{"summary": "Disk full", "debug_url": "http://10.0.0.12/debug"},
+	}
+	var err error
+	captureLog(t, func() { err = handleAlert(context.Background(), config, alertData) })
+	if err != nil {
+		t.Fatalf("handleAlert() unexpected error: %v", err)
+	}
+
+	if !strings.Contains(body, `"summary":"Disk full"`) {
+		t.Errorf("body = %s, want the included summary", body)
+	}
+	for _, leaked := range []string{"10.0.0.12", "jdoe", "debug_url"} {
+		if strings.Contains(body, leaked) {
+			t.Errorf("body leaked filtered %q: %s", leaked, body)
+		}
+	}
+}
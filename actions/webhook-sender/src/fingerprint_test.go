@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestAlertFingerprint(t *testing.T) {
+	labels := map[string]string{"alertname": "DiskFull", "instance": "node-1", "severity": "critical"}
+	// The same label set built in a different order
+	reordered := map[string]string{}
+	for _, key := range []string{"severity", "instance", "alertname"} {
+		reordered[key] = labels[key]
+	}
+
+	computed := alertFingerprint("", labels, true)
+	if len(computed) != 16 {
+		t.Errorf("alertFingerprint() = %q, want 16 hex characters like Alertmanager", computed)
+	}
+	if got := alertFingerprint("", reordered, true); got != computed {
+		t.Errorf("identical label sets gave %s and %s", computed, got)
+	}
+	if got := alertFingerprint("", map[string]string{"alertname": "DiskFull", "instance": "node-2"}, true); got == computed {
+		t.Errorf("different label sets share fingerprint %s", got)
+	}
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		labels      map[string]string
+		compute     bool
+		want        string
+	}{
+		{name: "alertmanager fingerprint kept", fingerprint: "c2a4d9e6b1f07a35", labels: labels, compute: true, want: "c2a4d9e6b1f07a35"},
+		{name: "computed from labels", labels: labels, compute: true, want: computed},
+		{name: "computing disabled", labels: labels},
+		{name: "no labels", compute: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alertFingerprint(tt.fingerprint, tt.labels, tt.compute); got != tt.want {
+				t.Errorf("alertFingerprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
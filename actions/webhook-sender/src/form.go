@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// formContentType is the media type of a WEBHOOK_FORMAT=form body
+const formContentType = "application/x-www-form-urlencoded"
+
+// formValues flattens the payload into form fields named like the
+// QUERY_PARAM_FIELDS paths: alertName, severity, labels.instance,
+// annotations.summary and so on. Empty fields are left out.
+func formValues(payload WebhookPayload) url.Values {
+	values := url.Values{}
+	for _, field := range payloadFields {
+		if value := extractPayloadField(payload, field); value != "" {
+			values.Set(field, value)
+		}
+	}
+	for key, value := range payload.Labels {
+		values.Set("labels."+key, value)
+	}
+	for key, value := range payload.Annotations {
+		values.Set("annotations."+key, value)
+	}
+	if payload.Truncated {
+		values.Set("truncated", strconv.FormatBool(payload.Truncated))
+	}
+	return values
+}
+
+// encodeForm returns the payload as an application/x-www-form-urlencoded
+// body, for legacy endpoints that don't accept JSON. The returned header
+// carries the Content-Type to send.
+func encodeForm(payload WebhookPayload) ([]byte, http.Header) {
+	header := http.Header{}
+	header.Set("Content-Type", formContentType)
+	return []byte(formValues(payload).Encode()), header
+}
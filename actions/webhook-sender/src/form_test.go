@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestFormValues(t *testing.T) {
+	payload := WebhookPayload{
+		AlertName:   "DiskFull",
+		Status:      "firing",
+		Severity:    "critical",
+		Labels:      map[string]string{"instance": "node-1"},
+		Annotations: map[string]string{"runbook": "https://runbooks.example.com/disk"},
+	}
+
+	got := formValues(payload)
+	want := url.Values{
+		"alertName":           {"DiskFull"},
+		"status":              {"firing"},
+		"severity":            {"critical"},
+		"labels.instance":     {"node-1"},
+		"annotations.runbook": {"https://runbooks.example.com/disk"},
+	}
+	if got.Encode() != want.Encode() {
+		t.Errorf("formValues() = %s, want %s", got.Encode(), want.Encode())
+	}
+}
+
+func TestSendWebhookForm(t *testing.T) {
+	var contentType string
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		r.ParseForm()
+		form = r.PostForm
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:        []WebhookTarget{{URL: server.URL}},
+		WebhookFormat:  webhookFormatForm,
+		TimeoutSeconds: 5,
+	}
+	payload := WebhookPayload{AlertName: "DiskFull", Status: "firing", Summary: "Disk 95% full on node-1"}
+
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, payload) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if contentType != formContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, formContentType)
+	}
+	if form.Get("alertName") != "DiskFull" || form.Get("summary") != payload.Summary {
+		t.Errorf("form = %v, want the payload fields", form)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// webhookFormatGraphQL sends the alert as the variables of a GraphQL
+// mutation
+const webhookFormatGraphQL = "graphql"
+
+// graphQLRequest is the body of a GraphQL request over HTTP
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLResponse is the part of a GraphQL response that reports errors
+type graphQLResponse struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// parseGraphQLConfig reads GRAPHQL_QUERY or GRAPHQL_QUERY_FILE, the mutation
+// sent with WEBHOOK_FORMAT=graphql, the optional GRAPHQL_OPERATION_NAME and
+// GRAPHQL_VARIABLES, a comma-separated list of variable=field pairs. The
+// request is the body, so it cannot be combined with a body template.
+func parseGraphQLConfig(config *Config) error {
+	query, file := os.Getenv("GRAPHQL_QUERY"), os.Getenv("GRAPHQL_QUERY_FILE")
+	operationName, variables := os.Getenv("GRAPHQL_OPERATION_NAME"), os.Getenv("GRAPHQL_VARIABLES")
+	if config.WebhookFormat != webhookFormatGraphQL {
+		if query != "" || file != "" || operationName != "" || variables != "" {
+			return fmt.Errorf("GRAPHQL_QUERY, GRAPHQL_QUERY_FILE, GRAPHQL_OPERATION_NAME and GRAPHQL_VARIABLES require WEBHOOK_FORMAT=%s", webhookFormatGraphQL)
+		}
+		return nil
+	}
+	if config.BodyTemplate != nil {
+		return fmt.Errorf("WEBHOOK_FORMAT=%s cannot be combined with a body template", webhookFormatGraphQL)
+	}
+
+	if query != "" && file != "" {
+		return fmt.Errorf("GRAPHQL_QUERY and GRAPHQL_QUERY_FILE are mutually exclusive")
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read GRAPHQL_QUERY_FILE: %w", err)
+		}
+		query = string(data)
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("GRAPHQL_QUERY or GRAPHQL_QUERY_FILE is required when WEBHOOK_FORMAT=%s", webhookFormatGraphQL)
+	}
+
+	fields, err := parseFieldMap("GRAPHQL_VARIABLES", "variable", variables)
+	if err != nil {
+		return err
+	}
+	config.GraphQLQuery = query
+	config.GraphQLOperationName = operationName
+	config.GraphQLVariables = fields
+	return nil
+}
+
+// encodeGraphQL builds the GraphQL request for the payload. The variables
+// are the GRAPHQL_VARIABLES fields, or the whole payload as $alert when
+// none are configured.
+func encodeGraphQL(config *Config, payload WebhookPayload) ([]byte, error) {
+	variables := map[string]interface{}{}
+	if len(config.GraphQLVariables) == 0 {
+		variables["alert"] = payload
+	}
+	for name, field := range config.GraphQLVariables {
+		variables[name] = extractPayloadField(payload, field)
+	}
+
+	data, err := json.Marshal(graphQLRequest{
+		Query:         config.GraphQLQuery,
+		OperationName: config.GraphQLOperationName,
+		Variables:     variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal GraphQL request: %w", err)
+	}
+	return data, nil
+}
+
+// checkGraphQLResponse fails a response that reports errors. GraphQL
+// servers answer 200 even when the mutation failed, so the errors array is
+// the only sign of it. Like other errors reported in the body, they are
+// retried.
+func checkGraphQLResponse(body []byte) error {
+	var response graphQLResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return &assertionError{err: fmt.Errorf("response body is not a GraphQL response: %w", err)}
+	}
+	if len(response.Errors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(response.Errors))
+	for i, graphQLErr := range response.Errors {
+		messages[i] = graphQLErr.Message
+	}
+	return &assertionError{err: fmt.Errorf("GraphQL errors: %s", strings.Join(messages, "; "))}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMutation = `mutation Raise($name: String!, $host: String) { raiseIncident(name: $name, host: $host) { id } }`
+
+func TestParseGraphQLConfig(t *testing.T) {
+	queryFile := filepath.Join(t.TempDir(), "mutation.graphql")
+	if err := os.WriteFile(queryFile, []byte(testMutation), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	template, _ := parseBodyTemplate("{}", "")
+
+	tests := []struct {
+		name          string
+		format        string
+		env           map[string]string
+		template      bool
+		wantVariables map[string]string
+		wantErr       string
+	}{
+		{name: "other format", format: webhookFormatKaro, env: map[string]string{}},
+		{name: "query", format: webhookFormatGraphQL, env: map[string]string{"GRAPHQL_QUERY": testMutation}, wantVariables: map[string]string{}},
+		{name: "query file and variables", format: webhookFormatGraphQL, env: map[string]string{"GRAPHQL_QUERY_FILE": queryFile, "GRAPHQL_VARIABLES": "name=alertName, host=labels.instance"}, wantVariables: map[string]string{"name": "alertName", "host": "labels.instance"}},
+		{name: "missing query", format: webhookFormatGraphQL, env: map[string]string{}, wantErr: "GRAPHQL_QUERY or GRAPHQL_QUERY_FILE is required"},
+		{name: "query and file", format: webhookFormatGraphQL, env: map[string]string{"GRAPHQL_QUERY": testMutation, "GRAPHQL_QUERY_FILE": queryFile}, wantErr: "mutually exclusive"},
+		{name: "unknown field", format: webhookFormatGraphQL, env: map[string]string{"GRAPHQL_QUERY": testMutation, "GRAPHQL_VARIABLES": "name=alert"}, wantErr: "unknown payload field"},
+		{name: "with template", format: webhookFormatGraphQL, env: map[string]string{"GRAPHQL_QUERY": testMutation}, template: true, wantErr: "body template"},
+		{name: "query without format", format: webhookFormatKaro, env: map[string]string{"GRAPHQL_QUERY": testMutation}, wantErr: "require WEBHOOK_FORMAT=graphql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"GRAPHQL_QUERY", "GRAPHQL_QUERY_FILE", "GRAPHQL_OPERATION_NAME", "GRAPHQL_VARIABLES"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{WebhookFormat: tt.format}
+			if tt.template {
+				config.BodyTemplate = template
+			}
+			err := parseGraphQLConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseGraphQLConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGraphQLConfig() unexpected error: %v", err)
+			}
+			if tt.wantVariables != nil && config.GraphQLQuery != testMutation {
+				t.Errorf("GraphQLQuery = %q, want %q", config.GraphQLQuery, testMutation)
+			}
+			assertStringMap(t, "GraphQLVariables", config.GraphQLVariables, tt.wantVariables)
+		})
+	}
+}
+
+func TestEncodeGraphQL(t *testing.T) {
+	payload := WebhookPayload{AlertName: "DiskFull", Status: "firing", Labels: map[string]string{"instance": "node-1"}}
+	tests := []struct {
+		name      string
+		config    *Config
+		wantVars  string
+		wantOpKey bool
+	}{
+		{name: "whole payload", config: &Config{GraphQLQuery: testMutation}, wantVars: `"alert":{"alertName":"DiskFull"`},
+		{name: "variables", config: &Config{GraphQLQuery: testMutation, GraphQLOperationName: "Raise", GraphQLVariables: map[string]string{"name": "alertName", "host": "labels.instance"}}, wantVars: `{"host":"node-1","name":"DiskFull"}`, wantOpKey: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := encodeGraphQL(tt.config, payload)
+			if err != nil {
+				t.Fatalf("encodeGraphQL() unexpected error: %v", err)
+			}
+			var request map[string]json.RawMessage
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Fatalf("invalid request JSON: %v", err)
+			}
+			var query string
+			json.Unmarshal(request["query"], &query)
+			if query != testMutation {
+				t.Errorf("query = %q", query)
+			}
+			if !strings.Contains(string(request["variables"]), tt.wantVars) {
+				t.Errorf("variables = %s, want them to contain %s", request["variables"], tt.wantVars)
+			}
+			if _, ok := request["operationName"]; ok != tt.wantOpKey {
+				t.Errorf("operationName present = %t, want %t", ok, tt.wantOpKey)
+			}
+		})
+	}
+}
+
+func TestSendWebhookGraphQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []string
+		wantRequests int
+		wantErr      string
+	}{
+		{name: "data", responses: []string{`{"data": {"raiseIncident": {"id": "1"}}}`}, wantRequests: 1},
+		{name: "errors are retried", responses: []string{`{"errors": [{"message": "backend timeout"}]}`, `{"data": {}}`}, wantRequests: 2},
+		{name: "errors fail the delivery", responses: []string{`{"data": null, "errors": [{"message": "name is invalid"}, {"message": "host is unknown"}]}`}, wantRequests: 2, wantErr: "GraphQL errors: name is invalid; host is unknown"},
+		{name: "not a GraphQL response", responses: []string{`ok`}, wantRequests: 2, wantErr: "not a GraphQL response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			var contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				contentType = r.Header.Get("Content-Type")
+				w.Write([]byte(tt.responses[min(requests, len(tt.responses)-1)]))
+				requests++
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:           []WebhookTarget{{URL: server.URL}},
+				WebhookFormat:     webhookFormatGraphQL,
+				GraphQLQuery:      testMutation,
+				TimeoutSeconds:    5,
+				RetryCount:        1,
+				RetryBackoffMs:    1,
+				RetryMaxBackoffMs: 5,
+			}
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("sendWebhook() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", requests, tt.wantRequests)
+			}
+			if contentType != defaultContentType {
+				t.Errorf("Content-Type = %q, want %q", contentType, defaultContentType)
+			}
+		})
+	}
+}
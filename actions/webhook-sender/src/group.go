@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+)
+
+// ALERT_GROUP_MODE values
+const (
+	groupModeFirst       = "first"
+	groupModePassthrough = "passthrough"
+	groupModePerAlert    = "per-alert"
+)
+
+// AlertGroup is an Alertmanager webhook notification: the alerts of one
+// group together with the labels and annotations they share
+type AlertGroup struct {
+	Status            string            `json:"status"`
+	Alerts            []AlertData       `json:"alerts"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+}
+
+// parseGroupMode reads ALERT_GROUP_MODE, how an ALERT_JSON with an alerts
+// array is handled. Passthrough forwards the document as it is, so it
+// cannot be combined with a body template, form or GraphQL encoding.
+func parseGroupMode(config *Config) error {
+	switch mode := os.Getenv("ALERT_GROUP_MODE"); mode {
+	case "":
+		config.GroupMode = groupModeFirst
+	case groupModeFirst, groupModePassthrough, groupModePerAlert:
+		config.GroupMode = mode
+	default:
+		return fmt.Errorf("unsupported ALERT_GROUP_MODE '%s', must be '%s', '%s' or '%s'", mode, groupModeFirst, groupModePassthrough, groupModePerAlert)
+	}
+
+	if config.GroupMode == groupModePassthrough {
+		if config.BodyTemplate != nil {
+			return fmt.Errorf("ALERT_GROUP_MODE=%s cannot be combined with a body template", groupModePassthrough)
+		}
+		if config.WebhookFormat == webhookFormatForm || config.WebhookFormat == webhookFormatGraphQL {
+			return fmt.Errorf("ALERT_GROUP_MODE=%s cannot be combined with WEBHOOK_FORMAT=%s", groupModePassthrough, config.WebhookFormat)
+		}
+	}
+	return nil
+}
+
+// parseAlertInput decodes ALERT_JSON into the alerts to handle. A single
+// alert is returned as it is. For an Alertmanager group, every alert
+// inherits the common labels and annotations and the group status it
+// doesn't set itself; per-alert mode returns all of them, the other modes
+// only the first. Passthrough also keeps the document as the request body.
+// A document that fails to parse is logged and handled as an empty alert.
+func parseAlertInput(config *Config, alertJSON string) []AlertData {
+	if alertJSON == "" {
+		return []AlertData{{}}
+	}
+
+	var probe struct {
+		Alerts json.RawMessage `json:"alerts"`
+	}
+	if err := json.Unmarshal([]byte(alertJSON), &probe); err != nil || probe.Alerts == nil {
+		var alertData AlertData
+		if err := json.Unmarshal([]byte(alertJSON), &alertData); err != nil {
+			log.Printf("Warning: Failed to parse ALERT_JSON: %v", err)
+			return []AlertData{alertData}
+		}
+		if config.GroupMode == groupModePassthrough {
+			config.PassthroughBody = json.RawMessage(alertJSON)
+		}
+		return []AlertData{alertData}
+	}
+
+	var group AlertGroup
+	if err := json.Unmarshal([]byte(alertJSON), &group); err != nil {
+		log.Printf("Warning: Failed to parse ALERT_JSON: %v", err)
+		return []AlertData{{}}
+	}
+	if len(group.Alerts) == 0 {
+		log.Println("ALERT_JSON contains no alerts, nothing to send")
+		return nil
+	}
+
+	alerts := make([]AlertData, len(group.Alerts))
+	for i, alertData := range group.Alerts {
+		alerts[i] = groupAlert(group, alertData)
+	}
+
+	switch config.GroupMode {
+	case groupModePerAlert:
+		log.Printf("ALERT_JSON contains %d alerts, handling each (ALERT_GROUP_MODE=%s)", len(alerts), groupModePerAlert)
+		return alerts
+	case groupModePassthrough:
+		// The group as a whole is routed by its own status, which is
+		// firing while any of its alerts is
+		config.PassthroughBody = json.RawMessage(alertJSON)
+		if group.Status != "" {
+			alerts[0].Status = group.Status
+		}
+	default:
+		if len(alerts) > 1 {
+			log.Printf("Warning: ALERT_JSON contains %d alerts, handling only the first (ALERT_GROUP_MODE=%s)", len(alerts), groupModeFirst)
+		}
+	}
+	return alerts[:1]
+}
+
+// groupAlert fills in what an alert of the group doesn't set itself: the
+// common labels and annotations and the group status
+func groupAlert(group AlertGroup, alertData AlertData) AlertData {
+	if len(group.CommonLabels) > 0 {
+		labels := maps.Clone(group.CommonLabels)
+		maps.Copy(labels, alertData.Labels)
+		alertData.Labels = labels
+	}
+	if len(group.CommonAnnotations) > 0 {
+		annotations := maps.Clone(group.CommonAnnotations)
+		maps.Copy(annotations, alertData.Annotations)
+		alertData.Annotations = annotations
+	}
+	if alertData.Status == "" {
+		alertData.Status = group.Status
+	}
+	return alertData
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// groupJSON is an Alertmanager notification for a group of two alerts
+const groupJSON = `{
+	"status": "firing",
+	"groupLabels": {"alertname": "DiskFull"},
+	"commonLabels": {"alertname": "DiskFull", "severity": "warning"},
+	"commonAnnotations": {"runbook": "https://runbooks.example.com/disk"},
+	"alerts": [
+		{"status": "firing", "labels": {"alertname": "DiskFull", "instance": "node-1", "severity": "critical"}},
+		{"status": "resolved", "labels": {"alertname": "DiskFull", "instance": "node-2"}}
+	]
+}`
+
+func TestParseGroupMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		format   string
+		template bool
+		want     string
+		wantErr  string
+	}{
+		{name: "default", want: groupModeFirst},
+		{name: "per-alert", mode: "per-alert", want: groupModePerAlert},
+		{name: "passthrough", mode: "passthrough", want: groupModePassthrough},
+		{name: "passthrough in a CloudEvent", mode: "passthrough", format: webhookFormatCloudEvents, want: groupModePassthrough},
+		{name: "unsupported", mode: "all", wantErr: "unsupported ALERT_GROUP_MODE 'all'"},
+		{name: "passthrough with template", mode: "passthrough", template: true, wantErr: "body template"},
+		{name: "passthrough as form", mode: "passthrough", format: webhookFormatForm, wantErr: "WEBHOOK_FORMAT=form"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALERT_GROUP_MODE", tt.mode)
+			config := &Config{WebhookFormat: tt.format}
+			if tt.template {
+				config.BodyTemplate = template.Must(template.New("body").Parse(`{{ .AlertName }}`))
+			}
+
+			err := parseGroupMode(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseGroupMode() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGroupMode() unexpected error: %v", err)
+			}
+			if config.GroupMode != tt.want {
+				t.Errorf("GroupMode = %q, want %q", config.GroupMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAlertInput(t *testing.T) {
+	tests := []struct {
+		name            string
+		mode            string
+		alertJSON       string
+		wantInstances   []string
+		wantStatus      string
+		wantPassthrough bool
+		wantLog         string
+	}{
+		{name: "no alert", mode: groupModeFirst, wantInstances: []string{""}},
+		{name: "single alert", mode: groupModeFirst, alertJSON: `{"status":"firing","labels":{"instance":"node-1"}}`, wantInstances: []string{"node-1"}, wantStatus: "firing"},
+		{name: "invalid JSON", mode: groupModeFirst, alertJSON: `{"labels":`, wantInstances: []string{""}, wantLog: "Failed to parse ALERT_JSON"},
+		{name: "group first", mode: groupModeFirst, alertJSON: groupJSON, wantInstances: []string{"node-1"}, wantStatus: "firing", wantLog: "handling only the first"},
+		{name: "group per alert", mode: groupModePerAlert, alertJSON: groupJSON, wantInstances: []string{"node-1", "node-2"}, wantStatus: "firing"},
+		{name: "group passthrough", mode: groupModePassthrough, alertJSON: groupJSON, wantInstances: []string{"node-1"}, wantStatus: "firing", wantPassthrough: true},
+		{name: "single alert passthrough", mode: groupModePassthrough, alertJSON: `{"labels":{"instance":"node-1"}}`, wantInstances: []string{"node-1"}, wantPassthrough: true},
+		{name: "empty group", mode: groupModePerAlert, alertJSON: `{"status":"resolved","alerts":[]}`, wantLog: "contains no alerts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{GroupMode: tt.mode}
+			var alerts []AlertData
+			output := captureLog(t, func() { alerts = parseAlertInput(config, tt.alertJSON) })
+
+			if len(alerts) != len(tt.wantInstances) {
+				t.Fatalf("parseAlertInput() = %+v, want instances %v", alerts, tt.wantInstances)
+			}
+			for i, instance := range tt.wantInstances {
+				if alerts[i].Labels["instance"] != instance {
+					t.Errorf("alert %d instance = %q, want %q", i, alerts[i].Labels["instance"], instance)
+				}
+			}
+			if len(alerts) > 0 && alerts[0].Status != tt.wantStatus {
+				t.Errorf("first alert status = %q, want %q", alerts[0].Status, tt.wantStatus)
+			}
+			if got := config.PassthroughBody != nil; got != tt.wantPassthrough {
+				t.Errorf("passthrough body kept = %t, want %t", got, tt.wantPassthrough)
+			}
+			if tt.wantLog != "" && !strings.Contains(output, tt.wantLog) {
+				t.Errorf("log missing %q: %s", tt.wantLog, output)
+			}
+		})
+	}
+}
+
+func TestParseAlertInputInheritsCommonLabels(t *testing.T) {
+	var alerts []AlertData
+	captureLog(t, func() { alerts = parseAlertInput(&Config{GroupMode: groupModePerAlert}, groupJSON) })
+	if len(alerts) != 2 {
+		t.Fatalf("parseAlertInput() returned %d alerts, want 2", len(alerts))
+	}
+
+	// The alert's own labels win over the common ones
+	if got := alerts[0].Labels["severity"]; got != "critical" {
+		t.Errorf("first alert severity = %q, want its own critical", got)
+	}
+	if got := alerts[1].Labels["severity"]; got != "warning" {
+		t.Errorf("second alert severity = %q, want the common warning", got)
+	}
+	if got := alerts[1].Annotations["runbook"]; got != "https://runbooks.example.com/disk" {
+		t.Errorf("second alert runbook = %q, want the common annotation", got)
+	}
+	if alerts[1].Status != "resolved" {
+		t.Errorf("second alert status = %q, want its own resolved", alerts[1].Status)
+	}
+}
+
+func TestSendWebhookPassthrough(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+	defer server.Close()
+
+	config := &Config{Targets: []WebhookTarget{{URL: server.URL}}, GroupMode: groupModePassthrough, TimeoutSeconds: 5}
+	alerts := parseAlertInput(config, groupJSON)
+	if string(config.PassthroughBody) != groupJSON {
+		t.Fatalf("passthrough body = %s, want the group as received", config.PassthroughBody)
+	}
+
+	var err error
+	captureLog(t, func() { err = handleAlert(context.Background(), config, alerts[0]) })
+	if err != nil {
+		t.Fatalf("handleAlert() unexpected error: %v", err)
+	}
+	if received != groupJSON {
+		t.Errorf("received body = %s, want the group as received", received)
+	}
+}
+
+func TestHandleAlertRoutesEachAlert(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:            []WebhookTarget{{URL: server.URL + "/alerts"}},
+		WebhookURLResolved: server.URL + "/resolved",
+		GroupMode:          groupModePerAlert,
+		TimeoutSeconds:     5,
+	}
+
+	// Routing one alert must not change where the next one goes
+	var alerts []AlertData
+	captureLog(t, func() { alerts = parseAlertInput(config, groupJSON) })
+	for _, alertData := range alerts {
+		var err error
+		captureLog(t, func() { err = handleAlert(context.Background(), config, alertData) })
+		if err != nil {
+			t.Fatalf("handleAlert() unexpected error: %v", err)
+		}
+	}
+	if got := strings.Join(paths, ","); got != "/alerts,/resolved" {
+		t.Errorf("request paths = %s, want /alerts,/resolved", got)
+	}
+	if config.Targets[0].URL != server.URL+"/alerts" {
+		t.Errorf("handleAlert() modified the configured targets: %+v", config.Targets)
+	}
+}
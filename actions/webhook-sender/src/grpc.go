@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// URL schemes of targets that are delivered to over gRPC, in plaintext or
+// with TLS
+const (
+	schemeGRPC  = "grpc"
+	schemeGRPCS = "grpcs"
+)
+
+// grpcNotifyMethod is the RPC gRPC targets are called with, see
+// alert/alert.proto
+const grpcNotifyMethod = "/karo.alert.v1.AlertNotification/Notify"
+
+// isGRPCTarget reports whether the target is delivered to over gRPC
+func isGRPCTarget(target WebhookTarget) bool {
+	return strings.HasPrefix(target.URL, schemeGRPC+"://") || strings.HasPrefix(target.URL, schemeGRPCS+"://")
+}
+
+// validateGRPCTargets checks that the settings only HTTP targets support
+// are not combined with gRPC targets, which always receive the Alert
+// message of alert/alert.proto, among the targets and the routes of
+// ROUTES_JSON. It must run after the body, method and auth settings are
+// parsed.
+func validateGRPCTargets(config *Config) error {
+	targets := slices.Clone(config.Targets)
+	for _, route := range config.Routes {
+		targets = append(targets, route.WebhookTarget)
+	}
+	for _, target := range targets {
+		if !isGRPCTarget(target) {
+			continue
+		}
+		u, err := url.Parse(target.URL)
+		if err != nil || u.Host == "" || u.Port() == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("invalid gRPC target URL '%s', must be %s://host:port or %s://host:port", redactURL(target.URL), schemeGRPC, schemeGRPCS)
+		}
+
+		switch {
+		case config.BodyTemplate != nil:
+			return fmt.Errorf("gRPC targets cannot be combined with a body template or WEBHOOK_PRESET")
+		case config.WebhookFormat != "" && config.WebhookFormat != webhookFormatKaro:
+			return fmt.Errorf("gRPC targets cannot be combined with WEBHOOK_FORMAT=%s", config.WebhookFormat)
+		case config.GroupMode == groupModePassthrough:
+			return fmt.Errorf("gRPC targets cannot be combined with ALERT_GROUP_MODE=%s", groupModePassthrough)
+		case (config.HTTPMethod != "" && config.HTTPMethod != http.MethodPost) || len(config.MethodByStatus) > 0 || len(config.QueryParamFields) > 0:
+			return fmt.Errorf("gRPC targets cannot be combined with HTTP_METHOD, METHOD_BY_STATUS or QUERY_PARAM_FIELDS")
+		case config.SigV4 != nil:
+			return fmt.Errorf("gRPC targets cannot be combined with SIGV4_REGION")
+		}
+	}
+	return nil
+}
+
+// encodeAlert encodes the payload as the Alert message of alert/alert.proto.
+// Map entries are sorted by key so the same alert always encodes to the
+// same bytes, which are hashed and signed like an HTTP body.
+func encodeAlert(payload WebhookPayload) []byte {
+	var b []byte
+	for _, field := range []struct {
+		number protowire.Number
+		value  string
+	}{
+		{1, payload.AlertName},
+		{2, payload.Status},
+		{3, payload.Severity},
+		{4, payload.Instance},
+		{5, payload.Summary},
+		{6, payload.Description},
+	} {
+		b = appendStringField(b, field.number, field.value)
+	}
+	b = appendMapField(b, 7, payload.Labels)
+	b = appendMapField(b, 8, payload.Annotations)
+	b = appendStringField(b, 9, payload.StartsAt)
+	b = appendStringField(b, 10, payload.EndsAt)
+	b = appendStringField(b, 11, payload.Fingerprint)
+	b = appendStringField(b, 12, payload.Timestamp)
+	if payload.Truncated {
+		b = protowire.AppendTag(b, 13, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+// appendStringField appends a proto3 string field, which is omitted when
+// empty
+func appendStringField(b []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// appendMapField appends a map<string, string> field, one entry message
+// with the key as field 1 and the value as field 2 per key
+func appendMapField(b []byte, number protowire.Number, values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var entry []byte
+		entry = appendStringField(entry, 1, key)
+		entry = appendStringField(entry, 2, values[key])
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// rawCodec passes already encoded messages through, so the Alert message
+// needs no generated code. It keeps the name of the proto codec, so
+// receivers decode the messages with their generated types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *message, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*message = append((*message)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// sendGRPC calls Notify on a gRPC target with the encoded Alert message.
+// HEADERS_JSON, the idempotency key, the content hash and signature and
+// the Authorization header are sent as metadata. grpcs targets use the
+// same CA bundle, client certificate and minimum TLS version as HTTPS.
+func sendGRPC(ctx context.Context, config *Config, target WebhookTarget, header http.Header, message []byte) error {
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse gRPC target URL: %w", err)
+	}
+	transportCredentials := insecure.NewCredentials()
+	if u.Scheme == schemeGRPCS {
+		transportCredentials = credentials.NewTLS(newTLSConfig(config))
+	}
+	conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+	defer conn.Close()
+
+	md := metadata.MD{}
+	for name, value := range config.Headers {
+		md.Set(name, value)
+	}
+	for name, values := range header {
+		md.Set(name, values...)
+	}
+	md.Set(alert.ContentHashHeader, alert.ContentHash(message))
+	if config.SigningSecret != "" {
+		md.Set(getValueWithFallback(config.SignatureHeader, alert.SignatureHeader), alert.Sign(message, config.SigningSecret))
+	}
+	if target.AuthHeader != "" {
+		md.Set("authorization", target.AuthHeader)
+	} else if config.OAuthTokenSource != nil {
+		token, err := config.OAuthTokenSource.Token()
+		if err != nil {
+			return fmt.Errorf("failed to get OAuth2 token: %w", err)
+		}
+		md.Set("authorization", token.Type()+" "+token.AccessToken)
+	}
+
+	if target.Name != "" {
+		log.Printf("Sending alert to gRPC target %s: %s", target.Name, redactURL(target.URL))
+	} else {
+		log.Printf("Sending alert over gRPC to: %s", redactURL(target.URL))
+	}
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+	var response []byte
+	if err := conn.Invoke(ctx, grpcNotifyMethod, &message, &response, grpc.ForceCodec(rawCodec{})); err != nil {
+		return fmt.Errorf("gRPC call failed: %w", err)
+	}
+	return nil
+}
+
+// isRetryableGRPCError reports whether a gRPC call failed with a status that
+// may succeed on another attempt
+func isRetryableGRPCError(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
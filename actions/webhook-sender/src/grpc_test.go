@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeAlert decodes an encoded Alert message into its string fields by
+// number, map fields as key=value entries and the truncated flag
+func decodeAlert(t *testing.T, message []byte) (map[protowire.Number][]string, bool) {
+	t.Helper()
+	fields := map[protowire.Number][]string{}
+	truncated := false
+	for len(message) > 0 {
+		number, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+		if typ == protowire.VarintType {
+			value, n := protowire.ConsumeVarint(message)
+			truncated = number == 13 && value == 1
+			message = message[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(message)
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", number, protowire.ParseError(n))
+		}
+		message = message[n:]
+		if number == 7 || number == 8 {
+			entry, _ := decodeAlert(t, value)
+			value = []byte(strings.Join(entry[1], "") + "=" + strings.Join(entry[2], ""))
+		}
+		fields[number] = append(fields[number], string(value))
+	}
+	return fields, truncated
+}
+
+func TestEncodeAlert(t *testing.T) {
+	payload := WebhookPayload{
+		AlertName:   "DiskFull",
+		Status:      "firing",
+		Severity:    "critical",
+		Labels:      map[string]string{"team": "storage", "instance": "node-1"},
+		Annotations: map[string]string{"summary": "Disk full"},
+		StartsAt:    "2024-01-01T12:00:00Z",
+		Truncated:   true,
+	}
+
+	message := encodeAlert(payload)
+	fields, truncated := decodeAlert(t, message)
+	want := map[protowire.Number][]string{
+		1: {"DiskFull"},
+		2: {"firing"},
+		3: {"critical"},
+		7: {"instance=node-1", "team=storage"},
+		8: {"summary=Disk full"},
+		9: {"2024-01-01T12:00:00Z"},
+	}
+	if len(fields) != len(want) {
+		t.Errorf("encoded fields = %v, want %v", fields, want)
+	}
+	for number, values := range want {
+		if strings.Join(fields[number], ",") != strings.Join(values, ",") {
+			t.Errorf("field %d = %v, want %v", number, fields[number], values)
+		}
+	}
+	if !truncated {
+		t.Error("truncated flag not encoded")
+	}
+	if string(encodeAlert(payload)) != string(message) {
+		t.Error("encodeAlert() is not deterministic")
+	}
+}
+
+func TestValidateGRPCTargets(t *testing.T) {
+	template, _ := parseBodyTemplate("{}", "")
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "http targets", config: Config{Targets: []WebhookTarget{{URL: "https://example.com"}}, BodyTemplate: template}},
+		{name: "grpc target", config: Config{Targets: []WebhookTarget{{URL: "grpcs://alerts.internal:443"}}, WebhookFormat: webhookFormatKaro}},
+		{name: "cloudevents", config: Config{Targets: []WebhookTarget{{URL: "grpc://alerts.internal:50051"}}, WebhookFormat: webhookFormatCloudEvents}, wantErr: "WEBHOOK_FORMAT=cloudevents"},
+		{name: "missing port", config: Config{Targets: []WebhookTarget{{URL: "grpc://alerts.internal"}}}, wantErr: "must be grpc://host:port"},
+		{name: "path", config: Config{Targets: []WebhookTarget{{URL: "grpc://alerts.internal:50051/notify"}}}, wantErr: "must be grpc://host:port"},
+		{name: "body template", config: Config{Targets: []WebhookTarget{{URL: "grpc://alerts.internal:50051"}}, BodyTemplate: template}, wantErr: "body template"},
+		{name: "method", config: Config{Targets: []WebhookTarget{{URL: "grpc://alerts.internal:50051"}}, HTTPMethod: "PUT"}, wantErr: "HTTP_METHOD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGRPCTargets(&tt.config)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("validateGRPCTargets() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateGRPCTargets() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// grpcCall is a call received by the test server
+type grpcCall struct {
+	method   string
+	metadata metadata.MD
+	message  []byte
+}
+
+// startGRPCServer serves any method, recording the calls and answering
+// with the codes in turn, OK once they run out
+func startGRPCServer(t *testing.T, failures ...codes.Code) (string, *[]grpcCall) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []grpcCall
+	var n atomic.Int32
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		var message []byte
+		if err := stream.RecvMsg(&message); err != nil {
+			return err
+		}
+		method, _ := grpc.MethodFromServerStream(stream)
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		calls = append(calls, grpcCall{method: method, metadata: md, message: message})
+
+		if i := int(n.Add(1)) - 1; i < len(failures) {
+			return status.Error(failures[i], "failed")
+		}
+		return stream.SendMsg(&[]byte{})
+	}))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return "grpc://" + listener.Addr().String(), &calls
+}
+
+func TestSendWebhookGRPC(t *testing.T) {
+	url, calls := startGRPCServer(t)
+	config := &Config{
+		Targets:        []WebhookTarget{{URL: url, AuthHeader: "Bearer abc"}},
+		Headers:        map[string]string{"X-Tenant": "team-a"},
+		SigningSecret:  "s3cret",
+		TimeoutSeconds: 5,
+	}
+	payload := WebhookPayload{AlertName: "DiskFull", Status: "firing"}
+
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, payload) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("server received %d calls, want 1", len(*calls))
+	}
+
+	call := (*calls)[0]
+	if call.method != grpcNotifyMethod {
+		t.Errorf("method = %q, want %q", call.method, grpcNotifyMethod)
+	}
+	if string(call.message) != string(encodeAlert(payload)) {
+		t.Errorf("message = %x, want the encoded alert", call.message)
+	}
+	get := func(name string) string { return strings.Join(call.metadata.Get(name), ",") }
+	if get("authorization") != "Bearer abc" || get("x-tenant") != "team-a" || get(alert.IdempotencyKeyHeader) == "" {
+		t.Errorf("metadata = %v", call.metadata)
+	}
+	if err := alert.VerifySignature(call.message, get(alert.SignatureHeader), "s3cret"); err != nil {
+		t.Errorf("signature does not match the message: %v", err)
+	}
+}
+
+func TestSendWebhookGRPCRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  []codes.Code
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "unavailable is retried", failures: []codes.Code{codes.Unavailable}, wantCalls: 2},
+		{name: "invalid argument is not", failures: []codes.Code{codes.InvalidArgument}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, calls := startGRPCServer(t, tt.failures...)
+			config := &Config{
+				Targets:           []WebhookTarget{{URL: url}},
+				TimeoutSeconds:    5,
+				RetryCount:        2,
+				RetryBackoffMs:    1,
+				RetryMaxBackoffMs: 5,
+			}
+
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendWebhook() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if len(*calls) != tt.wantCalls {
+				t.Errorf("server received %d calls, want %d", len(*calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLoadConfigGRPCTarget(t *testing.T) {
+	t.Setenv("WEBHOOK_URL", "grpcs://alerts.internal:443")
+
+	var err error
+	captureLog(t, func() { _, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+}
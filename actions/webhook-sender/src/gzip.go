@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// gzipBody compresses a rendered body for WEBHOOK_GZIP. It is called once
+// per run so every target, and any resend, gets the same bytes that were
+// hashed and signed.
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, fmt.Errorf("failed to compress body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sendWithGzipFallback delivers the compressed body to a target and, when
+// the target answers 415 Unsupported Media Type because it doesn't accept
+// Content-Encoding: gzip, delivers the uncompressed body instead. The resend
+// is hashed and signed over the uncompressed bytes and gets its own
+// retries; the attempts of both are counted.
+func sendWithGzipFallback(ctx context.Context, client *http.Client, config *Config, target WebhookTarget, method string, query url.Values, header http.Header, body, raw []byte) (*targetResponse, int, error) {
+	response, attempts, err := sendWithRetry(ctx, client, config, target, method, query, header, body)
+	if !config.Gzip || body == nil || err == nil || response == nil || response.StatusCode != http.StatusUnsupportedMediaType {
+		return response, attempts, err
+	}
+
+	log.Printf("Warning: Target rejected the gzip body with status %d, resending it uncompressed", response.StatusCode)
+	uncompressed := *config
+	uncompressed.Gzip = false
+	response, resent, err := sendWithRetry(ctx, client, &uncompressed, target, method, query, header, raw)
+	return response, attempts + resent, err
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+)
+
+func TestSendWebhookGzip(t *testing.T) {
+	payload := WebhookPayload{
+		AlertName:   "DiskFull",
+		Status:      "firing",
+		Labels:      map[string]string{"instance": "node-1", "team": "storage"},
+		Annotations: map[string]string{"description": "Disk / is full on node-1"},
+	}
+	want, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+
+	var requests int
+	var wire []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		wire, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:        []WebhookTarget{{URL: server.URL}},
+		Gzip:           true,
+		SigningSecret:  "s3cret",
+		TimeoutSeconds: 5,
+	}
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, payload) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("server received %d requests, want 1", requests)
+	}
+
+	if got := header.Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+
+	// The receiver can decompress the body back to the original JSON
+	reader, err := gzip.NewReader(bytes.NewReader(wire))
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress body: %v", err)
+	}
+	if !bytes.Equal(decompressed, want) {
+		t.Errorf("decompressed body = %s, want %s", decompressed, want)
+	}
+
+	// Hash and signature cover the compressed bytes on the wire
+	if err := alert.VerifyContentHash(wire, header.Get(alert.ContentHashHeader)); err != nil {
+		t.Errorf("content hash does not match the compressed body: %v", err)
+	}
+	if err := alert.VerifySignature(wire, header.Get(alert.SignatureHeader), "s3cret"); err != nil {
+		t.Errorf("signature does not match the compressed body: %v", err)
+	}
+	received, err := alert.ParsePayload(wire, header, "s3cret")
+	if err != nil {
+		t.Fatalf("ParsePayload() unexpected error: %v", err)
+	}
+	if received.Labels["team"] != "storage" {
+		t.Errorf("ParsePayload() = %+v", received)
+	}
+}
+
+func TestSendWebhookGzipFanOutSendsSameBytes(t *testing.T) {
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:        []WebhookTarget{{Name: "a", URL: server.URL}, {Name: "b", URL: server.URL}},
+		Gzip:           true,
+		TimeoutSeconds: 5,
+	}
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if len(bodies) != 2 || len(bodies[0]) == 0 || !bytes.Equal(bodies[0], bodies[1]) {
+		t.Errorf("targets received different or empty bodies: %q", bodies)
+	}
+}
+
+func TestSendWebhookGzipFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantRequests int
+		wantEncoding string
+		wantErr      bool
+	}{
+		{name: "unsupported media type resends uncompressed", status: http.StatusUnsupportedMediaType, wantRequests: 2},
+		{name: "other client errors are not resent", status: http.StatusBadRequest, wantRequests: 1, wantEncoding: "gzip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			var wire []byte
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				wire, _ = io.ReadAll(r.Body)
+				header = r.Header.Clone()
+				if r.Header.Get("Content-Encoding") == "gzip" {
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:        []WebhookTarget{{URL: server.URL}},
+				Gzip:           true,
+				SigningSecret:  "s3cret",
+				TimeoutSeconds: 5,
+			}
+			var err error
+			output := captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendWebhook() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Fatalf("server received %d requests, want %d", requests, tt.wantRequests)
+			}
+			if got := header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding of last request = %q, want %q", got, tt.wantEncoding)
+			}
+			if tt.wantEncoding != "" {
+				return
+			}
+
+			if !bytes.HasPrefix(wire, []byte("{")) {
+				t.Errorf("resent body is not the uncompressed JSON: %q", wire)
+			}
+			if err := alert.VerifySignature(wire, header.Get(alert.SignatureHeader), "s3cret"); err != nil {
+				t.Errorf("signature does not match the uncompressed body: %v", err)
+			}
+			if !bytes.Contains([]byte(output), []byte("resending it uncompressed")) {
+				t.Errorf("expected a warning about the resend, got:\n%s", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// reservedHeaders are set by the action itself, so HEADERS_JSON cannot set
+// them; the value names the setting to use instead, if any
+var reservedHeaders = map[string]string{
+	"Authorization":         "AUTH_HEADER",
+	"Content-Type":          "WEBHOOK_CONTENT_TYPE",
+	"Content-Encoding":      "WEBHOOK_GZIP",
+	"Content-Length":        "",
+	"Host":                  "",
+	"Idempotency-Key":       "IDEMPOTENCY_KEY_FIELD",
+	"X-Karo-Content-Sha256": "",
+	"X-Karo-Signature":      "WEBHOOK_SIGNING_SECRET",
+}
+
+// sensitiveHeaderWords mark custom headers whose values are masked in logs,
+// the LOG_CONFIG output and the file sink
+var sensitiveHeaderWords = []string{"auth", "token", "key", "secret", "password", "cookie", "credential"}
+
+// parseCustomHeaders parses HEADERS_JSON, a JSON object of header names to
+// values sent with every request, e.g. {"X-Tenant-ID": "team-a"}
+func parseCustomHeaders(spec string) (map[string]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(spec), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse HEADERS_JSON, expected an object of header names to string values: %w", err)
+	}
+
+	headers := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("invalid HEADERS_JSON header name '%s'", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("invalid HEADERS_JSON value for header '%s'", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if setting, reserved := reservedHeaders[name]; reserved {
+			if setting != "" {
+				return nil, fmt.Errorf("HEADERS_JSON cannot set %s, use %s instead", name, setting)
+			}
+			return nil, fmt.Errorf("HEADERS_JSON cannot set %s, it is set by the action", name)
+		}
+		if _, exists := headers[name]; exists {
+			return nil, fmt.Errorf("HEADERS_JSON sets header '%s' more than once", name)
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// isSensitiveHeader reports whether a custom header likely carries a
+// credential, e.g. X-Api-Key or X-Auth-Token
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns a copy of the custom headers with sensitive values
+// masked
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if isSensitiveHeader(name) {
+			value = "***"
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCustomHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "unset", spec: "", want: nil},
+		{
+			name: "names are canonicalized",
+			spec: `{"x-tenant-id": "team-a", "X-Request-Source": "karo"}`,
+			want: map[string]string{"X-Tenant-Id": "team-a", "X-Request-Source": "karo"},
+		},
+		{name: "not an object", spec: `["X-Tenant-ID"]`, wantErr: "failed to parse HEADERS_JSON"},
+		{name: "non-string value", spec: `{"X-Retries": 3}`, wantErr: "failed to parse HEADERS_JSON"},
+		{name: "invalid name", spec: `{"X Tenant": "a"}`, wantErr: "invalid HEADERS_JSON header name"},
+		{name: "invalid value", spec: `{"X-Tenant": "a\nb"}`, wantErr: "invalid HEADERS_JSON value"},
+		{name: "authorization is reserved", spec: `{"authorization": "Bearer x"}`, wantErr: "use AUTH_HEADER instead"},
+		{name: "host is reserved", spec: `{"Host": "example.com"}`, wantErr: "set by the action"},
+		{name: "duplicate after canonicalization", spec: `{"X-Tenant": "a", "x-tenant": "b"}`, wantErr: "more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCustomHeaders(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseCustomHeaders() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCustomHeaders() unexpected error: %v", err)
+			}
+			assertStringMap(t, "headers", got, tt.want)
+		})
+	}
+}
+
+func TestIsSensitiveHeader(t *testing.T) {
+	tests := map[string]bool{
+		"X-Api-Key":        true,
+		"X-Auth-Token":     true,
+		"X-Client-Secret":  true,
+		"Cookie":           true,
+		"X-Tenant-Id":      false,
+		"X-Request-Source": false,
+	}
+	for name, want := range tests {
+		if got := isSensitiveHeader(name); got != want {
+			t.Errorf("isSensitiveHeader(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestSendWebhookCustomHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:        []WebhookTarget{{URL: server.URL, AuthHeader: "Bearer x"}},
+		Headers:        map[string]string{"X-Tenant-Id": "team-a", "X-Api-Key": "api-secret"},
+		TimeoutSeconds: 5,
+	}
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if got.Get("X-Tenant-Id") != "team-a" || got.Get("X-Api-Key") != "api-secret" || got.Get("Authorization") != "Bearer x" {
+		t.Errorf("request headers = %v, want the custom headers alongside Authorization", got)
+	}
+
+	records, err := buildSinkRecords(config, WebhookPayload{AlertName: "DiskFull"})
+	if err != nil {
+		t.Fatalf("buildSinkRecords() unexpected error: %v", err)
+	}
+	if headers := records[0].Headers; headers["X-Tenant-Id"] != "team-a" || headers["X-Api-Key"] != "***" {
+		t.Errorf("sink headers = %v, want X-Api-Key masked", headers)
+	}
+}
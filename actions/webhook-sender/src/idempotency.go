@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+	"golang.org/x/net/http/httpguts"
+)
+
+// idempotencyKey derives a deterministic key for the alert, so a reaction
+// that karo re-invokes for the same alert sends the same key and the
+// receiver can drop the duplicate. With IDEMPOTENCY_KEY_FIELD set, value is
+// that field of the alert; when it is empty the key covers the alert name,
+// label set, start time and status instead, so alerts of the same name for
+// different instances get different keys.
+func idempotencyKey(field, value, alertName string, labels map[string]string, startsAt, status string) string {
+	labelSet := ""
+	if len(labels) > 0 {
+		labelSet = fmt.Sprintf("%016x", labelsFingerprint(labels))
+	}
+	parts := []string{alertName, labelSet, startsAt, status}
+	if field != "" {
+		if value != "" {
+			parts = []string{value}
+		} else {
+			log.Printf("Warning: IDEMPOTENCY_KEY_FIELD '%s' is empty, deriving the idempotency key from alertName, labels, startsAt and status", field)
+		}
+	}
+
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		// Separate the parts so e.g. ("ab", "c") and ("a", "bc") differ
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// parseIdempotencyKeyHeader validates IDEMPOTENCY_KEY_HEADER, the header the
+// idempotency key is sent in, defaulting to alert.IdempotencyKeyHeader, for
+// receivers that deduplicate on their own header, e.g. X-Dedup-Key
+func parseIdempotencyKeyHeader(name string) (string, error) {
+	if name == "" {
+		return alert.IdempotencyKeyHeader, nil
+	}
+	if !httpguts.ValidHeaderFieldName(name) {
+		return "", fmt.Errorf("invalid IDEMPOTENCY_KEY_HEADER '%s', must be a valid HTTP header name", name)
+	}
+	name = http.CanonicalHeaderKey(name)
+	if setting, reserved := reservedHeaders[name]; reserved && name != alert.IdempotencyKeyHeader {
+		return "", fmt.Errorf("invalid IDEMPOTENCY_KEY_HEADER '%s', the header is set by %s", name, getValueWithFallback(setting, "the action"))
+	}
+	return name, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	labels := map[string]string{"alertname": "DiskFull", "instance": "node-1"}
+	base := idempotencyKey("", "", "DiskFull", labels, "2024-01-01T12:00:00Z", "firing")
+
+	tests := []struct {
+		name     string
+		field    string
+		value    string
+		labels   map[string]string
+		status   string
+		wantBase bool
+		wantWarn bool
+	}{
+		{name: "same alert", status: "firing", wantBase: true},
+		{name: "same labels in another map", labels: map[string]string{"instance": "node-1", "alertname": "DiskFull"}, status: "firing", wantBase: true},
+		{name: "other instance", labels: map[string]string{"alertname": "DiskFull", "instance": "node-2"}, status: "firing"},
+		{name: "no labels", labels: map[string]string{}, status: "firing"},
+		{name: "other status", status: "resolved"},
+		{name: "field value", field: "labels.incident", value: "INC-1", status: "firing"},
+		{name: "empty field value falls back", field: "labels.incident", status: "firing", wantBase: true, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.labels == nil {
+				tt.labels = labels
+			}
+			var got string
+			output := captureLog(t, func() {
+				got = idempotencyKey(tt.field, tt.value, "DiskFull", tt.labels, "2024-01-01T12:00:00Z", tt.status)
+			})
+			if len(got) != 32 || strings.Trim(got, "0123456789abcdef") != "" {
+				t.Errorf("idempotencyKey() = %q, want 32 lowercase hex characters", got)
+			}
+			if (got == base) != tt.wantBase {
+				t.Errorf("idempotencyKey() = %s, base %s, want equal %t", got, base, tt.wantBase)
+			}
+			if strings.Contains(output, "Warning:") != tt.wantWarn {
+				t.Errorf("warning logged = %t, want %t: %s", !tt.wantWarn, tt.wantWarn, output)
+			}
+		})
+	}
+
+	if idempotencyKey("", "", "ab", nil, "c", "") == idempotencyKey("", "", "a", nil, "bc", "") {
+		t.Error("idempotencyKey() should separate its parts")
+	}
+}
+
+func TestParseIdempotencyKeyHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: alert.IdempotencyKeyHeader},
+		{name: "custom", header: "x-dedup-key", want: "X-Dedup-Key"},
+		{name: "default spelled out", header: "idempotency-key", want: alert.IdempotencyKeyHeader},
+		{name: "invalid name", header: "X Dedup", wantErr: true},
+		{name: "reserved", header: "Authorization", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIdempotencyKeyHeader(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIdempotencyKeyHeader() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseIdempotencyKeyHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// JWT_ALGORITHM values
+const (
+	jwtRS256 = "RS256"
+	jwtES256 = "ES256"
+	jwtHS256 = "HS256"
+)
+
+// jwtTokenSource signs a new short-lived JWT for every token it is asked
+// for. It is wrapped in oauth2.ReuseTokenSource, so requests share a token
+// until shortly before it expires, like OAuth2 tokens.
+type jwtTokenSource struct {
+	algorithm string
+	key       interface{}
+	keyID     string
+	claims    map[string]interface{}
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// parseJWTConfig reads JWT_SIGNING_KEY (or JWT_SIGNING_KEY_FILE) and the
+// claims of the JWT signed with it, which is then sent as the bearer token
+// of targets without their own authHeader. JWTs are off unless the key is
+// set. It must run after parseOAuthConfig, since both set the token source.
+func parseJWTConfig(config *Config) error {
+	signingKey, err := secretEnv("JWT_SIGNING_KEY")
+	if err != nil {
+		return err
+	}
+	if signingKey == "" {
+		return nil
+	}
+	switch {
+	case config.AuthHeader != "":
+		return fmt.Errorf("JWT_SIGNING_KEY and AUTH_HEADER, WEBHOOK_BEARER_TOKEN, WEBHOOK_BASIC_USER/WEBHOOK_BASIC_PASS are mutually exclusive, configure only one auth method")
+	case config.OAuthTokenSource != nil:
+		return fmt.Errorf("JWT_SIGNING_KEY and OAUTH_TOKEN_URL are mutually exclusive, configure only one auth method")
+	}
+
+	config.JWTAlgorithm = getValueWithFallback(os.Getenv("JWT_ALGORITHM"), jwtRS256)
+	key, err := parseJWTSigningKey(config.JWTAlgorithm, signingKey)
+	if err != nil {
+		return err
+	}
+
+	config.JWTTTLSeconds = 300
+	if err := envInt(config.StrictEnv, "JWT_TTL_SECONDS", &config.JWTTTLSeconds); err != nil {
+		return err
+	}
+	if config.JWTTTLSeconds < 1 {
+		return fmt.Errorf("JWT_TTL_SECONDS must be at least 1, got %d", config.JWTTTLSeconds)
+	}
+
+	claims := map[string]interface{}{}
+	if spec := os.Getenv("JWT_CLAIMS"); spec != "" {
+		if err := json.Unmarshal([]byte(spec), &claims); err != nil {
+			return fmt.Errorf("failed to parse JWT_CLAIMS, must be a JSON object of claim names to values: %w", err)
+		}
+		if claims == nil {
+			claims = map[string]interface{}{}
+		}
+		config.JWTClaims = json.RawMessage(spec)
+	}
+	config.JWTIssuer = os.Getenv("JWT_ISSUER")
+	config.JWTSubject = os.Getenv("JWT_SUBJECT")
+	config.JWTAudience = os.Getenv("JWT_AUDIENCE")
+	for name, value := range map[string]string{"iss": config.JWTIssuer, "sub": config.JWTSubject, "aud": config.JWTAudience} {
+		if value != "" {
+			claims[name] = value
+		}
+	}
+	config.JWTKeyID = os.Getenv("JWT_KEY_ID")
+
+	config.OAuthTokenSource = oauth2.ReuseTokenSource(nil, &jwtTokenSource{
+		algorithm: config.JWTAlgorithm,
+		key:       key,
+		keyID:     config.JWTKeyID,
+		claims:    claims,
+		ttl:       time.Duration(config.JWTTTLSeconds) * time.Second,
+		now:       time.Now,
+	})
+	return nil
+}
+
+// parseJWTSigningKey returns the key for the algorithm: the secret itself
+// for HS256, or else the PEM-encoded private key, in PKCS#1, SEC 1 or
+// PKCS#8 form
+func parseJWTSigningKey(algorithm, signingKey string) (interface{}, error) {
+	if algorithm == jwtHS256 {
+		return []byte(signingKey), nil
+	}
+	if algorithm != jwtRS256 && algorithm != jwtES256 {
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM '%s', must be '%s', '%s' or '%s'", algorithm, jwtRS256, jwtES256, jwtHS256)
+	}
+
+	block, _ := pem.Decode([]byte(signingKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid JWT_SIGNING_KEY: %s requires a PEM-encoded private key", algorithm)
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_SIGNING_KEY: %w", err)
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == jwtRS256 {
+			return key, nil
+		}
+	case *ecdsa.PrivateKey:
+		if algorithm == jwtES256 && key.Curve == elliptic.P256() {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid JWT_SIGNING_KEY: not a key for %s (RS256 takes an RSA key, ES256 a P-256 EC key)", algorithm)
+}
+
+// Token signs a JWT with the configured claims plus iat, exp and a unique
+// jti, which override JWT_CLAIMS
+func (s *jwtTokenSource) Token() (*oauth2.Token, error) {
+	now := s.now()
+	expiry := now.Add(s.ttl)
+
+	claims := make(map[string]interface{}, len(s.claims)+3)
+	for name, value := range s.claims {
+		claims[name] = value
+	}
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiry.Unix()
+	claims["jti"] = uuid.NewString()
+
+	header := map[string]string{"alg": s.algorithm, "typ": "JWT"}
+	if s.keyID != "" {
+		header["kid"] = s.keyID
+	}
+	token, err := signJWT(s.algorithm, s.key, header, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return &oauth2.Token{AccessToken: token, TokenType: "Bearer", Expiry: expiry}, nil
+}
+
+// signJWT encodes the header and claims and appends their signature in the
+// compact JWS serialization
+func signJWT(algorithm string, key interface{}, header map[string]string, claims map[string]interface{}) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch algorithm {
+	case jwtHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case jwtRS256:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case jwtES256:
+		// JWS uses the fixed-size r || s form rather than ASN.1
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	default:
+		err = fmt.Errorf("unsupported algorithm '%s'", algorithm)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{signingInput, base64.RawURLEncoding.EncodeToString(signature)}, "."), nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyJWT checks the token's signature with the public key (the secret
+// for HS256) and returns its header and claims
+func verifyJWT(t *testing.T, token string, key interface{}) (map[string]string, map[string]interface{}) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q is not a compact JWS", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("invalid signature encoding: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(parts[0] + "." + parts[1]))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			t.Error("HS256 signature does not verify")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			t.Errorf("RS256 signature does not verify: %v", err)
+		}
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			t.Fatalf("ES256 signature is %d bytes, want 64", len(signature))
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			t.Error("ES256 signature does not verify")
+		}
+	}
+
+	var header map[string]string
+	var claims map[string]interface{}
+	for i, target := range []interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatalf("invalid token part encoding: %v", err)
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			t.Fatalf("invalid token part JSON: %v", err)
+		}
+	}
+	return header, claims
+}
+
+func writeTestKey(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseJWTConfig(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8DER, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	rsaFile := writeTestKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	ecFile := writeTestKey(t, "EC PRIVATE KEY", ecDER)
+	pkcs8File := writeTestKey(t, "PRIVATE KEY", pkcs8DER)
+
+	tests := []struct {
+		name      string
+		env       map[string]string
+		authHdr   string
+		verifyKey interface{}
+		wantErr   string
+	}{
+		{name: "unset", env: map[string]string{}},
+		{name: "RS256 PKCS#1", env: map[string]string{"JWT_SIGNING_KEY_FILE": rsaFile}, verifyKey: &rsaKey.PublicKey},
+		{name: "RS256 PKCS#8", env: map[string]string{"JWT_SIGNING_KEY_FILE": pkcs8File}, verifyKey: &rsaKey.PublicKey},
+		{name: "ES256", env: map[string]string{"JWT_SIGNING_KEY_FILE": ecFile, "JWT_ALGORITHM": "ES256"}, verifyKey: &ecKey.PublicKey},
+		{name: "HS256", env: map[string]string{"JWT_SIGNING_KEY": "s3cret", "JWT_ALGORITHM": "HS256"}, verifyKey: []byte("s3cret")},
+		{name: "key of another algorithm", env: map[string]string{"JWT_SIGNING_KEY_FILE": rsaFile, "JWT_ALGORITHM": "ES256"}, wantErr: "not a key for ES256"},
+		{name: "not PEM", env: map[string]string{"JWT_SIGNING_KEY": "s3cret"}, wantErr: "PEM-encoded private key"},
+		{name: "unsupported algorithm", env: map[string]string{"JWT_SIGNING_KEY": "s3cret", "JWT_ALGORITHM": "none"}, wantErr: "unsupported JWT_ALGORITHM 'none'"},
+		{name: "invalid claims", env: map[string]string{"JWT_SIGNING_KEY": "s3cret", "JWT_ALGORITHM": "HS256", "JWT_CLAIMS": "[1]"}, wantErr: "failed to parse JWT_CLAIMS"},
+		{name: "zero ttl", env: map[string]string{"JWT_SIGNING_KEY": "s3cret", "JWT_ALGORITHM": "HS256", "JWT_TTL_SECONDS": "0"}, wantErr: "JWT_TTL_SECONDS must be at least 1"},
+		{name: "with static auth", env: map[string]string{"JWT_SIGNING_KEY": "s3cret", "JWT_ALGORITHM": "HS256"}, authHdr: "Bearer abc", wantErr: "mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"JWT_SIGNING_KEY", "JWT_SIGNING_KEY_FILE", "JWT_ALGORITHM", "JWT_CLAIMS", "JWT_TTL_SECONDS", "JWT_ISSUER", "JWT_SUBJECT", "JWT_AUDIENCE", "JWT_KEY_ID"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{AuthHeader: tt.authHdr}
+			err := parseJWTConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseJWTConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJWTConfig() unexpected error: %v", err)
+			}
+			if tt.verifyKey == nil {
+				if config.OAuthTokenSource != nil {
+					t.Error("token source configured without JWT_SIGNING_KEY")
+				}
+				return
+			}
+
+			token, err := config.OAuthTokenSource.Token()
+			if err != nil {
+				t.Fatalf("Token() unexpected error: %v", err)
+			}
+			header, _ := verifyJWT(t, token.AccessToken, tt.verifyKey)
+			if header["alg"] != config.JWTAlgorithm {
+				t.Errorf("alg = %q, want %q", header["alg"], config.JWTAlgorithm)
+			}
+		})
+	}
+}
+
+func TestJWTTokenClaims(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := &jwtTokenSource{
+		algorithm: jwtHS256,
+		key:       []byte("s3cret"),
+		keyID:     "key-1",
+		claims:    map[string]interface{}{"iss": "karo", "aud": "alerts", "tenant": "team-a", "exp": 1},
+		ttl:       5 * time.Minute,
+		now:       func() time.Time { return now },
+	}
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Token() unexpected error: %v", err)
+	}
+	if token.Type() != "Bearer" || !token.Expiry.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("token type %q, expiry %s", token.Type(), token.Expiry)
+	}
+
+	header, claims := verifyJWT(t, token.AccessToken, []byte("s3cret"))
+	if header["kid"] != "key-1" || header["typ"] != "JWT" {
+		t.Errorf("header = %v", header)
+	}
+	want := map[string]interface{}{"iss": "karo", "aud": "alerts", "tenant": "team-a", "iat": float64(now.Unix()), "exp": float64(now.Add(5 * time.Minute).Unix())}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("claim %s = %v, want %v", name, claims[name], value)
+		}
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Error("token has no jti")
+	}
+
+	second, _ := source.Token()
+	if second.AccessToken == token.AccessToken {
+		t.Error("tokens should have unique jti claims")
+	}
+}
+
+func TestSendWebhookJWT(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", "s3cret")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_AUDIENCE", "alerts")
+
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:        []WebhookTarget{{Name: "a", URL: server.URL}, {Name: "b", URL: server.URL, AuthHeader: "Bearer static"}},
+		TimeoutSeconds: 5,
+	}
+	if err := parseJWTConfig(config); err != nil {
+		t.Fatalf("parseJWTConfig() unexpected error: %v", err)
+	}
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+
+	var jwtCount int
+	for _, authorization := range authorizations {
+		if token, ok := strings.CutPrefix(authorization, "Bearer "); ok && token != "static" {
+			jwtCount++
+			if _, claims := verifyJWT(t, token, []byte("s3cret")); claims["aud"] != "alerts" {
+				t.Errorf("aud = %v, want alerts", claims["aud"])
+			}
+		}
+	}
+	if len(authorizations) != 2 || jwtCount != 1 {
+		t.Errorf("Authorization headers = %v, want one JWT and the target's own header", authorizations)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultMaxConcurrency bounds the deliveries in flight at once
+const defaultMaxConcurrency = 4
+
+// deliveryLimits bounds how many deliveries of a run are in flight at once
+// and how fast they start, so a large fan-out cannot overwhelm a receiver
+// or trip its rate limiter. The zero value imposes no limits.
+type deliveryLimits struct {
+	maxConcurrency int
+	limiter        *rate.Limiter
+	shared         *sharedRateLimit
+}
+
+// parseLimitsConfig reads MAX_CONCURRENCY, RATE_LIMIT_PER_SECOND,
+// RATE_LIMIT_BURST and RATE_LIMIT_FILE. The rate limit is off unless
+// RATE_LIMIT_PER_SECOND is set.
+func parseLimitsConfig(config *Config) error {
+	config.MaxConcurrency = defaultMaxConcurrency
+	if err := envInt(config.StrictEnv, "MAX_CONCURRENCY", &config.MaxConcurrency); err != nil {
+		return err
+	}
+	if config.MaxConcurrency < 1 {
+		return fmt.Errorf("MAX_CONCURRENCY must be at least 1, got %d", config.MaxConcurrency)
+	}
+
+	if err := envFloat(config.StrictEnv, "RATE_LIMIT_PER_SECOND", &config.RateLimitPerSecond); err != nil {
+		return err
+	}
+	if config.RateLimitPerSecond < 0 {
+		return fmt.Errorf("RATE_LIMIT_PER_SECOND must not be negative, got %g", config.RateLimitPerSecond)
+	}
+
+	config.RateLimitBurst = 1
+	if err := envInt(config.StrictEnv, "RATE_LIMIT_BURST", &config.RateLimitBurst); err != nil {
+		return err
+	}
+	if config.RateLimitBurst < 1 {
+		return fmt.Errorf("RATE_LIMIT_BURST must be at least 1, got %d", config.RateLimitBurst)
+	}
+	config.RateLimitFile = os.Getenv("RATE_LIMIT_FILE")
+	if config.RateLimitPerSecond == 0 && (config.RateLimitBurst > 1 || config.RateLimitFile != "") {
+		log.Println("Warning: RATE_LIMIT_BURST and RATE_LIMIT_FILE are ignored without RATE_LIMIT_PER_SECOND")
+	}
+	return nil
+}
+
+// newDeliveryLimits creates the limits shared by all deliveries of the run.
+// The limiter has a burst of RATE_LIMIT_BURST, one by default, so
+// deliveries are spread evenly instead of the first ones starting at once.
+// With RATE_LIMIT_FILE the limit is kept in the file instead, shared with
+// every run that uses it.
+func newDeliveryLimits(config *Config) deliveryLimits {
+	limits := deliveryLimits{maxConcurrency: config.MaxConcurrency}
+	switch {
+	case config.RateLimitPerSecond > 0 && config.RateLimitFile != "":
+		limits.shared = newSharedRateLimit(config.RateLimitFile, config.RateLimitPerSecond, config.RateLimitBurst)
+	case config.RateLimitPerSecond > 0:
+		limits.limiter = rate.NewLimiter(rate.Limit(config.RateLimitPerSecond), max(config.RateLimitBurst, 1))
+	}
+	return limits
+}
+
+// wait blocks until the rate limit allows another delivery or ctx is done
+func (l deliveryLimits) wait(ctx context.Context) error {
+	if l.shared != nil {
+		return l.shared.wait(ctx)
+	}
+	if l.limiter == nil {
+		return nil
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// batchSize returns how many of n deliveries may be in flight at once
+func (l deliveryLimits) batchSize(n int) int {
+	if l.maxConcurrency <= 0 || l.maxConcurrency > n {
+		return n
+	}
+	return l.maxConcurrency
+}
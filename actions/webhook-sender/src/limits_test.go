@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimitsConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		concurrency     string
+		rateLimit       string
+		burst           string
+		wantConcurrency int
+		wantRate        float64
+		wantBurst       int
+		wantErr         bool
+	}{
+		{name: "defaults", wantConcurrency: defaultMaxConcurrency, wantBurst: 1},
+		{name: "configured", concurrency: "2", rateLimit: "0.5", burst: "5", wantConcurrency: 2, wantRate: 0.5, wantBurst: 5},
+		{name: "zero concurrency", concurrency: "0", wantErr: true},
+		{name: "negative rate", rateLimit: "-1", wantErr: true},
+		{name: "zero burst", rateLimit: "1", burst: "0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_CONCURRENCY", tt.concurrency)
+			t.Setenv("RATE_LIMIT_PER_SECOND", tt.rateLimit)
+			t.Setenv("RATE_LIMIT_BURST", tt.burst)
+
+			config := &Config{StrictEnv: true}
+			err := parseLimitsConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLimitsConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && (config.MaxConcurrency != tt.wantConcurrency || config.RateLimitPerSecond != tt.wantRate || config.RateLimitBurst != tt.wantBurst) {
+				t.Errorf("limits = %d, %g, %d, want %d, %g, %d",
+					config.MaxConcurrency, config.RateLimitPerSecond, config.RateLimitBurst, tt.wantConcurrency, tt.wantRate, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func TestDeliveryLimits(t *testing.T) {
+	var unlimited deliveryLimits
+	if got := unlimited.batchSize(10); got != 10 {
+		t.Errorf("unlimited batchSize(10) = %d, want 10", got)
+	}
+	if err := unlimited.wait(context.Background()); err != nil {
+		t.Errorf("unlimited wait() unexpected error: %v", err)
+	}
+
+	limits := newDeliveryLimits(&Config{MaxConcurrency: 4, RateLimitPerSecond: 20})
+	if got := limits.batchSize(10); got != 4 {
+		t.Errorf("batchSize(10) = %d, want 4", got)
+	}
+	if got := limits.batchSize(2); got != 2 {
+		t.Errorf("batchSize(2) = %d, want 2", got)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limits.wait(context.Background()); err != nil {
+			t.Fatalf("wait() unexpected error: %v", err)
+		}
+	}
+	// The first delivery starts at once, the next two 50ms apart
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 deliveries at 20/s took %s, want at least 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limits.wait(ctx); err == nil {
+		t.Error("wait() should fail once the context is done")
+	}
+}
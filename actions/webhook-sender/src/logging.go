@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logActionName identifies this action in structured logs
+const logActionName = "webhook-sender"
+
+// LOG_FORMAT values
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Log levels, derived from the "Warning: " and "Error: " message prefixes
+const (
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// jsonLogs is the structured log writer while LOG_FORMAT=json, nil otherwise
+var jsonLogs *jsonLogWriter
+
+// parseLogFormat validates LOG_FORMAT, defaulting to text
+func parseLogFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return logFormatText, nil
+	case logFormatText, logFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported LOG_FORMAT '%s', must be 'text' or 'json'", format)
+	}
+}
+
+// setupLogging switches the standard logger to one JSON record per line when
+// format is json. It runs before the configuration is loaded so every line
+// is structured; an invalid format keeps plain text and is reported by
+// loadConfig.
+func setupLogging(format string) {
+	if format != logFormatJSON {
+		return
+	}
+	jsonLogs = &jsonLogWriter{out: log.Writer()}
+	log.SetFlags(0)
+	log.SetOutput(jsonLogs)
+}
+
+// setLogAlertName adds the alert name to subsequent structured log records
+func setLogAlertName(name string) {
+	if jsonLogs != nil {
+		jsonLogs.setAlertName(name)
+	}
+}
+
+// parseLogLine splits a log message into its level, message and error. The
+// "Warning: " and "Error: " prefixes set the level, and for those levels the
+// text after the first ": " is the error, as in "Failed to publish: %v".
+func parseLogLine(line string) (level, msg, errText string) {
+	level, msg = levelInfo, line
+	switch {
+	case strings.HasPrefix(line, "Warning: "):
+		level, msg = levelWarn, strings.TrimPrefix(line, "Warning: ")
+	case strings.HasPrefix(line, "Error: "):
+		level, msg = levelError, strings.TrimPrefix(line, "Error: ")
+	default:
+		return level, msg, ""
+	}
+
+	if i := strings.Index(msg, ": "); i > 0 {
+		msg, errText = msg[:i], msg[i+2:]
+	}
+	return level, msg, errText
+}
+
+// logRecord is a structured log line
+type logRecord struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Msg       string `json:"msg"`
+	Action    string `json:"action"`
+	AlertName string `json:"alertName,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// jsonLogWriter turns each standard log line into a JSON record
+type jsonLogWriter struct {
+	mu        sync.Mutex
+	out       io.Writer
+	alertName string
+}
+
+func (w *jsonLogWriter) setAlertName(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.alertName = name
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	level, msg, errText := parseLogLine(strings.TrimRight(string(p), "\n"))
+	data, err := json.Marshal(logRecord{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     level,
+		Msg:       msg,
+		Action:    logActionName,
+		AlertName: w.alertName,
+		Error:     errText,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
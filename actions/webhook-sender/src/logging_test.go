@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestParseLogFormat(t *testing.T) {
+	for format, want := range map[string]string{"": "text", "text": "text", "json": "json", "JSON": ""} {
+		got, err := parseLogFormat(format)
+		if (err != nil) != (want == "") || got != want {
+			t.Errorf("parseLogFormat(%q) = %q, %v, want %q", format, got, err, want)
+		}
+	}
+}
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantLevel string
+		wantMsg   string
+		wantErr   string
+	}{
+		{line: "Configuration loaded: project test", wantLevel: "info", wantMsg: "Configuration loaded: project test"},
+		{line: "Warning: Failed to parse ALERT_JSON: unexpected end of JSON input", wantLevel: "warn", wantMsg: "Failed to parse ALERT_JSON", wantErr: "unexpected end of JSON input"},
+		{line: "Warning: Invalid LOG_CONFIG value 'yes', using default false", wantLevel: "warn", wantMsg: "Invalid LOG_CONFIG value 'yes', using default false"},
+		{line: "Error: Configuration error: GCP_PROJECT_ID environment variable is required", wantLevel: "error", wantMsg: "Configuration error", wantErr: "GCP_PROJECT_ID environment variable is required"},
+	}
+
+	for _, tt := range tests {
+		level, msg, errText := parseLogLine(tt.line)
+		if level != tt.wantLevel || msg != tt.wantMsg || errText != tt.wantErr {
+			t.Errorf("parseLogLine(%q) = %q, %q, %q, want %q, %q, %q", tt.line, level, msg, errText, tt.wantLevel, tt.wantMsg, tt.wantErr)
+		}
+	}
+}
+
+func TestSetupLoggingJSON(t *testing.T) {
+	var out bytes.Buffer
+	originalWriter, originalFlags := log.Writer(), log.Flags()
+	log.SetOutput(&out)
+	defer func() {
+		log.SetOutput(originalWriter)
+		log.SetFlags(originalFlags)
+		jsonLogs = nil
+	}()
+
+	setupLogging("json")
+	log.Println("Starting up")
+	setLogAlertName("DiskFull")
+	log.Printf("Warning: Failed to publish: %s", "quota exceeded")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(lines), out.String())
+	}
+
+	var records []logRecord
+	for _, line := range lines {
+		var record logRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line is not JSON: %v\n%s", err, line)
+		}
+		records = append(records, record)
+	}
+
+	if records[0].Level != "info" || records[0].Msg != "Starting up" || records[0].Action != logActionName || records[0].AlertName != "" {
+		t.Errorf("first record = %+v", records[0])
+	}
+	if records[0].Time == "" {
+		t.Error("record has no time")
+	}
+	want := logRecord{Level: "warn", Msg: "Failed to publish", Action: logActionName, AlertName: "DiskFull", Error: "quota exceeded"}
+	want.Time = records[1].Time
+	if records[1] != want {
+		t.Errorf("second record = %+v, want %+v", records[1], want)
+	}
+}
+
+func TestSetupLoggingTextIsUnchanged(t *testing.T) {
+	originalWriter := log.Writer()
+	setupLogging("text")
+	if log.Writer() != originalWriter || jsonLogs != nil {
+		t.Error("text format should leave the standard logger unchanged")
+	}
+}
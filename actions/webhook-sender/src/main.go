@@ -2,14 +2,24 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"text/template"
 	"time"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+	"golang.org/x/oauth2"
 )
 
 // AlertData represents the structure of alert information
@@ -19,65 +29,736 @@ type AlertData struct {
 	Annotations map[string]string `json:"annotations"`
 	StartsAt    string            `json:"startsAt,omitempty"`
 	EndsAt      string            `json:"endsAt,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
 }
 
-// WebhookPayload represents the payload sent to the webhook
-type WebhookPayload struct {
-	AlertName   string            `json:"alertName"`
-	Status      string            `json:"status"`
-	Severity    string            `json:"severity"`
-	Instance    string            `json:"instance"`
-	Summary     string            `json:"summary"`
-	Description string            `json:"description"`
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
-	Timestamp   string            `json:"timestamp"`
+// WebhookPayload represents the payload sent to the webhook. It is defined
+// in the alert package so receivers can decode it with alert.ParsePayload.
+type WebhookPayload = alert.Payload
+
+type Config struct {
+	WebhookURL           string                `json:"WEBHOOK_URL"`
+	WebhookURLFiring     string                `json:"WEBHOOK_URL_FIRING"`
+	WebhookURLResolved   string                `json:"WEBHOOK_URL_RESOLVED"`
+	AuthHeader           string                `json:"AUTH_HEADER"`
+	Headers              map[string]string     `json:"HEADERS_JSON"`
+	Targets              []WebhookTarget       `json:"WEBHOOK_TARGETS"`
+	Routes               []webhookRoute        `json:"ROUTES_JSON"`
+	FailureMode          string                `json:"FAILURE_MODE"`
+	MaxConcurrency       int                   `json:"MAX_CONCURRENCY"`
+	SuccessStatus        []int                 `json:"SUCCESS_STATUS"`
+	ResponseBodyContains string                `json:"RESPONSE_BODY_CONTAINS"`
+	ResponseJSONFields   map[string]string     `json:"RESPONSE_JSON_FIELDS"`
+	RateLimitPerSecond   float64               `json:"RATE_LIMIT_PER_SECOND"`
+	RateLimitBurst       int                   `json:"RATE_LIMIT_BURST"`
+	RateLimitFile        string                `json:"RATE_LIMIT_FILE"`
+	BodyTemplateFile     string                `json:"WEBHOOK_BODY_TEMPLATE_FILE"`
+	BodyTemplate         *template.Template    `json:"-"`
+	Preset               string                `json:"WEBHOOK_PRESET"`
+	ContentType          string                `json:"WEBHOOK_CONTENT_TYPE"`
+	WebhookFormat        string                `json:"WEBHOOK_FORMAT"`
+	CloudEventsMode      string                `json:"CLOUDEVENTS_MODE"`
+	CloudEventsSource    string                `json:"CLOUDEVENTS_SOURCE"`
+	GraphQLQuery         string                `json:"GRAPHQL_QUERY"`
+	GraphQLOperationName string                `json:"GRAPHQL_OPERATION_NAME"`
+	GraphQLVariables     map[string]string     `json:"GRAPHQL_VARIABLES"`
+	GroupMode            string                `json:"ALERT_GROUP_MODE"`
+	PassthroughBody      json.RawMessage       `json:"-"`
+	TimestampSource      string                `json:"TIMESTAMP_SOURCE"`
+	ComputeFingerprint   bool                  `json:"COMPUTE_FINGERPRINT"`
+	IdempotencyKeyField  string                `json:"IDEMPOTENCY_KEY_FIELD"`
+	IdempotencyKeyHeader string                `json:"IDEMPOTENCY_KEY_HEADER"`
+	SigningSecret        string                `json:"WEBHOOK_SIGNING_SECRET"`
+	SignatureHeader      string                `json:"WEBHOOK_SIGNATURE_HEADER"`
+	Gzip                 bool                  `json:"WEBHOOK_GZIP"`
+	MaxPayloadBytes      int                   `json:"MAX_PAYLOAD_BYTES"`
+	OnOversize           string                `json:"ON_OVERSIZE"`
+	CACertFile           string                `json:"WEBHOOK_CA_CERT_FILE"`
+	CACertPool           *x509.CertPool        `json:"-"`
+	ClientCertFile       string                `json:"WEBHOOK_CLIENT_CERT_FILE"`
+	ClientKeyFile        string                `json:"WEBHOOK_CLIENT_KEY_FILE"`
+	ClientCert           *tls.Certificate      `json:"-"`
+	TLSMinVersion        string                `json:"WEBHOOK_TLS_MIN_VERSION"`
+	InsecureSkipVerify   bool                  `json:"WEBHOOK_INSECURE_SKIP_VERIFY"`
+	ProxyURL             string                `json:"PROXY_URL"`
+	Proxy                *url.URL              `json:"-"`
+	FollowRedirects      bool                  `json:"FOLLOW_REDIRECTS"`
+	MaxRedirects         int                   `json:"MAX_REDIRECTS"`
+	RedirectPreserveAuth bool                  `json:"REDIRECT_PRESERVE_AUTH"`
+	OAuthTokenURL        string                `json:"OAUTH_TOKEN_URL"`
+	OAuthClientID        string                `json:"OAUTH_CLIENT_ID"`
+	OAuthClientSecret    string                `json:"OAUTH_CLIENT_SECRET"`
+	OAuthScopes          []string              `json:"OAUTH_SCOPES"`
+	OAuthAudience        string                `json:"OAUTH_AUDIENCE"`
+	OAuthTokenSource     oauth2.TokenSource    `json:"-"`
+	JWTAlgorithm         string                `json:"JWT_ALGORITHM"`
+	JWTIssuer            string                `json:"JWT_ISSUER"`
+	JWTSubject           string                `json:"JWT_SUBJECT"`
+	JWTAudience          string                `json:"JWT_AUDIENCE"`
+	JWTKeyID             string                `json:"JWT_KEY_ID"`
+	JWTClaims            json.RawMessage       `json:"JWT_CLAIMS"`
+	JWTTTLSeconds        int                   `json:"JWT_TTL_SECONDS"`
+	SigV4Region          string                `json:"SIGV4_REGION"`
+	SigV4Service         string                `json:"SIGV4_SERVICE"`
+	SigV4                *sigV4Signer          `json:"-"`
+	HTTPMethod           string                `json:"HTTP_METHOD"`
+	MethodByStatus       map[string]string     `json:"METHOD_BY_STATUS"`
+	QueryParamFields     map[string]string     `json:"QUERY_PARAM_FIELDS"`
+	OutputFile           string                `json:"OUTPUT_FILE"`
+	OutputHeaders        bool                  `json:"OUTPUT_HEADERS"`
+	ResultJSON           bool                  `json:"RESULT_JSON"`
+	TimeoutSeconds       int                   `json:"TIMEOUT_SECONDS"`
+	RetryCount           int                   `json:"RETRY_COUNT"`
+	RetryBackoffMs       int                   `json:"RETRY_BACKOFF_MS"`
+	RetryMaxBackoffMs    int                   `json:"RETRY_MAX_BACKOFF"`
+	MissingAlertNameMode string                `json:"MISSING_ALERTNAME_MODE"`
+	AlertNameLabels      []string              `json:"ALERTNAME_FROM_LABELS"`
+	AllowedSeverities    []string              `json:"ALLOWED_SEVERITIES"`
+	AllowedStatuses      []string              `json:"ALLOWED_STATUSES"`
+	OnInvalid            string                `json:"ON_INVALID"`
+	LabelsInclude        []string              `json:"LABELS_INCLUDE"`
+	LabelsExclude        []string              `json:"LABELS_EXCLUDE"`
+	AnnotationsInclude   []string              `json:"ANNOTATIONS_INCLUDE"`
+	AnnotationsExclude   []string              `json:"ANNOTATIONS_EXCLUDE"`
+	LabelFilterMode      string                `json:"LABEL_FILTER_MODE"`
+	LabelFilter          keyFilter             `json:"-"`
+	AnnotationFilter     keyFilter             `json:"-"`
+	EnrichmentFile       string                `json:"ENRICHMENT_FILE"`
+	EnrichmentKeyField   string                `json:"ENRICHMENT_KEY_FIELD"`
+	Enrichment           map[string]Enrichment `json:"-"`
+	StrictEnv            bool                  `json:"STRICT_ENV"`
+	LogFormat            string                `json:"LOG_FORMAT"`
+	LogConfig            bool                  `json:"LOG_CONFIG"`
+	LogPayload           bool                  `json:"LOG_PAYLOAD"`
+	RedactFields         []string              `json:"REDACT_FIELDS"`
+	DedupRedisURL        string                `json:"DEDUP_REDIS_URL"`
+	DedupFile            string                `json:"DEDUP_FILE"`
+	DedupTTLSeconds      int                   `json:"DEDUP_TTL_SECONDS"`
+	DedupFailureMode     string                `json:"DEDUP_FAILURE_MODE"`
+	PushgatewayURL       string                `json:"PUSHGATEWAY_URL"`
+	DryRun               bool                  `json:"DRY_RUN"`
+	Sink                 string                `json:"SINK"`
+	SinkDir              string                `json:"SINK_DIR"`
+	DeadLetterURL        string                `json:"DEAD_LETTER_URL"`
+	DeadLetterAuthHeader string                `json:"DEAD_LETTER_AUTH_HEADER"`
+	DeadLetterDir        string                `json:"DEAD_LETTER_DIR"`
+	CircuitBreakerFile   string                `json:"CIRCUIT_BREAKER_FILE"`
+	FailureThreshold     int                   `json:"CIRCUIT_BREAKER_THRESHOLD"`
+	CooldownSeconds      int                   `json:"CIRCUIT_BREAKER_COOLDOWN_SECONDS"`
+}
+
+// exitCodeCancelled is the exit code used when the action is stopped by
+// SIGTERM or SIGINT, following the shell convention of 128+SIGINT
+const exitCodeCancelled = 130
+
+// exitCodeCircuitOpen is the exit code used when delivery is skipped because
+// the circuit breaker is open, EX_TEMPFAIL of sysexits.h
+const exitCodeCircuitOpen = 75
+
+// newSignalContext returns a context that is cancelled when the process
+// receives SIGTERM (e.g. pod eviction) or SIGINT
+func newSignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 }
 
 func main() {
+	// Structure logs first so every line, including config errors, uses the format
+	setupLogging(os.Getenv("LOG_FORMAT"))
+
 	log.Println("Starting webhook sender...")
 
-	// Get configuration from environment variables
-	webhookURL := os.Getenv("WEBHOOK_URL")
-	if webhookURL == "" {
-		log.Fatal("WEBHOOK_URL environment variable is required")
+	ctx, stop := newSignalContext()
+	defer stop()
+
+	// Bridge logs to OpenTelemetry when an OTLP endpoint is configured
+	ctx, err := setupTelemetry(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to set up OpenTelemetry, logging to stderr only: %v", err)
 	}
+	defer func() { shutdownTelemetry() }()
 
-	timeoutStr := os.Getenv("TIMEOUT_SECONDS")
-	timeout := 30 // default timeout
-	if timeoutStr != "" {
-		if t, err := strconv.Atoi(timeoutStr); err == nil {
-			timeout = t
-		}
+	// Push the run's outcome on exit when a Pushgateway is configured
+	setupPushgateway(os.Getenv("PUSHGATEWAY_URL"))
+	defer reactionMetrics.push(true)
+
+	// Load configuration
+	config, err := loadConfig()
+	if err != nil {
+		fatalf("Configuration error: %v", err)
+	}
+
+	if config.LogConfig {
+		logResolvedConfig(config)
 	}
 
 	// Parse alert data
-	alertJSON := os.Getenv("ALERT_JSON")
-	var alertData AlertData
+	alertJSON, err := readAlertJSON()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	alerts := parseAlertInput(config, alertJSON)
+
+	// Handle every alert of a group under ALERT_GROUP_MODE=per-alert, or
+	// else the single alert
+	var failed int
+	for i, alertData := range alerts {
+		if len(alerts) > 1 {
+			log.Printf("Handling alert %d of %d", i+1, len(alerts))
+		}
+		err := handleAlert(ctx, config, alertData)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			log.Printf("Webhook delivery cancelled by signal: %v", err)
+			exit(exitCodeCancelled)
+		}
+		// The breaker stays open for the rest of the group as well
+		var openErr *circuitOpenError
+		if errors.As(err, &openErr) {
+			log.Printf("Error: %v", err)
+			exit(exitCodeCircuitOpen)
+		}
+		if len(alerts) == 1 {
+			fatalf("%v", err)
+		}
+		log.Printf("Error: Alert %d of %d: %v", i+1, len(alerts), err)
+		failed++
+	}
+	if failed > 0 {
+		fatalf("%d of %d alerts failed", failed, len(alerts))
+	}
+}
+
+// handleAlert enriches, validates and routes one alert and delivers it to
+// the webhook, or to the dry run or file sink. Alerts that are skipped,
+// dropped or already handled return nil.
+func handleAlert(ctx context.Context, config *Config, alertData AlertData) error {
+	// Routing replaces the targets, which must not leak into the next alert
+	routed := *config
+	config = &routed
 
-	if alertJSON != "" {
-		if err := json.Unmarshal([]byte(alertJSON), &alertData); err != nil {
-			log.Printf("Warning: Failed to parse ALERT_JSON: %v", err)
+	// Merge static enrichment data into the alert
+	if config.EnrichmentFile != "" {
+		if enrichAlert(&alertData, config.EnrichmentKeyField, config.Enrichment) {
+			log.Printf("Alert enriched from %s", config.EnrichmentFile)
 		}
 	}
 
+	// Strip or mask filtered labels and annotations before anything uses them
+	filterAlert(config, &alertData)
+
 	// Build webhook payload
-	payload := buildWebhookPayload(alertData)
+	payload := buildWebhookPayload(alertData, config.TimestampSource)
+	payload.Fingerprint = alertFingerprint(payload.Fingerprint, payload.Labels, config.ComputeFingerprint)
+
+	// Handle alerts without an alertname label
+	alertName, send, err := ensureAlertName(config, payload.AlertName, payload.Labels)
+	if err != nil {
+		return fmt.Errorf("Invalid alert: %w", err)
+	}
+	if !send {
+		log.Println("Skipping alert without an alertname label (MISSING_ALERTNAME_MODE=skip)")
+		return nil
+	}
+	setLogAlertName(alertName)
+	reactionMetrics.setAlertStatus(payload.Status)
+	payload.AlertName = alertName
+
+	// Reject alerts with a severity or status outside the allowed sets
+	if err := validateAlert(config, payload.Severity, payload.Status); err != nil {
+		if config.OnInvalid == onInvalidDrop {
+			log.Printf("Dropping invalid alert (ON_INVALID=drop): %v", err)
+			return nil
+		}
+		return fmt.Errorf("Invalid alert: %w", err)
+	}
+
+	// Route the alert by ROUTES_JSON, or else to the webhook configured for
+	// its status
+	if err := routeAlert(config, payload); err != nil {
+		return fmt.Errorf("Configuration error: %w", err)
+	}
+
+	// Log what would be sent instead of contacting the webhook if configured
+	if config.DryRun {
+		if err := logDryRun(config, payload); err != nil {
+			return fmt.Errorf("Dry run failed: %w", err)
+		}
+		log.Println("Dry run complete, nothing was sent")
+		return nil
+	}
+
+	// Write to the local file sink instead of the webhook if configured
+	if config.Sink == sinkFile {
+		paths, err := writeFileSink(config, payload)
+		if err != nil {
+			return fmt.Errorf("Failed to write webhook request to file sink: %w", err)
+		}
+		for _, path := range paths {
+			log.Printf("Webhook request written to file sink: %s", path)
+		}
+		return nil
+	}
+
+	// Fail fast without claiming the alert while the receiver is known to be down
+	breaker := newCircuitBreaker(config)
+	if breaker != nil {
+		err := breaker.allow(time.Now())
+		var openErr *circuitOpenError
+		if errors.As(err, &openErr) {
+			deadLetter(ctx, config, payload, err)
+			return err
+		}
+		if err != nil {
+			log.Printf("Warning: Circuit breaker unavailable, delivering anyway: %v", err)
+		}
+	}
+
+	// Skip alerts another run already handled within DEDUP_TTL_SECONDS
+	dedup, key, duplicate, err := checkDuplicate(ctx, config, payload.Labels, payload.Status)
+	if err != nil {
+		return fmt.Errorf("Deduplication failed: %w", err)
+	}
+	if duplicate {
+		log.Printf("Skipping duplicate alert, already handled within the last %ds", config.DedupTTLSeconds)
+		return nil
+	}
+	if dedup != nil {
+		defer dedup.close()
+	}
 
 	// Send webhook
-	if err := sendWebhook(webhookURL, payload, timeout); err != nil {
-		log.Fatalf("Failed to send webhook: %v", err)
+	start := time.Now()
+	err = sendWebhook(ctx, config, payload)
+	reactionMetrics.observeCall(start)
+	if breaker != nil && ctx.Err() == nil {
+		if err := breaker.record(time.Now(), err); err != nil {
+			log.Printf("Warning: Failed to update circuit breaker: %v", err)
+		}
+	}
+	if err != nil {
+		releaseAlert(dedup, key)
+		deadLetter(ctx, config, payload, err)
+		return fmt.Errorf("Failed to send webhook: %w", err)
 	}
 
 	log.Println("Webhook sent successfully")
+	return nil
 }
 
-func buildWebhookPayload(alert AlertData) WebhookPayload {
+func loadConfig() (*Config, error) {
+	config := &Config{
+		WebhookURL:         os.Getenv("WEBHOOK_URL"),
+		WebhookURLFiring:   os.Getenv("WEBHOOK_URL_FIRING"),
+		WebhookURLResolved: os.Getenv("WEBHOOK_URL_RESOLVED"),
+		TimeoutSeconds:     30, // default timeout
+	}
+
+	// Parse STRICT_ENV first, since it decides how malformed values below are handled
+	strict, err := parseStrictEnv()
+	if err != nil {
+		return nil, err
+	}
+	config.StrictEnv = strict
+
+	// Resolve the default Authorization header from the configured auth method
+	basicUser, err := secretEnv("WEBHOOK_BASIC_USER")
+	if err != nil {
+		return nil, err
+	}
+	basicPass, err := secretEnv("WEBHOOK_BASIC_PASS")
+	if err != nil {
+		return nil, err
+	}
+	authHeader, err := resolveAuthHeader(authSettings{
+		Header:      os.Getenv("AUTH_HEADER"),
+		BearerToken: os.Getenv("WEBHOOK_BEARER_TOKEN"),
+		BasicUser:   basicUser,
+		BasicPass:   basicPass,
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.AuthHeader = authHeader
+
+	// Parse optional custom headers sent with every request
+	headers, err := parseCustomHeaders(os.Getenv("HEADERS_JSON"))
+	if err != nil {
+		return nil, err
+	}
+	config.Headers = headers
+
+	// Parse the optional routes that pick the target from the alert
+	routes, err := parseRoutes(os.Getenv("ROUTES_JSON"))
+	if err != nil {
+		return nil, err
+	}
+	config.Routes = routes
+
+	// Resolve targets from either a single WEBHOOK_URL or a WEBHOOK_TARGETS fan-out
+	if targetsStr := os.Getenv("WEBHOOK_TARGETS"); targetsStr != "" {
+		if config.WebhookURL != "" {
+			return nil, fmt.Errorf("WEBHOOK_URL and WEBHOOK_TARGETS are mutually exclusive")
+		}
+		targets, err := parseWebhookTargets(targetsStr)
+		if err != nil {
+			return nil, err
+		}
+		// The configured auth applies to targets without their own authHeader
+		for i := range targets {
+			if targets[i].AuthHeader == "" {
+				targets[i].AuthHeader = config.AuthHeader
+			}
+		}
+		config.Targets = targets
+	} else if config.WebhookURL == "" && config.WebhookURLFiring == "" && config.WebhookURLResolved == "" && len(config.Routes) == 0 {
+		return nil, fmt.Errorf("WEBHOOK_URL or WEBHOOK_TARGETS (or WEBHOOK_URL_FIRING/WEBHOOK_URL_RESOLVED or ROUTES_JSON) environment variable is required")
+	} else if config.WebhookURL != "" {
+		config.Targets = []WebhookTarget{{URL: config.WebhookURL, AuthHeader: config.AuthHeader}}
+	}
+
+	failureMode, err := parseFailureMode(os.Getenv("FAILURE_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	config.FailureMode = failureMode
+
+	// Parse the default success criteria of the targets
+	if err := parseResponseAssertions(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the limits on parallel and rate of deliveries to the targets
+	if err := parseLimitsConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional body template up front so syntax errors fail here
+	// rather than on delivery
+	config.BodyTemplateFile = os.Getenv("WEBHOOK_BODY_TEMPLATE_FILE")
+	bodyTemplate, err := parseBodyTemplate(os.Getenv("WEBHOOK_BODY_TEMPLATE"), config.BodyTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	config.BodyTemplate = bodyTemplate
+
+	// Use the body template of a built-in receiver preset if configured
+	if err := parsePreset(config); err != nil {
+		return nil, err
+	}
+	// Parse the optional CloudEvents envelope
+	if err := parseCloudEventsConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the mutation sent with WEBHOOK_FORMAT=graphql
+	if err := parseGraphQLConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse how grouped Alertmanager payloads are handled
+	if err := parseGroupMode(config); err != nil {
+		return nil, err
+	}
+
+	// Templated and passthrough bodies are sent as they are, so only they
+	// can be labelled with another content type
+	if contentType := os.Getenv("WEBHOOK_CONTENT_TYPE"); contentType != "" {
+		if config.BodyTemplate != nil || config.GroupMode == groupModePassthrough {
+			config.ContentType = contentType
+		} else {
+			log.Printf("Warning: WEBHOOK_CONTENT_TYPE is ignored without a body template or passthrough body, sending %s", defaultContentType)
+		}
+	}
+
+	// Parse the label and annotation filters applied before sending
+	if err := parseFilterConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the source of the payload timestamp
+	timestampSource, err := parseTimestampSource(os.Getenv("TIMESTAMP_SOURCE"))
+	if err != nil {
+		return nil, err
+	}
+	config.TimestampSource = timestampSource
+
+	// Parse whether to compute missing fingerprints from the labels
+	config.ComputeFingerprint = true
+	if err := envBool(config.StrictEnv, "COMPUTE_FINGERPRINT", &config.ComputeFingerprint); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional field the idempotency key is derived from
+	config.IdempotencyKeyField = os.Getenv("IDEMPOTENCY_KEY_FIELD")
+	if config.IdempotencyKeyField != "" && !isPayloadField(config.IdempotencyKeyField) {
+		return nil, fmt.Errorf("invalid IDEMPOTENCY_KEY_FIELD: unknown payload field '%s'", config.IdempotencyKeyField)
+	}
+	idempotencyKeyHeader, err := parseIdempotencyKeyHeader(os.Getenv("IDEMPOTENCY_KEY_HEADER"))
+	if err != nil {
+		return nil, err
+	}
+	config.IdempotencyKeyHeader = idempotencyKeyHeader
+
+	// Parse optional HMAC signing secret
+	config.SigningSecret = os.Getenv("WEBHOOK_SIGNING_SECRET")
+	signatureHeader, err := parseSignatureHeader(os.Getenv("WEBHOOK_SIGNATURE_HEADER"), config.SigningSecret)
+	if err != nil {
+		return nil, err
+	}
+	config.SignatureHeader = signatureHeader
+
+	// Parse optional body compression
+	if err := envBool(config.StrictEnv, "WEBHOOK_GZIP", &config.Gzip); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional body size limit; receivers have no common maximum,
+	// so there is none by default
+	if err := parseOversizeConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional CA bundle, client certificate and TLS verification settings
+	if err := parseTLSConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional explicit egress proxy
+	if err := parseProxyConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse how redirects are followed and whether credentials survive them
+	if err := parseRedirectConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional OAuth2 client credentials used instead of a static
+	// Authorization header
+	if err := parseOAuthConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional key JWT bearer tokens are signed with
+	if err := parseJWTConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional AWS SigV4 signing for IAM-authenticated APIs
+	if err := parseSigV4Config(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the HTTP method and optional per-status overrides
+	httpMethod, err := parseHTTPMethod(os.Getenv("HTTP_METHOD"))
+	if err != nil {
+		return nil, err
+	}
+	config.HTTPMethod = httpMethod
+	methods, err := parseMethodByStatus(os.Getenv("METHOD_BY_STATUS"))
+	if err != nil {
+		return nil, err
+	}
+	config.MethodByStatus = methods
+
+	// Parse optional alert fields sent as query parameters
+	queryFields, err := parseQueryParamFields(os.Getenv("QUERY_PARAM_FIELDS"))
+	if err != nil {
+		return nil, err
+	}
+	config.QueryParamFields = queryFields
+
+	// Parse optional output file for chaining the webhook response
+	if err := parseOutputConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional timeout
+	if err := envInt(config.StrictEnv, "TIMEOUT_SECONDS", &config.TimeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	// Parse optional retries of transient failures
+	if err := parseRetryConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse handling of alerts without an alertname label
+	mode, err := parseMissingAlertNameMode(os.Getenv("MISSING_ALERTNAME_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	config.MissingAlertNameMode = mode
+	config.AlertNameLabels = parseLabelList(os.Getenv("ALERTNAME_FROM_LABELS"))
+
+	// Parse optional severity and status validation
+	if err := parseValidationConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse optional enrichment lookup file
+	config.EnrichmentFile = os.Getenv("ENRICHMENT_FILE")
+	config.EnrichmentKeyField = os.Getenv("ENRICHMENT_KEY_FIELD")
+	if config.EnrichmentKeyField == "" {
+		config.EnrichmentKeyField = defaultEnrichmentKeyField
+	}
+	if err := validateEnrichmentKeyField(config.EnrichmentKeyField); err != nil {
+		return nil, err
+	}
+	if config.EnrichmentFile != "" {
+		enrichment, err := loadEnrichmentFile(config.EnrichmentFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Enrichment = enrichment
+	}
+
+	// Parse log config flag
+	if err := envBool(config.StrictEnv, "LOG_CONFIG", &config.LogConfig); err != nil {
+		return nil, err
+	}
+
+	// Parse payload logging options; the payload is logged by default
+	config.LogPayload = true
+	if err := envBool(config.StrictEnv, "LOG_PAYLOAD", &config.LogPayload); err != nil {
+		return nil, err
+	}
+	config.RedactFields = parseLabelList(os.Getenv("REDACT_FIELDS"))
+
+	// Parse log format, applied by setupLogging at startup
+	logFormat, err := parseLogFormat(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFormat = logFormat
+
+	// PUSHGATEWAY_URL is applied before the configuration is loaded, see
+	// setupPushgateway; it is kept here so LOG_CONFIG shows it
+	config.PushgatewayURL = os.Getenv("PUSHGATEWAY_URL")
+
+	// Parse optional sink override
+	config.Sink = os.Getenv("SINK")
+	config.SinkDir = os.Getenv("SINK_DIR")
+	if err := validateSink(config.Sink, config.SinkDir); err != nil {
+		return nil, err
+	}
+
+	// Parse dry-run flag
+	if err := envBool(config.StrictEnv, "DRY_RUN", &config.DryRun); err != nil {
+		return nil, err
+	}
+	if config.DryRun && config.Sink != "" {
+		return nil, fmt.Errorf("DRY_RUN and SINK are mutually exclusive")
+	}
+
+	// Parse optional deduplication settings
+	if err := parseDedupConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse where undeliverable alerts are sent or written
+	if err := parseDeadLetterConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Parse the optional circuit breaker shared between runs
+	if err := parseCircuitBreakerConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Reject HTTP-only settings when targets are delivered to over gRPC
+	if err := validateGRPCTargets(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// logResolvedConfig logs the effective configuration as a single JSON line.
+// The webhook URL, auth header, Redis URL and custom headers may embed
+// credentials, so they are masked.
+func logResolvedConfig(config *Config) {
+	redacted := *config
+	if redacted.WebhookURL != "" {
+		redacted.WebhookURL = redactURL(redacted.WebhookURL)
+	}
+	if redacted.WebhookURLFiring != "" {
+		redacted.WebhookURLFiring = redactURL(redacted.WebhookURLFiring)
+	}
+	if redacted.WebhookURLResolved != "" {
+		redacted.WebhookURLResolved = redactURL(redacted.WebhookURLResolved)
+	}
+	if redacted.AuthHeader != "" {
+		redacted.AuthHeader = "***"
+	}
+	redacted.Headers = redactHeaders(config.Headers)
+	if redacted.SigningSecret != "" {
+		redacted.SigningSecret = "***"
+	}
+	if redacted.OAuthClientSecret != "" {
+		redacted.OAuthClientSecret = "***"
+	}
+	if redacted.ProxyURL != "" {
+		redacted.ProxyURL = redactURL(redacted.ProxyURL)
+	}
+	if redacted.DedupRedisURL != "" {
+		redacted.DedupRedisURL = redactURL(redacted.DedupRedisURL)
+	}
+	if redacted.PushgatewayURL != "" {
+		redacted.PushgatewayURL = redactURL(redacted.PushgatewayURL)
+	}
+	if redacted.DeadLetterURL != "" {
+		redacted.DeadLetterURL = redactURL(redacted.DeadLetterURL)
+	}
+	if redacted.DeadLetterAuthHeader != "" {
+		redacted.DeadLetterAuthHeader = "***"
+	}
+	redacted.Targets = make([]WebhookTarget, len(config.Targets))
+	for i, target := range config.Targets {
+		target.URL = redactURL(target.URL)
+		if target.AuthHeader != "" {
+			target.AuthHeader = "***"
+		}
+		redacted.Targets[i] = target
+	}
+
+	redacted.Routes = make([]webhookRoute, len(config.Routes))
+	for i, route := range config.Routes {
+		route.URL = redactURL(route.URL)
+		if route.AuthHeader != "" {
+			route.AuthHeader = "***"
+		}
+		redacted.Routes[i] = route
+	}
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		log.Printf("Warning: Failed to marshal configuration: %v", err)
+		return
+	}
+	log.Printf("Resolved configuration: %s", string(data))
+}
+
+// redactURL keeps only the scheme and host of a URL, since paths and query
+// strings of webhook URLs (e.g. Slack) commonly carry tokens
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+func buildWebhookPayload(alert AlertData, timestampSource string) WebhookPayload {
 	payload := WebhookPayload{
 		Status:      alert.Status,
 		Labels:      alert.Labels,
 		Annotations: alert.Annotations,
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		StartsAt:    getValueWithFallback(alert.StartsAt, os.Getenv("ALERT_STARTS_AT")),
+		EndsAt:      getValueWithFallback(alert.EndsAt, os.Getenv("ALERT_ENDS_AT")),
+		Fingerprint: getValueWithFallback(alert.Fingerprint, os.Getenv("ALERT_FINGERPRINT")),
 	}
 
 	// Extract common fields with fallbacks to environment variables
@@ -103,6 +784,7 @@ func buildWebhookPayload(alert AlertData) WebhookPayload {
 	if payload.Status == "" {
 		payload.Status = os.Getenv("ALERT_STATUS")
 	}
+	payload.Timestamp = alertTimestamp(timestampSource, payload.Status, payload.StartsAt, payload.EndsAt)
 
 	return payload
 }
@@ -114,58 +796,237 @@ func getValueWithFallback(primary, fallback string) string {
 	return fallback
 }
 
-func sendWebhook(url string, payload WebhookPayload, timeoutSeconds int) error {
-	// Convert payload to JSON
-	jsonData, err := json.Marshal(payload)
+// requestBody renders the body for the payload and compresses it once when
+// WEBHOOK_GZIP is set, so hashes and signatures cover the bytes actually
+// sent. It returns the bytes to send, the uncompressed body and any headers
+// that describe the body, e.g. the ce-* attributes of a binary-mode
+// CloudEvent; all are nil for methods sent without a body. The uncompressed
+// body is held to MAX_PAYLOAD_BYTES.
+func requestBody(config *Config, method string, payload WebhookPayload) (body, raw []byte, header http.Header, err error) {
+	if !methodHasBody(method) {
+		return nil, nil, nil, nil
+	}
+
+	text := payloadText{
+		annotations: &payload.Annotations,
+		fields:      []*string{&payload.Summary, &payload.Description},
+		truncated:   &payload.Truncated,
+	}
+	raw, err = fitPayload(config, text, func() ([]byte, error) {
+		if config.WebhookFormat == webhookFormatForm {
+			var rendered []byte
+			rendered, header = encodeForm(payload)
+			return rendered, nil
+		}
+		if config.WebhookFormat == webhookFormatGraphQL {
+			return encodeGraphQL(config, payload)
+		}
+		rendered, err := renderBody(config, payload)
+		if err != nil || config.WebhookFormat != webhookFormatCloudEvents {
+			return rendered, err
+		}
+		rendered, header, err = encodeCloudEvent(config, payload, rendered)
+		return rendered, err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, nil, nil, err
 	}
+	if !config.Gzip {
+		return raw, raw, header, nil
+	}
+	body, err = gzipBody(raw)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return body, raw, header, nil
+}
 
-	log.Printf("Sending webhook to: %s", url)
-	log.Printf("Payload: %s", string(jsonData))
+// requestHeader adds the idempotency key of the payload, in the
+// IDEMPOTENCY_KEY_HEADER header, to the body headers from requestBody, so
+// it is sent with every method and retry
+func requestHeader(config *Config, payload WebhookPayload, header http.Header) http.Header {
+	if header == nil {
+		header = http.Header{}
+	}
+	value := ""
+	if config.IdempotencyKeyField != "" {
+		value = extractPayloadField(payload, config.IdempotencyKeyField)
+	}
+	key := idempotencyKey(config.IdempotencyKeyField, value, payload.AlertName, payload.Labels, payload.StartsAt, payload.Status)
+	header.Set(getValueWithFallback(config.IdempotencyKeyHeader, alert.IdempotencyKeyHeader), key)
+	return header
+}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: time.Duration(timeoutSeconds) * time.Second,
+// buildRequest builds the HTTP request for a target with the body as sent on
+// the wire, i.e. already compressed when WEBHOOK_GZIP is set, and the
+// QUERY_PARAM_FIELDS params appended to the URL. The body's content hash is
+// always sent, and its HMAC signature when WEBHOOK_SIGNING_SECRET is set.
+// The headers from requestHeader override the default Content-Type.
+func buildRequest(config *Config, target WebhookTarget, method string, query url.Values, header http.Header, body []byte) (*http.Request, error) {
+	contentType := config.ContentType
+	if contentType == "" {
+		contentType = defaultContentType
 	}
 
 	// Create request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(method, target.URL, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	appendQuery(req.URL, query)
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
+	// Set headers; custom headers cannot collide with the ones below, since
+	// parseCustomHeaders rejects those names
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+		if config.Gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	req.Header.Set("User-Agent", "karo-webhook-sender/1.0.0")
+	req.Header.Set(alert.ContentHashHeader, alert.ContentHash(body))
+	if config.SigningSecret != "" {
+		name := config.SignatureHeader
+		if name == "" {
+			name = alert.SignatureHeader
+		}
+		req.Header.Set(name, alert.Sign(body, config.SigningSecret))
+	}
+
+	// Add authorization header if configured; OAuth2 tokens are only
+	// requested when the request is actually sent
+	if target.AuthHeader != "" {
+		req.Header.Set("Authorization", target.AuthHeader)
+	}
+
+	return req, nil
+}
+
+// sendWebhook delivers the payload to every target, at most MAX_CONCURRENCY
+// at once and no faster than RATE_LIMIT_PER_SECOND, retrying transient
+// failures up to RETRY_COUNT times, and aggregates the per-target results
+// under FAILURE_MODE once every delivery has finished
+func sendWebhook(ctx context.Context, config *Config, payload WebhookPayload) error {
+	// Create HTTP client with timeout, proxy and TLS settings
+	client := newHTTPClient(config)
+
+	method := requestMethod(config, payload.Status)
+	query := queryParams(config, payload)
+	body, raw, header, err := requestBody(config, method, payload)
+	if err != nil {
+		return err
+	}
+	header = requestHeader(config, payload, header)
+	if raw != nil && config.LogPayload {
+		log.Printf("Payload: %s", payloadForLog(raw, config.RedactFields))
+	}
+	if config.Gzip && body != nil {
+		log.Printf("Compressed body from %d to %d bytes", len(raw), len(body))
+	}
+
+	// gRPC targets receive the Alert message of alert/alert.proto instead
+	var message []byte
+	for _, target := range config.Targets {
+		if isGRPCTarget(target) {
+			message = encodeAlert(payload)
+			break
+		}
+	}
+
+	limits := newDeliveryLimits(config)
+	start := time.Now()
+	results := make([]targetResult, len(config.Targets))
+	forEachConcurrently(len(config.Targets), limits.batchSize(len(config.Targets)), func(i int) {
+		target := config.Targets[i]
+		result := targetResult{Target: target.Name}
+		result.Err = limits.wait(ctx)
+		if result.Err == nil {
+			sendStart := time.Now()
+			targetBody := body
+			if isGRPCTarget(target) {
+				targetBody = message
+			}
+			result.Response, result.Attempts, result.Err = sendWithGzipFallback(ctx, client, config, target, method, query, header, targetBody, raw)
+			result.Duration = time.Since(sendStart)
+		}
+		if result.Err != nil && len(config.Targets) > 1 {
+			log.Printf("Target %s failed: %v", target.Name, result.Err)
+		}
+		results[i] = result
+	})
+
+	err = aggregateResults(config.FailureMode, results)
+	if config.ResultJSON {
+		writeRunResult(resultOutput, payload, results, err, time.Since(start))
+	}
+	if err != nil {
+		return err
+	}
+
+	// OUTPUT_FILE is limited to a single target, so this is its response
+	if config.OutputFile != "" && len(results) == 1 && results[0].Response != nil {
+		return writeResponseOutput(config.OutputFile, results[0], config.OutputHeaders)
+	}
+	return nil
+}
+
+// sendToTarget sends the body to one target and checks the response
+// against the target's success criteria. The response is returned when the
+// request got one, whether or not it met the criteria.
+func sendToTarget(ctx context.Context, client *http.Client, config *Config, target WebhookTarget, method string, query url.Values, header http.Header, body []byte) (*targetResponse, error) {
+	if isGRPCTarget(target) {
+		return nil, sendGRPC(ctx, config, target, header, body)
+	}
+
+	req, err := buildRequest(config, target, method, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := setOAuthToken(config, target, req); err != nil {
+		return nil, err
+	}
+	if err := signSigV4(config, target, req, body); err != nil {
+		return nil, err
+	}
 
-	// Add custom headers from environment variables
-	if authHeader := os.Getenv("AUTH_HEADER"); authHeader != "" {
-		req.Header.Set("Authorization", authHeader)
+	if target.Name != "" {
+		log.Printf("Sending webhook to target %s: %s %s", target.Name, method, redactURL(target.URL))
+	} else {
+		log.Printf("Sending webhook to: %s %s", method, redactURL(target.URL))
 	}
 
 	// Send request
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("Warning: Failed to read response body: %v", err)
 	}
 
 	log.Printf("Response status: %s", resp.Status)
-	if len(body) > 0 {
-		log.Printf("Response body: %s", string(body))
+	if len(respBody) > 0 {
+		log.Printf("Response body: %s", string(respBody))
 	}
 
-	// Check if request was successful
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook request failed with status %d: %s", resp.StatusCode, string(body))
+	// Check the response against the target's success criteria
+	response := &targetResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}
+	if err := checkResponse(withDefaultAssertions(config, target), resp.StatusCode, respBody); err != nil {
+		return response, err
 	}
-
-	return nil
+	if config.WebhookFormat == webhookFormatGraphQL {
+		return response, checkGraphQLResponse(respBody)
+	}
+	return response, nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// allowedMethods are the HTTP methods accepted in HTTP_METHOD and
+// METHOD_BY_STATUS
+var allowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// parseMethodByStatus parses METHOD_BY_STATUS, a comma-separated list of
+// status=METHOD pairs such as "firing=POST,resolved=DELETE"
+func parseMethodByStatus(spec string) (map[string]string, error) {
+	methods := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		status, method, ok := strings.Cut(entry, "=")
+		status = strings.ToLower(strings.TrimSpace(status))
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if !ok || status == "" || method == "" {
+			return nil, fmt.Errorf("invalid METHOD_BY_STATUS entry '%s', expected status=METHOD", entry)
+		}
+		if !isAllowedMethod(method) {
+			return nil, fmt.Errorf("invalid METHOD_BY_STATUS method '%s' for status '%s', must be one of %s",
+				method, status, strings.Join(allowedMethods, ", "))
+		}
+		if _, exists := methods[status]; exists {
+			return nil, fmt.Errorf("METHOD_BY_STATUS maps status '%s' more than once", status)
+		}
+		methods[status] = method
+	}
+	return methods, nil
+}
+
+// parseHTTPMethod validates HTTP_METHOD, the method for statuses that
+// METHOD_BY_STATUS doesn't map, defaulting to POST
+func parseHTTPMethod(method string) (string, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		return http.MethodPost, nil
+	}
+	if !isAllowedMethod(method) {
+		return "", fmt.Errorf("unsupported HTTP_METHOD '%s', must be one of %s", method, strings.Join(allowedMethods, ", "))
+	}
+	return method, nil
+}
+
+func isAllowedMethod(method string) bool {
+	for _, allowed := range allowedMethods {
+		if method == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMethod returns the HTTP method for an alert status: the one
+// METHOD_BY_STATUS maps the status to, otherwise HTTP_METHOD (POST by default)
+func requestMethod(config *Config, status string) string {
+	if method, ok := config.MethodByStatus[strings.ToLower(status)]; ok {
+		return method
+	}
+	if config.HTTPMethod != "" {
+		return config.HTTPMethod
+	}
+	return http.MethodPost
+}
+
+// methodHasBody reports whether requests with the method carry the payload.
+// GET and DELETE requests are sent without a body, since many servers reject
+// one; QUERY_PARAM_FIELDS can carry alert fields instead.
+func methodHasBody(method string) bool {
+	return method != http.MethodGet && method != http.MethodDelete
+}
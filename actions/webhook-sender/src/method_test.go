@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseMethodByStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "unset", spec: "", want: map[string]string{}},
+		{name: "firing and resolved", spec: "firing=POST,resolved=DELETE", want: map[string]string{"firing": "POST", "resolved": "DELETE"}},
+		{name: "normalized case and spaces", spec: " Firing = put , resolved=patch ", want: map[string]string{"firing": "PUT", "resolved": "PATCH"}},
+		{name: "GET for query-only receivers", spec: "firing=GET", want: map[string]string{"firing": "GET"}},
+		{name: "method not allowed", spec: "firing=HEAD", wantErr: true},
+		{name: "unknown method", spec: "firing=FETCH", wantErr: true},
+		{name: "missing method", spec: "firing=", wantErr: true},
+		{name: "missing separator", spec: "firing", wantErr: true},
+		{name: "duplicate status", spec: "firing=POST,firing=PUT", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMethodByStatus(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMethodByStatus() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assertStringMap(t, "methods", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHTTPMethod(t *testing.T) {
+	tests := []struct {
+		method  string
+		want    string
+		wantErr bool
+	}{
+		{method: "", want: "POST"},
+		{method: "put", want: "PUT"},
+		{method: " PATCH ", want: "PATCH"},
+		{method: "GET", want: "GET"},
+		{method: "HEAD", wantErr: true},
+		{method: "FETCH", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseHTTPMethod(tt.method)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHTTPMethod(%q) error = %v, wantErr %t", tt.method, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseHTTPMethod(%q) = %q, want %q", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestSendWebhookMethodByStatus(t *testing.T) {
+	methods, err := parseMethodByStatus("firing=POST,resolved=DELETE")
+	if err != nil {
+		t.Fatalf("parseMethodByStatus() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		status          string
+		httpMethod      string
+		wantMethod      string
+		wantBody        bool
+		wantContentType string
+	}{
+		{name: "firing posts the payload", status: "firing", wantMethod: "POST", wantBody: true, wantContentType: "application/json"},
+		{name: "resolved deletes without a body", status: "resolved", wantMethod: "DELETE"},
+		{name: "unmapped status defaults to POST", status: "pending", wantMethod: "POST", wantBody: true, wantContentType: "application/json"},
+		{name: "unmapped status uses HTTP_METHOD", status: "pending", httpMethod: "PATCH", wantMethod: "PATCH", wantBody: true, wantContentType: "application/json"},
+		{name: "METHOD_BY_STATUS wins over HTTP_METHOD", status: "resolved", httpMethod: "PUT", wantMethod: "DELETE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotContentType string
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotMethod = r.Method
+				gotContentType = r.Header.Get("Content-Type")
+				gotBody, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:        []WebhookTarget{{URL: server.URL}},
+				HTTPMethod:     tt.httpMethod,
+				MethodByStatus: methods,
+				Gzip:           true,
+				TimeoutSeconds: 5,
+			}
+			var err error
+			captureLog(t, func() {
+				err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull", Status: tt.status})
+			})
+			if err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+
+			if gotMethod != tt.wantMethod {
+				t.Errorf("method = %s, want %s", gotMethod, tt.wantMethod)
+			}
+			if (len(gotBody) > 0) != tt.wantBody {
+				t.Errorf("body = %q, want body %t", gotBody, tt.wantBody)
+			}
+			if gotContentType != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", gotContentType, tt.wantContentType)
+			}
+		})
+	}
+}
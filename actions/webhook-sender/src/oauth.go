@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// parseOAuthConfig reads the OAuth2 client-credentials settings. OAuth is off
+// unless one of them is set, and then OAUTH_TOKEN_URL, OAUTH_CLIENT_ID and
+// OAUTH_CLIENT_SECRET are all required. It must run after parseTLSConfig,
+// since tokens are requested with the same proxy and TLS settings as the
+// webhook.
+func parseOAuthConfig(config *Config) error {
+	config.OAuthTokenURL = os.Getenv("OAUTH_TOKEN_URL")
+	config.OAuthClientID = os.Getenv("OAUTH_CLIENT_ID")
+	config.OAuthClientSecret = os.Getenv("OAUTH_CLIENT_SECRET")
+	config.OAuthScopes = parseLabelList(strings.ReplaceAll(os.Getenv("OAUTH_SCOPES"), " ", ","))
+	config.OAuthAudience = os.Getenv("OAUTH_AUDIENCE")
+
+	var missing []string
+	for _, setting := range []struct{ name, value string }{
+		{"OAUTH_TOKEN_URL", config.OAuthTokenURL},
+		{"OAUTH_CLIENT_ID", config.OAuthClientID},
+		{"OAUTH_CLIENT_SECRET", config.OAuthClientSecret},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	switch {
+	case len(missing) == 3 && len(config.OAuthScopes) == 0 && config.OAuthAudience == "":
+		return nil
+	case len(missing) > 0:
+		return fmt.Errorf("OAuth2 client credentials require %s to be set", strings.Join(missing, ", "))
+	case config.AuthHeader != "":
+		return fmt.Errorf("OAUTH_TOKEN_URL and AUTH_HEADER, WEBHOOK_BEARER_TOKEN, WEBHOOK_BASIC_USER/WEBHOOK_BASIC_PASS are mutually exclusive, configure only one auth method")
+	}
+
+	credentials := &clientcredentials.Config{
+		ClientID:     config.OAuthClientID,
+		ClientSecret: config.OAuthClientSecret,
+		TokenURL:     config.OAuthTokenURL,
+		Scopes:       config.OAuthScopes,
+	}
+	// Providers such as Auth0 issue tokens for the API named by audience
+	if config.OAuthAudience != "" {
+		credentials.EndpointParams = url.Values{"audience": {config.OAuthAudience}}
+	}
+	// The token source caches the token until shortly before it expires, so
+	// every request of the run reuses it
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, newHTTPClient(config))
+	config.OAuthTokenSource = credentials.TokenSource(ctx)
+	return nil
+}
+
+// setOAuthToken sets the Authorization header of a request to a target
+// without its own header to the OAuth2 token, if OAuth2 is configured
+func setOAuthToken(config *Config, target WebhookTarget, req *http.Request) error {
+	if config.OAuthTokenSource == nil || target.AuthHeader != "" {
+		return nil
+	}
+
+	token, err := config.OAuthTokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get OAuth2 token: %w", err)
+	}
+	token.SetAuthHeader(req)
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseOAuthConfig(t *testing.T) {
+	complete := map[string]string{
+		"OAUTH_TOKEN_URL":     "https://auth.example.com/oauth/token",
+		"OAUTH_CLIENT_ID":     "karo",
+		"OAUTH_CLIENT_SECRET": "s3cret",
+	}
+	withScopes := map[string]string{"OAUTH_SCOPES": "alerts:write, incidents:write", "OAUTH_AUDIENCE": "https://api.example.com"}
+	for key, value := range complete {
+		withScopes[key] = value
+	}
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		authHeader string
+		wantOAuth  bool
+		wantScopes []string
+		wantErr    string
+	}{
+		{name: "not configured", env: map[string]string{}},
+		{name: "client credentials", env: complete, wantOAuth: true},
+		{name: "scopes and audience", env: withScopes, wantOAuth: true, wantScopes: []string{"alerts:write", "incidents:write"}},
+		{name: "missing secret", env: map[string]string{"OAUTH_TOKEN_URL": "https://auth.example.com/oauth/token", "OAUTH_CLIENT_ID": "karo"}, wantErr: "require OAUTH_CLIENT_SECRET"},
+		{name: "scopes only", env: map[string]string{"OAUTH_SCOPES": "alerts:write"}, wantErr: "require OAUTH_TOKEN_URL, OAUTH_CLIENT_ID, OAUTH_CLIENT_SECRET"},
+		{name: "audience only", env: map[string]string{"OAUTH_AUDIENCE": "https://api.example.com"}, wantErr: "require OAUTH_TOKEN_URL"},
+		{name: "static header as well", env: complete, authHeader: "Bearer static", wantErr: "mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OAUTH_TOKEN_URL", "OAUTH_CLIENT_ID", "OAUTH_CLIENT_SECRET", "OAUTH_SCOPES", "OAUTH_AUDIENCE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{AuthHeader: tt.authHeader}
+			err := parseOAuthConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseOAuthConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseOAuthConfig() unexpected error: %v", err)
+			}
+			if (config.OAuthTokenSource != nil) != tt.wantOAuth {
+				t.Errorf("token source configured = %t, want %t", config.OAuthTokenSource != nil, tt.wantOAuth)
+			}
+			if strings.Join(config.OAuthScopes, " ") != strings.Join(tt.wantScopes, " ") {
+				t.Errorf("scopes = %v, want %v", config.OAuthScopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestSendWebhookOAuthToken(t *testing.T) {
+	var tokenRequests int
+	var gotScope, gotAudience string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		r.ParseForm()
+		gotScope = r.PostForm.Get("scope")
+		gotAudience = r.PostForm.Get("audience")
+		if user, pass, ok := r.BasicAuth(); !ok || user != "karo" || pass != "s3cret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"short-lived","token_type":"bearer","expires_in":300}`))
+	}))
+	defer tokenServer.Close()
+
+	var mu sync.Mutex
+	var gotAuth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotAuth = append(gotAuth, r.URL.Path+" "+r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	t.Setenv("OAUTH_TOKEN_URL", tokenServer.URL)
+	t.Setenv("OAUTH_CLIENT_ID", "karo")
+	t.Setenv("OAUTH_CLIENT_SECRET", "s3cret")
+	t.Setenv("OAUTH_SCOPES", "alerts:write")
+	t.Setenv("OAUTH_AUDIENCE", "https://api.example.com")
+	config := &Config{
+		Targets: []WebhookTarget{
+			{Name: "api", URL: server.URL + "/api"},
+			{Name: "legacy", URL: server.URL + "/legacy", AuthHeader: "Bearer static"},
+		},
+		MaxConcurrency: 1,
+		TimeoutSeconds: 5,
+	}
+	if err := parseOAuthConfig(config); err != nil {
+		t.Fatalf("parseOAuthConfig() unexpected error: %v", err)
+	}
+
+	// A second delivery in the same run reuses the cached token
+	for i := 0; i < 2; i++ {
+		var err error
+		captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+		if err != nil {
+			t.Fatalf("sendWebhook() unexpected error: %v", err)
+		}
+	}
+
+	if tokenRequests != 1 || gotScope != "alerts:write" || gotAudience != "https://api.example.com" {
+		t.Errorf("token requests = %d with scope %q and audience %q, want 1 with alerts:write for the API", tokenRequests, gotScope, gotAudience)
+	}
+	want := "/api Bearer short-lived,/legacy Bearer static,/api Bearer short-lived,/legacy Bearer static"
+	if got := strings.Join(gotAuth, ","); got != want {
+		t.Errorf("Authorization headers = %s, want %s", got, want)
+	}
+}
+
+func TestSendWebhookOAuthTokenFailure(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+	}))
+	defer tokenServer.Close()
+
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	t.Setenv("OAUTH_TOKEN_URL", tokenServer.URL)
+	t.Setenv("OAUTH_CLIENT_ID", "karo")
+	t.Setenv("OAUTH_CLIENT_SECRET", "wrong")
+	t.Setenv("OAUTH_SCOPES", "")
+	config := &Config{Targets: []WebhookTarget{{URL: server.URL}}, TimeoutSeconds: 5}
+	if err := parseOAuthConfig(config); err != nil {
+		t.Fatalf("parseOAuthConfig() unexpected error: %v", err)
+	}
+
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err == nil || !strings.Contains(err.Error(), "failed to get OAuth2 token") {
+		t.Errorf("sendWebhook() error = %v, want a token error", err)
+	}
+	if called {
+		t.Error("the webhook should not be called without a token")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// targetResponse is what a target answered, kept so it can be written to
+// OUTPUT_FILE after a successful delivery
+type targetResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// resultOutput receives the RESULT_JSON lines
+var resultOutput io.Writer = os.Stdout
+
+// ResponseOutput is written to OUTPUT_FILE so the webhook response, e.g. the
+// ID of an incident created by the receiver, can be consumed by a subsequent
+// action
+type ResponseOutput struct {
+	StatusCode int                 `json:"statusCode"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       json.RawMessage     `json:"body,omitempty"`
+	Attempts   int                 `json:"attempts"`
+	DurationMs int64               `json:"durationMs"`
+}
+
+// RunResult is the RESULT_JSON line summarizing a delivery, so log
+// pipelines and later reactions can consume the outcome without parsing
+// log messages
+type RunResult struct {
+	AlertName  string          `json:"alertName,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Success    bool            `json:"success"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs int64           `json:"durationMs"`
+	Targets    []TargetOutcome `json:"targets"`
+}
+
+// TargetOutcome is one target's part of a RunResult. The status code and
+// body are those of the last attempt, if any got a response.
+type TargetOutcome struct {
+	Name       string          `json:"name,omitempty"`
+	StatusCode int             `json:"statusCode,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Attempts   int             `json:"attempts"`
+	DurationMs int64           `json:"durationMs"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// parseOutputConfig reads OUTPUT_FILE, OUTPUT_HEADERS and RESULT_JSON. The
+// output file holds a single response, so it cannot be combined with a
+// WEBHOOK_TARGETS fan-out to several targets.
+func parseOutputConfig(config *Config) error {
+	config.OutputFile = os.Getenv("OUTPUT_FILE")
+	if err := envBool(config.StrictEnv, "OUTPUT_HEADERS", &config.OutputHeaders); err != nil {
+		return err
+	}
+	if err := envBool(config.StrictEnv, "RESULT_JSON", &config.ResultJSON); err != nil {
+		return err
+	}
+	if config.OutputFile != "" && len(config.Targets) > 1 {
+		return fmt.Errorf("OUTPUT_FILE is not supported with more than one target in WEBHOOK_TARGETS")
+	}
+	if config.OutputHeaders && config.OutputFile == "" {
+		log.Printf("Warning: OUTPUT_HEADERS is ignored without OUTPUT_FILE")
+	}
+	return nil
+}
+
+// newResponseOutput summarizes a delivery for OUTPUT_FILE
+func newResponseOutput(result targetResult, includeHeaders bool) ResponseOutput {
+	output := ResponseOutput{
+		StatusCode: result.Response.StatusCode,
+		Body:       outputBody(result.Response.Body),
+		Attempts:   result.Attempts,
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	if includeHeaders {
+		output.Headers = result.Response.Header
+	}
+	return output
+}
+
+// outputBody keeps a JSON response body structured so consumers don't have
+// to decode it twice; any other body is written as a string
+func outputBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// writeResponseOutput writes the response status code, body, attempts,
+// duration and optionally headers as JSON to path
+func writeResponseOutput(path string, result targetResult, includeHeaders bool) error {
+	data, err := json.MarshalIndent(newResponseOutput(result, includeHeaders), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal OUTPUT_FILE: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write OUTPUT_FILE: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write OUTPUT_FILE: %w", err)
+	}
+
+	log.Printf("Webhook response written to %s", path)
+	return nil
+}
+
+// writeRunResult writes the outcome of a delivery as a single JSON line,
+// whether it succeeded or not
+func writeRunResult(w io.Writer, payload WebhookPayload, results []targetResult, err error, duration time.Duration) {
+	result := RunResult{
+		AlertName:  payload.AlertName,
+		Status:     payload.Status,
+		Success:    err == nil,
+		DurationMs: duration.Milliseconds(),
+		Targets:    make([]TargetOutcome, len(results)),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for i, target := range results {
+		outcome := TargetOutcome{
+			Name:       target.Target,
+			Attempts:   target.Attempts,
+			DurationMs: target.Duration.Milliseconds(),
+		}
+		if target.Response != nil {
+			outcome.StatusCode = target.Response.StatusCode
+			outcome.Body = outputBody(target.Response.Body)
+		}
+		if target.Err != nil {
+			outcome.Error = target.Err.Error()
+		}
+		result.Targets[i] = outcome
+	}
+
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		log.Printf("Warning: Failed to marshal RESULT_JSON: %v", marshalErr)
+		return
+	}
+	if _, writeErr := w.Write(append(data, '\n')); writeErr != nil {
+		log.Printf("Warning: Failed to write RESULT_JSON: %v", writeErr)
+	}
+}
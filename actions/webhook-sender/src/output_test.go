@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSendWebhookWritesOutputFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		contentType   string
+		body          string
+		headers       bool
+		wantWritten   bool
+		wantBody      string
+		wantRequestID string
+	}{
+		{
+			name:        "JSON body is nested",
+			status:      http.StatusCreated,
+			contentType: "application/json",
+			body:        `{"incident":{"id":"INC-42"}}`,
+			wantWritten: true,
+			wantBody:    `{"incident":{"id":"INC-42"}}`,
+		},
+		{
+			name:        "text body is a string",
+			status:      http.StatusOK,
+			contentType: "text/plain",
+			body:        "queued",
+			wantWritten: true,
+			wantBody:    `"queued"`,
+		},
+		{
+			name:          "headers are included when enabled",
+			status:        http.StatusOK,
+			contentType:   "application/json",
+			body:          `{}`,
+			headers:       true,
+			wantWritten:   true,
+			wantBody:      `{}`,
+			wantRequestID: "req-1",
+		},
+		{
+			name:   "failed delivery writes nothing",
+			status: http.StatusInternalServerError,
+			body:   `{"error":"boom"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("X-Request-Id", "req-1")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "out", "response.json")
+			config := &Config{
+				Targets:        []WebhookTarget{{URL: server.URL}},
+				OutputFile:     path,
+				OutputHeaders:  tt.headers,
+				TimeoutSeconds: 5,
+			}
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if (err != nil) == tt.wantWritten {
+				t.Fatalf("sendWebhook() error = %v, want success %t", err, tt.wantWritten)
+			}
+
+			data, readErr := os.ReadFile(path)
+			if !tt.wantWritten {
+				if !errors.Is(readErr, os.ErrNotExist) {
+					t.Errorf("OUTPUT_FILE should not be written on failure, read error = %v", readErr)
+				}
+				return
+			}
+			if readErr != nil {
+				t.Fatalf("failed to read OUTPUT_FILE: %v", readErr)
+			}
+
+			var output ResponseOutput
+			if err := json.Unmarshal(data, &output); err != nil {
+				t.Fatalf("OUTPUT_FILE is not valid JSON: %v\n%s", err, data)
+			}
+			if output.StatusCode != tt.status || output.Attempts != 1 {
+				t.Errorf("statusCode, attempts = %d, %d, want %d, 1", output.StatusCode, output.Attempts, tt.status)
+			}
+			var body bytes.Buffer
+			json.Compact(&body, output.Body)
+			if body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", body.String(), tt.wantBody)
+			}
+			if got := http.Header(output.Headers).Get("X-Request-Id"); got != tt.wantRequestID {
+				t.Errorf("X-Request-Id header = %q, want %q", got, tt.wantRequestID)
+			}
+		})
+	}
+}
+
+func TestSendWebhookResultJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":"INC-42"}`))
+	}))
+	defer server.Close()
+
+	var stdout bytes.Buffer
+	resultOutput = &stdout
+	defer func() { resultOutput = os.Stdout }()
+
+	config := &Config{
+		Targets: []WebhookTarget{
+			{Name: "tickets", URL: server.URL + "/tickets"},
+			{Name: "chat", URL: server.URL + "/down"},
+		},
+		FailureMode:       failureModeAny,
+		MaxConcurrency:    1,
+		ResultJSON:        true,
+		RetryCount:        1,
+		RetryBackoffMs:    1,
+		RetryMaxBackoffMs: 1,
+		TimeoutSeconds:    5,
+	}
+	var err error
+	captureLog(t, func() {
+		err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull", Status: "firing"})
+	})
+	if err == nil {
+		t.Fatal("sendWebhook() should fail when a target fails with FAILURE_MODE=any")
+	}
+
+	// The result is a single JSON line on stdout
+	if bytes.Count(stdout.Bytes(), []byte("\n")) != 1 {
+		t.Fatalf("stdout = %q, want one line", stdout.String())
+	}
+	var result RunResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("result is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if result.Success || result.Error == "" || result.AlertName != "DiskFull" || len(result.Targets) != 2 {
+		t.Fatalf("result = %+v, want a failed run of two targets", result)
+	}
+	tickets, chat := result.Targets[0], result.Targets[1]
+	if tickets.StatusCode != http.StatusOK || string(tickets.Body) != `{"id":"INC-42"}` || tickets.Attempts != 1 || tickets.Error != "" {
+		t.Errorf("tickets outcome = %+v", tickets)
+	}
+	if chat.StatusCode != http.StatusServiceUnavailable || string(chat.Body) != `"unavailable\n"` || chat.Attempts != 2 || chat.Error == "" {
+		t.Errorf("chat outcome = %+v", chat)
+	}
+}
+
+func TestParseOutputConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets int
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "unset", targets: 1, env: map[string]string{}},
+		{name: "single target", targets: 1, env: map[string]string{"OUTPUT_FILE": "/tmp/out.json", "OUTPUT_HEADERS": "true"}},
+		{name: "fan-out is rejected", targets: 2, env: map[string]string{"OUTPUT_FILE": "/tmp/out.json"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OUTPUT_FILE", "OUTPUT_HEADERS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{Targets: make([]WebhookTarget, tt.targets)}
+			err := parseOutputConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOutputConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.OutputFile != tt.env["OUTPUT_FILE"] {
+				t.Errorf("OutputFile = %q, want %q", config.OutputFile, tt.env["OUTPUT_FILE"])
+			}
+		})
+	}
+}
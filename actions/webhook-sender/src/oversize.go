@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"unicode/utf8"
+)
+
+// ON_OVERSIZE values
+const (
+	onOversizeFail     = "fail"
+	onOversizeTruncate = "truncate"
+)
+
+// truncatedSuffix marks a value trimmed by ON_OVERSIZE=truncate
+const truncatedSuffix = "...[truncated]"
+
+// parseOversizeConfig reads MAX_PAYLOAD_BYTES over the default already set
+// on config, where 0 disables the check, and ON_OVERSIZE
+func parseOversizeConfig(config *Config) error {
+	if err := envInt(config.StrictEnv, "MAX_PAYLOAD_BYTES", &config.MaxPayloadBytes); err != nil {
+		return err
+	}
+	if config.MaxPayloadBytes < 0 {
+		return fmt.Errorf("MAX_PAYLOAD_BYTES must not be negative, got %d", config.MaxPayloadBytes)
+	}
+
+	switch mode := os.Getenv("ON_OVERSIZE"); mode {
+	case "", onOversizeFail:
+		config.OnOversize = onOversizeFail
+	case onOversizeTruncate:
+		config.OnOversize = mode
+	default:
+		return fmt.Errorf("unsupported ON_OVERSIZE '%s', must be 'fail' or 'truncate'", mode)
+	}
+	return nil
+}
+
+// payloadText points at the free text of a payload that ON_OVERSIZE=truncate
+// may trim, and at its truncated marker
+type payloadText struct {
+	annotations *map[string]string
+	fields      []*string
+	truncated   *bool
+}
+
+// fitPayload marshals the payload with marshal and checks the size against
+// MAX_PAYLOAD_BYTES. With ON_OVERSIZE=truncate the largest text value is
+// trimmed and the payload marked truncated until it fits; the annotations
+// are copied first so the alert itself is left intact.
+func fitPayload(config *Config, text payloadText, marshal func() ([]byte, error)) ([]byte, error) {
+	copied := false
+	for {
+		data, err := marshal()
+		if err != nil || config.MaxPayloadBytes == 0 || len(data) <= config.MaxPayloadBytes {
+			return data, err
+		}
+		if config.OnOversize != onOversizeTruncate {
+			return nil, fmt.Errorf("payload %d bytes exceeds limit %d (MAX_PAYLOAD_BYTES)", len(data), config.MaxPayloadBytes)
+		}
+
+		if !copied {
+			*text.annotations = maps.Clone(*text.annotations)
+			copied = true
+		}
+		if !trimLargest(text, len(data)-config.MaxPayloadBytes) {
+			return nil, fmt.Errorf("payload %d bytes exceeds limit %d (MAX_PAYLOAD_BYTES) even with every annotation dropped", len(data), config.MaxPayloadBytes)
+		}
+		*text.truncated = true
+	}
+}
+
+// trimLargest shortens the largest text value by excess bytes, making room
+// for truncatedSuffix. Values too short to keep anything are dropped. It
+// reports false when there is nothing left to trim.
+func trimLargest(text payloadText, excess int) bool {
+	annotations := *text.annotations
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	largestKey, largestField, size := "", (*string)(nil), 0
+	for _, key := range keys {
+		if len(annotations[key]) > size {
+			largestKey, size = key, len(annotations[key])
+		}
+	}
+	for _, field := range text.fields {
+		if len(*field) > size {
+			largestField, size = field, len(*field)
+		}
+	}
+	if size == 0 {
+		return false
+	}
+
+	if largestField != nil {
+		*largestField = trimValue(*largestField, excess)
+		return true
+	}
+	if value := trimValue(annotations[largestKey], excess); value != "" {
+		annotations[largestKey] = value
+	} else {
+		delete(annotations, largestKey)
+	}
+	return true
+}
+
+// trimValue removes excess bytes plus the room for truncatedSuffix from the
+// end of value without splitting a UTF-8 sequence, or returns "" when
+// nothing would be left
+func trimValue(value string, excess int) string {
+	keep := len(value) - excess - len(truncatedSuffix)
+	if keep <= 0 {
+		return ""
+	}
+	for keep > 0 && !utf8.RuneStart(value[keep]) {
+		keep--
+	}
+	return value[:keep] + truncatedSuffix
+}
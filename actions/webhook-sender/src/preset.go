@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// presetTemplates are built-in body templates that render the payload in
+// the native message schema of common chat receivers, so their incoming
+// webhooks can be used without a transformer in between. Each one shows the
+// status and alert name as the title, the summary and description, and the
+// severity and instance.
+var presetTemplates = map[string]string{
+	// Slack incoming webhooks, as a legacy attachment with a status color
+	"slack": `{
+  "text": {{ title . | json }},
+  "attachments": [{
+    "color": {{ if eq .Status "resolved" }}"good"{{ else if eq .Severity "critical" }}"danger"{{ else }}"warning"{{ end }},
+    "title": {{ .Summary | default .AlertName | json }},
+    "text": {{ .Description | json }},
+    "fields": [
+      {"title": "Severity", "value": {{ .Severity | default "-" | json }}, "short": true},
+      {"title": "Instance", "value": {{ .Instance | default "-" | json }}, "short": true}
+    ]
+  }]
+}`,
+	// Microsoft Teams incoming webhooks (Workflows), as an Adaptive Card
+	"teams": `{
+  "type": "message",
+  "attachments": [{
+    "contentType": "application/vnd.microsoft.card.adaptive",
+    "content": {
+      "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+      "type": "AdaptiveCard",
+      "version": "1.4",
+      "body": [
+        {"type": "TextBlock", "size": "Large", "weight": "Bolder", "wrap": true, "text": {{ title . | json }},
+         "color": {{ if eq .Status "resolved" }}"Good"{{ else if eq .Severity "critical" }}"Attention"{{ else }}"Warning"{{ end }}},
+        {"type": "TextBlock", "wrap": true, "text": {{ .Summary | default .AlertName | json }}},
+        {{- with .Description }}
+        {"type": "TextBlock", "wrap": true, "isSubtle": true, "text": {{ json . }}},
+        {{- end }}
+        {"type": "FactSet", "facts": [
+          {"title": "Severity", "value": {{ .Severity | default "-" | json }}},
+          {"title": "Instance", "value": {{ .Instance | default "-" | json }}}
+        ]}
+      ]
+    }
+  }]
+}`,
+	// Discord webhooks, as an embed with a status color
+	"discord": `{
+  "embeds": [{
+    "title": {{ title . | json }},
+    "description": {{ .Summary | default .AlertName | json }},
+    "color": {{ if eq .Status "resolved" }}3066993{{ else if eq .Severity "critical" }}15158332{{ else }}15105570{{ end }},
+    "fields": [
+      {{- with .Description }}
+      {"name": "Description", "value": {{ json . }}},
+      {{- end }}
+      {"name": "Severity", "value": {{ .Severity | default "-" | json }}, "inline": true},
+      {"name": "Instance", "value": {{ .Instance | default "-" | json }}, "inline": true}
+    ]
+    {{- with .Timestamp }},
+    "timestamp": {{ json . }}
+    {{- end }}
+  }]
+}`,
+	// Google Chat incoming webhooks, as a card
+	"googlechat": `{
+  "text": {{ title . | json }},
+  "cardsV2": [{
+    "cardId": "alert",
+    "card": {
+      "header": {"title": {{ title . | json }}, "subtitle": {{ .Summary | json }}},
+      "sections": [{
+        "widgets": [
+          {{- with .Description }}
+          {"textParagraph": {"text": {{ json . }}}},
+          {{- end }}
+          {"decoratedText": {"topLabel": "Severity", "text": {{ .Severity | default "-" | json }}}},
+          {"decoratedText": {"topLabel": "Instance", "text": {{ .Instance | default "-" | json }}}}
+        ]
+      }]
+    }
+  }]
+}`,
+}
+
+// presetFuncs extend templateFuncs for the presets
+var presetFuncs = template.FuncMap{
+	// title is the message headline, e.g. "[FIRING] DiskFull"
+	"title": func(payload WebhookPayload) string {
+		status := strings.ToUpper(payload.Status)
+		if status == "" {
+			return payload.AlertName
+		}
+		return fmt.Sprintf("[%s] %s", status, payload.AlertName)
+	},
+}
+
+// parsePreset reads WEBHOOK_PRESET and, when set, uses the preset's template
+// as the body template. A preset replaces the body template, so both cannot
+// be set.
+func parsePreset(config *Config) error {
+	config.Preset = os.Getenv("WEBHOOK_PRESET")
+	if config.Preset == "" {
+		return nil
+	}
+
+	text, ok := presetTemplates[config.Preset]
+	if !ok {
+		return fmt.Errorf("unsupported WEBHOOK_PRESET '%s', must be one of: %s", config.Preset, strings.Join(presetNames(), ", "))
+	}
+	if config.BodyTemplate != nil {
+		return fmt.Errorf("WEBHOOK_PRESET and WEBHOOK_BODY_TEMPLATE/WEBHOOK_BODY_TEMPLATE_FILE are mutually exclusive")
+	}
+
+	config.BodyTemplate = template.Must(template.New("preset " + config.Preset).Funcs(templateFuncs).Funcs(presetFuncs).Parse(text))
+	return nil
+}
+
+// presetNames returns the supported WEBHOOK_PRESET values in order
+func presetNames() []string {
+	names := make([]string, 0, len(presetTemplates))
+	for name := range presetTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
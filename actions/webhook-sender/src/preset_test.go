@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParsePreset(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		template bool
+		wantErr  string
+	}{
+		{name: "unset", preset: ""},
+		{name: "slack", preset: "slack"},
+		{name: "unknown", preset: "mattermost", wantErr: "unsupported WEBHOOK_PRESET 'mattermost', must be one of: discord, googlechat, slack, teams"},
+		{name: "with body template", preset: "teams", template: true, wantErr: "mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_PRESET", tt.preset)
+
+			config := &Config{}
+			if tt.template {
+				config.BodyTemplate, _ = parseBodyTemplate(`{}`, "")
+			}
+			err := parsePreset(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parsePreset() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePreset() unexpected error: %v", err)
+			}
+			if (config.BodyTemplate != nil) != (tt.preset != "") {
+				t.Errorf("BodyTemplate set = %t, want %t", config.BodyTemplate != nil, tt.preset != "")
+			}
+		})
+	}
+}
+
+func TestPresetsRenderJSON(t *testing.T) {
+	payloads := map[string]WebhookPayload{
+		"full": {
+			AlertName:   "DiskFull",
+			Status:      "firing",
+			Severity:    "critical",
+			Instance:    "node-1",
+			Summary:     `Disk "/" is full`,
+			Description: "Only 1% left\non /dev/sda1",
+			Timestamp:   "2024-01-01T00:00:00Z",
+		},
+		"resolved": {AlertName: "DiskFull", Status: "resolved"},
+		"empty":    {},
+	}
+
+	for _, preset := range presetNames() {
+		for name, payload := range payloads {
+			t.Run(preset+"/"+name, func(t *testing.T) {
+				t.Setenv("WEBHOOK_PRESET", preset)
+				config := &Config{}
+				if err := parsePreset(config); err != nil {
+					t.Fatalf("parsePreset() unexpected error: %v", err)
+				}
+
+				body, err := renderBody(config, payload)
+				if err != nil {
+					t.Fatalf("renderBody() unexpected error: %v", err)
+				}
+				var message map[string]interface{}
+				if err := json.Unmarshal(body, &message); err != nil {
+					t.Fatalf("preset rendered invalid JSON: %v\n%s", err, body)
+				}
+				if payload.AlertName != "" && !strings.Contains(string(body), payload.AlertName) {
+					t.Errorf("rendered body doesn't mention the alert name:\n%s", body)
+				}
+			})
+		}
+	}
+}
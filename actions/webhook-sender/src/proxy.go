@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// parseProxyConfig reads PROXY_URL, an explicit proxy for all requests that
+// takes precedence over HTTPS_PROXY and HTTP_PROXY, and the optional
+// PROXY_USERNAME and PROXY_PASSWORD (or PROXY_PASSWORD_FILE) to
+// authenticate to it
+func parseProxyConfig(config *Config) error {
+	username := os.Getenv("PROXY_USERNAME")
+	password, err := secretEnv("PROXY_PASSWORD")
+	if err != nil {
+		return err
+	}
+
+	config.ProxyURL = os.Getenv("PROXY_URL")
+	if config.ProxyURL == "" {
+		if username != "" || password != "" {
+			return fmt.Errorf("PROXY_USERNAME and PROXY_PASSWORD require PROXY_URL")
+		}
+		return nil
+	}
+
+	proxy, err := url.Parse(config.ProxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid PROXY_URL: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported PROXY_URL scheme '%s', must be 'http', 'https' or 'socks5'", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return fmt.Errorf("invalid PROXY_URL: missing host")
+	}
+
+	// Credentials in the URL are used as they are, unless set separately
+	if username != "" {
+		proxy.User = url.UserPassword(username, password)
+	} else if password != "" {
+		return fmt.Errorf("PROXY_USERNAME is required when PROXY_PASSWORD is set")
+	}
+	config.Proxy = proxy
+	return nil
+}
+
+// proxyFunc returns the transport's proxy selection: PROXY_URL for every
+// host not excluded by NO_PROXY, or else the standard proxy variables
+func proxyFunc(config *Config) func(*http.Request) (*url.URL, error) {
+	if config.Proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  config.Proxy.String(),
+		HTTPSProxy: config.Proxy.String(),
+		NoProxy:    os.Getenv("NO_PROXY"),
+	}
+	selectProxy := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// parseQueryParamFields parses QUERY_PARAM_FIELDS, a comma-separated list of
+// param=field pairs such as "alert=alertName,host=labels.instance"
+func parseQueryParamFields(spec string) (map[string]string, error) {
+	return parseFieldMap("QUERY_PARAM_FIELDS", "param", spec)
+}
+
+// parseFieldMap parses a comma-separated list of name=field pairs of the
+// setting, mapping names of the given kind to payload fields
+func parseFieldMap(setting, kind, spec string) (map[string]string, error) {
+	fields := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, field, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		field = strings.TrimSpace(field)
+		if !ok || name == "" || field == "" {
+			return nil, fmt.Errorf("invalid %s entry '%s', expected %s=field", setting, entry, kind)
+		}
+		if !isPayloadField(field) {
+			return nil, fmt.Errorf("invalid %s field '%s' for %s '%s': unknown payload field", setting, field, kind, name)
+		}
+		if _, exists := fields[name]; exists {
+			return nil, fmt.Errorf("%s maps %s '%s' more than once", setting, kind, name)
+		}
+		fields[name] = field
+	}
+	return fields, nil
+}
+
+// payloadFields are the top-level payload fields extractPayloadField
+// resolves, besides labels.<key> and annotations.<key>
+var payloadFields = []string{"alertName", "status", "severity", "instance", "summary", "description", "startsAt", "endsAt", "fingerprint", "timestamp"}
+
+// isPayloadField reports whether extractPayloadField can resolve the field path
+func isPayloadField(fieldPath string) bool {
+	for _, prefix := range []string{"labels.", "annotations."} {
+		if key, found := strings.CutPrefix(fieldPath, prefix); found {
+			return key != ""
+		}
+	}
+	return slices.Contains(payloadFields, fieldPath)
+}
+
+// extractPayloadField resolves a dot-notation field path against the payload
+// Examples: "alertName", "severity", "labels.instance", "annotations.team"
+func extractPayloadField(payload WebhookPayload, fieldPath string) string {
+	if key, found := strings.CutPrefix(fieldPath, "labels."); found {
+		return payload.Labels[key]
+	}
+	if key, found := strings.CutPrefix(fieldPath, "annotations."); found {
+		return payload.Annotations[key]
+	}
+
+	switch fieldPath {
+	case "alertName":
+		return payload.AlertName
+	case "status":
+		return payload.Status
+	case "severity":
+		return payload.Severity
+	case "instance":
+		return payload.Instance
+	case "summary":
+		return payload.Summary
+	case "description":
+		return payload.Description
+	case "startsAt":
+		return payload.StartsAt
+	case "endsAt":
+		return payload.EndsAt
+	case "fingerprint":
+		return payload.Fingerprint
+	case "timestamp":
+		return payload.Timestamp
+	}
+	return ""
+}
+
+// queryParams resolves QUERY_PARAM_FIELDS against the payload. Fields that
+// resolve to an empty value are left out.
+func queryParams(config *Config, payload WebhookPayload) url.Values {
+	params := url.Values{}
+	for param, field := range config.QueryParamFields {
+		if value := extractPayloadField(payload, field); value != "" {
+			params.Set(param, value)
+		}
+	}
+	return params
+}
+
+// appendQuery adds the encoded params after any query string already in the
+// URL, which is kept as configured
+func appendQuery(u *url.URL, params url.Values) {
+	if len(params) == 0 {
+		return
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&" + params.Encode()
+	} else {
+		u.RawQuery = params.Encode()
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseQueryParamFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "unset", spec: "", want: map[string]string{}},
+		{name: "fields and labels", spec: "alert=alertName, host = labels.instance", want: map[string]string{"alert": "alertName", "host": "labels.instance"}},
+		{name: "unknown field", spec: "alert=name", wantErr: true},
+		{name: "empty label key", spec: "host=labels.", wantErr: true},
+		{name: "missing field", spec: "alert=", wantErr: true},
+		{name: "missing separator", spec: "alert", wantErr: true},
+		{name: "duplicate param", spec: "alert=alertName,alert=status", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQueryParamFields(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQueryParamFields() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assertStringMap(t, "fields", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendWebhookQueryParams(t *testing.T) {
+	fields, err := parseQueryParamFields("alert=alertName,host=labels.instance,summary=annotations.summary,team=labels.team")
+	if err != nil {
+		t.Fatalf("parseQueryParamFields() unexpected error: %v", err)
+	}
+	payload := WebhookPayload{
+		AlertName:   "Disk Full",
+		Status:      "firing",
+		Labels:      map[string]string{"instance": "node-1:9100"},
+		Annotations: map[string]string{"summary": "50% used & rising/fast?"},
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		method    string
+		wantQuery string
+		wantBody  bool
+	}{
+		{
+			name:      "GET drops the body",
+			path:      "/hook",
+			method:    "GET",
+			wantQuery: "alert=Disk+Full&host=node-1%3A9100&summary=50%25+used+%26+rising%2Ffast%3F",
+		},
+		{
+			name:      "existing query string is kept",
+			path:      "/hook?token=abc",
+			method:    "POST",
+			wantQuery: "token=abc&alert=Disk+Full&host=node-1%3A9100&summary=50%25+used+%26+rising%2Ffast%3F",
+			wantBody:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotQuery string
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotMethod = r.Method
+				gotQuery = r.URL.RawQuery
+				gotBody, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:          []WebhookTarget{{URL: server.URL + tt.path}},
+				MethodByStatus:   map[string]string{"firing": tt.method},
+				QueryParamFields: fields,
+				TimeoutSeconds:   5,
+			}
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, payload) })
+			if err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+
+			if gotMethod != tt.method {
+				t.Errorf("method = %s, want %s", gotMethod, tt.method)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Errorf("query = %s, want %s", gotQuery, tt.wantQuery)
+			}
+			if (len(gotBody) > 0) != tt.wantBody {
+				t.Errorf("body = %q, want body %t", gotBody, tt.wantBody)
+			}
+		})
+	}
+}
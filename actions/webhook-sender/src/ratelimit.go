@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sharedRateLimit is a rate limit kept in RATE_LIMIT_FILE, so concurrent
+// runs that mount the same volume stay under a receiver's limit together.
+// The file holds the theoretical arrival time of the next request, in Unix
+// nanoseconds, of the generic cell rate algorithm, which allows the same
+// bursts as a token bucket. Runs take an exclusive lock on the file while
+// they reserve a slot.
+type sharedRateLimit struct {
+	path string
+	// interval is the time between requests at the configured rate
+	interval time.Duration
+	// tolerance is how far ahead of the rate a burst may run
+	tolerance time.Duration
+}
+
+// newSharedRateLimit creates the limit for path at rps requests per second
+// with bursts of up to burst requests
+func newSharedRateLimit(path string, rps float64, burst int) *sharedRateLimit {
+	interval := time.Duration(float64(time.Second) / rps)
+	return &sharedRateLimit{
+		path:      path,
+		interval:  interval,
+		tolerance: time.Duration(max(burst, 1)-1) * interval,
+	}
+}
+
+// wait reserves the next slot in the file and blocks until it starts or ctx
+// is done. A cancelled wait keeps its slot, which only makes later requests
+// slightly more conservative.
+func (s *sharedRateLimit) wait(ctx context.Context) error {
+	delay, err := s.reserve(time.Now())
+	if err != nil {
+		return err
+	}
+	if delay == 0 {
+		return nil
+	}
+	log.Printf("Rate limit reached (RATE_LIMIT_FILE), waiting %s", delay.Round(time.Millisecond))
+	return sleepContext(ctx, delay)
+}
+
+// reserve records a request at now and returns how long it has to wait. A
+// missing, empty or unreadable state counts as no previous requests.
+func (s *sharedRateLimit) reserve(now time.Time) (time.Duration, error) {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open RATE_LIMIT_FILE: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return 0, fmt.Errorf("failed to lock RATE_LIMIT_FILE: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read RATE_LIMIT_FILE: %w", err)
+	}
+	tat := now
+	if nanos, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+		if stored := time.Unix(0, nanos); stored.After(now) {
+			tat = stored
+		}
+	}
+
+	next := strconv.FormatInt(tat.Add(s.interval).UnixNano(), 10)
+	if err := file.Truncate(0); err != nil {
+		return 0, fmt.Errorf("failed to write RATE_LIMIT_FILE: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(next), 0); err != nil {
+		return 0, fmt.Errorf("failed to write RATE_LIMIT_FILE: %w", err)
+	}
+	return max(tat.Sub(now)-s.tolerance, 0), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSharedRateLimitReserve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit")
+	limit := newSharedRateLimit(path, 10, 3)
+	now := time.Unix(1700000000, 0)
+
+	// A burst of three starts at once, the fourth waits for the rate
+	tests := []struct {
+		at   time.Duration
+		want time.Duration
+	}{
+		{at: 0, want: 0},
+		{at: 0, want: 0},
+		{at: 0, want: 0},
+		{at: 0, want: 100 * time.Millisecond},
+		{at: 50 * time.Millisecond, want: 150 * time.Millisecond},
+		// After a quiet second the whole burst is available again
+		{at: time.Second, want: 0},
+	}
+	for i, tt := range tests {
+		got, err := limit.reserve(now.Add(tt.at))
+		if err != nil {
+			t.Fatalf("reserve() unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("request %d at +%s waits %s, want %s", i+1, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestSharedRateLimitSharedBetweenRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit")
+	now := time.Now()
+
+	// Two runs with their own limiter share the file
+	first := newSharedRateLimit(path, 2, 1)
+	second := newSharedRateLimit(path, 2, 1)
+	if delay, err := first.reserve(now); err != nil || delay != 0 {
+		t.Fatalf("first run reserve() = %s, %v, want no wait", delay, err)
+	}
+	if delay, err := second.reserve(now); err != nil || delay != 500*time.Millisecond {
+		t.Fatalf("second run reserve() = %s, %v, want 500ms", delay, err)
+	}
+}
+
+func TestSharedRateLimitCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit")
+	if err := os.WriteFile(path, []byte("not a timestamp"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	limit := newSharedRateLimit(path, 1, 1)
+	if delay, err := limit.reserve(time.Now()); err != nil || delay != 0 {
+		t.Errorf("reserve() = %s, %v, want a corrupt file to count as no previous requests", delay, err)
+	}
+}
+
+func TestDeliveryLimitsRateLimitFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit")
+	config := &Config{MaxConcurrency: 1, RateLimitPerSecond: 20, RateLimitBurst: 1, RateLimitFile: path}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		var err error
+		captureLog(t, func() { err = newDeliveryLimits(config).wait(context.Background()) })
+		if err != nil {
+			t.Fatalf("wait() unexpected error: %v", err)
+		}
+	}
+	// Every run creates its own limits, yet they are spaced 50ms apart
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 deliveries at 20/s took %s, want at least 100ms", elapsed)
+	}
+}
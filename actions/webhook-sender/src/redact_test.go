@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPayloadForLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		fields []string
+		want   string
+	}{
+		{
+			name: "no fields logs the payload as-is",
+			data: `{"labels":{"token":"s3cret"}}`,
+			want: `{"labels":{"token":"s3cret"}}`,
+		},
+		{
+			name:   "labels and annotations are masked",
+			data:   `{"alertName":"DiskFull","labels":{"token":"s3cret","severity":"critical"},"annotations":{"password":"hunter2"}}`,
+			fields: []string{"token", "password"},
+			want:   `{"alertName":"DiskFull","annotations":{"password":"***"},"labels":{"severity":"critical","token":"***"}}`,
+		},
+		{
+			name:   "nested in arrays",
+			data:   `{"alerts":[{"labels":{"token":"a"}},{"labels":{"token":"b"}}]}`,
+			fields: []string{"token"},
+			want:   `{"alerts":[{"labels":{"token":"***"}},{"labels":{"token":"***"}}]}`,
+		},
+		{
+			name:   "numbers are preserved",
+			data:   `{"value":12345678901234567890,"token":1}`,
+			fields: []string{"token"},
+			want:   `{"token":"***","value":12345678901234567890}`,
+		},
+		{
+			name:   "non-JSON payload is not logged",
+			data:   `alert DiskFull token=s3cret`,
+			fields: []string{"token"},
+			want:   `<27 bytes, not JSON so REDACT_FIELDS cannot be applied>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := payloadForLog([]byte(tt.data), tt.fields); got != tt.want {
+				t.Errorf("payloadForLog() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendWebhookRedactsLoggedPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		logPayload bool
+		wantLogged string
+	}{
+		{name: "redacted payload is logged", logPayload: true, wantLogged: `"token":"***"`},
+		{name: "LOG_PAYLOAD=false skips the payload", logPayload: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotBody, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:        []WebhookTarget{{URL: server.URL, AuthHeader: "Bearer abc123"}},
+				LogPayload:     tt.logPayload,
+				RedactFields:   []string{"token"},
+				TimeoutSeconds: 5,
+			}
+			payload := WebhookPayload{AlertName: "DiskFull", Labels: map[string]string{"token": "s3cret"}}
+
+			var err error
+			output := captureLog(t, func() { err = sendWebhook(context.Background(), config, payload) })
+			if err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+
+			if !strings.Contains(string(gotBody), `"token":"s3cret"`) {
+				t.Errorf("sent body should keep the real value, got %s", gotBody)
+			}
+			for _, secret := range []string{"s3cret", "abc123"} {
+				if strings.Contains(output, secret) {
+					t.Errorf("log output leaks %q:\n%s", secret, output)
+				}
+			}
+			if tt.wantLogged != "" && !strings.Contains(output, tt.wantLogged) {
+				t.Errorf("log output should contain %s:\n%s", tt.wantLogged, output)
+			}
+			if !tt.logPayload && strings.Contains(output, "Payload:") {
+				t.Errorf("payload should not be logged:\n%s", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultMaxRedirects matches the redirects net/http follows by default
+const defaultMaxRedirects = 10
+
+// parseRedirectConfig reads FOLLOW_REDIRECTS, MAX_REDIRECTS and
+// REDIRECT_PRESERVE_AUTH. Redirects are followed by default.
+func parseRedirectConfig(config *Config) error {
+	config.FollowRedirects = true
+	if err := envBool(config.StrictEnv, "FOLLOW_REDIRECTS", &config.FollowRedirects); err != nil {
+		return err
+	}
+
+	config.MaxRedirects = defaultMaxRedirects
+	if err := envInt(config.StrictEnv, "MAX_REDIRECTS", &config.MaxRedirects); err != nil {
+		return err
+	}
+	if config.MaxRedirects < 1 {
+		return fmt.Errorf("MAX_REDIRECTS must be at least 1, got %d; set FOLLOW_REDIRECTS=false to not follow redirects", config.MaxRedirects)
+	}
+
+	if err := envBool(config.StrictEnv, "REDIRECT_PRESERVE_AUTH", &config.RedirectPreserveAuth); err != nil {
+		return err
+	}
+	if !config.FollowRedirects && config.RedirectPreserveAuth {
+		log.Println("Warning: REDIRECT_PRESERVE_AUTH is ignored with FOLLOW_REDIRECTS=false")
+	}
+	return nil
+}
+
+// checkRedirect returns the redirect policy of the HTTP client. Without
+// FOLLOW_REDIRECTS the 3xx response is returned as is, and fails the
+// delivery unless the target's success criteria accept it.
+func checkRedirect(config *Config) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !config.FollowRedirects {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > config.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects (MAX_REDIRECTS)", config.MaxRedirects)
+		}
+		if req.Response != nil {
+			log.Printf("Following redirect (%s) to %s", req.Response.Status, redactURL(req.URL.String()))
+		}
+		if config.RedirectPreserveAuth {
+			forwardCredentials(req, via[0])
+		}
+		return nil
+	}
+}
+
+// forwardCredentials keeps the Authorization header and the credential-like
+// custom headers of the original request on a redirect to the same host,
+// and removes them from a redirect to any other host. By default net/http
+// sends Authorization on to subdomains and over plain http, and custom
+// headers to any host.
+func forwardCredentials(req, original *http.Request) {
+	same := isSameHost(original.URL, req.URL)
+	for name, values := range original.Header {
+		if name != "Authorization" && !isSensitiveHeader(name) {
+			continue
+		}
+		if same {
+			req.Header[name] = values
+		} else {
+			req.Header.Del(name)
+		}
+	}
+}
+
+// isSameHost reports whether a redirect from one URL to another stays on the
+// same host name. A downgrade from https to http counts as another host, so
+// credentials are never sent in the clear.
+func isSameHost(from, to *url.URL) bool {
+	if from.Scheme == "https" && to.Scheme != "https" {
+		return false
+	}
+	return strings.EqualFold(from.Hostname(), to.Hostname())
+}
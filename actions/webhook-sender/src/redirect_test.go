@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRedirectConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantFollow   bool
+		wantMax      int
+		wantPreserve bool
+		wantErr      string
+	}{
+		{name: "defaults", env: map[string]string{}, wantFollow: true, wantMax: 10},
+		{name: "custom", env: map[string]string{"MAX_REDIRECTS": "3", "REDIRECT_PRESERVE_AUTH": "true"}, wantFollow: true, wantMax: 3, wantPreserve: true},
+		{name: "not followed", env: map[string]string{"FOLLOW_REDIRECTS": "false"}, wantMax: 10},
+		{name: "zero max", env: map[string]string{"MAX_REDIRECTS": "0"}, wantErr: "MAX_REDIRECTS must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"FOLLOW_REDIRECTS", "MAX_REDIRECTS", "REDIRECT_PRESERVE_AUTH"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseRedirectConfig(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRedirectConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRedirectConfig() unexpected error: %v", err)
+			}
+			if config.FollowRedirects != tt.wantFollow || config.MaxRedirects != tt.wantMax || config.RedirectPreserveAuth != tt.wantPreserve {
+				t.Errorf("config = %t, %d, %t, want %t, %d, %t", config.FollowRedirects, config.MaxRedirects, config.RedirectPreserveAuth, tt.wantFollow, tt.wantMax, tt.wantPreserve)
+			}
+		})
+	}
+}
+
+// redirectServer answers every request with a 307 to location
+func redirectServer(location string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+	}))
+}
+
+func TestSendWebhookRedirectLimits(t *testing.T) {
+	var received int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer target.Close()
+	second := redirectServer(target.URL)
+	defer second.Close()
+	first := redirectServer(second.URL)
+	defer first.Close()
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "followed", config: Config{FollowRedirects: true, MaxRedirects: 2}},
+		{name: "too many", config: Config{FollowRedirects: true, MaxRedirects: 1}, wantErr: "stopped after 1 redirects"},
+		{name: "not followed", config: Config{MaxRedirects: 10}, wantErr: "status 307"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = 0
+			config := tt.config
+			config.Targets = []WebhookTarget{{URL: first.URL}}
+			config.TimeoutSeconds = 5
+
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), &config, WebhookPayload{AlertName: "DiskFull"}) })
+			if tt.wantErr == "" {
+				if err != nil || received != 1 {
+					t.Fatalf("sendWebhook() = %v with %d deliveries, want one delivery", err, received)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("sendWebhook() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if received != 0 {
+				t.Errorf("target received %d requests, want none", received)
+			}
+		})
+	}
+}
+
+func TestSendWebhookRedirectPreserveAuth(t *testing.T) {
+	var auth, apiKey string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		apiKey = r.Header.Get("X-Api-Key")
+	}))
+	defer target.Close()
+
+	// The load balancer and the target share the host name but not the port
+	sameHost := redirectServer(target.URL)
+	defer sameHost.Close()
+	otherHost := redirectServer(strings.Replace(target.URL, "127.0.0.1", "localhost", 1))
+	defer otherHost.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		preserve   bool
+		wantAuth   string
+		wantAPIKey string
+	}{
+		{name: "other host by default", url: otherHost.URL, wantAPIKey: "key"},
+		{name: "same host", url: sameHost.URL, preserve: true, wantAuth: "Bearer token", wantAPIKey: "key"},
+		{name: "other host", url: otherHost.URL, preserve: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, apiKey = "", ""
+			config := &Config{
+				Targets:              []WebhookTarget{{URL: tt.url, AuthHeader: "Bearer token"}},
+				Headers:              map[string]string{"X-Api-Key": "key"},
+				FollowRedirects:      true,
+				MaxRedirects:         10,
+				RedirectPreserveAuth: tt.preserve,
+				TimeoutSeconds:       5,
+			}
+
+			var err error
+			output := captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+			if auth != tt.wantAuth || apiKey != tt.wantAPIKey {
+				t.Errorf("target received Authorization %q and X-Api-Key %q, want %q and %q", auth, apiKey, tt.wantAuth, tt.wantAPIKey)
+			}
+			if !strings.Contains(output, "Following redirect (307 Temporary Redirect)") {
+				t.Errorf("expected the redirect in the logs, got:\n%s", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Defaults of RETRY_BACKOFF_MS and RETRY_MAX_BACKOFF, in milliseconds
+const (
+	defaultRetryBackoffMs    = 500
+	defaultRetryMaxBackoffMs = 10000
+)
+
+// parseRetryConfig reads RETRY_COUNT, the retries after the first attempt,
+// and the backoff between them. Retries are disabled by default.
+func parseRetryConfig(config *Config) error {
+	if err := envInt(config.StrictEnv, "RETRY_COUNT", &config.RetryCount); err != nil {
+		return err
+	}
+	if config.RetryCount < 0 {
+		return fmt.Errorf("RETRY_COUNT must not be negative, got %d", config.RetryCount)
+	}
+
+	config.RetryBackoffMs = defaultRetryBackoffMs
+	if err := envInt(config.StrictEnv, "RETRY_BACKOFF_MS", &config.RetryBackoffMs); err != nil {
+		return err
+	}
+	if config.RetryBackoffMs < 1 {
+		return fmt.Errorf("RETRY_BACKOFF_MS must be at least 1, got %d", config.RetryBackoffMs)
+	}
+
+	config.RetryMaxBackoffMs = defaultRetryMaxBackoffMs
+	if err := envInt(config.StrictEnv, "RETRY_MAX_BACKOFF", &config.RetryMaxBackoffMs); err != nil {
+		return err
+	}
+	if config.RetryMaxBackoffMs < config.RetryBackoffMs {
+		return fmt.Errorf("RETRY_MAX_BACKOFF must be at least RETRY_BACKOFF_MS (%d), got %d", config.RetryBackoffMs, config.RetryMaxBackoffMs)
+	}
+	return nil
+}
+
+// isRetryableError reports whether a failed delivery is transient and may
+// succeed on another attempt: the request failed in transport, e.g. timed
+// out, the receiver answered 5xx or 429, or it accepted the request but
+// reported an error in the body. gRPC calls are retried on the status
+// codes of isRetryableGRPCError. Other responses, such as 4xx or a status
+// outside the target's successStatus, fail the same way every time.
+func isRetryableError(ctx context.Context, response *targetResponse, err error) bool {
+	// The run's own deadline or cancellation leaves no time to retry
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var assertErr *assertionError
+	if errors.As(err, &assertErr) || isRetryableGRPCError(err) {
+		return true
+	}
+	if response == nil {
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+}
+
+// retryBackoff returns the delay before the given retry, counting from 1:
+// RETRY_BACKOFF_MS doubling up to RETRY_MAX_BACKOFF, with the upper half
+// randomized so parallel runs don't retry in lockstep
+func retryBackoff(config *Config, retry int) time.Duration {
+	backoff := time.Duration(config.RetryBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(config.RetryMaxBackoffMs) * time.Millisecond
+	for i := 1; i < retry && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// sendWithRetry sends to the target like sendToTarget, retrying transient
+// failures up to RETRY_COUNT times with backoff. The last attempt's
+// response and error are returned with the number of attempts made.
+func sendWithRetry(ctx context.Context, client *http.Client, config *Config, target WebhookTarget, method string, query url.Values, header http.Header, body []byte) (*targetResponse, int, error) {
+	attempts := config.RetryCount + 1
+	for attempt := 1; ; attempt++ {
+		response, err := sendToTarget(ctx, client, config, target, method, query, header, body)
+		if err == nil || attempt == attempts || !isRetryableError(ctx, response, err) {
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return response, attempt, err
+		}
+
+		backoff := retryBackoff(config, attempt)
+		log.Printf("Warning: Attempt %d/%d failed, retrying in %s: %v", attempt, attempts, backoff.Round(time.Millisecond), err)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return response, attempt, fmt.Errorf("retry cancelled after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// sleepContext waits for d, returning early with the context's error when it
+// is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
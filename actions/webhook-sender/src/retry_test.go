@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantCount   int
+		wantBackoff int
+		wantMax     int
+		wantErr     bool
+	}{
+		{name: "defaults", env: map[string]string{}, wantBackoff: 500, wantMax: 10000},
+		{name: "custom", env: map[string]string{"RETRY_COUNT": "3", "RETRY_BACKOFF_MS": "200", "RETRY_MAX_BACKOFF": "2000"}, wantCount: 3, wantBackoff: 200, wantMax: 2000},
+		{name: "negative count", env: map[string]string{"RETRY_COUNT": "-1"}, wantErr: true},
+		{name: "zero backoff", env: map[string]string{"RETRY_BACKOFF_MS": "0"}, wantErr: true},
+		{name: "max below backoff", env: map[string]string{"RETRY_BACKOFF_MS": "1000", "RETRY_MAX_BACKOFF": "500"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"RETRY_COUNT", "RETRY_BACKOFF_MS", "RETRY_MAX_BACKOFF"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			err := parseRetryConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRetryConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && (config.RetryCount != tt.wantCount || config.RetryBackoffMs != tt.wantBackoff || config.RetryMaxBackoffMs != tt.wantMax) {
+				t.Errorf("count, backoff, max = %d, %d, %d, want %d, %d, %d",
+					config.RetryCount, config.RetryBackoffMs, config.RetryMaxBackoffMs, tt.wantCount, tt.wantBackoff, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	transport := &url.Error{Op: "Post", URL: "https://example.com", Err: context.DeadlineExceeded}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		response *targetResponse
+		err      error
+		want     bool
+	}{
+		{name: "timeout", ctx: context.Background(), err: transport, want: true},
+		{name: "server error", ctx: context.Background(), response: &targetResponse{StatusCode: 503}, err: errors.New("status 503"), want: true},
+		{name: "too many requests", ctx: context.Background(), response: &targetResponse{StatusCode: 429}, err: errors.New("status 429"), want: true},
+		{name: "client error", ctx: context.Background(), response: &targetResponse{StatusCode: 400}, err: errors.New("status 400"), want: false},
+		{name: "status not in success list", ctx: context.Background(), response: &targetResponse{StatusCode: 200}, err: errors.New("status 200 is not one of [202]"), want: false},
+		{name: "error reported in body", ctx: context.Background(), response: &targetResponse{StatusCode: 200}, err: &assertionError{err: errors.New("response field ok is \"false\"")}, want: true},
+		{name: "invalid request", ctx: context.Background(), err: errors.New("failed to create request"), want: false},
+		{name: "run cancelled", ctx: cancelled, err: transport, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.ctx, tt.response, tt.err); got != tt.want {
+				t.Errorf("isRetryableError() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	config := &Config{RetryBackoffMs: 100, RetryMaxBackoffMs: 500}
+	ceilings := []time.Duration{100, 200, 400, 500, 500}
+	for i, ceiling := range ceilings {
+		ceiling *= time.Millisecond
+		for n := 0; n < 20; n++ {
+			if got := retryBackoff(config, i+1); got < ceiling/2 || got > ceiling {
+				t.Errorf("retryBackoff(%d) = %s, want between %s and %s", i+1, got, ceiling/2, ceiling)
+			}
+		}
+	}
+}
+
+func TestSendWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryCount   int
+		failures     int32
+		status       int
+		wantRequests int32
+		wantErr      string
+	}{
+		{name: "recovers after transient failures", retryCount: 3, failures: 2, status: http.StatusBadGateway, wantRequests: 3},
+		{name: "gives up after RETRY_COUNT", retryCount: 2, failures: 10, status: http.StatusServiceUnavailable, wantRequests: 3, wantErr: "after 3 attempts"},
+		{name: "client errors are not retried", retryCount: 3, failures: 10, status: http.StatusBadRequest, wantRequests: 1, wantErr: "400"},
+		{name: "retries disabled by default", failures: 1, status: http.StatusInternalServerError, wantRequests: 1, wantErr: "500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tt.failures {
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer server.Close()
+
+			config := &Config{
+				Targets:           []WebhookTarget{{URL: server.URL}},
+				TimeoutSeconds:    5,
+				RetryCount:        tt.retryCount,
+				RetryBackoffMs:    1,
+				RetryMaxBackoffMs: 5,
+			}
+			var err error
+			output := captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("sendWebhook() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("received %d requests, want %d", got, tt.wantRequests)
+			}
+			if got := int32(strings.Count(output, "retrying in")); got != tt.wantRequests-1 {
+				t.Errorf("logged %d retries, want %d:\n%s", got, tt.wantRequests-1, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// webhookRoute is an entry of ROUTES_JSON: the target that alerts matching
+// all of its matchers are sent to
+type webhookRoute struct {
+	// Match maps payload fields, e.g. severity or labels.team, to regular
+	// expressions their whole value must match; a route without matchers
+	// matches every alert
+	Match map[string]string `json:"match,omitempty"`
+	WebhookTarget
+	matchers map[string]*regexp.Regexp
+}
+
+// parseRoutes parses ROUTES_JSON, a JSON array of routes evaluated in order,
+// naming unnamed routes by position
+func parseRoutes(spec string) ([]webhookRoute, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var routes []webhookRoute
+	decoder := json.NewDecoder(strings.NewReader(spec))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&routes); err != nil {
+		return nil, fmt.Errorf("failed to parse ROUTES_JSON: %w", err)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("ROUTES_JSON must contain at least one route")
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+		if route.URL == "" {
+			return nil, fmt.Errorf("ROUTES_JSON route '%s' is missing url", route.Name)
+		}
+		for _, code := range route.SuccessStatus {
+			if code < 100 || code > 599 {
+				return nil, fmt.Errorf("ROUTES_JSON route '%s' has invalid success status %d", route.Name, code)
+			}
+		}
+
+		route.matchers = make(map[string]*regexp.Regexp, len(route.Match))
+		for field, pattern := range route.Match {
+			if !isPayloadField(field) {
+				return nil, fmt.Errorf("ROUTES_JSON route '%s' matches unknown payload field '%s'", route.Name, field)
+			}
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("ROUTES_JSON route '%s' has an invalid pattern for %s: %w", route.Name, field, err)
+			}
+			route.matchers[field] = re
+		}
+	}
+	return routes, nil
+}
+
+// matches reports whether every matcher of the route matches the payload
+func (r webhookRoute) matches(payload WebhookPayload) bool {
+	for field, re := range r.matchers {
+		if !re.MatchString(extractPayloadField(payload, field)) {
+			return false
+		}
+	}
+	return true
+}
+
+// routeAlert replaces the targets with the first route of ROUTES_JSON that
+// matches the alert, e.g. to page for critical alerts and only log warnings.
+// Alerts no route matches fall back to routeWebhook.
+func routeAlert(config *Config, payload WebhookPayload) error {
+	for _, route := range config.Routes {
+		if !route.matches(payload) {
+			continue
+		}
+		target := route.WebhookTarget
+		if target.AuthHeader == "" {
+			target.AuthHeader = config.AuthHeader
+		}
+		config.Targets = []WebhookTarget{target}
+		log.Printf("Routing alert to route %s of ROUTES_JSON", route.Name)
+		return nil
+	}
+
+	err := routeWebhook(config, payload.Status)
+	if err != nil && len(config.Routes) > 0 {
+		return fmt.Errorf("no route in ROUTES_JSON matches the alert, and %w", err)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    int
+		wantErr string
+	}{
+		{name: "unset", spec: ""},
+		{name: "routes", spec: `[{"match": {"severity": "critical"}, "url": "https://pager.example.com"}, {"url": "https://logs.example.com"}]`, want: 2},
+		{name: "empty", spec: `[]`, wantErr: "at least one route"},
+		{name: "missing url", spec: `[{"match": {"severity": "critical"}}]`, wantErr: "route 'route-1' is missing url"},
+		{name: "unknown field", spec: `[{"match": {"priority": "P1"}, "url": "https://pager.example.com"}]`, wantErr: "unknown payload field 'priority'"},
+		{name: "invalid pattern", spec: `[{"match": {"severity": "("}, "url": "https://pager.example.com"}]`, wantErr: "invalid pattern for severity"},
+		{name: "unknown key", spec: `[{"matches": {"severity": "critical"}, "url": "https://pager.example.com"}]`, wantErr: "failed to parse ROUTES_JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := parseRoutes(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRoutes() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoutes() unexpected error: %v", err)
+			}
+			if len(routes) != tt.want {
+				t.Errorf("parseRoutes() returned %d routes, want %d", len(routes), tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteAlert(t *testing.T) {
+	routes, err := parseRoutes(`[
+		{"name": "paging", "match": {"severity": "critical"}, "url": "https://pager.example.com", "authHeader": "Bearer pager"},
+		{"match": {"severity": "warning|info", "labels.team": "payments"}, "url": "https://payments.example.com"},
+		{"match": {"severity": "warning|info"}, "url": "https://logs.example.com"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseRoutes() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		payload  WebhookPayload
+		fallback string
+		wantURL  string
+		wantAuth string
+		wantErr  string
+	}{
+		{name: "critical", payload: WebhookPayload{Severity: "critical"}, wantURL: "https://pager.example.com", wantAuth: "Bearer pager"},
+		{name: "all matchers", payload: WebhookPayload{Severity: "info", Labels: map[string]string{"team": "payments"}}, wantURL: "https://payments.example.com", wantAuth: "Bearer default"},
+		{name: "first match wins", payload: WebhookPayload{Severity: "warning"}, wantURL: "https://logs.example.com", wantAuth: "Bearer default"},
+		{name: "whole value", payload: WebhookPayload{Severity: "critical-ish"}, fallback: "https://default.example.com", wantURL: "https://default.example.com", wantAuth: "Bearer default"},
+		{name: "no match", payload: WebhookPayload{Severity: "debug", Status: "firing"}, wantErr: "no route in ROUTES_JSON matches the alert, and WEBHOOK_URL_FIRING or WEBHOOK_URL is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Routes: routes, AuthHeader: "Bearer default"}
+			if tt.fallback != "" {
+				config.Targets = []WebhookTarget{{URL: tt.fallback, AuthHeader: config.AuthHeader}}
+			}
+
+			var err error
+			captureLog(t, func() { err = routeAlert(config, tt.payload) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("routeAlert() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("routeAlert() unexpected error: %v", err)
+			}
+			if len(config.Targets) != 1 || config.Targets[0].URL != tt.wantURL || config.Targets[0].AuthHeader != tt.wantAuth {
+				t.Errorf("targets = %+v, want %s with %q", config.Targets, tt.wantURL, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRoutesOnly(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	t.Setenv("WEBHOOK_URL", "")
+	t.Setenv("ROUTES_JSON", `[{"match": {"severity": "critical"}, "url": "`+server.URL+`/page"}, {"url": "`+server.URL+`/log"}]`)
+
+	var config *Config
+	var err error
+	captureLog(t, func() { config, err = loadConfig() })
+	if err != nil {
+		t.Fatalf("loadConfig() unexpected error: %v", err)
+	}
+
+	for _, severity := range []string{"critical", "warning"} {
+		alertData := AlertData{Status: "firing", Labels: map[string]string{"alertname": "DiskFull", "severity": severity}}
+		captureLog(t, func() { err = handleAlert(context.Background(), config, alertData) })
+		if err != nil {
+			t.Fatalf("handleAlert() unexpected error: %v", err)
+		}
+	}
+	if got := strings.Join(paths, ","); got != "/page,/log" {
+		t.Errorf("request paths = %s, want /page,/log", got)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+	"golang.org/x/net/http/httpguts"
+)
+
+// parseSignatureHeader validates WEBHOOK_SIGNATURE_HEADER, the header the
+// HMAC signature is sent in, defaulting to alert.SignatureHeader. Receivers
+// that expect the signature under their own name, e.g. X-Hub-Signature-256,
+// can be served without a proxy rewriting the header.
+func parseSignatureHeader(name, secret string) (string, error) {
+	if name == "" {
+		return alert.SignatureHeader, nil
+	}
+	if !httpguts.ValidHeaderFieldName(name) {
+		return "", fmt.Errorf("invalid WEBHOOK_SIGNATURE_HEADER '%s', must be a valid HTTP header name", name)
+	}
+	if secret == "" {
+		log.Println("Warning: WEBHOOK_SIGNATURE_HEADER is ignored without WEBHOOK_SIGNING_SECRET")
+	}
+	return http.CanonicalHeaderKey(name), nil
+}
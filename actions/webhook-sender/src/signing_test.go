@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dudizimber/karo-reactions/webhook-sender/alert"
+)
+
+func TestParseSignatureHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		secret      string
+		want        string
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "default", secret: "s3cret", want: alert.SignatureHeader},
+		{name: "custom name is canonicalized", header: "x-hub-signature-256", secret: "s3cret", want: "X-Hub-Signature-256"},
+		{name: "invalid name", header: "X Signature", secret: "s3cret", wantErr: true},
+		{name: "ignored without a secret", header: "X-Signature", want: "X-Signature", wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var err error
+			output := captureLog(t, func() { got, err = parseSignatureHeader(tt.header, tt.secret) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSignatureHeader() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSignatureHeader() = %q, want %q", got, tt.want)
+			}
+			if warned := strings.Contains(output, "Warning:"); warned != tt.wantWarning {
+				t.Errorf("warning logged = %t, want %t: %s", warned, tt.wantWarning, output)
+			}
+		})
+	}
+}
+
+func TestSendWebhookCustomSignatureHeader(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Targets:         []WebhookTarget{{URL: server.URL}},
+		SigningSecret:   "s3cret",
+		SignatureHeader: "X-Hub-Signature-256",
+		TimeoutSeconds:  5,
+	}
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+
+	if header.Get(alert.SignatureHeader) != "" {
+		t.Errorf("signature also sent in %s", alert.SignatureHeader)
+	}
+	if err := alert.VerifySignature(body, header.Get("X-Hub-Signature-256"), "s3cret"); err != nil {
+		t.Errorf("signature in X-Hub-Signature-256 does not verify: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sigV4Algorithm is the signing algorithm named in SigV4 Authorization
+// headers
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// awsCredentials are the keys requests are signed with. Temporary
+// credentials carry a session token and expire.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiry          time.Time
+}
+
+// sigV4Signer signs requests with AWS Signature Version 4 for the
+// configured region and service. Credentials come from AWS_ACCESS_KEY_ID
+// and AWS_SECRET_ACCESS_KEY, or else from an IRSA web identity token, which
+// is exchanged for temporary credentials with STS and cached until shortly
+// before they expire.
+type sigV4Signer struct {
+	region  string
+	service string
+
+	mu          sync.Mutex
+	credentials awsCredentials
+	refresh     func() (awsCredentials, error)
+}
+
+// parseSigV4Config reads SIGV4_REGION, which turns on SigV4 signing, and
+// SIGV4_SERVICE, the signing name of the AWS API (execute-api for API
+// Gateway). It must run after parseJWTConfig, since SigV4 replaces the
+// other auth methods.
+func parseSigV4Config(config *Config) error {
+	config.SigV4Region = os.Getenv("SIGV4_REGION")
+	service := os.Getenv("SIGV4_SERVICE")
+	if config.SigV4Region == "" {
+		if service != "" {
+			return fmt.Errorf("SIGV4_SERVICE requires SIGV4_REGION")
+		}
+		return nil
+	}
+	if config.AuthHeader != "" || config.OAuthTokenSource != nil {
+		return fmt.Errorf("SIGV4_REGION cannot be combined with another auth method, configure only one")
+	}
+	config.SigV4Service = getValueWithFallback(service, "execute-api")
+
+	signer := &sigV4Signer{region: config.SigV4Region, service: config.SigV4Service}
+	accessKeyID, secretAccessKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	switch {
+	case accessKeyID != "" || secretAccessKey != "":
+		if accessKeyID == "" || secretAccessKey == "" {
+			return fmt.Errorf("SigV4 signing requires both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		signer.credentials = awsCredentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+	case roleARN != "" && tokenFile != "":
+		client := newHTTPClient(config)
+		endpoint := getValueWithFallback(os.Getenv("AWS_ENDPOINT_URL_STS"), "https://sts."+config.SigV4Region+".amazonaws.com")
+		sessionName := getValueWithFallback(os.Getenv("AWS_ROLE_SESSION_NAME"), "karo-webhook-sender")
+		signer.refresh = func() (awsCredentials, error) {
+			return assumeRoleWithWebIdentity(client, endpoint, roleARN, sessionName, tokenFile)
+		}
+	default:
+		return fmt.Errorf("SigV4 signing requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE (IRSA)")
+	}
+	config.SigV4 = signer
+	return nil
+}
+
+// signSigV4 signs a request to a target without its own authHeader, if
+// SigV4 is configured. It must run after every other header is set.
+func signSigV4(config *Config, target WebhookTarget, req *http.Request, body []byte) error {
+	if config.SigV4 == nil || target.AuthHeader != "" {
+		return nil
+	}
+	credentials, err := config.SigV4.currentCredentials()
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+	config.SigV4.sign(req, body, credentials, time.Now())
+	return nil
+}
+
+// currentCredentials returns the credentials to sign with, exchanging the
+// web identity token for new ones when there are none or they expire within
+// five minutes
+func (s *sigV4Signer) currentCredentials() (awsCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refresh != nil && (s.credentials.AccessKeyID == "" || time.Until(s.credentials.Expiry) < 5*time.Minute) {
+		credentials, err := s.refresh()
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		s.credentials = credentials
+	}
+	return s.credentials, nil
+}
+
+// sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers.
+// The host, the Content-Type and all X-Amz-* headers are signed.
+func (s *sigV4Signer) sign(req *http.Request, body []byte, credentials awsCredentials, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+	// S3 requires the payload hash as a header
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, timestamp, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	for _, part := range []string{s.region, s.service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalURI encodes the path as sent, and every service but S3 expects
+// its segments encoded a second time
+func (s *sigV4Signer) canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if s.service == "s3" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the query parameters by encoded name, then by encoded
+// value, as SigV4 requires. Sorting the joined "name=value" strings instead
+// would misorder names that extend another name with a character below '=',
+// e.g. "a-b" before "a".
+func canonicalQuery(u *url.URL) string {
+	type param struct{ name, value string }
+	var params []param
+	for name, values := range u.Query() {
+		for _, value := range values {
+			params = append(params, param{sigV4Escape(name), sigV4Escape(value)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+
+	joined := make([]string, len(params))
+	for i, p := range params {
+		joined[i] = p.name + "=" + p.value
+	}
+	return strings.Join(joined, "&")
+}
+
+// sigV4Escape percent-encodes everything but the RFC 3986 unreserved
+// characters
+func sigV4Escape(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// assumeRoleWithWebIdentityResponse is the part of the STS response that
+// holds the temporary credentials
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// assumeRoleWithWebIdentity exchanges the projected service account token
+// for temporary credentials of the role. The token file is read on every
+// call, since the kubelet rotates it.
+func assumeRoleWithWebIdentity(client *http.Client, endpoint, roleARN, sessionName, tokenFile string) (awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read AWS_WEB_IDENTITY_TOKEN_FILE: %w", err)
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to create STS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("STS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read STS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("STS AssumeRoleWithWebIdentity failed with status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var result assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to parse STS response: %w", err)
+	}
+	credentials := result.Credentials
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("STS response contains no credentials")
+	}
+	return awsCredentials{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SessionToken,
+		Expiry:          credentials.Expiration,
+	}, nil
+}
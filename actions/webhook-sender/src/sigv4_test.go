@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSigV4Sign(t *testing.T) {
+	// The get-vanilla and get-vanilla-query-order-key cases of the AWS
+	// Signature Version 4 test suite
+	credentials := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "get-vanilla",
+			url:  "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "get-vanilla-query-order-key",
+			url:  "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer := &sigV4Signer{region: "us-east-1", service: "service"}
+			signer.sign(req, nil, credentials, now)
+
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, tt.want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+		})
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "by name, then value", query: "b=2&a=2&a=1", want: "a=1&a=2&b=2"},
+		// Sorting "a-b=1" and "a=1" as strings would put "a-b" first, as '-' sorts before '='
+		{name: "name is a prefix of another", query: "a-b=1&a=1&a.c=1&a0=1", want: "a=1&a-b=1&a.c=1&a0=1"},
+		{name: "encoded before sorting", query: "z=%20&Z=1&%2A=1", want: "%2A=1&Z=1&z=%20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &url.URL{RawQuery: tt.query}
+			if got := canonicalQuery(u); got != tt.want {
+				t.Errorf("canonicalQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSigV4SignSessionToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://abc.execute-api.eu-west-1.amazonaws.com/prod/alerts", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	signer := &sigV4Signer{region: "eu-west-1", service: "execute-api"}
+	signer.sign(req, []byte("{}"), awsCredentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token"}, time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("X-Amz-Security-Token = %q, want token", got)
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization = %q, want the content type and session token signed", got)
+	}
+}
+
+func TestParseSigV4Config(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("jwt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		authHeader  string
+		wantService string
+		wantErr     string
+	}{
+		{name: "unset", env: map[string]string{}},
+		{name: "static credentials", env: map[string]string{"SIGV4_REGION": "eu-west-1", "AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret"}, wantService: "execute-api"},
+		{name: "web identity", env: map[string]string{"SIGV4_REGION": "eu-west-1", "SIGV4_SERVICE": "lambda", "AWS_ROLE_ARN": "arn:aws:iam::123:role/x", "AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile}, wantService: "lambda"},
+		{name: "no credentials", env: map[string]string{"SIGV4_REGION": "eu-west-1"}, wantErr: "requires AWS_ACCESS_KEY_ID"},
+		{name: "partial credentials", env: map[string]string{"SIGV4_REGION": "eu-west-1", "AWS_ACCESS_KEY_ID": "AKID"}, wantErr: "both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY"},
+		{name: "service without region", env: map[string]string{"SIGV4_SERVICE": "lambda"}, wantErr: "requires SIGV4_REGION"},
+		{name: "with static auth", env: map[string]string{"SIGV4_REGION": "eu-west-1", "AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret"}, authHeader: "Bearer abc", wantErr: "another auth method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SIGV4_REGION", "SIGV4_SERVICE", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{AuthHeader: tt.authHeader, TimeoutSeconds: 5}
+			err := parseSigV4Config(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseSigV4Config() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSigV4Config() unexpected error: %v", err)
+			}
+			if (config.SigV4 != nil) != (tt.wantService != "") || config.SigV4Service != tt.wantService {
+				t.Errorf("signer set = %t, service = %q, want %q", config.SigV4 != nil, config.SigV4Service, tt.wantService)
+			}
+		})
+	}
+}
+
+func TestSendWebhookSigV4WebIdentity(t *testing.T) {
+	var stsCalls atomic.Int32
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stsCalls.Add(1)
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("WebIdentityToken") != "projected-token" || r.Form.Get("RoleArn") != "arn:aws:iam::123:role/alerts" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIATEMP</AccessKeyId>
+      <SecretAccessKey>temp-secret</SecretAccessKey>
+      <SessionToken>temp-token</SessionToken>
+      <Expiration>` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer sts.Close()
+
+	var authorizations, tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		tokens = append(tokens, r.Header.Get("X-Amz-Security-Token"))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("projected-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIGV4_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123:role/alerts")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ENDPOINT_URL_STS", sts.URL)
+
+	config := &Config{Targets: []WebhookTarget{{URL: server.URL}}, TimeoutSeconds: 5}
+	if err := parseSigV4Config(config); err != nil {
+		t.Fatalf("parseSigV4Config() unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		var err error
+		captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+		if err != nil {
+			t.Fatalf("sendWebhook() unexpected error: %v", err)
+		}
+	}
+
+	if got := stsCalls.Load(); got != 1 {
+		t.Errorf("STS called %d times, want the credentials cached after 1", got)
+	}
+	for i, authorization := range authorizations {
+		if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=ASIATEMP/") || !strings.Contains(authorization, "/eu-west-1/execute-api/aws4_request") || tokens[i] != "temp-token" {
+			t.Errorf("request %d: Authorization = %q, X-Amz-Security-Token = %q", i, authorization, tokens[i])
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sinkFile writes messages to a local directory instead of the webhook
+const sinkFile = "file"
+
+// FileSinkRecord is the content written by the file sink for each alert.
+// It mirrors the HTTP request that would have been sent, with the URL,
+// Authorization header and sensitive HEADERS_JSON values redacted. JSON bodies are embedded as-is, other
+// templated bodies as a string. With WEBHOOK_GZIP the uncompressed body is
+// recorded, while the headers describe the compressed request.
+type FileSinkRecord struct {
+	Target  string            `json:"target,omitempty"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    interface{}       `json:"body"`
+}
+
+// validateSink checks the SINK and SINK_DIR settings
+func validateSink(sink, dir string) error {
+	switch sink {
+	case "":
+		return nil
+	case sinkFile:
+		if dir == "" {
+			return fmt.Errorf("SINK_DIR is required when SINK=%s", sinkFile)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported SINK '%s', must be '%s' or unset", sink, sinkFile)
+	}
+}
+
+// writeFileSink writes the request that would have been sent to a new file
+// in the sink directory, one per target, and returns their paths
+func writeFileSink(config *Config, payload WebhookPayload) ([]string, error) {
+	records, err := buildSinkRecords(config, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var paths []string
+	for _, record := range records {
+		// Fan-out targets get their name appended so each has its own file
+		name := sinkFileName(payload.AlertName, payload.Status, now)
+		if record.Target != "" {
+			name = strings.TrimSuffix(name, ".json") + "-" + sanitizeFileComponent(record.Target, "target") + ".json"
+		}
+
+		path, err := writeSinkFile(config.SinkDir, name, record)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// buildSinkRecords builds the record of the request that would have been
+// sent to each target, shared by the file sink and DRY_RUN
+func buildSinkRecords(config *Config, payload WebhookPayload) ([]FileSinkRecord, error) {
+	method := requestMethod(config, payload.Status)
+	query := queryParams(config, payload)
+	body, raw, header, err := requestBody(config, method, payload)
+	if err != nil {
+		return nil, err
+	}
+	header = requestHeader(config, payload, header)
+	var recordBody interface{}
+	if json.Valid(raw) {
+		recordBody = json.RawMessage(raw)
+	} else if raw != nil {
+		recordBody = string(raw)
+	}
+
+	records := make([]FileSinkRecord, 0, len(config.Targets))
+	for _, target := range config.Targets {
+		req, err := buildRequest(config, target, method, query, header, body)
+		if err != nil {
+			return nil, err
+		}
+
+		headers := make(map[string]string, len(req.Header))
+		for name := range req.Header {
+			headers[name] = req.Header.Get(name)
+		}
+		if _, ok := headers["Authorization"]; ok {
+			headers["Authorization"] = "***"
+		}
+		for name := range config.Headers {
+			if isSensitiveHeader(name) {
+				headers[name] = "***"
+			}
+		}
+
+		records = append(records, FileSinkRecord{
+			Target:  target.Name,
+			Method:  req.Method,
+			URL:     redactURL(target.URL),
+			Headers: headers,
+			Body:    recordBody,
+		})
+	}
+	return records, nil
+}
+
+// sinkFileName builds a file name that sorts chronologically and is safe on
+// any filesystem, e.g. 20240101T120000.000000000Z-HighCPU-firing.json
+func sinkFileName(alertName, status string, now time.Time) string {
+	return fmt.Sprintf("%s-%s-%s.json",
+		now.UTC().Format("20060102T150405.000000000Z"),
+		sanitizeFileComponent(alertName, "alert"),
+		sanitizeFileComponent(status, "unknown"))
+}
+
+// sanitizeFileComponent replaces characters that are not safe in file names
+func sanitizeFileComponent(value, fallback string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+
+	sanitized = strings.Trim(sanitized, "._")
+	if sanitized == "" {
+		return fallback
+	}
+	return sanitized
+}
+
+// writeSinkFile writes the record as indented JSON, refusing to overwrite an
+// existing file
+func writeSinkFile(dir, name string, record interface{}) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create sink directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal sink record: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sink file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write sink file: %w", err)
+	}
+
+	return path, nil
+}
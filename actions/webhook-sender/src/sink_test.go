@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "unset", sink: ""},
+		{name: "file with directory", sink: "file", dir: "/tmp/out"},
+		{name: "file without directory", sink: "file", wantErr: true},
+		{name: "unknown sink", sink: "s3", dir: "/tmp/out", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSink(tt.sink, tt.dir); (err != nil) != tt.wantErr {
+				t.Errorf("validateSink(%q, %q) error = %v, wantErr %t", tt.sink, tt.dir, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSinkFileName(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name      string
+		alertName string
+		status    string
+		want      string
+	}{
+		{name: "plain", alertName: "HighCPU", status: "firing", want: "20240102T020405.000000006Z-HighCPU-firing.json"},
+		{name: "unsafe characters", alertName: "../disk full/", status: "resolved", want: "20240102T020405.000000006Z-disk_full-resolved.json"},
+		{name: "empty values", want: "20240102T020405.000000006Z-alert-unknown.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sinkFileName(tt.alertName, tt.status, now); got != tt.want {
+				t.Errorf("sinkFileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sink")
+	config := &Config{
+		Targets: []WebhookTarget{{
+			URL:        "https://hooks.slack.com/services/T000/B000/XXXXXXXX",
+			AuthHeader: "Bearer super-secret-token",
+		}},
+		SinkDir: dir,
+	}
+	payload := WebhookPayload{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Severity:  "critical",
+		Labels:    map[string]string{"instance": "node-1"},
+	}
+
+	paths, err := writeFileSink(config, payload)
+	if err != nil {
+		t.Fatalf("writeFileSink() unexpected error: %v", err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("writeFileSink() wrote %d files, want 1", len(paths))
+	}
+	path := paths[0]
+	if filepath.Dir(path) != dir {
+		t.Errorf("file written to %s, want directory %s", path, dir)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read sink file: %v", err)
+	}
+
+	var record struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    WebhookPayload    `json:"body"`
+	}
+	if err := json.Unmarshal(content, &record); err != nil {
+		t.Fatalf("sink file is not valid JSON: %v\n%s", err, content)
+	}
+
+	if record.Method != "POST" {
+		t.Errorf("method = %q, want POST", record.Method)
+	}
+	if record.URL != "https://hooks.slack.com/***" {
+		t.Errorf("url = %q", record.URL)
+	}
+	if record.Headers["Content-Type"] != "application/json" || record.Headers["Authorization"] != "***" {
+		t.Errorf("headers = %+v", record.Headers)
+	}
+	if record.Body.AlertName != "DiskFull" || record.Body.Labels["instance"] != "node-1" {
+		t.Errorf("body = %+v", record.Body)
+	}
+	for _, secret := range []string{"super-secret-token", "XXXXXXXX"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("sink file leaked secret %q: %s", secret, content)
+		}
+	}
+
+	// A second alert gets its own file
+	second, err := writeFileSink(config, payload)
+	if err != nil {
+		t.Fatalf("writeFileSink() second call unexpected error: %v", err)
+	}
+	if second[0] == path {
+		t.Errorf("second alert overwrote %s", path)
+	}
+}
+
+func TestWriteFileSinkPerTarget(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		Targets: []WebhookTarget{
+			{Name: "pagerduty", URL: "https://events.pagerduty.com/v2/enqueue"},
+			{Name: "team chat", URL: "https://chat.example.com/hook"},
+		},
+		SinkDir: dir,
+	}
+
+	paths, err := writeFileSink(config, WebhookPayload{AlertName: "DiskFull", Status: "firing"})
+	if err != nil {
+		t.Fatalf("writeFileSink() unexpected error: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("writeFileSink() wrote %d files, want 2", len(paths))
+	}
+	for i, suffix := range []string{"-DiskFull-firing-pagerduty.json", "-DiskFull-firing-team_chat.json"} {
+		if !strings.HasSuffix(paths[i], suffix) {
+			t.Errorf("file %d = %s, want suffix %s", i, paths[i], suffix)
+		}
+	}
+
+	content, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatalf("failed to read sink file: %v", err)
+	}
+	if !strings.Contains(string(content), `"target": "team chat"`) || !strings.Contains(string(content), "https://chat.example.com/***") {
+		t.Errorf("unexpected sink record: %s", content)
+	}
+}
+
+func TestWriteSinkFileRefusesOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := writeSinkFile(dir, "alert.json", map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("writeSinkFile() unexpected error: %v", err)
+	}
+	if _, err := writeSinkFile(dir, "alert.json", map[string]string{"a": "c"}); err == nil {
+		t.Error("writeSinkFile() should refuse to overwrite an existing file")
+	}
+}
+
+func TestWriteFileSinkTemplatedBody(t *testing.T) {
+	tmpl, err := parseBodyTemplate(`{{ .AlertName }} is {{ .Status }}`, "")
+	if err != nil {
+		t.Fatalf("parseBodyTemplate() unexpected error: %v", err)
+	}
+	config := &Config{
+		Targets:      []WebhookTarget{{URL: "https://chat.example.com/hook"}},
+		BodyTemplate: tmpl,
+		ContentType:  "text/plain",
+		SinkDir:      t.TempDir(),
+	}
+
+	paths, err := writeFileSink(config, WebhookPayload{AlertName: "DiskFull", Status: "firing"})
+	if err != nil {
+		t.Fatalf("writeFileSink() unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("failed to read sink file: %v", err)
+	}
+	for _, want := range []string{`"Content-Type": "text/plain"`, `"body": "DiskFull is firing"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("sink record missing %s: %s", want, content)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FAILURE_MODE values
+const (
+	failureModeAny = "any"
+	failureModeAll = "all"
+)
+
+// WebhookTarget is a single receiver in WEBHOOK_TARGETS. Each target has its
+// own success criteria, evaluated independently of the other targets.
+type WebhookTarget struct {
+	Name       string `json:"name,omitempty"`
+	URL        string `json:"url"`
+	AuthHeader string `json:"authHeader,omitempty"`
+	// SuccessStatus lists the accepted status codes; any 2xx when empty
+	SuccessStatus []int `json:"successStatus,omitempty"`
+	// BodyContains must appear in the response body
+	BodyContains string `json:"bodyContains,omitempty"`
+	// JSONFields maps dot-separated paths in a JSON response to their
+	// expected values
+	JSONFields map[string]string `json:"jsonFields,omitempty"`
+}
+
+// targetResult is the outcome of delivering to one target
+type targetResult struct {
+	Target string
+	Err    error
+	// Response is the last response, nil when no attempt got one
+	Response *targetResponse
+	Attempts int
+	Duration time.Duration
+}
+
+// parseWebhookTargets parses WEBHOOK_TARGETS, either a JSON array of
+// targets or a comma-separated list of URLs with the default success
+// criteria, naming unnamed targets by position
+func parseWebhookTargets(spec string) ([]WebhookTarget, error) {
+	var targets []WebhookTarget
+	if trimmed := strings.TrimSpace(spec); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		decoder := json.NewDecoder(strings.NewReader(spec))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&targets); err != nil {
+			return nil, fmt.Errorf("failed to parse WEBHOOK_TARGETS: %w", err)
+		}
+	} else {
+		for _, url := range parseLabelList(spec) {
+			targets = append(targets, WebhookTarget{URL: url})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("WEBHOOK_TARGETS must contain at least one target")
+	}
+
+	seen := map[string]bool{}
+	for i := range targets {
+		target := &targets[i]
+		if target.Name == "" {
+			target.Name = fmt.Sprintf("target-%d", i+1)
+		}
+		if seen[target.Name] {
+			return nil, fmt.Errorf("WEBHOOK_TARGETS contains duplicate target name '%s'", target.Name)
+		}
+		seen[target.Name] = true
+
+		if target.URL == "" {
+			return nil, fmt.Errorf("WEBHOOK_TARGETS target '%s' is missing url", target.Name)
+		}
+		for _, code := range target.SuccessStatus {
+			if code < 100 || code > 599 {
+				return nil, fmt.Errorf("WEBHOOK_TARGETS target '%s' has invalid success status %d", target.Name, code)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// routeWebhook replaces the targets with WEBHOOK_URL_FIRING or
+// WEBHOOK_URL_RESOLVED when one is set for the alert's status, e.g. to send
+// resolutions to a quieter channel. The override is a single target with
+// the configured auth, also when WEBHOOK_TARGETS would fan out otherwise.
+func routeWebhook(config *Config, status string) error {
+	if url := statusOverride(config.WebhookURLFiring, config.WebhookURLResolved, status); url != "" {
+		config.Targets = []WebhookTarget{{URL: url, AuthHeader: config.AuthHeader}}
+		log.Printf("Routing %s alert to WEBHOOK_URL_%s", strings.ToLower(status), strings.ToUpper(status))
+	}
+	if len(config.Targets) == 0 {
+		return missingRouteError("WEBHOOK_URL", status)
+	}
+	return nil
+}
+
+// parseFailureMode validates FAILURE_MODE, defaulting to any
+func parseFailureMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return failureModeAny, nil
+	case failureModeAny, failureModeAll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported FAILURE_MODE '%s', must be 'any' or 'all'", mode)
+	}
+}
+
+// checkResponse evaluates a response against the target's success criteria
+func checkResponse(target WebhookTarget, statusCode int, body []byte) error {
+	if len(target.SuccessStatus) > 0 {
+		accepted := false
+		for _, code := range target.SuccessStatus {
+			if statusCode == code {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			return fmt.Errorf("status %d is not one of %v: %s", statusCode, target.SuccessStatus, string(body))
+		}
+	} else if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("webhook request failed with status %d: %s", statusCode, string(body))
+	}
+
+	if err := checkResponseBody(target, body); err != nil {
+		return &assertionError{err: err}
+	}
+	return nil
+}
+
+// checkResponseBody evaluates the target's assertions on the body of a
+// response with an accepted status code
+func checkResponseBody(target WebhookTarget, body []byte) error {
+	if target.BodyContains != "" && !bytes.Contains(body, []byte(target.BodyContains)) {
+		return fmt.Errorf("response body does not contain %q", target.BodyContains)
+	}
+
+	if len(target.JSONFields) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return fmt.Errorf("response body is not JSON: %w", err)
+		}
+		for path, want := range target.JSONFields {
+			got, ok := jsonFieldString(document, path)
+			if !ok {
+				return fmt.Errorf("response field %s is missing", path)
+			}
+			if got != want {
+				return fmt.Errorf("response field %s is %q, want %q", path, got, want)
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonFieldString resolves a dot-separated path in a decoded JSON document
+// and formats scalar values as strings
+func jsonFieldString(document interface{}, path string) (string, bool) {
+	node := document
+	for _, part := range strings.Split(path, ".") {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if node, ok = object[part]; !ok {
+			return "", false
+		}
+	}
+
+	switch value := node.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return fmt.Sprint(value), true
+	case nil:
+		return "null", true
+	default:
+		return "", false
+	}
+}
+
+// forEachConcurrently calls fn with every index below n from a pool of
+// workers goroutines and returns once all calls have returned
+func forEachConcurrently(n, workers int, fn func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// aggregateResults combines per-target outcomes under the failure mode:
+// with "any" a single failed target fails the run, with "all" the run only
+// fails when every target failed
+func aggregateResults(mode string, results []targetResult) error {
+	var failed []error
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, fmt.Errorf("target %s: %w", result.Target, result.Err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	if mode == failureModeAll && len(failed) < len(results) {
+		log.Printf("Warning: %d of %d targets failed (FAILURE_MODE=all)", len(failed), len(results))
+		return nil
+	}
+	return errors.Join(failed...)
+}
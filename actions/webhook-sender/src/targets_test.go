@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseWebhookTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "named and unnamed targets",
+			spec:      `[{"name":"pagerduty","url":"https://a.example.com","successStatus":[202]},{"url":"https://b.example.com"}]`,
+			wantNames: []string{"pagerduty", "target-2"},
+		},
+		{name: "comma-separated URLs", spec: "https://a.example.com, https://b.example.com", wantNames: []string{"target-1", "target-2"}},
+		{name: "only commas", spec: " , ", wantErr: true},
+		{name: "not an array", spec: `{"url":"https://a.example.com"}`, wantErr: true},
+		{name: "empty array", spec: `[]`, wantErr: true},
+		{name: "missing url", spec: `[{"name":"a"}]`, wantErr: true},
+		{name: "duplicate names", spec: `[{"name":"a","url":"https://a"},{"name":"a","url":"https://b"}]`, wantErr: true},
+		{name: "invalid status code", spec: `[{"url":"https://a","successStatus":[2000]}]`, wantErr: true},
+		{name: "unknown field", spec: `[{"url":"https://a","successCodes":[200]}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := parseWebhookTargets(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWebhookTargets() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if len(targets) != len(tt.wantNames) {
+				t.Fatalf("parseWebhookTargets() = %+v, want names %v", targets, tt.wantNames)
+			}
+			for i, name := range tt.wantNames {
+				if targets[i].Name != name {
+					t.Errorf("target %d name = %q, want %q", i, targets[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  WebhookTarget
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "default accepts 2xx", status: 204},
+		{name: "default rejects 4xx", status: 400, wantErr: true},
+		{name: "explicit status accepted", target: WebhookTarget{SuccessStatus: []int{202}}, status: 202},
+		{name: "2xx outside explicit list rejected", target: WebhookTarget{SuccessStatus: []int{202}}, status: 200, wantErr: true},
+		{name: "non-2xx in explicit list accepted", target: WebhookTarget{SuccessStatus: []int{200, 409}}, status: 409},
+		{name: "body contains", target: WebhookTarget{BodyContains: "queued"}, status: 200, body: `{"result":"queued"}`},
+		{name: "body does not contain", target: WebhookTarget{BodyContains: "queued"}, status: 200, body: `{"result":"dropped"}`, wantErr: true},
+		{
+			name:   "json fields match",
+			target: WebhookTarget{JSONFields: map[string]string{"status": "success", "data.count": "1", "ok": "true"}},
+			status: 200,
+			body:   `{"status":"success","data":{"count":1},"ok":true}`,
+		},
+		{name: "json field mismatch", target: WebhookTarget{JSONFields: map[string]string{"ok": "true"}}, status: 200, body: `{"ok":false}`, wantErr: true},
+		{name: "json field missing", target: WebhookTarget{JSONFields: map[string]string{"data.id": "1"}}, status: 200, body: `{"data":{}}`, wantErr: true},
+		{name: "json field on non-JSON body", target: WebhookTarget{JSONFields: map[string]string{"ok": "true"}}, status: 200, body: "ok", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResponse(tt.target, tt.status, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkResponse() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAggregateResults(t *testing.T) {
+	ok := targetResult{Target: "a"}
+	failed := targetResult{Target: "b", Err: errors.New("boom")}
+
+	tests := []struct {
+		name    string
+		mode    string
+		results []targetResult
+		wantErr bool
+	}{
+		{name: "any with all succeeded", mode: "any", results: []targetResult{ok, ok}},
+		{name: "any with one failed", mode: "any", results: []targetResult{ok, failed}, wantErr: true},
+		{name: "all with one failed", mode: "all", results: []targetResult{ok, failed}},
+		{name: "all with every target failed", mode: "all", results: []targetResult{failed, failed}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			captureLog(t, func() { err = aggregateResults(tt.mode, tt.results) })
+			if (err != nil) != tt.wantErr {
+				t.Errorf("aggregateResults() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendWebhookPerTargetCriteria(t *testing.T) {
+	// Each receiver answers differently; only the target's own criteria decide success
+	newReceiver := func(status int, body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	strict := newReceiver(http.StatusOK, `{"ok":true}`)
+	async := newReceiver(http.StatusAccepted, `{"queued":true}`)
+	softFail := newReceiver(http.StatusOK, `{"ok":false}`)
+
+	targets := []WebhookTarget{
+		{Name: "strict", URL: strict.URL, SuccessStatus: []int{200}},
+		{Name: "async", URL: async.URL, SuccessStatus: []int{202}, BodyContains: "queued"},
+		{Name: "soft-fail", URL: softFail.URL, JSONFields: map[string]string{"ok": "true"}},
+	}
+
+	tests := []struct {
+		name      string
+		targets   []WebhookTarget
+		mode      string
+		wantErr   bool
+		wantInErr string
+	}{
+		{name: "distinct criteria all met", targets: targets[:2], mode: "any"},
+		{name: "one target fails its assertion", targets: targets, mode: "any", wantErr: true, wantInErr: "target soft-fail"},
+		{name: "tolerated under FAILURE_MODE=all", targets: targets, mode: "all"},
+		{name: "202 rejected when target requires 200", targets: []WebhookTarget{{Name: "strict", URL: async.URL, SuccessStatus: []int{200}}}, mode: "any", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Targets: tt.targets, FailureMode: tt.mode, TimeoutSeconds: 5}
+
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendWebhook() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantInErr != "" && !strings.Contains(err.Error(), tt.wantInErr) {
+				t.Errorf("error %q does not mention %q", err, tt.wantInErr)
+			}
+		})
+	}
+}
+
+func TestSendWebhookMaxConcurrency(t *testing.T) {
+	var inFlight, maxInFlight, requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	targets := make([]WebhookTarget, 6)
+	for i := range targets {
+		targets[i] = WebhookTarget{Name: fmt.Sprintf("target-%d", i+1), URL: server.URL}
+	}
+	targets[4].URL = server.URL + "/fail"
+	config := &Config{Targets: targets, FailureMode: failureModeAny, MaxConcurrency: 2, TimeoutSeconds: 5}
+
+	var err error
+	captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+	if err == nil || !strings.Contains(err.Error(), "target target-5") {
+		t.Errorf("sendWebhook() error = %v, want target-5 to fail", err)
+	}
+	if got := requests.Load(); got != 6 {
+		t.Errorf("received %d requests, want 6", got)
+	}
+	if got := maxInFlight.Load(); got != 2 {
+		t.Errorf("max in-flight requests = %d, want 2", got)
+	}
+}
+
+func TestLoadConfigTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		targets     string
+		authHeader  string
+		failureMode string
+		wantURLs    []string
+		wantAuth    []string
+		wantErr     bool
+	}{
+		{name: "single URL", url: "https://a.example.com", authHeader: "Bearer x", wantURLs: []string{"https://a.example.com"}, wantAuth: []string{"Bearer x"}},
+		{
+			name:       "targets inherit AUTH_HEADER",
+			targets:    `[{"url":"https://a.example.com"},{"url":"https://b.example.com","authHeader":"Token y"}]`,
+			authHeader: "Bearer x",
+			wantURLs:   []string{"https://a.example.com", "https://b.example.com"},
+			wantAuth:   []string{"Bearer x", "Token y"},
+		},
+		{name: "neither set", wantErr: true},
+		{name: "both set", url: "https://a.example.com", targets: `[{"url":"https://b.example.com"}]`, wantErr: true},
+		{name: "invalid failure mode", url: "https://a.example.com", failureMode: "some", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", tt.url)
+			t.Setenv("WEBHOOK_TARGETS", tt.targets)
+			t.Setenv("AUTH_HEADER", tt.authHeader)
+			t.Setenv("FAILURE_MODE", tt.failureMode)
+
+			config, err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if config.FailureMode != "any" {
+				t.Errorf("FailureMode = %q, want default %q", config.FailureMode, "any")
+			}
+			if len(config.Targets) != len(tt.wantURLs) {
+				t.Fatalf("Targets = %+v, want URLs %v", config.Targets, tt.wantURLs)
+			}
+			for i := range tt.wantURLs {
+				if config.Targets[i].URL != tt.wantURLs[i] || config.Targets[i].AuthHeader != tt.wantAuth[i] {
+					t.Errorf("target %d = %+v, want url %q auth %q", i, config.Targets[i], tt.wantURLs[i], tt.wantAuth[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRouteWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		targets  string
+		firing   string
+		resolved string
+		status   string
+		wantURLs []string
+		wantErr  string
+	}{
+		{name: "generic URL", url: "https://a.example.com", status: "resolved", wantURLs: []string{"https://a.example.com"}},
+		{name: "resolved override", url: "https://a.example.com", resolved: "https://quiet.example.com", status: "resolved", wantURLs: []string{"https://quiet.example.com"}},
+		{name: "firing keeps generic URL", url: "https://a.example.com", resolved: "https://quiet.example.com", status: "firing", wantURLs: []string{"https://a.example.com"}},
+		{
+			name:     "override replaces fan-out",
+			targets:  `[{"url":"https://a.example.com"},{"url":"https://b.example.com"}]`,
+			resolved: "https://quiet.example.com",
+			status:   "resolved",
+			wantURLs: []string{"https://quiet.example.com"},
+		},
+		{name: "override for the other status", firing: "https://a.example.com", status: "resolved", wantErr: "WEBHOOK_URL_RESOLVED or WEBHOOK_URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", tt.url)
+			t.Setenv("WEBHOOK_TARGETS", tt.targets)
+			t.Setenv("WEBHOOK_URL_FIRING", tt.firing)
+			t.Setenv("WEBHOOK_URL_RESOLVED", tt.resolved)
+			t.Setenv("AUTH_HEADER", "Bearer x")
+
+			config, err := loadConfig()
+			if err != nil {
+				t.Fatalf("loadConfig() unexpected error: %v", err)
+			}
+			captureLog(t, func() { err = routeWebhook(config, tt.status) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("routeWebhook() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("routeWebhook() unexpected error: %v", err)
+			}
+			if len(config.Targets) != len(tt.wantURLs) {
+				t.Fatalf("Targets = %+v, want URLs %v", config.Targets, tt.wantURLs)
+			}
+			for i, want := range tt.wantURLs {
+				if config.Targets[i].URL != want || config.Targets[i].AuthHeader != "Bearer x" {
+					t.Errorf("target %d = %+v, want url %q with AUTH_HEADER", i, config.Targets[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// defaultContentType is sent with the built-in payload and with templated
+// bodies unless WEBHOOK_CONTENT_TYPE overrides it
+const defaultContentType = "application/json"
+
+// templateFuncs are the helper functions available in WEBHOOK_BODY_TEMPLATE
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default returns fallback when value is empty, e.g.
+	// {{ .Severity | default "warning" }}
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	// json encodes a value as JSON, for embedding strings or maps safely in
+	// a JSON body, e.g. {"text": {{ .Summary | json }}}
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// parseBodyTemplate parses the body template from WEBHOOK_BODY_TEMPLATE or
+// the file named by WEBHOOK_BODY_TEMPLATE_FILE. It returns nil when neither
+// is set, in which case the built-in JSON payload is sent.
+func parseBodyTemplate(text, file string) (*template.Template, error) {
+	if text != "" && file != "" {
+		return nil, fmt.Errorf("WEBHOOK_BODY_TEMPLATE and WEBHOOK_BODY_TEMPLATE_FILE are mutually exclusive")
+	}
+
+	name := "WEBHOOK_BODY_TEMPLATE"
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WEBHOOK_BODY_TEMPLATE_FILE: %w", err)
+		}
+		text = string(data)
+		name = file
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderBody builds the request body for the payload, rendering the body
+// template if one is configured. With ALERT_GROUP_MODE=passthrough the
+// body is the alert document as received.
+func renderBody(config *Config, payload WebhookPayload) ([]byte, error) {
+	if config.PassthroughBody != nil {
+		return config.PassthroughBody, nil
+	}
+	if config.BodyTemplate == nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := config.BodyTemplate.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("failed to render body template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBodyTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "body.tmpl")
+	if err := os.WriteFile(file, []byte(`{"text": "{{ .AlertName }}"}`), 0o644); err != nil {
+		t.Fatalf("failed to write template file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		file    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "unset", wantNil: true},
+		{name: "inline", text: `{"text": "{{ .AlertName }}"}`},
+		{name: "file", file: file},
+		{name: "both set", text: "{{ .AlertName }}", file: file, wantErr: true},
+		{name: "syntax error", text: "{{ .AlertName ", wantErr: true},
+		{name: "unknown function", text: "{{ .AlertName | shout }}", wantErr: true},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.tmpl"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseBodyTemplate(tt.text, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBodyTemplate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && (tmpl == nil) != tt.wantNil {
+				t.Errorf("parseBodyTemplate() = %v, want nil %t", tmpl, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	payload := WebhookPayload{
+		AlertName: "DiskFull",
+		Status:    "firing",
+		Summary:   `Disk "/" is full`,
+		Labels:    map[string]string{"instance": "node-1"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name: "built-in payload",
+			want: `{"alertName":"DiskFull","status":"firing","severity":"","instance":"","summary":"Disk \"/\" is full",`,
+		},
+		{
+			name:     "slack message with helpers",
+			template: `{"text": "[{{ .Status | upper }}] {{ .AlertName | lower }} ({{ .Severity | default "warning" }}) on {{ .Labels.instance }}"}`,
+			want:     `{"text": "[FIRING] diskfull (warning) on node-1"}`,
+		},
+		{
+			name:     "json helper escapes values",
+			template: `{"text": {{ .Summary | json }}}`,
+			want:     `{"text": "Disk \"/\" is full"}`,
+		},
+		{
+			name:     "plain text",
+			template: `{{ .AlertName }} is {{ .Status }}`,
+			want:     `DiskFull is firing`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseBodyTemplate(tt.template, "")
+			if err != nil {
+				t.Fatalf("parseBodyTemplate() unexpected error: %v", err)
+			}
+			body, err := renderBody(&Config{BodyTemplate: tmpl}, payload)
+			if err != nil {
+				t.Fatalf("renderBody() unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(string(body), tt.want) {
+				t.Errorf("renderBody() = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigBodyTemplate(t *testing.T) {
+	tests := []struct {
+		name            string
+		template        string
+		groupMode       string
+		contentType     string
+		wantContentType string
+		wantWarning     bool
+		wantErr         bool
+	}{
+		{name: "no template"},
+		{name: "template", template: "{{ .AlertName }}"},
+		{name: "template with content type", template: "{{ .AlertName }}", contentType: "text/plain", wantContentType: "text/plain"},
+		{name: "content type without template", contentType: "text/plain", wantWarning: true},
+		{name: "passthrough with content type", groupMode: "passthrough", contentType: "application/vnd.alertmanager+json", wantContentType: "application/vnd.alertmanager+json"},
+		{name: "parse error fails at load", template: "{{ if .AlertName }}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", "https://example.com/hook")
+			t.Setenv("WEBHOOK_BODY_TEMPLATE", tt.template)
+			t.Setenv("WEBHOOK_CONTENT_TYPE", tt.contentType)
+			t.Setenv("ALERT_GROUP_MODE", tt.groupMode)
+
+			var config *Config
+			var err error
+			output := captureLog(t, func() { config, err = loadConfig() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (config.BodyTemplate != nil) != (tt.template != "") {
+				t.Errorf("BodyTemplate = %v, want set %t", config.BodyTemplate, tt.template != "")
+			}
+			if config.ContentType != tt.wantContentType {
+				t.Errorf("ContentType = %q, want %q", config.ContentType, tt.wantContentType)
+			}
+			if gotWarning := strings.Contains(output, "WEBHOOK_CONTENT_TYPE is ignored"); gotWarning != tt.wantWarning {
+				t.Errorf("warning logged = %t, want %t (output: %s)", gotWarning, tt.wantWarning, output)
+			}
+		})
+	}
+}
+
+func TestSendWebhookTemplatedBody(t *testing.T) {
+	var gotBody, gotContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		gotContentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	tmpl, err := parseBodyTemplate(`{{ .AlertName }} is {{ .Status | upper }}`, "")
+	if err != nil {
+		t.Fatalf("parseBodyTemplate() unexpected error: %v", err)
+	}
+	config := &Config{
+		Targets:        []WebhookTarget{{URL: server.URL}},
+		BodyTemplate:   tmpl,
+		ContentType:    "text/plain",
+		TimeoutSeconds: 5,
+	}
+
+	captureLog(t, func() {
+		err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull", Status: "firing"})
+	})
+	if err != nil {
+		t.Fatalf("sendWebhook() unexpected error: %v", err)
+	}
+	if gotBody != "DiskFull is FIRING" {
+		t.Errorf("body = %q, want %q", gotBody, "DiskFull is FIRING")
+	}
+	if gotContentType != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", gotContentType)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestampSource(t *testing.T) {
+	for _, source := range []string{"", "starts_at", "ends_at", "now"} {
+		if got, err := parseTimestampSource(source); err != nil || got != source {
+			t.Errorf("parseTimestampSource(%q) = %q, %v", source, got, err)
+		}
+	}
+	if _, err := parseTimestampSource("startsAt"); err == nil {
+		t.Error("parseTimestampSource(startsAt) should fail")
+	}
+}
+
+func TestAlertTimestamp(t *testing.T) {
+	const (
+		startsAt = "2024-01-01T12:00:00.123+02:00"
+		endsAt   = "2024-01-01T13:30:00Z"
+		zero     = "0001-01-01T00:00:00Z"
+	)
+
+	tests := []struct {
+		name     string
+		source   string
+		status   string
+		startsAt string
+		endsAt   string
+		want     string
+		wantNow  bool
+		wantWarn bool
+	}{
+		{name: "firing uses startsAt", status: "firing", startsAt: startsAt, endsAt: zero, want: "2024-01-01T10:00:00Z"},
+		{name: "resolved uses endsAt", status: "resolved", startsAt: startsAt, endsAt: endsAt, want: endsAt},
+		{name: "explicit starts_at for resolved", source: "starts_at", status: "resolved", startsAt: startsAt, endsAt: endsAt, want: "2024-01-01T10:00:00Z"},
+		{name: "explicit ends_at", source: "ends_at", status: "firing", startsAt: startsAt, endsAt: endsAt, want: endsAt},
+		{name: "now", source: "now", status: "firing", startsAt: startsAt, wantNow: true},
+		{name: "missing startsAt", status: "firing", wantNow: true},
+		{name: "unset endsAt", status: "resolved", startsAt: startsAt, endsAt: zero, wantNow: true},
+		{name: "invalid startsAt", status: "firing", startsAt: "yesterday", wantNow: true, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			output := captureLog(t, func() { got = alertTimestamp(tt.source, tt.status, tt.startsAt, tt.endsAt) })
+
+			if tt.wantNow {
+				parsed, err := time.Parse(time.RFC3339, got)
+				if err != nil || time.Since(parsed) > time.Minute {
+					t.Errorf("alertTimestamp() = %s, want the current time", got)
+				}
+			} else if got != tt.want {
+				t.Errorf("alertTimestamp() = %s, want %s", got, tt.want)
+			}
+			if strings.Contains(output, "Warning:") != tt.wantWarn {
+				t.Errorf("warning logged = %t, want %t: %s", !tt.wantWarn, tt.wantWarn, output)
+			}
+		})
+	}
+}
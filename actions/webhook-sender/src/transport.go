@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// tlsVersions are the supported WEBHOOK_TLS_MIN_VERSION values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSConfig reads WEBHOOK_CA_CERT_FILE, the WEBHOOK_CLIENT_CERT_FILE
+// and WEBHOOK_CLIENT_KEY_FILE pair, WEBHOOK_TLS_MIN_VERSION and
+// WEBHOOK_INSECURE_SKIP_VERIFY, loading the files up front so a bad file
+// fails at startup
+func parseTLSConfig(config *Config) error {
+	config.CACertFile = os.Getenv("WEBHOOK_CA_CERT_FILE")
+	if config.CACertFile != "" {
+		pool, err := loadCACertPool(config.CACertFile)
+		if err != nil {
+			return err
+		}
+		config.CACertPool = pool
+	}
+
+	// Load the client certificate presented to targets that require mTLS
+	config.ClientCertFile = os.Getenv("WEBHOOK_CLIENT_CERT_FILE")
+	config.ClientKeyFile = os.Getenv("WEBHOOK_CLIENT_KEY_FILE")
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return fmt.Errorf("WEBHOOK_CLIENT_CERT_FILE and WEBHOOK_CLIENT_KEY_FILE must be set together")
+	}
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.ClientCert = &cert
+	}
+
+	config.TLSMinVersion = os.Getenv("WEBHOOK_TLS_MIN_VERSION")
+	if config.TLSMinVersion == "" {
+		config.TLSMinVersion = "1.2"
+	}
+	if _, ok := tlsVersions[config.TLSMinVersion]; !ok {
+		return fmt.Errorf("unsupported WEBHOOK_TLS_MIN_VERSION '%s', must be '1.2' or '1.3'", config.TLSMinVersion)
+	}
+
+	if err := envBool(config.StrictEnv, "WEBHOOK_INSECURE_SKIP_VERIFY", &config.InsecureSkipVerify); err != nil {
+		return err
+	}
+	if config.InsecureSkipVerify {
+		log.Println("Warning: WEBHOOK_INSECURE_SKIP_VERIFY is enabled, TLS certificates of webhook targets are NOT verified. Never use this outside development.")
+	}
+	return nil
+}
+
+// loadCACertPool returns the system roots extended with the PEM certificates
+// in path, so public endpoints keep working alongside the private CA
+func loadCACertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WEBHOOK_CA_CERT_FILE: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("WEBHOOK_CA_CERT_FILE %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// newHTTPClient builds the client shared by all targets. Proxies are taken
+// from PROXY_URL, or else HTTPS_PROXY and HTTP_PROXY, with NO_PROXY, and
+// redirects are followed as set by FOLLOW_REDIRECTS and MAX_REDIRECTS.
+func newHTTPClient(config *Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(config)
+	transport.TLSClientConfig = newTLSConfig(config)
+
+	return &http.Client{
+		Timeout:       time.Duration(config.TimeoutSeconds) * time.Second,
+		Transport:     transport,
+		CheckRedirect: checkRedirect(config),
+	}
+}
+
+// newTLSConfig builds the TLS settings of HTTPS and grpcs targets from the
+// CA bundle, client certificate and minimum version
+func newTLSConfig(config *Config) *tls.Config {
+	tlsConfig := &tls.Config{
+		RootCAs:            config.CACertPool,
+		MinVersion:         tlsVersions[config.TLSMinVersion],
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.ClientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*config.ClientCert}
+	}
+	return tlsConfig
+}
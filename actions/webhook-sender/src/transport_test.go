@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeServerCA writes the TLS test server's certificate as a PEM bundle
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	return path
+}
+
+func TestSendWebhookTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := writeServerCA(t, server)
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantErr  string
+		wantWarn bool
+	}{
+		{name: "untrusted certificate", env: map[string]string{}, wantErr: "certificate"},
+		{name: "custom CA bundle", env: map[string]string{"WEBHOOK_CA_CERT_FILE": caFile}},
+		{name: "insecure skip verify", env: map[string]string{"WEBHOOK_INSECURE_SKIP_VERIFY": "true"}, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", server.URL)
+			for _, key := range []string{"WEBHOOK_CA_CERT_FILE", "WEBHOOK_INSECURE_SKIP_VERIFY"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			var config *Config
+			var err error
+			output := captureLog(t, func() { config, err = loadConfig() })
+			if err != nil {
+				t.Fatalf("loadConfig() unexpected error: %v", err)
+			}
+			if warned := strings.Contains(output, "Warning: WEBHOOK_INSECURE_SKIP_VERIFY is enabled"); warned != tt.wantWarn {
+				t.Errorf("insecure warning logged = %t, want %t", warned, tt.wantWarn)
+			}
+
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("sendWebhook() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendWebhookTLSMinVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writeServerCA(t, server)
+
+	tests := []struct {
+		name       string
+		minVersion string
+		wantErr    string
+	}{
+		{name: "default accepts TLS 1.2", minVersion: ""},
+		{name: "TLS 1.3 required", minVersion: "1.3", wantErr: "protocol version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_URL", server.URL)
+			t.Setenv("WEBHOOK_CA_CERT_FILE", caFile)
+			t.Setenv("WEBHOOK_TLS_MIN_VERSION", tt.minVersion)
+
+			var config *Config
+			var err error
+			captureLog(t, func() { config, err = loadConfig() })
+			if err != nil {
+				t.Fatalf("loadConfig() unexpected error: %v", err)
+			}
+
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("sendWebhook() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("sendWebhook() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTLSConfigInvalidMinVersion(t *testing.T) {
+	for _, version := range []string{"1.1", "TLS1.2", "1.4"} {
+		t.Setenv("WEBHOOK_TLS_MIN_VERSION", version)
+		if err := parseTLSConfig(&Config{}); err == nil || !strings.Contains(err.Error(), "unsupported WEBHOOK_TLS_MIN_VERSION") {
+			t.Errorf("parseTLSConfig() error = %v for %q, want an unsupported version error", err, version)
+		}
+	}
+}
+
+func TestParseTLSConfigInvalidCAFile(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	for _, path := range []string{empty, filepath.Join(t.TempDir(), "missing.pem")} {
+		t.Setenv("WEBHOOK_CA_CERT_FILE", path)
+		if err := parseTLSConfig(&Config{}); err == nil {
+			t.Errorf("parseTLSConfig() should fail for %s", path)
+		}
+	}
+}
+
+func TestNewHTTPClientUsesProxyFromEnvironment(t *testing.T) {
+	transport := newHTTPClient(&Config{TimeoutSeconds: 5}).Transport.(*http.Transport)
+	if transport.Proxy == nil {
+		t.Error("transport should honor HTTPS_PROXY/HTTP_PROXY")
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key as PEM
+// files and returns their paths and the parsed certificate
+func writeClientCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "karo-webhook-sender"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestSendWebhookMutualTLS(t *testing.T) {
+	certFile, keyFile, clientCert := writeClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	var gotClient string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClient = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	// The rejected handshake is logged by the server, keep it out of the
+	// log captured from the client
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	caFile := writeServerCA(t, server)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "no client certificate", env: map[string]string{"WEBHOOK_CA_CERT_FILE": caFile}, wantErr: true},
+		{name: "client certificate with CA bundle", env: map[string]string{"WEBHOOK_CA_CERT_FILE": caFile, "WEBHOOK_CLIENT_CERT_FILE": certFile, "WEBHOOK_CLIENT_KEY_FILE": keyFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotClient = ""
+			for _, key := range []string{"WEBHOOK_CA_CERT_FILE", "WEBHOOK_CLIENT_CERT_FILE", "WEBHOOK_CLIENT_KEY_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+			config := &Config{Targets: []WebhookTarget{{URL: server.URL}}, TimeoutSeconds: 5}
+			if err := parseTLSConfig(config); err != nil {
+				t.Fatalf("parseTLSConfig() unexpected error: %v", err)
+			}
+
+			var err error
+			captureLog(t, func() { err = sendWebhook(context.Background(), config, WebhookPayload{AlertName: "DiskFull"}) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendWebhook() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && gotClient != "karo-webhook-sender" {
+				t.Errorf("server saw client %q, want karo-webhook-sender", gotClient)
+			}
+		})
+	}
+}
+
+func TestParseTLSConfigClientCertificate(t *testing.T) {
+	certFile, keyFile, _ := writeClientCert(t)
+
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		wantErr bool
+	}{
+		{name: "pair", cert: certFile, key: keyFile},
+		{name: "certificate only", cert: certFile, wantErr: true},
+		{name: "key only", key: keyFile, wantErr: true},
+		{name: "mismatched files", cert: keyFile, key: certFile, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_CA_CERT_FILE", "")
+			t.Setenv("WEBHOOK_CLIENT_CERT_FILE", tt.cert)
+			t.Setenv("WEBHOOK_CLIENT_KEY_FILE", tt.key)
+
+			config := &Config{}
+			err := parseTLSConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTLSConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && config.ClientCert == nil {
+				t.Error("client certificate should be loaded")
+			}
+		})
+	}
+}